@@ -32,6 +32,15 @@ var (
 
 	// ErrServerError
 	ErrServerError = errors.New("error: server error")
+
+	// ErrForbidden ...
+	ErrForbidden = errors.New("error: forbidden")
+
+	// ErrNotFound ...
+	ErrNotFound = errors.New("error: not found")
+
+	// ErrTooManyRequests ...
+	ErrTooManyRequests = errors.New("error: too many requests, try again later")
 )
 
 // Client ...
@@ -110,6 +119,12 @@ func (c *Client) do(req *http.Request, v interface{}) error {
 		return ErrUnauthorized
 	case http.StatusBadRequest:
 		return ErrBadRequest
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		return ErrTooManyRequests
 	case http.StatusInternalServerError:
 		return ErrServerError
 	}
@@ -121,7 +136,13 @@ func (c *Client) do(req *http.Request, v interface{}) error {
 
 // Login ...
 func (c *Client) Login(username, password string) (res types.AuthResponse, err error) {
-	req, err := c.newRequest("POST", "/auth", types.AuthRequest{Username: username, Password: password})
+	return c.LoginWithOTP(username, password, "")
+}
+
+// LoginWithOTP logs in a user who enabled two-factor authentication with
+// their current two-factor code or one of their recovery codes
+func (c *Client) LoginWithOTP(username, password, otp string) (res types.AuthResponse, err error) {
+	req, err := c.newRequest("POST", "/auth", types.AuthRequest{Username: username, Password: password, OTP: otp})
 	if err != nil {
 		return types.AuthResponse{}, err
 	}
@@ -139,6 +160,20 @@ func (c *Client) Post(text, as string) (res types.AuthResponse, err error) {
 	return
 }
 
+// PostThread posts a thread of twts where every twt after the first replies
+// to the first twt.
+func (c *Client) PostThread(texts []string, as string) (res types.AuthResponse, err error) {
+	if len(texts) == 0 {
+		return types.AuthResponse{}, fmt.Errorf("error: empty thread")
+	}
+	req, err := c.newRequest("POST", "/post", types.PostRequest{Text: texts[0], Thread: texts[1:], PostAs: as})
+	if err != nil {
+		return types.AuthResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
 func (c *Client) GetAndSetTwter() error {
 	if !c.Twter.IsZero() {
 		return nil
@@ -169,6 +204,31 @@ func (c *Client) Profile(username string) (res types.ProfileResponse, err error)
 	return
 }
 
+// GetMetadata returns the metadata of the user's feed or one of the
+// user's feeds if feed is non-empty.
+func (c *Client) GetMetadata(feed string) (res types.MetadataResponse, err error) {
+	req, err := c.newRequest("GET", "/metadata", nil)
+	if err != nil {
+		return types.MetadataResponse{}, err
+	}
+	if feed != "" {
+		req.URL.RawQuery = url.Values{"feed": []string{feed}}.Encode()
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// SetMetadata replaces the metadata of the user's feed or one of the
+// user's feeds if feed is non-empty.
+func (c *Client) SetMetadata(feed string, metadata url.Values) (res types.MetadataResponse, err error) {
+	req, err := c.newRequest("POST", "/metadata", types.MetadataRequest{Feed: feed, Metadata: metadata})
+	if err != nil {
+		return types.MetadataResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
 // Timeline ...
 func (c *Client) Timeline(page int) (res types.PagedResponse, err error) {
 	if err := c.GetAndSetTwter(); err != nil {
@@ -183,3 +243,167 @@ func (c *Client) Timeline(page int) (res types.PagedResponse, err error) {
 	err = c.do(req, &res)
 	return
 }
+
+// Notifications returns a page of the user's notifications
+func (c *Client) Notifications(page int) (res types.NotificationsResponse, err error) {
+	req, err := c.newRequest("POST", "/notifications", types.PagedRequest{Page: page})
+	if err != nil {
+		return types.NotificationsResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// MarkNotificationsRead marks the user's notifications with the given ids, or
+// all of them if all is true, as read
+func (c *Client) MarkNotificationsRead(ids []string, all bool) (res types.NotificationsReadResponse, err error) {
+	req, err := c.newRequest("POST", "/notifications/read", types.NotificationsReadRequest{IDs: ids, All: all})
+	if err != nil {
+		return types.NotificationsReadResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// Sessions returns the user's active web sessions and API tokens
+func (c *Client) Sessions() (res types.SessionsResponse, err error) {
+	req, err := c.newRequest("POST", "/sessions", struct{}{})
+	if err != nil {
+		return types.SessionsResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// RevokeSession revokes the user's web session or API token with the given
+// id, or all of them except the client's token if others is true
+func (c *Client) RevokeSession(id string, others bool) error {
+	req, err := c.newRequest("POST", "/sessions/revoke", types.RevokeSessionRequest{ID: id, Others: others})
+	if err != nil {
+		return err
+	}
+	var res struct{}
+	return c.do(req, &res)
+}
+
+// EditTwt replaces the user's last twt identified by hash with text
+func (c *Client) EditTwt(hash, text string) (res types.EditTwtResponse, err error) {
+	req, err := c.newRequest("PATCH", "/post", types.EditTwtRequest{Hash: hash, Text: text})
+	if err != nil {
+		return types.EditTwtResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// DeleteTwt deletes the user's last twt identified by hash
+func (c *Client) DeleteTwt(hash string) error {
+	req, err := c.newRequest("DELETE", "/post", types.DeleteTwtRequest{Hash: hash})
+	if err != nil {
+		return err
+	}
+	return c.do(req, &struct{}{})
+}
+
+// Bookmark toggles a bookmark for the twt identified by hash
+func (c *Client) Bookmark(hash string) (res types.BookmarkResponse, err error) {
+	req, err := c.newRequest("POST", "/bookmark", types.BookmarkRequest{Hash: hash})
+	if err != nil {
+		return types.BookmarkResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// Bookmarks returns the bookmarked twts of a user or the logged in user if
+// nick is empty
+func (c *Client) Bookmarks(nick string, page int) (res types.PagedResponse, err error) {
+	req, err := c.newRequest("POST", "/bookmarks", types.BookmarksRequest{Nick: nick, Page: page})
+	if err != nil {
+		return types.PagedResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// Feeds returns the feeds the user manages
+func (c *Client) Feeds() (res types.FeedsResponse, err error) {
+	req, err := c.newRequest("GET", "/feeds", nil)
+	if err != nil {
+		return types.FeedsResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// CreateFeed creates a new feed owned by the user
+func (c *Client) CreateFeed(name string) (res types.FeedResponse, err error) {
+	req, err := c.newRequest("POST", "/feeds", types.CreateFeedRequest{Name: name})
+	if err != nil {
+		return types.FeedResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// UpdateFeed updates the description of a feed the user manages
+func (c *Client) UpdateFeed(name, description string) (res types.FeedResponse, err error) {
+	req, err := c.newRequest("POST", "/feeds/"+url.PathEscape(name), types.UpdateFeedRequest{Description: description})
+	if err != nil {
+		return types.FeedResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// DeleteFeed deletes a feed owned by the user
+func (c *Client) DeleteFeed(name string) error {
+	req, err := c.newRequest("DELETE", "/feeds/"+url.PathEscape(name), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, &struct{}{})
+}
+
+// Followers returns the followers of a local user or feed or the logged in
+// user if nick is empty
+func (c *Client) Followers(nick string) (res types.FollowersResponse, err error) {
+	req, err := c.newRequest("POST", "/followers", types.FollowersRequest{Nick: nick})
+	if err != nil {
+		return types.FollowersResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// Following returns the feeds a local user follows or the logged in user
+// follows if nick is empty
+func (c *Client) Following(nick string) (res types.FollowingResponse, err error) {
+	req, err := c.newRequest("POST", "/following", types.FollowingRequest{Nick: nick})
+	if err != nil {
+		return types.FollowingResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// Import follows the given feeds, a mapping of nicks to urls
+func (c *Client) Import(follows map[string]string) (res types.ImportResponse, err error) {
+	req, err := c.newRequest("POST", "/import", types.ImportRequest{Follows: follows})
+	if err != nil {
+		return types.ImportResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
+
+// WhoFollows returns the local followers of the feed the whoFollows token
+// was issued for
+func (c *Client) WhoFollows(token string) (res types.WhoFollowsResponse, err error) {
+	req, err := c.newRequest("POST", "/whoFollows", types.WhoFollowsRequest{Token: token})
+	if err != nil {
+		return types.WhoFollowsResponse{}, err
+	}
+	err = c.do(req, &res)
+	return
+}
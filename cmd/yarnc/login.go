@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strings"
 	"syscall"
 
 	log "github.com/sirupsen/logrus"
@@ -41,35 +42,42 @@ func init() {
 	RootCmd.AddCommand(loginCmd)
 }
 
-func readCredentials() (string, string, error) {
+func readCredentials() (string, string, string, error) {
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Print("Username: ")
 	username, err := reader.ReadString('\n')
 	if err != nil {
 		log.WithError(err).Error("error reading username")
-		return "", "", err
+		return "", "", "", err
 	}
 
 	fmt.Print("Password: ")
 	data, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
 		log.WithError(err).Error("error reading password")
-		return "", "", err
+		return "", "", "", err
 	}
 	password := string(data)
 
-	return username, password, nil
+	fmt.Print("\nTwo-Factor Code (leave empty if not enabled): ")
+	otp, err := reader.ReadString('\n')
+	if err != nil {
+		log.WithError(err).Error("error reading two-factor code")
+		return "", "", "", err
+	}
+
+	return username, password, strings.TrimSpace(otp), nil
 }
 
 func login(cli *client.Client) {
-	username, password, err := readCredentials()
+	username, password, otp, err := readCredentials()
 	if err != nil {
 		log.WithError(err).Error("error reading credentials")
 		os.Exit(1)
 	}
 
-	res, err := cli.Login(username, password)
+	res, err := cli.LoginWithOTP(username, password, otp)
 	if err != nil {
 		log.WithError(err).Error("error making login request")
 		os.Exit(1)
@@ -75,6 +75,11 @@ var (
 	// Pod Settings
 	openProfiles      bool
 	openRegistrations bool
+	inviteQuota       int
+	requireApproval   bool
+	registerCaptcha   bool
+	spamHideThreshold float64
+	spamHoldThreshold float64
 	disableGzip       bool
 	disableLogger     bool
 	disableMedia      bool
@@ -83,17 +88,20 @@ var (
 	// Pod Limits
 	twtsPerPage      int
 	maxTwtLength     int
+	maxThreadLength  int
 	maxUploadSize    int64
 	maxFetchLimit    int64
 	maxCacheFetchers int
 	maxCacheTTL      time.Duration
 	fetchInterval    string
 	maxCacheItems    int
+	twtHashVersion   int
 
 	// Pod Secrets
-	apiSigningKey   string
-	cookieSecret    string
-	magiclinkSecret string
+	apiSigningKey       string
+	cookieSecret        string
+	magiclinkSecret     string
+	oldMagiclinkSecrets []string
 
 	// Email Setitngs
 	smtpHost string
@@ -106,13 +114,32 @@ var (
 	sessionExpiry     time.Duration
 	sessionCacheTTL   time.Duration
 	apiSessionTime    time.Duration
+	apiLegacyTokens   bool
 	transcoderTimeout time.Duration
 
+	// Rate Limits
+	rateLimits     map[string]string
+	trustedProxies []string
+
+	// OpenID Connect
+	oidcIssuer           string
+	oidcClientID         string
+	oidcClientSecret     string
+	oidcName             string
+	oidcUsernameClaim    string
+	oidcAutoProvision    bool
+	oidcRequiredClaims   map[string]string
+	oidcDisablePasswords bool
+
 	// Whitelists, Blacklists, Feedsources
 	feedSources       []string
 	whitelistedImages []string
 	blacklistedFeeds  []string
 
+	// Registration Checks
+	deniedUsernames        []string
+	disposableEmailDomains []string
+
 	// Optional Features
 	enabledFeatures flagSliceOfFeatureType
 )
@@ -141,10 +168,30 @@ func init() {
 		&openRegistrations, "open-registrations", "R", internal.DefaultOpenRegistrations,
 		"whether or not to have open user registgration",
 	)
+	flag.IntVar(
+		&inviteQuota, "invite-quota", internal.DefaultInviteQuota,
+		"number of invites each user can create (0 to let only staff create invites)",
+	)
+	flag.BoolVar(
+		&requireApproval, "require-approval", internal.DefaultRequireApproval,
+		"whether or not new accounts are pending until approved by an admin",
+	)
+	flag.BoolVar(
+		&registerCaptcha, "register-captcha", internal.DefaultRegisterCaptcha,
+		"whether or not to require a captcha to register",
+	)
 	flag.BoolVarP(
 		&openProfiles, "open-profiles", "O", internal.DefaultOpenProfiles,
 		"whether or not to have open user profiles",
 	)
+	flag.Float64Var(
+		&spamHideThreshold, "spam-hide-threshold", internal.DefaultSpamHideThreshold,
+		"spam score (0-1) at or above which twts of external feeds are hidden from discover (0 to disable)",
+	)
+	flag.Float64Var(
+		&spamHoldThreshold, "spam-hold-threshold", internal.DefaultSpamHoldThreshold,
+		"spam score (0-1) at or above which twts of external feeds are held for review (0 to disable)",
+	)
 	flag.BoolVar(
 		&disableGzip, "disable-gzip", internal.DefaultDisableGzip,
 		"whether or not to disable Gzip compression",
@@ -171,6 +218,10 @@ func init() {
 		&maxTwtLength, "max-twt-length", "L", internal.DefaultMaxTwtLength,
 		"maximum length of posts",
 	)
+	flag.IntVar(
+		&maxThreadLength, "max-thread-length", internal.DefaultMaxThreadLength,
+		"maximum number of posts of a thread posted at once",
+	)
 	flag.Int64VarP(
 		&maxUploadSize, "max-upload-size", "U", internal.DefaultMaxUploadSize,
 		"maximum upload size of media",
@@ -195,6 +246,10 @@ func init() {
 		&maxCacheItems, "max-cache-items", "I", internal.DefaultMaxCacheItems,
 		"maximum cache items (per feed source) of cached twts in memory",
 	)
+	flag.IntVar(
+		&twtHashVersion, "twt-hash-version", internal.DefaultTwtHashVersion,
+		"hash version local feeds hash their twts with (changing this migrates all local twts to new hashes)",
+	)
 
 	// Pod Secrets
 	flag.StringVar(
@@ -209,6 +264,10 @@ func init() {
 		&magiclinkSecret, "magiclink-secret", internal.DefaultMagicLinkSecret,
 		"magiclink secret to use for password reset tokens",
 	)
+	flag.StringSliceVar(
+		&oldMagiclinkSecrets, "old-magiclink-secrets", internal.DefaultOldMagicLinkSecrets,
+		"previous magiclink secrets to re-encrypt digest email addresses with after rotating the secret",
+	)
 
 	// Email Setitngs
 	flag.StringVar(&smtpHost, "smtp-host", internal.DefaultSMTPHost, "SMTP Host to use for email sending")
@@ -230,11 +289,47 @@ func init() {
 		&apiSessionTime, "api-session-time", internal.DefaultAPISessionTime,
 		"timeout for api tokens to expire",
 	)
+	flag.BoolVar(
+		&apiLegacyTokens, "api-legacy-tokens", internal.DefaultAPILegacyTokens,
+		"accept deprecated api tokens issued by older versions without an id (will be removed)",
+	)
 	flag.DurationVar(
 		&transcoderTimeout, "transcoder-timeout", internal.DefaultTranscoderTimeout,
 		"timeout for the video transcoder",
 	)
 
+	// Rate Limits
+	flag.StringToStringVar(
+		&rateLimits, "rate-limits", internal.DefaultRateLimits,
+		"request budgets of rate limited routes as name=<requests>/<period> (e.g: login=5/1m)",
+	)
+	flag.StringSliceVar(
+		&trustedProxies, "trusted-proxies", internal.DefaultTrustedProxies,
+		"addresses or networks (CIDRs) of reverse proxies whose X-Forwarded-For headers are trusted",
+	)
+
+	// OpenID Connect
+	flag.StringVar(&oidcIssuer, "oidc-issuer", "", "issuer URL of an OpenID Connect provider to login with (disabled if empty)")
+	flag.StringVar(&oidcClientID, "oidc-client-id", "", "client ID of the pod at the OpenID Connect provider")
+	flag.StringVar(&oidcClientSecret, "oidc-client-secret", "", "client secret of the pod at the OpenID Connect provider")
+	flag.StringVar(&oidcName, "oidc-name", internal.DefaultOIDCName, "name of the OpenID Connect provider shown on the login button")
+	flag.StringVar(
+		&oidcUsernameClaim, "oidc-username-claim", internal.DefaultOIDCUsernameClaim,
+		"claim new accounts take their username from when auto-provisioning",
+	)
+	flag.BoolVar(
+		&oidcAutoProvision, "oidc-auto-provision", false,
+		"create accounts for users logging in with the OpenID Connect provider",
+	)
+	flag.StringToStringVar(
+		&oidcRequiredClaims, "oidc-required-claims", nil,
+		"claims users must have to be auto-provisioned as claim=value (e.g: groups=yarn)",
+	)
+	flag.BoolVar(
+		&oidcDisablePasswords, "oidc-disable-passwords", false,
+		"disable password login for accounts linked to the OpenID Connect provider",
+	)
+
 	// Whitelists, Blacklists, Feedsources
 	flag.StringSliceVar(
 		&feedSources, "feed-sources", internal.DefaultFeedSources,
@@ -249,6 +344,16 @@ func init() {
 		"blacklist of external feed uris to prohibit fetching",
 	)
 
+	// Registration Checks
+	flag.StringSliceVar(
+		&deniedUsernames, "denied-usernames", internal.DefaultDeniedUsernames,
+		"patterns (regexes) of usernames people cannot register with",
+	)
+	flag.StringSliceVar(
+		&disposableEmailDomains, "disposable-email-domains", internal.DefaultDisposableEmailDomains,
+		"disposable email domains people cannot register with",
+	)
+
 	// Optional Features
 	flag.Var(&enabledFeatures, "enable-feature", "enable the named feature")
 }
@@ -324,6 +429,11 @@ func main() {
 		// Pod Settings
 		internal.WithOpenProfiles(openProfiles),
 		internal.WithOpenRegistrations(openRegistrations),
+		internal.WithInviteQuota(inviteQuota),
+		internal.WithRequireApproval(requireApproval),
+		internal.WithRegisterCaptcha(registerCaptcha),
+		internal.WithSpamHideThreshold(spamHideThreshold),
+		internal.WithSpamHoldThreshold(spamHoldThreshold),
 		internal.WithDisableGzip(disableGzip),
 		internal.WithDisableLogger(disableLogger),
 		internal.WithDisableMedia(disableMedia),
@@ -332,17 +442,20 @@ func main() {
 		// Pod Limits
 		internal.WithTwtsPerPage(twtsPerPage),
 		internal.WithMaxTwtLength(maxTwtLength),
+		internal.WithMaxThreadLength(maxThreadLength),
 		internal.WithMaxUploadSize(maxUploadSize),
 		internal.WithMaxFetchLimit(maxFetchLimit),
 		internal.WithMaxCacheFetchers(maxCacheFetchers),
 		internal.WithMaxCacheTTL(maxCacheTTL),
 		internal.WithFetchInterval(fetchInterval),
 		internal.WithMaxCacheItems(maxCacheItems),
+		internal.WithTwtHashVersion(twtHashVersion),
 
 		// Pod Secrets
 		internal.WithAPISigningKey(apiSigningKey),
 		internal.WithCookieSecret(cookieSecret),
 		internal.WithMagicLinkSecret(magiclinkSecret),
+		internal.WithOldMagicLinkSecrets(oldMagiclinkSecrets),
 
 		// Email Setitngs
 		internal.WithSMTPHost(smtpHost),
@@ -355,12 +468,28 @@ func main() {
 		internal.WithSessionExpiry(sessionExpiry),
 		internal.WithSessionCacheTTL(sessionCacheTTL),
 		internal.WithAPISessionTime(apiSessionTime),
+		internal.WithAPILegacyTokens(apiLegacyTokens),
 		internal.WithTranscoderTimeout(transcoderTimeout),
 
+		// Rate Limits
+		internal.WithRateLimits(rateLimits),
+		internal.WithTrustedProxies(trustedProxies),
+
+		// OpenID Connect
+		internal.WithOIDCIssuer(oidcIssuer),
+		internal.WithOIDCClientID(oidcClientID),
+		internal.WithOIDCClientSecret(oidcClientSecret),
+		internal.WithOIDCName(oidcName),
+		internal.WithOIDCUsernameClaim(oidcUsernameClaim),
+		internal.WithOIDCAutoProvision(oidcAutoProvision, oidcRequiredClaims),
+		internal.WithOIDCDisablePasswords(oidcDisablePasswords),
+
 		// Whitelists, Blacklists, Feedsources
 		internal.WithFeedSources(feedSources),
 		internal.WithWhitelistedImages(whitelistedImages),
 		internal.WithBlacklistedFeeds(blacklistedFeeds),
+		internal.WithDeniedUsernames(deniedUsernames),
+		internal.WithDisposableEmailDomains(disposableEmailDomains),
 
 		// Optional Features
 		internal.WithEnabledFeatures(enabledFeatures),
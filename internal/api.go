@@ -21,6 +21,7 @@ import (
 	"github.com/vcraescu/go-paginator/adapter"
 
 	"git.mills.io/yarnsocial/yarn/internal/passwords"
+	"git.mills.io/yarnsocial/yarn/internal/session"
 	"git.mills.io/yarnsocial/yarn/types"
 )
 
@@ -40,29 +41,22 @@ var (
 	ErrInvalidToken = errors.New("error: invalid token")
 )
 
-// Token ...
-type Token struct {
-	Signature string
-	Value     string
-	UserAgent string
-	CreatedAt time.Time
-	ExpiresAt time.Time
-}
-
 // API ...
 type API struct {
-	router  *Router
-	config  *Config
-	cache   *Cache
-	archive Archiver
-	db      Store
-	pm      passwords.Passwords
-	tasks   *Dispatcher
+	router   *Router
+	config   *Config
+	cache    *Cache
+	archive  Archiver
+	db       Store
+	pm       passwords.Passwords
+	tasks    *Dispatcher
+	limits   *RateLimiters
+	sessions session.Store
 }
 
 // NewAPI ...
-func NewAPI(router *Router, config *Config, cache *Cache, archive Archiver, db Store, pm passwords.Passwords, tasks *Dispatcher) *API {
-	api := &API{router, config, cache, archive, db, pm, tasks}
+func NewAPI(router *Router, config *Config, cache *Cache, archive Archiver, db Store, pm passwords.Passwords, tasks *Dispatcher, limits *RateLimiters, sessions session.Store) *API {
+	api := &API{router, config, cache, archive, db, pm, tasks, limits, sessions}
 
 	api.initRoutes()
 
@@ -70,27 +64,51 @@ func NewAPI(router *Router, config *Config, cache *Cache, archive Archiver, db S
 }
 
 func (a *API) initRoutes() {
-	router := a.router.Group("/api/v1")
+	router := a.router.Group(apiPrefix, a.limits.Limit("api", a.rateLimitKey))
 
 	router.GET("/ping", a.PingEndpoint())
-	router.POST("/auth", a.AuthEndpoint())
-	router.POST("/register", a.RegisterEndpoint())
+	router.POST("/auth", a.limit("auth", a.AuthEndpoint()))
+	router.POST("/register", a.limit("register", a.RegisterEndpoint()))
 	router.GET("/config", a.PodConfigEndpoint())
+	router.GET("/openapi.json", a.OpenAPIEndpoint())
+	// The spec is also discoverable outside of the API's prefix
+	a.router.GET("/.well-known/openapi.json", a.OpenAPIEndpoint())
+
+	router.POST("/post", a.isAuthorized(ScopePost, a.limit("post", a.PostEndpoint())))
+	router.POST("/upload", a.isAuthorized(ScopePost, a.limit("upload", a.UploadMediaEndpoint())))
+	router.POST("/inject", a.isAuthorized(ScopePost, a.limit("post", a.InjectEndpoint())))
+	router.PATCH("/post", a.isAuthorized(ScopePost, a.limit("post", a.EditTwtEndpoint())))
+	router.DELETE("/post", a.isAuthorized(ScopePost, a.DeleteTwtEndpoint()))
+
+	router.POST("/bookmark", a.isAuthorized(ScopePost, a.BookmarkEndpoint()))
+	router.POST("/bookmarks", a.BookmarksEndpoint())
 
-	router.POST("/post", a.isAuthorized(a.PostEndpoint()))
-	router.POST("/upload", a.isAuthorized(a.UploadMediaEndpoint()))
-	router.POST("/inject", a.isAuthorized(a.InjectEndpoint()))
+	router.GET("/feeds", a.isAuthorized(ScopeRead, a.FeedsEndpoint()))
+	router.POST("/feeds", a.isAuthorized(ScopePost, a.CreateFeedEndpoint()))
+	router.POST("/feeds/:name", a.isAuthorized(ScopePost, a.ManageFeedEndpoint()))
+	router.DELETE("/feeds/:name", a.isAuthorized(ScopePost, a.ManageFeedEndpoint()))
 
-	router.GET("/settings", a.isAuthorized(a.SettingsEndpoint()))
-	router.POST("/settings", a.isAuthorized(a.SettingsEndpoint()))
+	router.GET("/settings", a.isAuthorized(ScopeRead, a.SettingsEndpoint()))
+	router.POST("/settings", a.isAuthorized(ScopePost, a.SettingsEndpoint()))
 
-	router.POST("/follow", a.isAuthorized(a.FollowEndpoint()))
-	router.POST("/unfollow", a.isAuthorized(a.UnfollowEndpoint()))
+	router.GET("/metadata", a.isAuthorized(ScopeRead, a.MetadataEndpoint()))
+	router.POST("/metadata", a.isAuthorized(ScopePost, a.MetadataEndpoint()))
 
-	router.POST("/mute", a.isAuthorized(a.MuteEndpoint()))
-	router.POST("/unmute", a.isAuthorized(a.UnmuteEndpoint()))
+	router.POST("/follow", a.isAuthorized(ScopeFollow, a.FollowEndpoint()))
+	router.POST("/unfollow", a.isAuthorized(ScopeFollow, a.UnfollowEndpoint()))
+	router.POST("/import", a.isAuthorized(ScopeFollow, a.ImportEndpoint()))
+	router.POST("/followers", a.FollowersEndpoint())
+	router.POST("/following", a.FollowingEndpoint())
+	router.POST("/whoFollows", a.WhoFollowsEndpoint())
 
-	router.POST("/timeline", a.isAuthorized(a.TimelineEndpoint()))
+	router.POST("/mute", a.isAuthorized(ScopeFollow, a.MuteEndpoint()))
+	router.POST("/unmute", a.isAuthorized(ScopeFollow, a.UnmuteEndpoint()))
+
+	router.POST("/filters", a.isAuthorized(ScopeRead, a.MuteFiltersEndpoint()))
+	router.POST("/filters/add", a.isAuthorized(ScopeFollow, a.AddMuteFilterEndpoint()))
+	router.POST("/filters/remove", a.isAuthorized(ScopeFollow, a.RemoveMuteFilterEndpoint()))
+
+	router.POST("/timeline", a.isAuthorized(ScopeRead, a.TimelineEndpoint()))
 	router.POST("/discover", a.DiscoverEndpoint())
 
 	router.GET("/profile", a.ProfileEndpoint())
@@ -100,18 +118,66 @@ func (a *API) initRoutes() {
 
 	router.POST("/external", a.ExternalProfileEndpoint())
 
-	router.POST("/mentions", a.isAuthorized(a.MentionsEndpoint()))
+	router.POST("/mentions", a.isAuthorized(ScopeRead, a.MentionsEndpoint()))
+
+	router.POST("/notifications", a.isAuthorized(ScopeRead, a.NotificationsEndpoint()))
+	router.POST("/notifications/read", a.isAuthorized(ScopePost, a.NotificationsReadEndpoint()))
+
+	router.POST("/sessions", a.isAuthorized(ScopeRead, a.SessionsEndpoint()))
+	router.POST("/sessions/revoke", a.isAuthorized(ScopePost, a.RevokeSessionEndpoint()))
 
 	// Support / Report endpoints
-	router.POST("/support", a.isAuthorized(a.SupportEndpoint()))
-	router.POST("/report", a.isAuthorized(a.ReportEndpoint()))
+	router.POST("/support", a.isAuthorized(ScopePost, a.limit("support", a.SupportEndpoint())))
+	router.POST("/report", a.isAuthorized(ScopePost, a.limit("support", a.ReportEndpoint())))
+
+	// Admin endpoints
+	router.POST("/admin/users", a.isAuthorized(ScopeAdmin, a.hasPermission(PermissionManageUsers, a.AdminUsersEndpoint())))
+	router.POST("/admin/users/role", a.isAuthorized(ScopeAdmin, a.hasPermission(PermissionManageRoles, a.AdminSetRoleEndpoint())))
+	router.POST("/admin/users/limit", a.isAuthorized(ScopeAdmin, a.hasPermission(PermissionLimitUsers, a.AdminLimitUserEndpoint())))
+	router.POST("/admin/reports", a.isAuthorized(ScopeAdmin, a.hasPermission(PermissionViewReports, a.AdminReportsEndpoint())))
+	router.POST("/admin/reports/decide", a.isAuthorized(ScopeAdmin, a.hasPermission(PermissionViewReports, a.AdminDecideReportEndpoint())))
+}
+
+// limit rate limits an endpoint by the named budget
+func (a *API) limit(name string, endpoint httprouter.Handle) httprouter.Handle {
+	return a.limits.Limit(name, a.rateLimitKey)(endpoint)
+}
+
+// rateLimitKey keys API requests by the ID of the request's token falling
+// back to the client's IP address for requests without a valid token
+func (a *API) rateLimitKey(r *http.Request) string {
+	if token, err := jwt.Parse(requestToken(r), a.jwtKeyFunc); err == nil && token.Valid {
+		if id, _ := token.Claims.(jwt.MapClaims)["jti"].(string); id != "" {
+			return "token:" + id
+		}
+	}
+	return RateLimitByIP(r)
+}
+
+// CreateToken issues and stores a new token for the user with the given scopes
+func (a *API) CreateToken(user *User, r *http.Request, scopes []TokenScope) (*Token, error) {
+	return a.CreateClientToken(user, r, scopes, "")
 }
 
-// CreateToken ...
-func (a *API) CreateToken(user *User, r *http.Request) (*Token, error) {
+// CreateClientToken creates a token issued to a third-party client through
+// the IndieAuth token endpoint identified by its clientID
+func (a *API) CreateClientToken(user *User, r *http.Request, scopes []TokenScope, clientID string) (*Token, error) {
+	createdAt := time.Now()
+	expiresAt := createdAt.Add(a.config.APISessionTime)
+
+	id := GenerateRandomToken()
+
+	var scopeClaims []string
+	for _, scope := range scopes {
+		scopeClaims = append(scopeClaims, string(scope))
+	}
+
 	claims := jwt.MapClaims{}
 	claims["username"] = user.Username
-	createdAt := time.Now()
+	claims["jti"] = id
+	claims["iat"] = createdAt.Unix()
+	claims["exp"] = expiresAt.Unix()
+	claims["scopes"] = scopeClaims
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(a.config.APISigningKey))
 	if err != nil {
@@ -126,10 +192,21 @@ func (a *API) CreateToken(user *User, r *http.Request) (*Token, error) {
 	}
 
 	tkn := &Token{
-		Signature: signedToken.Signature,
-		Value:     tokenString,
-		UserAgent: r.UserAgent(),
-		CreatedAt: createdAt,
+		ID:         id,
+		Signature:  signedToken.Signature,
+		Value:      tokenString,
+		UserAgent:  r.UserAgent(),
+		RemoteAddr: ApproximateIP(RemoteIP(r)),
+		ClientID:   clientID,
+		Scopes:     scopes,
+		CreatedAt:  createdAt,
+		ExpiresAt:  expiresAt,
+	}
+
+	user.AddToken(tkn)
+	if err := a.db.SetUser(user.Username, user); err != nil {
+		log.WithError(err).Error("error storing token")
+		return nil, err
 	}
 
 	return tkn, nil
@@ -142,24 +219,48 @@ func (a *API) jwtKeyFunc(token *jwt.Token) (interface{}, error) {
 	return []byte(a.config.APISigningKey), nil
 }
 
-func (a *API) getLoggedInUser(r *http.Request) *User {
-	token, err := jwt.Parse(r.Header.Get("Token"), a.jwtKeyFunc)
-	if err != nil {
-		return nil
+// requestToken returns the request's token from the Token header or from
+// an `Authorization: Bearer` header as sent by IndieAuth and OAuth2 clients
+func requestToken(r *http.Request) string {
+	if token := r.Header.Get("Token"); token != "" {
+		return token
 	}
 
-	if !token.Valid {
-		return nil
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+
+	return ""
+}
+
+// authenticate validates the request's token against the tokens issued to
+// its user returning the user and the token
+func (a *API) authenticate(r *http.Request) (*User, *Token, error) {
+	token, err := jwt.Parse(requestToken(r), a.jwtKeyFunc)
+	if err != nil || !token.Valid {
+		return nil, nil, ErrInvalidToken
 	}
 
 	claims := token.Claims.(jwt.MapClaims)
 
-	username := claims["username"].(string)
+	username, _ := claims["username"].(string)
+	id, _ := claims["jti"].(string)
 
 	user, err := a.db.GetUser(username)
 	if err != nil {
 		log.WithError(err).Error("error loading user object")
-		return nil
+		return nil, nil, err
+	}
+
+	var tkn *Token
+	if id == "" {
+		tkn, err = a.legacyToken(user)
+	} else {
+		tkn, err = user.GetToken(id)
+	}
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Every registered new user follows themselves
@@ -167,59 +268,88 @@ func (a *API) getLoggedInUser(r *http.Request) *User {
 	if user.Following == nil {
 		user.Following = make(map[string]string)
 	}
-
 	user.Follow(user.Username, user.URL)
 
+	return user, tkn, nil
+}
+
+// legacyToken returns the token of a token issued by older versions without an
+// ID, such tokens are deprecated and only accepted with the default scopes
+// whilst the pod accepts them (See: WithAPILegacyTokens) until the user
+// revokes their other sessions, e.g: by changing their password
+func (a *API) legacyToken(user *User) (*Token, error) {
+	if !a.config.APILegacyTokens || user.LegacyTokensRevoked {
+		return nil, ErrTokenRevoked
+	}
+
+	log.Warnf("deprecated token without an ID used by %s", user.Username)
+
+	return &Token{Scopes: DefaultTokenScopes}, nil
+}
+
+func (a *API) getLoggedInUser(r *http.Request) *User {
+	user, _, err := a.authenticate(r)
+	if err != nil {
+		return nil
+	}
 	return user
 }
 
-func (a *API) isAuthorized(endpoint httprouter.Handle) httprouter.Handle {
+// hasPermission wraps an endpoint that requires the authorized user's role to
+// grant the permission, it must be wrapped by isAuthorized
+func (a *API) hasPermission(perm Permission, endpoint httprouter.Handle) httprouter.Handle {
+	hasPermission := HasPermissionFactory(a.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		if !hasPermission(user, perm) {
+			http.Error(w, "Permission Denied", http.StatusForbidden)
+			return
+		}
+
+		endpoint(w, r, p)
+	}
+}
+
+func (a *API) isAuthorized(scope TokenScope, endpoint httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		if r.Header.Get("Token") == "" {
+		if requestToken(r) == "" {
 			http.Error(w, "No Token Provided", http.StatusUnauthorized)
 			return
 		}
 
-		token, err := jwt.Parse(r.Header.Get("Token"), a.jwtKeyFunc)
+		user, token, err := a.authenticate(r)
 		if err != nil {
-			log.WithError(err).Error("error parsing token")
-			http.Error(w, "Bad Request", http.StatusBadRequest)
+			log.WithError(err).Warn("error authenticating token")
+			http.Error(w, "Invalid Token", http.StatusUnauthorized)
 			return
 		}
 
-		if token.Valid {
-			claims := token.Claims.(jwt.MapClaims)
-
-			username := claims["username"].(string)
-
-			user, err := a.db.GetUser(username)
-			if err != nil {
-				log.WithError(err).Error("error loading user object")
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
+		if token.ID == "" {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Warning", `299 - "Deprecated token, please login again"`)
+		}
 
-			// Every registered new user follows themselves
-			// TODO: Make  this configurable server behaviour?
-			if user.Following == nil {
-				user.Following = make(map[string]string)
-			}
-			user.Follow(user.Username, user.URL)
+		if !token.HasScope(scope) {
+			http.Error(w, "Insufficient Scope", http.StatusForbidden)
+			return
+		}
 
-			ctx := context.WithValue(r.Context(), TokenContextKey, token)
-			ctx = context.WithValue(ctx, UserContextKey, user)
+		// Suspended users cannot use the API
+		if user.IsSuspended() {
+			http.Error(w, "Account Suspended", http.StatusForbidden)
+			return
+		}
 
-			// TODO: Use event sourcing for this?
-			user.LastSeenAt = time.Now().Round(24 * time.Hour)
-			if err := a.db.SetUser(user.Username, user); err != nil {
-				log.WithError(err).Warnf("error updating user.LastSeenAt for %s", user.Username)
-			}
+		ctx := context.WithValue(r.Context(), TokenContextKey, token)
+		ctx = context.WithValue(ctx, UserContextKey, user)
 
-			endpoint(w, r.WithContext(ctx), p)
-		} else {
-			http.Error(w, "Invalid Token", http.StatusUnauthorized)
-			return
+		if err := TouchUser(a.db, user, token, ApproximateIP(RemoteIP(r)), time.Now()); err != nil {
+			log.WithError(err).Warnf("error updating user.LastSeenAt for %s", user.Username)
 		}
+
+		endpoint(w, r.WithContext(ctx), p)
 	}
 }
 
@@ -245,6 +375,25 @@ func (a *API) RegisterEndpoint() httprouter.Handle {
 		password := req.Password
 		// XXX: We DO NOT store this! (EVER)
 		email := strings.TrimSpace(req.Email)
+		invite := strings.TrimSpace(req.Invite)
+		reason := strings.TrimSpace(req.Reason)
+
+		// An invite lets people register when open registrations are disabled
+		if !a.config.OpenRegistrations && !ValidInvite(a.db, invite) {
+			if invite != "" {
+				http.Error(w, "Invalid Invite", http.StatusForbidden)
+			} else {
+				http.Error(w, "Registrations Disabled", http.StatusForbidden)
+			}
+			return
+		}
+
+		// Captchas can only be solved on the web so people registering with
+		// the API must be invited when the pod requires a captcha
+		if a.config.RegisterCaptcha && invite == "" {
+			http.Error(w, "Captcha Required", http.StatusForbidden)
+			return
+		}
 
 		if err := ValidateUsername(username); err != nil {
 			http.Error(w, "Bad Username", http.StatusBadRequest)
@@ -256,15 +405,30 @@ func (a *API) RegisterEndpoint() httprouter.Handle {
 			return
 		}
 
-		fn := filepath.Join(a.config.Data, feedsDir, username)
-		if _, err := os.Stat(fn); err == nil {
-			http.Error(w, "Feed Exists", http.StatusBadRequest)
+		// People registering with an invite are vouched for by its creator
+		pending := a.config.RequireApproval && !ValidInvite(a.db, invite)
+
+		if pending && reason == "" {
+			http.Error(w, "Reason Required", http.StatusBadRequest)
 			return
 		}
 
-		if err := ioutil.WriteFile(fn, []byte{}, 0644); err != nil {
-			log.WithError(err).Error("error creating new user feed")
-			http.Error(w, "Feed Creation Failed", http.StatusInternalServerError)
+		if err := CheckSignup(a.config, a.limits, username, email, RemoteIP(r)); err != nil {
+			log.WithError(err).Warnf("signup of %s from %s denied", username, RemoteIP(r))
+			switch err {
+			case ErrDeniedUsername:
+				http.Error(w, "Bad Username", http.StatusBadRequest)
+			case ErrDisposableEmail:
+				http.Error(w, "Disposable Email", http.StatusBadRequest)
+			default:
+				http.Error(w, "Too Many Signups", http.StatusTooManyRequests)
+			}
+			return
+		}
+
+		fn := filepath.Join(a.config.Data, feedsDir, username)
+		if _, err := os.Stat(fn); err == nil {
+			http.Error(w, "Feed Exists", http.StatusBadRequest)
 			return
 		}
 
@@ -278,18 +442,69 @@ func (a *API) RegisterEndpoint() httprouter.Handle {
 		recoveryHash := fmt.Sprintf("email:%s", FastHashString(email))
 
 		user := &User{
-			Username:  username,
-			Password:  hash,
-			Recovery:  recoveryHash,
-			URL:       URLForUser(a.config.BaseURL, username),
-			CreatedAt: time.Now(),
+			Username:        username,
+			Password:        hash,
+			Recovery:        recoveryHash,
+			URL:             URLForUser(a.config.BaseURL, username),
+			CreatedAt:       time.Now(),
+			PendingApproval: pending,
+			JoinReason:      reason,
+		}
+
+		var reserved *Invite
+		if invite != "" {
+			reserved, err = ReserveInvite(a.db, invite, username)
+			if err != nil {
+				log.WithError(err).Warnf("error reserving invite for %s", username)
+				http.Error(w, "Invalid Invite", http.StatusForbidden)
+				return
+			}
+			user.InvitedBy = reserved.CreatedBy
+		}
+
+		releaseInvite := func() {
+			if reserved != nil {
+				if err := ReleaseInvite(a.db, reserved.Code, username); err != nil {
+					log.WithError(err).Warnf("error releasing invite reserved for %s", username)
+				}
+			}
+		}
+
+		if err := ioutil.WriteFile(fn, []byte{}, 0644); err != nil {
+			log.WithError(err).Error("error creating new user feed")
+			releaseInvite()
+			http.Error(w, "Feed Creation Failed", http.StatusInternalServerError)
+			return
 		}
 
 		if err := a.db.SetUser(username, user); err != nil {
 			log.WithError(err).Error("error saving user object for new user")
+			releaseInvite()
 			http.Error(w, "User Creation Failed", http.StatusInternalServerError)
 			return
 		}
+
+		if err := CreateSigningKey(a.config, a.db, username); err != nil {
+			log.WithError(err).Errorf("error creating signing key for %s", username)
+		}
+
+		status := http.StatusOK
+		if user.PendingApproval {
+			log.Infof("%s registered and is pending approval", username)
+
+			go func() {
+				if err := SendPendingApprovalEmail(a.config, user); err != nil {
+					log.WithError(err).Warnf("error sending pending approval email for %s", username)
+				}
+			}()
+
+			status = http.StatusAccepted
+		}
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{}`))
 	}
 }
 
@@ -298,6 +513,8 @@ func (a *API) AuthEndpoint() httprouter.Handle {
 	// #239: Throttle failed login attempts and lock user  account.
 	failures := NewTTLCache(5 * time.Minute)
 
+	isStaffUser := IsStaffUserFactory(a.config)
+
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		req, err := types.NewAuthRequest(r.Body)
 		if err != nil {
@@ -306,6 +523,12 @@ func (a *API) AuthEndpoint() httprouter.Handle {
 			return
 		}
 
+		scopes, err := ParseTokenScopes(req.Scopes)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
 		username := NormalizeUsername(req.Username)
 		password := req.Password
 
@@ -323,6 +546,13 @@ func (a *API) AuthEndpoint() httprouter.Handle {
 			return
 		}
 
+		// Linked accounts must login with the OpenID Connect provider and
+		// authorize clients with IndieAuth
+		if a.config.PasswordLoginDisabled(user) {
+			http.Error(w, "Password Login Disabled", http.StatusForbidden)
+			return
+		}
+
 		// #239: Throttle failed login attempts and lock user  account.
 		if failures.Get(user.Username) > MaxFailedLogins {
 			http.Error(w, "Account Locked", http.StatusTooManyRequests)
@@ -341,13 +571,57 @@ func (a *API) AuthEndpoint() httprouter.Handle {
 			return
 		}
 
+		// Validate the user's second factor
+		if user.HasTOTP() {
+			if req.OTP == "" {
+				http.Error(w, "Two-Factor Code Required", http.StatusUnauthorized)
+				return
+			}
+
+			if !user.CheckSecondFactor(req.OTP) {
+				failed := failures.Inc(user.Username)
+				time.Sleep(time.Duration(IntPow(2, failed)) * time.Second)
+
+				log.WithField("username", username).Warn("login attempt with invalid two-factor code")
+				http.Error(w, "Invalid Credentials", http.StatusUnauthorized)
+				return
+			}
+
+			// Persist the used code so it cannot be used again
+			if err := a.db.SetUser(user.Username, user); err != nil {
+				log.WithError(err).Error("error saving user")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+
 		// #239: Throttle failed login attempts and lock user  account.
 		failures.Reset(user.Username)
 
+		// Suspended users cannot login, the state of the account is only
+		// revealed once the user has authenticated
+		if user.IsSuspended() {
+			http.Error(w, "Account Suspended", http.StatusForbidden)
+			return
+		}
+
+		// Users pending approval cannot login
+		if user.PendingApproval {
+			http.Error(w, "Account Pending Approval", http.StatusForbidden)
+			return
+		}
+
 		// Login successful
 		log.WithField("username", username).Info("login successful")
 
-		token, err := a.CreateToken(user, r)
+		for _, scope := range scopes {
+			if scope == ScopeAdmin && !isStaffUser(user) {
+				http.Error(w, "Insufficient Scope", http.StatusForbidden)
+				return
+			}
+		}
+
+		token, err := a.CreateToken(user, r, scopes)
 		if err != nil {
 			log.WithError(err).Error("error creating token")
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -382,18 +656,34 @@ func (a *API) PostEndpoint() httprouter.Handle {
 			return
 		}
 
-		text := CleanTwt(req.Text)
-		if text == "" {
+		texts := CleanThread(append([]string{req.Text}, req.Thread...))
+		if len(texts) == 0 {
 			http.Error(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
 
+		if len(texts) > a.config.MaxThreadLength {
+			http.Error(w, "Thread Too Long", http.StatusBadRequest)
+			return
+		}
+
+		if req.ContentWarning != "" {
+			for i, text := range texts {
+				texts[i] = WithContentWarning(text, req.ContentWarning)
+			}
+		}
+
+		if user.IsPostingDisabled() {
+			http.Error(w, "Posting Disabled", http.StatusForbidden)
+			return
+		}
+
 		var sources types.Feeds
 
 		switch req.PostAs {
 		case "", me:
 			sources = user.Source()
-			_, err = appendTwt(user, nil, text)
+			_, err = AppendThread(appendTwt, user, nil, texts)
 		default:
 			if user.OwnsFeed(req.PostAs) {
 				feed, feedErr := a.db.GetFeed(req.PostAs)
@@ -404,7 +694,7 @@ func (a *API) PostEndpoint() httprouter.Handle {
 				}
 				sources = feed.Source()
 
-				_, err = appendTwt(user, feed, text)
+				_, err = AppendThread(appendTwt, user, feed, texts)
 			} else {
 				err = ErrFeedImposter
 			}
@@ -458,7 +748,8 @@ func (a *API) TimelineEndpoint() httprouter.Handle {
 		}
 
 		res := types.PagedResponse{
-			Twts: pagedTwts,
+			Twts:      pagedTwts,
+			Collapsed: CollapsedTwts(user, pagedTwts),
 			Pager: types.PagerResponse{
 				Current:   pager.Page(),
 				MaxPages:  pager.PageNums(),
@@ -504,7 +795,8 @@ func (a *API) DiscoverEndpoint() httprouter.Handle {
 		}
 
 		res := types.PagedResponse{
-			Twts: pagedTwts,
+			Twts:      pagedTwts,
+			Collapsed: CollapsedTwts(loggedInUser, pagedTwts),
 			Pager: types.PagerResponse{
 				Current:   pager.Page(),
 				MaxPages:  pager.PageNums(),
@@ -551,7 +843,8 @@ func (a *API) MentionsEndpoint() httprouter.Handle {
 		}
 
 		res := types.PagedResponse{
-			Twts: pagedTwts,
+			Twts:      pagedTwts,
+			Collapsed: CollapsedTwts(user, pagedTwts),
 			Pager: types.PagerResponse{
 				Current:   pager.Page(),
 				MaxPages:  pager.PageNums(),
@@ -571,102 +864,269 @@ func (a *API) MentionsEndpoint() httprouter.Handle {
 	}
 }
 
-// FollowEndpoint ...
-func (a *API) FollowEndpoint() httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		user := r.Context().Value(UserContextKey).(*User)
-
-		req, err := types.NewFollowRequest(r.Body)
+// NotificationsEndpoint ...
+func (a *API) NotificationsEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		req, err := types.NewPagedRequest(r.Body)
 		if err != nil {
-			log.WithError(err).Error("error parsing follow request")
+			log.WithError(err).Error("error parsing notifications request")
 			http.Error(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
 
-		nick := strings.TrimSpace(req.Nick)
-		url := NormalizeURL(req.URL)
+		user := r.Context().Value(UserContextKey).(*User)
 
-		if nick == "" || url == "" {
-			http.Error(w, "Bad Request", http.StatusBadRequest)
+		n, err := a.db.GetNotifications(user.Username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading notifications for %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		if err := user.FollowAndValidate(a.config, nick, url); err != nil {
-			log.WithError(err).Errorf("error validating new feed @<%s %s>", nick, url)
-			http.Error(w, "Invalid Feed", http.StatusBadRequest)
-			return
-		}
+		var notifications []*types.Notification
 
-		if err := a.db.SetUser(user.Username, user); err != nil {
-			log.WithError(err).Error("error saving user object")
+		pager := paginator.New(adapter.NewSliceAdapter(n.Items), a.config.TwtsPerPage)
+		pager.SetPage(req.Page)
+
+		if err = pager.Results(&notifications); err != nil {
+			log.WithError(err).Error("error loading notifications")
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		a.cache.GetByUser(user, true)
-
-		// No real response
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{}`))
-	}
-}
+		res := types.NotificationsResponse{
+			Notifications: []types.Notification{},
+			Twts:          types.Twts{},
+			Unread:        n.Unread(),
+			Pager: types.PagerResponse{
+				Current:   pager.Page(),
+				MaxPages:  pager.PageNums(),
+				TotalTwts: pager.Nums(),
+			},
+		}
 
-// UnfollowEndpoint ...
-func (a *API) UnfollowEndpoint() httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		req, err := types.NewUnfollowRequest(r.Body)
+		twts := lookupNotificationTwts(a.cache, a.archive, notifications)
+		for _, notification := range notifications {
+			res.Notifications = append(res.Notifications, *notification)
+			if twt, ok := twts[notification.Hash]; ok {
+				res.Twts = append(res.Twts, twt)
+			}
+		}
 
+		body, err := res.Bytes()
 		if err != nil {
-			log.WithError(err).Error("error parsing follow request")
-			http.Error(w, "Bad Request", http.StatusBadRequest)
+			log.WithError(err).Error("error serializing response")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		nick := req.Nick
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+}
 
-		if nick == "" {
+// NotificationsReadEndpoint ...
+func (a *API) NotificationsReadEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		req, err := types.NewNotificationsReadRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing notifications read request")
 			http.Error(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
 
 		user := r.Context().Value(UserContextKey).(*User)
 
-		if user == nil {
-			log.Fatalf("user not found in context")
-		}
-
-		if _, ok := user.Following[nick]; !ok {
-			http.Error(w, "Bad Request", http.StatusBadRequest)
+		n, err := MarkNotificationsRead(a.db, user.Username, req.All, req.IDs...)
+		if err != nil {
+			log.WithError(err).Errorf("error marking notifications read for %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		user.Unfollow(nick)
-
-		if err := a.db.SetUser(user.Username, user); err != nil {
-			log.WithError(err).Warnf("error updating user object for user  %s", user.Username)
-			http.Error(w, "Bad Request", http.StatusBadRequest)
+		body, err := types.NotificationsReadResponse{Unread: n.Unread()}.Bytes()
+		if err != nil {
+			log.WithError(err).Error("error serializing response")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		a.cache.GetByUser(user, true)
-
-		// No real response
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{}`))
+		_, _ = w.Write(body)
 	}
 }
 
-// SettingsEndpoint ...
-func (a *API) SettingsEndpoint() httprouter.Handle {
-	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		// Limit request body to to abuse
-		r.Body = http.MaxBytesReader(w, r.Body, a.config.MaxUploadSize)
-		defer r.Body.Close()
+// currentTokenID returns the ID of the token making the request
+func currentTokenID(r *http.Request) string {
+	if token, ok := r.Context().Value(TokenContextKey).(*Token); ok {
+		return token.ID
+	}
+	return ""
+}
+
+// SessionsEndpoint lists the user's active web sessions and API tokens
+func (a *API) SessionsEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		sessions, err := GetUserSessions(a.sessions, user, currentTokenID(r))
+		if err != nil {
+			log.WithError(err).Errorf("error loading sessions for %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		body, err := types.SessionsResponse{Sessions: sessions}.Bytes()
+		if err != nil {
+			log.WithError(err).Error("error serializing response")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+}
+
+// RevokeSessionEndpoint revokes one of the user's web sessions or API tokens
+// or all of them except the token making the request
+func (a *API) RevokeSessionEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		req, err := types.NewRevokeSessionRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing revoke session request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		user := r.Context().Value(UserContextKey).(*User)
+
+		if req.Others {
+			if err := RevokeOtherSessions(a.sessions, user, currentTokenID(r)); err != nil {
+				log.WithError(err).Errorf("error revoking sessions for %s", user.Username)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			ok, err := RevokeSession(a.sessions, user, req.ID)
+			if err != nil {
+				log.WithError(err).Errorf("error revoking session for %s", user.Username)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.Error(w, "Session Not Found", http.StatusNotFound)
+				return
+			}
+		}
+
+		if err := a.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Errorf("error saving user object for %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+// FollowEndpoint ...
+func (a *API) FollowEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewFollowRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing follow request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		nick := strings.TrimSpace(req.Nick)
+		url := NormalizeURL(req.URL)
+
+		if nick == "" || url == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if err := user.FollowAndValidate(a.config, nick, url); err != nil {
+			log.WithError(err).Errorf("error validating new feed @<%s %s>", nick, url)
+			http.Error(w, "Invalid Feed", http.StatusBadRequest)
+			return
+		}
+
+		if err := a.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Error("error saving user object")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		a.cache.GetByUser(user, true)
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+// UnfollowEndpoint ...
+func (a *API) UnfollowEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		req, err := types.NewUnfollowRequest(r.Body)
+
+		if err != nil {
+			log.WithError(err).Error("error parsing follow request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		nick := req.Nick
+
+		if nick == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		user := r.Context().Value(UserContextKey).(*User)
+
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		if _, ok := user.Following[nick]; !ok {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		user.Unfollow(nick)
+
+		if err := a.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Warnf("error updating user object for user  %s", user.Username)
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		a.cache.GetByUser(user, true)
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+// SettingsEndpoint ...
+func (a *API) SettingsEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		// Limit request body to to abuse
+		r.Body = http.MaxBytesReader(w, r.Body, a.config.MaxUploadSize)
+		defer r.Body.Close()
 
 		user := r.Context().Value(UserContextKey).(*User)
 
 		if r.Method == http.MethodGet {
-			data, err := json.Marshal(user)
+			data, err := user.Settings().Bytes()
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -701,6 +1161,11 @@ func (a *API) SettingsEndpoint() httprouter.Handle {
 			}
 
 			user.Password = hash
+
+			// Log the user out of all web sessions and revoke all other tokens
+			if err := RevokeOtherSessions(a.sessions, user, currentTokenID(r)); err != nil {
+				log.WithError(err).Errorf("error revoking sessions for %s", user.Username)
+			}
 		}
 
 		if avatarFile != nil {
@@ -753,6 +1218,87 @@ func (a *API) SettingsEndpoint() httprouter.Handle {
 	}
 }
 
+// MetadataEndpoint ...
+func (a *API) MetadataEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		var (
+			req types.MetadataRequest
+			err error
+		)
+
+		if r.Method == http.MethodGet {
+			req.Feed = r.URL.Query().Get("feed")
+		} else {
+			req, err = types.NewMetadataRequest(r.Body)
+			if err != nil {
+				log.WithError(err).Error("error parsing metadata request")
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var (
+			feed     *Feed
+			metadata url.Values
+		)
+
+		if req.Feed == "" || req.Feed == user.Username {
+			metadata = user.Metadata
+		} else {
+			if !user.OwnsFeed(req.Feed) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			feed, err = a.db.GetFeed(req.Feed)
+			if err != nil {
+				log.WithError(err).Errorf("error loading feed object for %s", req.Feed)
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			metadata = feed.Metadata
+		}
+
+		if r.Method == http.MethodPost {
+			metadata, err = ValidateMetadata(req.Metadata)
+			if err != nil {
+				log.WithError(err).Warn("invalid metadata")
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			if feed != nil {
+				feed.Metadata = metadata
+				err = a.db.SetFeed(feed.Name, feed)
+			} else {
+				user.Metadata = metadata
+				err = a.db.SetUser(user.Username, user)
+			}
+			if err != nil {
+				log.WithError(err).Error("error saving metadata")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		res := types.MetadataResponse{Feed: req.Feed, Metadata: metadata}
+		if res.Feed == "" {
+			res.Feed = user.Username
+		}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
 // OldUploadMediaEndpoint ...
 // TODO: Remove when the api_old_upload_media counter nears zero
 // XXX: Used for Goryon < v1.0.3
@@ -935,9 +1481,19 @@ func (a *API) InjectEndpoint() httprouter.Handle {
 			return
 		}
 
+		signature := r.Header.Get(TwtSignatureHeader)
+		if !IsTwtAuthentic(a.config, a.cache, twt, signature) {
+			log.Warnf("rejecting possible forged twt %s", twt.Hash())
+			http.Error(w, "Forged Twt", http.StatusBadRequest)
+			return
+		}
+
 		GetExternalAvatar(a.config, twt.Twter())
 
 		a.cache.InjectFeed(twt.Twter().URI, twt)
+		if signature != "" {
+			a.cache.SetSignature(twt, signature)
+		}
 		if err := a.archive.Archive(twt); err != nil {
 			log.WithError(err).Warnf("error archiving injected twt %s", twt.Hash())
 		}
@@ -955,6 +1511,10 @@ func (a *API) ProfileEndpoint() httprouter.Handle {
 
 		username := NormalizeUsername(p.ByName("username"))
 		if username == "" {
+			if loggedInUser == nil {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
 			username = loggedInUser.Username
 		}
 
@@ -1070,12 +1630,14 @@ func (a *API) ConversationEndpoint() httprouter.Handle {
 		}
 
 		res := types.PagedResponse{
-			Twts: pagedTwts,
+			Twts:      pagedTwts,
+			Collapsed: CollapsedTwts(loggedInUser, pagedTwts),
 			Pager: types.PagerResponse{
 				Current:   pager.Page(),
 				MaxPages:  pager.PageNums(),
 				TotalTwts: pager.Nums(),
 			},
+			Poll: a.cache.GetPoll(twt),
 		}
 
 		data, err := json.Marshal(res)
@@ -1144,6 +1706,8 @@ func (a *API) FetchTwtsEndpoint() httprouter.Handle {
 			return
 		}
 
+		twts = FilterTwts(loggedInUser, twts)
+
 		var pagedTwts types.Twts
 
 		pager := paginator.New(adapter.NewSliceAdapter(twts), a.config.TwtsPerPage)
@@ -1156,7 +1720,8 @@ func (a *API) FetchTwtsEndpoint() httprouter.Handle {
 		}
 
 		res := types.PagedResponse{
-			Twts: pagedTwts,
+			Twts:      pagedTwts,
+			Collapsed: CollapsedTwts(loggedInUser, pagedTwts),
 			Pager: types.PagerResponse{
 				Current:   pager.Page(),
 				MaxPages:  pager.PageNums(),
@@ -1212,7 +1777,9 @@ func (a *API) ExternalProfileEndpoint() httprouter.Handle {
 		}
 
 		// Set nick to what the user follows as (if any)
-		nick = loggedInUser.FollowsAs(uri)
+		if loggedInUser != nil {
+			nick = loggedInUser.FollowsAs(uri)
+		}
 
 		// If no nick provided try to guess a suitable nick
 		// from the feed or some heuristics from the feed's URI
@@ -1260,12 +1827,18 @@ func (a *API) ExternalProfileEndpoint() httprouter.Handle {
 			NFollowing: twter.Following,
 			NFollowers: twter.Followers,
 
+			Links:    types.NewLinksFromMetadata(twter.Metadata),
+			Metadata: twter.Metadata,
+
 			ShowFollowing: true,
 			ShowFollowers: true,
+		}
 
-			Follows:    loggedInUser.Follows(uri),
-			FollowedBy: loggedInUser.FollowedBy(uri),
-			Muted:      loggedInUser.HasMuted(uri),
+		// Anonymous requests have no relationship with the feed
+		if loggedInUser != nil {
+			profile.Follows = loggedInUser.Follows(uri)
+			profile.FollowedBy = loggedInUser.FollowedBy(uri)
+			profile.Muted = loggedInUser.HasMuted(uri)
 		}
 
 		profileResponse := types.ProfileResponse{
@@ -1356,69 +1929,402 @@ func (a *API) UnmuteEndpoint() httprouter.Handle {
 	}
 }
 
-// SupportEndpoint ...
-func (a *API) SupportEndpoint() httprouter.Handle {
+// MuteFiltersEndpoint lists the user's content filters
+func (a *API) MuteFiltersEndpoint() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		req, err := types.NewSupportRequest(r.Body)
-		if err != nil {
-			log.WithError(err).Error("error parsing support request")
-			http.Error(w, "Bad Request", http.StatusBadRequest)
-			return
-		}
-
-		name := req.Name
-		email := req.Email
-		subject := req.Subject
-		message := req.Message
+		user := r.Context().Value(UserContextKey).(*User)
 
-		if err := SendSupportRequestEmail(a.config, name, email, subject, message); err != nil {
-			log.WithError(err).Errorf("unable to send support email for %s", email)
-			log.WithError(err).Error("error sending support request")
+		body, err := types.MuteFiltersResponse{Filters: user.GetMuteFilters().AsMuteFilters()}.Bytes()
+		if err != nil {
+			log.WithError(err).Error("error serializing response")
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		log.Infof("support message email sent for %s", email)
-
-		// No real response
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{}`))
-
+		_, _ = w.Write(body)
 	}
 }
 
-// ReportEndpoint ...
-func (a *API) ReportEndpoint() httprouter.Handle {
+// AddMuteFilterEndpoint adds a content filter returning the user's filters
+func (a *API) AddMuteFilterEndpoint() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		req, err := types.NewReportRequest(r.Body)
+		user := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewAddMuteFilterRequest(r.Body)
 		if err != nil {
-			log.WithError(err).Error("error parsing report request")
+			log.WithError(err).Error("error parsing add filter request")
 			http.Error(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
 
-		nick := req.Nick
-		url := req.URL
-
-		if nick == "" || url == "" {
+		f, err := NewMuteFilter(req.Kind, req.Pattern, req.Action, time.Duration(req.ExpiresIn)*time.Second)
+		if err != nil {
 			http.Error(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
 
-		name := req.Name
-		email := req.Email
-		category := req.Category
-		message := req.Message
+		if err := user.AddMuteFilter(f); err != nil {
+			http.Error(w, "Too Many Filters", http.StatusBadRequest)
+			return
+		}
+
+		if err := a.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Error("error updating user object")
+			http.Error(w, "User Update Failed", http.StatusInternalServerError)
+			return
+		}
 
-		if err := SendReportAbuseEmail(a.config, nick, url, name, email, category, message); err != nil {
-			log.WithError(err).Errorf("unable to send report email for %s", email)
+		a.cache.DeleteUserViews(user)
+
+		body, err := types.MuteFiltersResponse{Filters: user.GetMuteFilters().AsMuteFilters()}.Bytes()
+		if err != nil {
+			log.WithError(err).Error("error serializing response")
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
-		// No real response
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{}`))
+		_, _ = w.Write(body)
+	}
+}
+
+// RemoveMuteFilterEndpoint removes one of the user's content filters
+func (a *API) RemoveMuteFilterEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewRemoveMuteFilterRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing remove filter request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if !user.RemoveMuteFilter(req.ID) {
+			http.Error(w, "Filter Not Found", http.StatusNotFound)
+			return
+		}
+
+		if err := a.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Error("error updating user object")
+			http.Error(w, "User Update Failed", http.StatusInternalServerError)
+			return
+		}
+
+		a.cache.DeleteUserViews(user)
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+// SupportEndpoint ...
+func (a *API) SupportEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		req, err := types.NewSupportRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing support request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		name := req.Name
+		email := req.Email
+		subject := req.Subject
+		message := req.Message
+
+		if message == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if err := SendSupportRequestEmail(a.config, name, email, subject, message); err != nil {
+			log.WithError(err).Errorf("unable to send support email for %s", email)
+			log.WithError(err).Error("error sending support request")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Infof("support message email sent for %s", email)
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+
+	}
+}
+
+// ReportEndpoint ...
+func (a *API) ReportEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		req, err := types.NewReportRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing report request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		nick := req.Nick
+		url := req.URL
+
+		if nick == "" || url == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		report := NewReport(nick, url, req.Hash, req.Category, req.Message)
+		report.Reporter = a.getLoggedInUser(r).Username
+		report.ReporterName = req.Name
+
+		if err := FileReport(a.config, a.db, report, req.Email); err != nil {
+			log.WithError(err).Errorf("unable to file report for %s", req.Email)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+// AdminUsersEndpoint lists the pod's users with their roles
+func (a *API) AdminUsersEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		users, err := a.db.GetAllUsers()
+		if err != nil {
+			log.WithError(err).Error("error loading users")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+		res := types.AdminUsersResponse{Users: make([]types.AdminUser, len(users))}
+		for i, user := range users {
+			res.Users[i] = types.AdminUser{
+				Username:        user.Username,
+				Role:            string(RoleOf(a.config, user)),
+				CreatedAt:       user.CreatedAt,
+				LastSeenAt:      user.LastSeenAt,
+				Silenced:        user.HasLimit(LimitSilence),
+				PostingDisabled: user.HasLimit(LimitDisablePosting),
+				Suspended:       user.IsSuspended(),
+			}
+		}
+
+		body, err := res.Bytes()
+		if err != nil {
+			log.WithError(err).Error("error serializing response")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+}
+
+// AdminSetRoleEndpoint assigns a role to a user
+func (a *API) AdminSetRoleEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		actor := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewAdminSetRoleRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing set role request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		role, err := ParseRole(req.Role)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		user, err := a.db.GetUser(NormalizeUsername(req.Username))
+		if err != nil {
+			http.Error(w, "User Not Found", http.StatusNotFound)
+			return
+		}
+
+		before := user.Role
+
+		if err := SetUserRole(a.config, a.db, actor, user, role); err != nil {
+			if err == ErrPermissionDenied {
+				http.Error(w, "Permission Denied", http.StatusForbidden)
+				return
+			}
+			log.WithError(err).Errorf("error setting role for %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Infof("%s set the role of %s to %q", actor.Username, user.Username, role)
+
+		Audit(a.db, actor.Username, AuditSetRole, user.Username, string(before), string(role), "")
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+// AdminLimitUserEndpoint places a limit on a user or lifts it
+func (a *API) AdminLimitUserEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		actor := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewAdminLimitUserRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing limit user request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		limit, err := ParseAccountLimit(req.Limit)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if !req.Lift && !req.Until.IsZero() && req.Until.Before(time.Now()) {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		user, err := a.db.GetUser(NormalizeUsername(req.Username))
+		if err != nil {
+			http.Error(w, "User Not Found", http.StatusNotFound)
+			return
+		}
+
+		if req.Lift {
+			err = LiftUserLimit(a.config, a.db, actor, user, limit)
+		} else {
+			err = LimitUser(a.config, a.db, a.sessions, actor, user, limit, req.Reason, req.Until)
+		}
+		if err != nil {
+			if err == ErrPermissionDenied {
+				http.Error(w, "Permission Denied", http.StatusForbidden)
+				return
+			}
+			log.WithError(err).Errorf("error limiting %s", user.Username)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		a.cache.Refresh()
+
+		if req.Lift {
+			log.Infof("%s lifted %s of %s", actor.Username, limit, user.Username)
+			Audit(a.db, actor.Username, AuditLiftLimit, user.Username, string(limit), "", "")
+		} else {
+			log.Infof("%s placed %s on %s", actor.Username, limit, user.Username)
+			after := string(limit)
+			if !req.Until.IsZero() {
+				after = fmt.Sprintf("%s until %s", limit, req.Until.UTC().Format(time.RFC3339))
+			}
+			Audit(a.db, actor.Username, AuditLimitUser, user.Username, "", after, req.Reason)
+		}
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+// AdminReportsEndpoint lists the abuse reports in the moderation queue
+func (a *API) AdminReportsEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		req, err := types.NewAdminReportsRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing reports request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		reports, err := a.db.GetAllReports()
+		if err != nil {
+			log.WithError(err).Error("error loading reports")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		body, err := types.AdminReportsResponse{
+			Reports: reports.Filter(ReportStatus(req.Status)).AsReports(),
+		}.Bytes()
+		if err != nil {
+			log.WithError(err).Error("error serializing response")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+}
+
+// AdminDecideReportEndpoint takes an action on an abuse report and records
+// the decision in the report's audit trail
+func (a *API) AdminDecideReportEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		actor := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewAdminDecideReportRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing decide report request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		report, err := a.db.GetReport(req.ID)
+		if err != nil {
+			http.Error(w, "Report Not Found", http.StatusNotFound)
+			return
+		}
+
+		action := ReportAction(req.Action)
+		if err := ModerateReport(a.config, a.cache, a.db, a.sessions, actor, report, action, req.Note); err != nil {
+			switch err {
+			case ErrPermissionDenied:
+				http.Error(w, "Permission Denied", http.StatusForbidden)
+			case ErrInvalidReportAction, ErrReportNotAboutTwt, ErrReportNotAboutUser, ErrNoReporterEmail:
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+			default:
+				log.WithError(err).Errorf("error taking action %s on report %s", action, report.ID)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		before := report.Status
+
+		if err := report.Decide(actor.Username, action, req.Note); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if err := a.db.SetReport(report.ID, report); err != nil {
+			log.WithError(err).Errorf("error saving report %s", report.ID)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		AuditReport(a.db, report, before)
+
+		body, err := json.Marshal(report.AsReport())
+		if err != nil {
+			log.WithError(err).Error("error serializing response")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
 	}
 }
 
@@ -1436,3 +2342,666 @@ func (a *API) PodConfigEndpoint() httprouter.Handle {
 		_, _ = w.Write(data)
 	}
 }
+
+// BookmarkEndpoint toggles a bookmark for a twt
+func (a *API) BookmarkEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewBookmarkRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing bookmark request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if req.Hash == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		twt, ok := a.cache.Lookup(req.Hash)
+		if !ok && a.archive.Has(req.Hash) {
+			twt, err = a.archive.Get(req.Hash)
+			if err != nil {
+				log.WithError(err).Errorf("error fetching twt %s from archive", req.Hash)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if twt.IsZero() {
+			http.Error(w, "Twt Not Found", http.StatusNotFound)
+			return
+		}
+
+		user.Bookmark(twt.Hash())
+
+		if err := a.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Error("error saving user object")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		res := types.BookmarkResponse{Hash: twt.Hash(), Bookmarked: user.Bookmarked(twt.Hash())}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// BookmarksEndpoint ...
+func (a *API) BookmarksEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		loggedInUser := a.getLoggedInUser(r)
+
+		req, err := types.NewBookmarksRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing bookmarks request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		nick := NormalizeUsername(req.Nick)
+		if nick == "" && loggedInUser != nil {
+			nick = loggedInUser.Username
+		}
+
+		user, err := a.db.GetUser(nick)
+		if err != nil {
+			http.Error(w, "User Not Found", http.StatusNotFound)
+			return
+		}
+
+		if !user.IsBookmarksPubliclyVisible && (loggedInUser == nil || !loggedInUser.Is(user.URL)) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var twts types.Twts
+		for hash := range user.Bookmarks {
+			if twt, ok := a.cache.Lookup(hash); ok {
+				twts = append(twts, twt)
+			} else if a.archive.Has(hash) {
+				if twt, err := a.archive.Get(hash); err == nil {
+					twts = append(twts, twt)
+				} else {
+					log.WithError(err).Errorf("error loading twt %s from archive", hash)
+				}
+			}
+		}
+		sort.Sort(twts)
+
+		var pagedTwts types.Twts
+
+		pager := paginator.New(adapter.NewSliceAdapter(twts), a.config.TwtsPerPage)
+		pager.SetPage(req.Page)
+
+		if err = pager.Results(&pagedTwts); err != nil {
+			log.WithError(err).Error("error loading twts")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		res := types.PagedResponse{
+			Twts: pagedTwts,
+			Pager: types.PagerResponse{
+				Current:   pager.Page(),
+				MaxPages:  pager.PageNums(),
+				TotalTwts: pager.Nums(),
+			},
+		}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// FeedsEndpoint lists the feeds the user can manage
+func (a *API) FeedsEndpoint() httprouter.Handle {
+	isAdminUser := IsAdminUserFactory(a.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		allFeeds, err := a.db.GetAllFeeds()
+		if err != nil {
+			log.WithError(err).Error("error loading feeds")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		res := types.FeedsResponse{Feeds: []types.Profile{}}
+		for _, feed := range allFeeds {
+			if user.OwnsFeed(feed.Name) || (IsSpecialFeed(feed.Name) && isAdminUser(user)) {
+				res.Feeds = append(res.Feeds, feed.Profile(a.config.BaseURL, user))
+			}
+		}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// CreateFeedEndpoint creates a new feed owned by the user
+func (a *API) CreateFeedEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewCreateFeedRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing create feed request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		name := NormalizeFeedName(req.Name)
+		if err := ValidateFeedName(a.config.Data, name); err != nil {
+			http.Error(w, "Invalid Feed Name", http.StatusBadRequest)
+			return
+		}
+
+		if err := CreateFeed(a.config, a.db, user, name, false); err != nil {
+			log.WithError(err).Errorf("error creating feed %s", name)
+			if err == ErrTooManyFeeds || err == ErrFeedAlreadyExists {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			} else {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		user.Follow(name, URLForUser(a.config.BaseURL, name))
+
+		if err := a.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Error("error saving user object")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		a.cache.DeleteUserViews(user)
+
+		feed, err := a.db.GetFeed(name)
+		if err != nil {
+			log.WithError(err).Errorf("error loading feed object for %s", name)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		res := types.FeedResponse{Feed: feed.Profile(a.config.BaseURL, user)}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// ManageFeedEndpoint updates or deletes a feed the user manages
+func (a *API) ManageFeedEndpoint() httprouter.Handle {
+	isAdminUser := IsAdminUserFactory(a.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		name := NormalizeFeedName(p.ByName("name"))
+
+		feed, err := a.db.GetFeed(name)
+		if err != nil {
+			if err == ErrFeedNotFound {
+				http.Error(w, "Feed Not Found", http.StatusNotFound)
+			} else {
+				log.WithError(err).Errorf("error loading feed object for %s", name)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodDelete:
+			if IsSpecialFeed(feed.Name) || !user.OwnsFeed(feed.Name) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if err := DeleteFeed(a.db, user, feed); err != nil {
+				log.WithError(err).Errorf("error deleting feed %s", feed.Name)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			a.cache.DeleteUserViews(user)
+
+			// No real response
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+			return
+		default:
+			if !user.OwnsFeed(feed.Name) && !(IsSpecialFeed(feed.Name) && isAdminUser(user)) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			req, err := types.NewUpdateFeedRequest(r.Body)
+			if err != nil {
+				log.WithError(err).Error("error parsing update feed request")
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			feed.Description = req.Description
+
+			if err := a.db.SetFeed(feed.Name, feed); err != nil {
+				log.WithError(err).Errorf("error saving feed object for %s", feed.Name)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			res := types.FeedResponse{Feed: feed.Profile(a.config.BaseURL, user)}
+
+			data, err := res.Bytes()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(data)
+		}
+	}
+}
+
+// FollowersEndpoint lists the followers of a local user or feed
+func (a *API) FollowersEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		loggedInUser := a.getLoggedInUser(r)
+
+		req, err := types.NewFollowersRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing followers request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		nick := NormalizeUsername(req.Nick)
+		if nick == "" && loggedInUser != nil {
+			nick = loggedInUser.Username
+		}
+
+		var profile types.Profile
+
+		if a.db.HasUser(nick) {
+			user, err := a.db.GetUser(nick)
+			if err != nil {
+				log.WithError(err).Errorf("error loading user object for %s", nick)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			if !user.IsFollowersPubliclyVisible && (loggedInUser == nil || !loggedInUser.Is(user.URL)) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			profile = user.Profile(a.config.BaseURL, loggedInUser)
+		} else if a.db.HasFeed(nick) {
+			feed, err := a.db.GetFeed(nick)
+			if err != nil {
+				log.WithError(err).Errorf("error loading feed object for %s", nick)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			profile = feed.Profile(a.config.BaseURL, loggedInUser)
+		} else {
+			http.Error(w, "User or Feed Not Found", http.StatusNotFound)
+			return
+		}
+
+		res := types.FollowersResponse{Followers: a.cache.GetFollowers(profile)}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// FollowingEndpoint lists the feeds a local user follows
+func (a *API) FollowingEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		loggedInUser := a.getLoggedInUser(r)
+
+		req, err := types.NewFollowingRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing following request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		nick := NormalizeUsername(req.Nick)
+		if nick == "" && loggedInUser != nil {
+			nick = loggedInUser.Username
+		}
+
+		user, err := a.db.GetUser(nick)
+		if err != nil {
+			http.Error(w, "User Not Found", http.StatusNotFound)
+			return
+		}
+
+		if !user.IsFollowingPubliclyVisible && (loggedInUser == nil || !loggedInUser.Is(user.URL)) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		res := types.FollowingResponse{Following: user.Following}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// EditTwtEndpoint replaces the last twt of the user or a feed they own
+// preserving its timestamp
+func (a *API) EditTwtEndpoint() httprouter.Handle {
+	appendTwt := AppendTwtFactory(a.config, a.db)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewEditTwtRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing edit twt request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		text := CleanTwt(req.Text)
+		if req.Hash == "" || text == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if user.IsPostingDisabled() {
+			http.Error(w, "Posting Disabled", http.StatusForbidden)
+			return
+		}
+
+		feed, err := a.postAsFeed(user, req.PostAs)
+		if err != nil {
+			if err == ErrFeedImposter {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			} else {
+				log.WithError(err).Error("error loading feed")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		lastTwt, _, err := a.getLastTwt(user, feed)
+		if err != nil {
+			log.WithError(err).Error("error loading last twt")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// Only the last twt can be edited
+		if lastTwt.IsZero() || lastTwt.Hash() != req.Hash {
+			http.Error(w, "Twt Not Found", http.StatusNotFound)
+			return
+		}
+
+		if err := a.deleteLastTwt(user, feed); err != nil {
+			log.WithError(err).Error("error deleting last twt")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		twt, err := appendTwt(user, feed, text, lastTwt.Created())
+		if err != nil {
+			log.WithError(err).Error("error posting twt")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		a.refreshFeed(user, feed)
+
+		res := types.EditTwtResponse{Hash: twt.Hash()}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// postAsFeed returns the feed the user edits or deletes twts of, nil for the
+// user's own feed, users can only edit or delete twts of feeds they own
+func (a *API) postAsFeed(user *User, postAs string) (*Feed, error) {
+	if postAs == "" || postAs == me || postAs == user.Username {
+		return nil, nil
+	}
+
+	if !user.OwnsFeed(postAs) {
+		return nil, ErrFeedImposter
+	}
+
+	return a.db.GetFeed(postAs)
+}
+
+// getLastTwt returns the last twt of the user's feed or the feed if not nil
+func (a *API) getLastTwt(user *User, feed *Feed) (types.Twt, int, error) {
+	if feed != nil {
+		return GetLastFeedTwt(a.config, feed)
+	}
+	return GetLastTwt(a.config, user)
+}
+
+// deleteLastTwt deletes the last twt of the user's feed or the feed if not nil
+func (a *API) deleteLastTwt(user *User, feed *Feed) error {
+	if feed != nil {
+		return DeleteLastFeedTwt(a.config, feed)
+	}
+	return DeleteLastTwt(a.config, user)
+}
+
+// refreshFeed refetches the user's feed or the feed if not nil after one of
+// its twts was edited or deleted, the feed is refetched in the background
+func (a *API) refreshFeed(user *User, feed *Feed) {
+	sources := user.Source()
+	if feed != nil {
+		sources = feed.Source()
+	}
+
+	// Delete the feed from the cache as it was edited
+	a.cache.DeleteFeeds(sources)
+
+	a.tasks.DispatchFunc(func() error {
+		a.cache.FetchFeeds(a.config, a.archive, sources, nil)
+
+		// Re-populate/Warm cache for User
+		a.cache.GetByUser(user, true)
+
+		return nil
+	})
+}
+
+// DeleteTwtEndpoint deletes the last twt of the user or a feed they own
+func (a *API) DeleteTwtEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewDeleteTwtRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing delete twt request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if req.Hash == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		feed, err := a.postAsFeed(user, req.PostAs)
+		if err != nil {
+			if err == ErrFeedImposter {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			} else {
+				log.WithError(err).Error("error loading feed")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		lastTwt, _, err := a.getLastTwt(user, feed)
+		if err != nil {
+			log.WithError(err).Error("error loading last twt")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// Only the last twt can be deleted
+		if lastTwt.IsZero() || lastTwt.Hash() != req.Hash {
+			http.Error(w, "Twt Not Found", http.StatusNotFound)
+			return
+		}
+
+		if err := a.deleteLastTwt(user, feed); err != nil {
+			log.WithError(err).Error("error deleting last twt")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		a.refreshFeed(user, feed)
+
+		// No real response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+// ImportEndpoint follows a list of feeds
+func (a *API) ImportEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := r.Context().Value(UserContextKey).(*User)
+
+		req, err := types.NewImportRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing import request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if len(req.Follows) == 0 {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		imported := 0
+		for nick, url := range req.Follows {
+			nick = strings.TrimSpace(nick)
+			url = NormalizeURL(strings.TrimSpace(url))
+			if nick != "" && url != "" {
+				user.Follow(nick, url)
+				imported++
+			}
+		}
+
+		if err := a.db.SetUser(user.Username, user); err != nil {
+			log.WithError(err).Error("error saving user object")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		a.cache.GetByUser(user, true)
+
+		res := types.ImportResponse{Imported: imported}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// WhoFollowsEndpoint ...
+func (a *API) WhoFollowsEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		req, err := types.NewWhoFollowsRequest(r.Body)
+		if err != nil {
+			log.WithError(err).Error("error parsing whoFollows request")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if req.Token == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		uri := tokenCache.GetString(req.Token)
+		if uri == "" {
+			http.Error(w, "Token Not Found", http.StatusNotFound)
+			return
+		}
+		tokenCache.Del(req.Token)
+
+		nick, followers, err := WhoFollows(a.config, a.db, uri, a.getLoggedInUser(r))
+		if err != nil {
+			log.WithError(err).Error("unable to get all users from database")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		res := types.WhoFollowsResponse{Nick: nick, URI: uri, Followers: followers.AsMap()}
+
+		data, err := res.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
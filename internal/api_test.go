@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func newTestAPI(t *testing.T) *API {
+	cfg := NewConfig()
+	cfg.BaseURL = "http://127.0.0.1:8000"
+	cfg.Data = t.TempDir()
+	cfg.APISigningKey = "secret"
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.Data, feedsDir), 0755))
+
+	db, err := NewStore("bitcask://" + filepath.Join(cfg.Data, "yarn.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	archive, err := NewNullArchiver()
+	require.NoError(t, err)
+
+	tasks := NewDispatcher(1, 10)
+	tasks.Start()
+	t.Cleanup(tasks.Stop)
+
+	return &API{config: cfg, cache: NewCache(cfg), archive: archive, db: db, tasks: tasks, sessions: NewSessionStore(db, time.Hour)}
+}
+
+// callEndpoint calls an API endpoint as user (if non-nil) and decodes the
+// JSON response into v (if non-nil) returning the response's status code
+func callEndpoint(t *testing.T, endpoint httprouter.Handle, user *User, method string, body, v interface{}, params ...httprouter.Param) int {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(method, "/api/v1/", bytes.NewReader(data))
+	if user != nil {
+		r = r.WithContext(context.WithValue(r.Context(), UserContextKey, user))
+	}
+
+	w := httptest.NewRecorder()
+	endpoint(w, r, params)
+
+	if v != nil && w.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(v))
+	}
+
+	return w.Code
+}
+
+func TestBookmarkEndpoint(t *testing.T) {
+	api := newTestAPI(t)
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	twt := types.MakeTwt(testLocalTwter, time.Now(), "Hello World!")
+	api.cache.UpdateFeed(testLocalFeed, "", types.Twts{twt})
+	api.cache.Refresh()
+
+	var res types.BookmarkResponse
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.BookmarkEndpoint(), user, http.MethodPost, types.BookmarkRequest{Hash: twt.Hash()}, &res))
+	assert.True(t, res.Bookmarked)
+
+	user, err := api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.True(t, user.Bookmarked(twt.Hash()))
+
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.BookmarkEndpoint(), user, http.MethodPost, types.BookmarkRequest{Hash: twt.Hash()}, &res))
+	assert.False(t, res.Bookmarked)
+
+	assert.Equal(t, http.StatusNotFound, callEndpoint(t, api.BookmarkEndpoint(), user, http.MethodPost, types.BookmarkRequest{Hash: "foo"}, nil))
+}
+
+func TestFollowingEndpoint(t *testing.T) {
+	api := newTestAPI(t)
+
+	user := NewUser()
+	user.Username = "alice"
+	user.URL = URLForUser(api.config.BaseURL, user.Username)
+	user.Follow("bob", "https://example.com/bob.txt")
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	var res types.FollowingResponse
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.FollowingEndpoint(), nil, http.MethodPost, types.FollowingRequest{Nick: "alice"}, &res))
+	assert.Equal(t, "https://example.com/bob.txt", res.Following["bob"])
+
+	user.IsFollowingPubliclyVisible = false
+	require.NoError(t, api.db.SetUser(user.Username, user))
+	assert.Equal(t, http.StatusUnauthorized, callEndpoint(t, api.FollowingEndpoint(), nil, http.MethodPost, types.FollowingRequest{Nick: "alice"}, nil))
+	assert.Equal(t, http.StatusNotFound, callEndpoint(t, api.FollowingEndpoint(), nil, http.MethodPost, types.FollowingRequest{Nick: "nobody"}, nil))
+}
+
+func TestPostEndpointThreadLength(t *testing.T) {
+	api := newTestAPI(t)
+	api.config.MaxThreadLength = 2
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	req := types.PostRequest{Text: "Hello", Thread: []string{"World", "!"}}
+	assert.Equal(t, http.StatusBadRequest, callEndpoint(t, api.PostEndpoint(), user, http.MethodPost, req, nil))
+
+	_, err := os.Stat(filepath.Join(api.config.Data, feedsDir, user.Username))
+	assert.True(t, os.IsNotExist(err), "no part of the thread is posted")
+}
+
+func TestFeedEndpoints(t *testing.T) {
+	api := newTestAPI(t)
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	var feed types.FeedResponse
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.CreateFeedEndpoint(), user, http.MethodPost, types.CreateFeedRequest{Name: "recipes"}, &feed))
+	assert.Equal(t, "recipes", feed.Feed.Nick)
+
+	var feeds types.FeedsResponse
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.FeedsEndpoint(), user, http.MethodGet, nil, &feeds))
+	require.Len(t, feeds.Feeds, 1)
+
+	params := []httprouter.Param{{Key: "name", Value: "recipes"}}
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.ManageFeedEndpoint(), user, http.MethodPost, types.UpdateFeedRequest{Description: "All the recipes"}, &feed, params...))
+	assert.Equal(t, "All the recipes", feed.Feed.Description)
+
+	other := NewUser()
+	other.Username = "bob"
+	assert.Equal(t, http.StatusUnauthorized, callEndpoint(t, api.ManageFeedEndpoint(), other, http.MethodDelete, nil, nil, params...))
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.ManageFeedEndpoint(), user, http.MethodDelete, nil, nil, params...))
+	assert.False(t, api.db.HasFeed("recipes"))
+}
+
+func TestEditAndDeleteFeedTwt(t *testing.T) {
+	api := newTestAPI(t)
+
+	alice := NewUser()
+	alice.Username = "alice"
+	require.NoError(t, api.db.SetUser(alice.Username, alice))
+	require.NoError(t, CreateFeed(api.config, api.db, alice, "news", true))
+
+	bob := NewUser()
+	bob.Username = "bob"
+	require.NoError(t, api.db.SetUser(bob.Username, bob))
+
+	feed, err := api.db.GetFeed("news")
+	require.NoError(t, err)
+	twt, err := AppendTwtFactory(api.config, api.db)(alice, feed, "Hello World!")
+	require.NoError(t, err)
+
+	// Only the feed's owner can edit or delete its twts
+	edit := types.EditTwtRequest{PostAs: "news", Hash: twt.Hash(), Text: "Hello Yarn!"}
+	assert.Equal(t, http.StatusUnauthorized, callEndpoint(t, api.EditTwtEndpoint(), bob, http.MethodPatch, edit, nil))
+	assert.Equal(t, http.StatusNotFound, callEndpoint(t, api.EditTwtEndpoint(), alice, http.MethodPatch, types.EditTwtRequest{Hash: twt.Hash(), Text: "Hello Yarn!"}, nil))
+
+	var res types.EditTwtResponse
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.EditTwtEndpoint(), alice, http.MethodPatch, edit, &res))
+
+	edited, _, err := GetLastFeedTwt(api.config, feed)
+	require.NoError(t, err)
+	assert.Equal(t, res.Hash, edited.Hash())
+	assert.Contains(t, fmt.Sprintf("%l", edited), "Hello Yarn!")
+	assert.WithinDuration(t, twt.Created(), edited.Created(), time.Second)
+
+	remove := types.DeleteTwtRequest{PostAs: "news", Hash: edited.Hash()}
+	assert.Equal(t, http.StatusUnauthorized, callEndpoint(t, api.DeleteTwtEndpoint(), bob, http.MethodDelete, remove, nil))
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.DeleteTwtEndpoint(), alice, http.MethodDelete, remove, nil))
+
+	last, _, err := GetLastFeedTwt(api.config, feed)
+	require.NoError(t, err)
+	assert.True(t, last.IsZero())
+}
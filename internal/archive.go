@@ -15,6 +15,10 @@ import (
 
 const (
 	archiveDir = "archive"
+
+	// archiveAliasesMarker marks archives whose twts have been linked under
+	// their hashes of all hash versions (See: LinkAliases)
+	archiveAliasesMarker = ".aliases"
 )
 
 var (
@@ -32,6 +36,7 @@ type Archiver interface {
 	Get(hash string) (types.Twt, error)
 	Archive(twt types.Twt) error
 	Count() (int, error)
+	LinkAliases() error
 }
 
 // NullArchiver implements Archiver using dummy implementation stubs
@@ -46,6 +51,7 @@ func (a *NullArchiver) Has(hash string) bool               { return false }
 func (a *NullArchiver) Get(hash string) (types.Twt, error) { return types.NilTwt, nil }
 func (a *NullArchiver) Archive(twt types.Twt) error        { return nil }
 func (a *NullArchiver) Count() (int, error)                { return 0, nil }
+func (a *NullArchiver) LinkAliases() error                 { return nil }
 
 // DiskArchiver implements Archiver using an on-disk hash layout directory
 // structure with one directory per 2-letter hash sequence with a single
@@ -165,9 +171,78 @@ func (a *DiskArchiver) Archive(twt types.Twt) error {
 		return err
 	}
 
+	a.linkAliases(twt, fn)
+
 	return nil
 }
 
+// linkAliases links the twt's hashes under other hash versions to the twt
+// archived as fn so it can be retrieved by any of them (See:
+// types.TwtHashVersion)
+func (a *DiskArchiver) linkAliases(twt types.Twt, fn string) {
+	for _, alias := range twt.Hashes()[1:] {
+		aliasFn, err := a.makePath(alias)
+		if err != nil || a.fileExists(aliasFn) {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(aliasFn), 0755); err != nil {
+			log.WithError(err).Warnf("error creating archive directory for twt %s", alias)
+			continue
+		}
+
+		target, err := filepath.Rel(filepath.Dir(aliasFn), fn)
+		if err != nil {
+			continue
+		}
+
+		if err := os.Symlink(target, aliasFn); err != nil {
+			log.WithError(err).Warnf("error linking twt %s to %s in archive", alias, twt.Hash())
+		}
+	}
+}
+
+// LinkAliases links the twts archived by older versions under their hashes of
+// all hash versions, it only walks the archive once
+func (a *DiskArchiver) LinkAliases() error {
+	marker := filepath.Join(a.path, archiveAliasesMarker)
+	if a.fileExists(marker) {
+		return nil
+	}
+
+	err := filepath.Walk(a.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 || info.IsDir() || filepath.Ext(info.Name()) != ".json" {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.WithError(err).Warnf("error reading archived twt %s", path)
+			return nil
+		}
+
+		twt, err := types.DecodeJSON(data)
+		if err != nil {
+			log.WithError(err).Warnf("error decoding archived twt %s", path)
+			return nil
+		}
+
+		a.linkAliases(twt, path)
+
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).Error("error linking archived twts")
+		return err
+	}
+
+	return ioutil.WriteFile(marker, []byte{}, 0644)
+}
+
 func (a *DiskArchiver) Count() (int, error) {
 	var count int
 
@@ -177,6 +252,11 @@ func (a *DiskArchiver) Count() (int, error) {
 			return err
 		}
 
+		// Skip links to twts archived under other hashes
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
 		if !info.IsDir() && filepath.Ext(info.Name()) == ".json" {
 			count++
 		}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestDiskArchiverLinkAliases(t *testing.T) {
+	archive, err := NewDiskArchiver(t.TempDir())
+	require.NoError(t, err)
+
+	twter := types.Twter{Nick: testLocalNick, URI: testLocalFeed, HashVersion: types.TwtHashV2}
+	twt := types.MakeTwt(twter, time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC), "Hello World!")
+	hashes := twt.Hashes()
+	require.Len(t, hashes, 2)
+
+	require.NoError(t, archive.Archive(twt))
+	assert.True(t, archive.Has(hashes[1]))
+
+	// Older versions only archived twts under their hash
+	fn, err := archive.(*DiskArchiver).makePath(hashes[1])
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(fn))
+	assert.False(t, archive.Has(hashes[1]))
+
+	require.NoError(t, archive.LinkAliases())
+	archived, err := archive.Get(hashes[1])
+	require.NoError(t, err)
+	assert.Equal(t, hashes[0], archived.Hash())
+
+	count, err := archive.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// The archive is only walked once
+	require.NoError(t, os.Remove(fn))
+	require.NoError(t, archive.LinkAliases())
+	assert.False(t, archive.Has(hashes[1]))
+}
@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrAuditEntryExists is returned when adding an audit entry with the
+	// id of an existing entry, entries can never be changed once added
+	ErrAuditEntryExists = errors.New("error: audit entry already exists")
+)
+
+// AuditAction is an admin or moderation action recorded in the audit log
+type AuditAction string
+
+// Audited actions, the target of each is a username, feed name, setting, job
+// name or report id respectively
+const (
+	AuditAddUser        AuditAction = "add_user"
+	AuditDelUser        AuditAction = "del_user"
+	AuditRstUser        AuditAction = "reset_password"
+	AuditRstTwoFactor   AuditAction = "reset_2fa"
+	AuditSetRole        AuditAction = "set_role"
+	AuditDelFeed        AuditAction = "del_feed"
+	AuditEditSettings   AuditAction = "edit_settings"
+	AuditRunJob         AuditAction = "run_job"
+	AuditRefreshCache   AuditAction = "refresh_cache"
+	AuditModerateReport AuditAction = "moderate_report"
+
+	// The target of blocklist actions is the blocklist entry or the source of
+	// the imported blocklist
+	AuditAddBlock        AuditAction = "add_block"
+	AuditDelBlock        AuditAction = "del_block"
+	AuditImportBlocklist AuditAction = "import_blocklist"
+
+	// The target of account limit actions is the username, the limit is
+	// recorded as the after and before value when placed and lifted
+	AuditLimitUser AuditAction = "limit_user"
+	AuditLiftLimit AuditAction = "lift_limit"
+
+	// The target of invite actions is the invite code, only invites created
+	// and revoked by staff managing invites are recorded
+	AuditCreateInvite AuditAction = "create_invite"
+	AuditRevokeInvite AuditAction = "revoke_invite"
+
+	// The target of registration actions is the username of the user pending
+	// approval
+	AuditApproveUser AuditAction = "approve_user"
+	AuditRejectUser  AuditAction = "reject_user"
+
+	// The target of spam review actions is the hash of the twt flagged as
+	// spam, the action taken by the spam filter is recorded as the before
+	// value and the feed as the note
+	AuditReleaseSpam AuditAction = "release_spam"
+	AuditHideSpam    AuditAction = "hide_spam"
+)
+
+// AuditActions are the actions recorded in the audit log
+var AuditActions = []AuditAction{
+	AuditAddUser,
+	AuditDelUser,
+	AuditRstUser,
+	AuditRstTwoFactor,
+	AuditSetRole,
+	AuditDelFeed,
+	AuditEditSettings,
+	AuditRunJob,
+	AuditRefreshCache,
+	AuditModerateReport,
+	AuditAddBlock,
+	AuditDelBlock,
+	AuditImportBlocklist,
+	AuditLimitUser,
+	AuditLiftLimit,
+	AuditCreateInvite,
+	AuditRevokeInvite,
+	AuditApproveUser,
+	AuditRejectUser,
+	AuditReleaseSpam,
+	AuditHideSpam,
+}
+
+// AuditEntry records who took which action on what, and the value of what
+// was changed before and after the action (if applicable)
+type AuditEntry struct {
+	ID     string      `json:"id"`
+	Actor  string      `json:"actor"`
+	Action AuditAction `json:"action"`
+	Target string      `json:"target"`
+
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	Note   string `json:"note,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewAuditEntry returns a new audit entry of the actor's action on target
+func NewAuditEntry(actor string, action AuditAction, target, before, after string) *AuditEntry {
+	return &AuditEntry{
+		ID:        GenerateRandomToken(),
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Before:    before,
+		After:     after,
+		CreatedAt: time.Now(),
+	}
+}
+
+// LoadAuditEntry ...
+func LoadAuditEntry(data []byte) (entry *AuditEntry, err error) {
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Bytes ...
+func (e *AuditEntry) Bytes() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// AuditFilter selects audit entries by actor, action and target, empty
+// fields match every entry and Target matches any part of an entry's target
+type AuditFilter struct {
+	Actor  string
+	Action AuditAction
+	Target string
+}
+
+// Match returns true if the entry is selected by the filter
+func (f AuditFilter) Match(e *AuditEntry) bool {
+	if f.Actor != "" && NormalizeUsername(f.Actor) != e.Actor {
+		return false
+	}
+	if f.Action != "" && f.Action != e.Action {
+		return false
+	}
+	if f.Target != "" && !strings.Contains(strings.ToLower(e.Target), strings.ToLower(f.Target)) {
+		return false
+	}
+	return true
+}
+
+// AuditEntries is a list of audit entries sorted by most recent first
+type AuditEntries []*AuditEntry
+
+func (entries AuditEntries) Len() int { return len(entries) }
+func (entries AuditEntries) Less(i, j int) bool {
+	return entries[i].CreatedAt.After(entries[j].CreatedAt)
+}
+func (entries AuditEntries) Swap(i, j int) { entries[i], entries[j] = entries[j], entries[i] }
+
+// Filter returns the entries selected by the filter
+func (entries AuditEntries) Filter(f AuditFilter) (filtered AuditEntries) {
+	for _, entry := range entries {
+		if f.Match(entry) {
+			filtered = append(filtered, entry)
+		}
+	}
+	sort.Sort(filtered)
+	return
+}
+
+// JSONLines returns the entries as JSON Lines, one entry per line
+func (entries AuditEntries) JSONLines() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Audit appends the actor's action to the audit log, a failure to record
+// the action is logged but does not fail the action itself
+func Audit(db Store, actor string, action AuditAction, target, before, after, note string) {
+	entry := NewAuditEntry(actor, action, target, before, after)
+	entry.Note = note
+
+	if err := db.AddAuditEntry(entry); err != nil {
+		log.WithError(err).Errorf("error recording audit entry %s by %s on %s", action, actor, target)
+	}
+}
+
+// SettingsSnapshot is the value of every pod setting keyed by the setting's
+// yaml key, it is taken before and after editing the pod's settings to audit
+// the changes as the settings share feature flags and lists with the config
+type SettingsSnapshot map[string]string
+
+// SnapshotSettings ...
+func SnapshotSettings(settings *Settings) SettingsSnapshot {
+	snapshot := make(SettingsSnapshot)
+
+	v := reflect.ValueOf(settings).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		snapshot[key] = formatSetting(v.Field(i).Interface())
+	}
+
+	return snapshot
+}
+
+// AuditSettings appends an edit_settings entry for every setting changed
+// between the before and after snapshots
+func AuditSettings(db Store, actor string, before, after SettingsSnapshot) {
+	var keys []string
+	for key := range after {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if before[key] != after[key] {
+			Audit(db, actor, AuditEditSettings, key, before[key], after[key], "")
+		}
+	}
+}
+
+func formatSetting(v interface{}) string {
+	switch v := v.(type) {
+	case []string:
+		return strings.Join(v, "\n")
+	case []*BlockEntry:
+		entries := make([]string, len(v))
+		for i, e := range v {
+			entries[i] = e.String()
+		}
+		return strings.Join(entries, "\n")
+	case *FeatureFlags:
+		if v == nil {
+			return ""
+		}
+		features := v.AsStrings()
+		sort.Strings(features)
+		return strings.Join(features, "\n")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
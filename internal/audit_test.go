@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+)
+
+func TestAuditLog(t *testing.T) {
+	api := newTestAPI(t)
+
+	older := NewAuditEntry("admin", AuditAddUser, "alice", "", "")
+	older.CreatedAt = older.CreatedAt.Add(-time.Hour)
+	require.NoError(t, api.db.AddAuditEntry(older))
+
+	// The audit log is append only
+	older.Target = "mallory"
+	assert.Equal(t, ErrAuditEntryExists, api.db.AddAuditEntry(older))
+
+	Audit(api.db, "mod", AuditDelFeed, "news", "", "", "spam")
+	Audit(api.db, "admin", AuditSetRole, "alice", "", "moderator", "")
+
+	entries, err := api.db.GetAllAuditEntries()
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, AuditAddUser, entries[2].Action)
+	assert.Equal(t, "alice", entries[2].Target)
+
+	assert.Len(t, entries.Filter(AuditFilter{}), 3)
+	assert.Len(t, entries.Filter(AuditFilter{Actor: "Admin"}), 2)
+	assert.Len(t, entries.Filter(AuditFilter{Action: AuditSetRole}), 1)
+	assert.Len(t, entries.Filter(AuditFilter{Target: "ALI"}), 2)
+	assert.Len(t, entries.Filter(AuditFilter{Actor: "mod", Target: "alice"}), 0)
+
+	data, err := entries.Filter(AuditFilter{Actor: "mod"}).JSONLines()
+	require.NoError(t, err)
+	assert.Equal(t, 1, bytes.Count(data, []byte("\n")))
+
+	var entry AuditEntry
+	require.NoError(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, "news", entry.Target)
+	assert.Equal(t, "spam", entry.Note)
+}
+
+func TestAuditSettings(t *testing.T) {
+	api := newTestAPI(t)
+
+	before := SnapshotSettings(api.config.Settings())
+
+	api.config.Name = "My Pod"
+	require.NoError(t, WithBlacklistedFeeds([]string{"example.com"})(api.config))
+	require.NoError(t, WithEnabledFeatures([]FeatureType{FeatureFoo})(api.config))
+
+	AuditSettings(api.db, "admin", before, SnapshotSettings(api.config.Settings()))
+
+	entries, err := api.db.GetAllAuditEntries()
+	require.NoError(t, err)
+
+	changed := map[string]*AuditEntry{}
+	for _, entry := range entries {
+		assert.Equal(t, AuditEditSettings, entry.Action)
+		changed[entry.Target] = entry
+	}
+	require.Len(t, changed, 3)
+	assert.Equal(t, "My Pod", changed["pod_name"].After)
+	assert.Equal(t, "example.com", changed["blacklisted_feeds"].After)
+	assert.Contains(t, changed["features"].After, FeatureFoo.String())
+}
+
+func TestManageAudit(t *testing.T) {
+	server := newTestServer(t)
+	server.config.AdminUser = "owner"
+
+	owner := NewUser()
+	owner.Username = "owner"
+	require.NoError(t, server.db.SetUser(owner.Username, owner))
+
+	mod := NewUser()
+	mod.Username = "mod"
+	require.NoError(t, server.db.SetUser(mod.Username, mod))
+
+	request := func(method, path string, user *User, form url.Values) *http.Request {
+		sess := session.NewSession(server.sc)
+		sess.ID = GenerateRandomToken()
+		sess.Data = session.Map{"username": user.Username}
+
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+	}
+
+	w := httptest.NewRecorder()
+	server.SetRoleHandler()(w, request(http.MethodPost, "/manage/setrole", owner, url.Values{"username": {"mod"}, "role": {"moderator"}}), nil)
+
+	// Moderators cannot view the audit log
+	w = httptest.NewRecorder()
+	server.ManageAuditHandler()(w, request(http.MethodGet, "/manage/audit", mod, nil), nil)
+	assert.Contains(t, w.Body.String(), "You do not have permission to do this!")
+
+	w = httptest.NewRecorder()
+	server.ManageAuditHandler()(w, request(http.MethodGet, "/manage/audit?action=set_role", owner, nil), nil)
+	assert.Contains(t, w.Body.String(), "set_role")
+	assert.Contains(t, w.Body.String(), "moderator")
+
+	w = httptest.NewRecorder()
+	server.ManageAuditHandler()(w, request(http.MethodGet, "/manage/audit?format=jsonl&actor=owner", owner, nil), nil)
+	assert.Equal(t, "application/jsonl", w.Header().Get("Content-Type"))
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var entry AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 1)
+	assert.Equal(t, "owner", entries[0].Actor)
+	assert.Equal(t, AuditSetRole, entries[0].Action)
+	assert.Equal(t, "mod", entries[0].Target)
+	assert.Equal(t, "", entries[0].Before)
+	assert.Equal(t, "moderator", entries[0].After)
+
+	w = httptest.NewRecorder()
+	server.ManageAuditHandler()(w, request(http.MethodGet, "/manage/audit?format=jsonl&actor=mod", owner, nil), nil)
+	assert.Empty(t, w.Body.String())
+}
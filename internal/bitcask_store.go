@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"git.mills.io/prologic/bitcask"
 	log "github.com/sirupsen/logrus"
@@ -14,14 +16,25 @@ import (
 )
 
 const (
-	feedsKeyPrefix    = "/feeds"
-	sessionsKeyPrefix = "/sessions"
-	usersKeyPrefix    = "/users"
+	auditKeyPrefix         = "/audit"
+	feedsKeyPrefix         = "/feeds"
+	invitesKeyPrefix       = "/invites"
+	notificationsKeyPrefix = "/notifications"
+	reportsKeyPrefix       = "/reports"
+	signingKeysKeyPrefix   = "/keys"
+	sessionsKeyPrefix      = "/sessions"
+	userSessionsKeyPrefix  = "/index/sessions"
+	usersKeyPrefix         = "/users"
 )
 
 // BitcaskStore ...
 type BitcaskStore struct {
 	db *bitcask.Bitcask
+
+	// unread caches the number of unread notifications of each user so that
+	// rendering a page doesn't load the user's inbox
+	unreadMu sync.RWMutex
+	unread   map[string]int
 }
 
 func newBitcaskStore(path string) (*BitcaskStore, error) {
@@ -45,7 +58,7 @@ func newBitcaskStore(path string) (*BitcaskStore, error) {
 		return nil, err
 	}
 
-	return &BitcaskStore{db: db}, nil
+	return &BitcaskStore{db: db, unread: make(map[string]int)}, nil
 }
 
 func (bs *BitcaskStore) scanKeys(prefix string) (keys [][]byte, err error) {
@@ -96,6 +109,10 @@ func (bs *BitcaskStore) HasFeed(name string) bool {
 }
 
 func (bs *BitcaskStore) DelFeed(name string) error {
+	if err := bs.DelSigningKey(name); err != nil {
+		return err
+	}
+
 	key := []byte(fmt.Sprintf("%s/%s", feedsKeyPrefix, name))
 	return bs.db.Delete(key)
 }
@@ -180,6 +197,10 @@ func (bs *BitcaskStore) HasUser(username string) bool {
 }
 
 func (bs *BitcaskStore) DelUser(username string) error {
+	if err := bs.DelSigningKey(username); err != nil {
+		return err
+	}
+
 	key := []byte(fmt.Sprintf("%s/%s", usersKeyPrefix, username))
 	return bs.db.Delete(key)
 }
@@ -282,7 +303,20 @@ func (bs *BitcaskStore) SetSession(sid string, sess *session.Session) error {
 		return err
 	}
 
-	return bs.db.Put(key, data)
+	if err := bs.db.Put(key, data); err != nil {
+		return err
+	}
+
+	// Index sessions by user so a user's sessions can be listed and revoked
+	if username, ok := sess.Get("username"); ok && username != "" {
+		return bs.db.Put(userSessionKey(username, sid), []byte(sid))
+	}
+
+	return nil
+}
+
+func userSessionKey(username, sid string) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%s", userSessionsKeyPrefix, username, sid))
 }
 
 func (bs *BitcaskStore) HasSession(sid string) bool {
@@ -291,6 +325,14 @@ func (bs *BitcaskStore) HasSession(sid string) bool {
 }
 
 func (bs *BitcaskStore) DelSession(sid string) error {
+	if sess, err := bs.GetSession(sid); err == nil {
+		if username, ok := sess.Get("username"); ok && username != "" {
+			if err := bs.db.Delete(userSessionKey(username, sid)); err != nil {
+				return err
+			}
+		}
+	}
+
 	key := []byte(fmt.Sprintf("%s/%s", sessionsKeyPrefix, sid))
 	return bs.db.Delete(key)
 }
@@ -341,3 +383,289 @@ func (bs *BitcaskStore) GetAllSessions() ([]*session.Session, error) {
 
 	return sessions, nil
 }
+
+// GetUserSessions returns the persisted sessions of the user logged in as
+// username, stale entries in the index are removed as they are found
+func (bs *BitcaskStore) GetUserSessions(username string) ([]*session.Session, error) {
+	var sessions []*session.Session
+
+	keys, err := bs.scanKeys(fmt.Sprintf("%s/%s/", userSessionsKeyPrefix, username))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		sid := strings.TrimPrefix(string(key), fmt.Sprintf("%s/%s/", userSessionsKeyPrefix, username))
+
+		sess, err := bs.GetSession(sid)
+		if err == session.ErrSessionNotFound {
+			if err := bs.db.Delete(key); err != nil {
+				log.WithError(err).Warnf("error deleting stale session index %s", key)
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if u, _ := sess.Get("username"); u != username {
+			if err := bs.db.Delete(key); err != nil {
+				log.WithError(err).Warnf("error deleting stale session index %s", key)
+			}
+			continue
+		}
+
+		sessions = append(sessions, sess)
+	}
+
+	return sessions, nil
+}
+
+// GetNotifications returns the user's notifications or an empty inbox if the
+// user has none yet
+func (bs *BitcaskStore) GetNotifications(username string) (*Notifications, error) {
+	key := []byte(fmt.Sprintf("%s/%s", notificationsKeyPrefix, username))
+	data, err := bs.db.Get(key)
+	if err != nil {
+		if err == bitcask.ErrKeyNotFound {
+			return NewNotifications(), nil
+		}
+		return nil, err
+	}
+	return LoadNotifications(data)
+}
+
+func (bs *BitcaskStore) SetNotifications(username string, notifications *Notifications) error {
+	data, err := notifications.Bytes()
+	if err != nil {
+		return err
+	}
+
+	key := []byte(fmt.Sprintf("%s/%s", notificationsKeyPrefix, username))
+	if err := bs.db.Put(key, data); err != nil {
+		return err
+	}
+
+	bs.unreadMu.Lock()
+	bs.unread[username] = notifications.Unread()
+	bs.unreadMu.Unlock()
+
+	return nil
+}
+
+func (bs *BitcaskStore) DelNotifications(username string) error {
+	key := []byte(fmt.Sprintf("%s/%s", notificationsKeyPrefix, username))
+	if err := bs.db.Delete(key); err != nil && err != bitcask.ErrKeyNotFound {
+		return err
+	}
+
+	bs.unreadMu.Lock()
+	delete(bs.unread, username)
+	bs.unreadMu.Unlock()
+
+	return nil
+}
+
+// GetUnreadNotifications returns the number of the user's unread
+// notifications, the user's inbox is only loaded if the count isn't cached
+func (bs *BitcaskStore) GetUnreadNotifications(username string) (int, error) {
+	bs.unreadMu.RLock()
+	unread, ok := bs.unread[username]
+	bs.unreadMu.RUnlock()
+	if ok {
+		return unread, nil
+	}
+
+	// Hold the lock while loading so a concurrent SetNotifications can't be
+	// overwritten with a stale count
+	bs.unreadMu.Lock()
+	defer bs.unreadMu.Unlock()
+
+	notifications, err := bs.GetNotifications(username)
+	if err != nil {
+		return 0, err
+	}
+	unread = notifications.Unread()
+	bs.unread[username] = unread
+
+	return unread, nil
+}
+
+func (bs *BitcaskStore) GetReport(id string) (*Report, error) {
+	key := []byte(fmt.Sprintf("%s/%s", reportsKeyPrefix, id))
+	data, err := bs.db.Get(key)
+	if err != nil {
+		if err == bitcask.ErrKeyNotFound {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	return LoadReport(data)
+}
+
+func (bs *BitcaskStore) SetReport(id string, report *Report) error {
+	data, err := report.Bytes()
+	if err != nil {
+		return err
+	}
+
+	key := []byte(fmt.Sprintf("%s/%s", reportsKeyPrefix, id))
+	return bs.db.Put(key, data)
+}
+
+func (bs *BitcaskStore) GetAllReports() (Reports, error) {
+	var reports Reports
+
+	keys, err := bs.scanKeys(reportsKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		data, err := bs.db.Get(key)
+		if err != nil {
+			return nil, err
+		}
+
+		report, err := LoadReport(data)
+		if err != nil {
+			log.WithError(err).Warnf("error loading report %s", key)
+			continue
+		}
+
+		reports = append(reports, report)
+	}
+
+	sort.Sort(reports)
+
+	return reports, nil
+}
+
+// AddAuditEntry appends the entry to the audit log, the audit log is append
+// only so existing entries are never overwritten
+func (bs *BitcaskStore) AddAuditEntry(entry *AuditEntry) error {
+	key := []byte(fmt.Sprintf("%s/%s", auditKeyPrefix, entry.ID))
+	if bs.db.Has(key) {
+		return ErrAuditEntryExists
+	}
+
+	data, err := entry.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return bs.db.Put(key, data)
+}
+
+func (bs *BitcaskStore) GetAllAuditEntries() (AuditEntries, error) {
+	var entries AuditEntries
+
+	keys, err := bs.scanKeys(auditKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		data, err := bs.db.Get(key)
+		if err != nil {
+			return nil, err
+		}
+
+		entry, err := LoadAuditEntry(data)
+		if err != nil {
+			log.WithError(err).Warnf("error loading audit entry %s", key)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Sort(entries)
+
+	return entries, nil
+}
+
+func (bs *BitcaskStore) GetInvite(code string) (*Invite, error) {
+	key := []byte(fmt.Sprintf("%s/%s", invitesKeyPrefix, code))
+	data, err := bs.db.Get(key)
+	if err != nil {
+		if err == bitcask.ErrKeyNotFound {
+			return nil, ErrInviteNotFound
+		}
+		return nil, err
+	}
+	return LoadInvite(data)
+}
+
+func (bs *BitcaskStore) SetInvite(code string, invite *Invite) error {
+	data, err := invite.Bytes()
+	if err != nil {
+		return err
+	}
+
+	key := []byte(fmt.Sprintf("%s/%s", invitesKeyPrefix, code))
+	return bs.db.Put(key, data)
+}
+
+func (bs *BitcaskStore) DelInvite(code string) error {
+	key := []byte(fmt.Sprintf("%s/%s", invitesKeyPrefix, code))
+	if err := bs.db.Delete(key); err != nil && err != bitcask.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
+
+func (bs *BitcaskStore) GetAllInvites() (Invites, error) {
+	var invites Invites
+
+	keys, err := bs.scanKeys(invitesKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		data, err := bs.db.Get(key)
+		if err != nil {
+			return nil, err
+		}
+
+		invite, err := LoadInvite(data)
+		if err != nil {
+			log.WithError(err).Warnf("error loading invite %s", key)
+			continue
+		}
+
+		invites = append(invites, invite)
+	}
+
+	sort.Sort(invites)
+
+	return invites, nil
+}
+
+// GetSigningKey returns the private key used to sign the twts of a local
+// user or feed, keys are stored apart from users and feeds so they are never
+// serialized along with them
+func (bs *BitcaskStore) GetSigningKey(nick string) ([]byte, error) {
+	key := []byte(fmt.Sprintf("%s/%s", signingKeysKeyPrefix, nick))
+	data, err := bs.db.Get(key)
+	if err != nil {
+		if err == bitcask.ErrKeyNotFound {
+			return nil, ErrNoSigningKey
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (bs *BitcaskStore) SetSigningKey(nick string, data []byte) error {
+	key := []byte(fmt.Sprintf("%s/%s", signingKeysKeyPrefix, nick))
+	return bs.db.Put(key, data)
+}
+
+func (bs *BitcaskStore) DelSigningKey(nick string) error {
+	key := []byte(fmt.Sprintf("%s/%s", signingKeysKeyPrefix, nick))
+	if err := bs.db.Delete(key); err != nil && err != bitcask.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+const (
+	// maxBlocklistSize is the maximum size of a blocklist imported from or
+	// subscribed to at another pod
+	maxBlocklistSize = 1 << 20
+
+	// maxBlockPatternLength is the maximum length of a block entry's pattern
+	maxBlockPatternLength = 256
+)
+
+var (
+	// ErrInvalidBlockEntry is returned when adding a block entry with an
+	// unknown kind or action, no actions, or a missing or invalid pattern
+	ErrInvalidBlockEntry = errors.New("error: invalid blocklist entry")
+
+	// ErrBlockEntryExists is returned when adding a block entry with the kind
+	// and pattern of an existing entry
+	ErrBlockEntryExists = errors.New("error: blocklist entry already exists")
+
+	// ErrBlockEntryNotFound is returned when removing a block entry that does
+	// not exist
+	ErrBlockEntryNotFound = errors.New("error: blocklist entry not found")
+
+	// ErrInvalidBlocklist is returned when importing a blocklist of an
+	// unsupported version
+	ErrInvalidBlocklist = errors.New("error: invalid or unsupported blocklist")
+)
+
+// BlockKinds are the kinds of patterns block entries match feed uris by
+var BlockKinds = []types.BlockKind{
+	types.BlockDomain,
+	types.BlockPrefix,
+	types.BlockRegex,
+}
+
+// BlockActions are the actions block entries take on matching feeds
+var BlockActions = []types.BlockAction{
+	types.BlockRejectFetch,
+	types.BlockHideFromDiscover,
+	types.BlockStripFromConversations,
+}
+
+// BlockEntry is an entry of the pod's blocklist that rejects fetching, hides
+// from discover or strips from conversations the feeds matching its domain,
+// uri prefix or regular expression
+type BlockEntry struct {
+	types.BlocklistEntry `yaml:",inline"`
+
+	ID string `yaml:"id"`
+
+	// Source is the url of the subscribed blocklist the entry was synced
+	// from, empty for entries added or imported by staff
+	Source string `yaml:"source,omitempty"`
+
+	CreatedBy string    `yaml:"created_by,omitempty"`
+	CreatedAt time.Time `yaml:"created_at"`
+
+	re     *regexp.Regexp
+	prefix string
+}
+
+// NewBlockEntry returns a new entry taking the actions on feeds matching the
+// pattern
+func NewBlockEntry(kind types.BlockKind, pattern string, actions []types.BlockAction, reason string) (*BlockEntry, error) {
+	e := &BlockEntry{
+		BlocklistEntry: types.BlocklistEntry{
+			Kind:    types.BlockKind(strings.ToLower(strings.TrimSpace(string(kind)))),
+			Pattern: strings.TrimSpace(pattern),
+			Reason:  strings.TrimSpace(reason),
+		},
+		ID:        GenerateRandomToken(),
+		CreatedAt: time.Now(),
+	}
+
+	if e.Kind == types.BlockDomain {
+		e.Pattern = strings.TrimPrefix(strings.ToLower(e.Pattern), "www.")
+	}
+
+	for _, action := range actions {
+		action = types.BlockAction(strings.ToLower(strings.TrimSpace(string(action))))
+		if !e.Has(action) {
+			e.Actions = append(e.Actions, action)
+		}
+	}
+
+	if err := e.compile(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// compile validates the entry and compiles the regular expression regex
+// entries match feed uris with
+func (e *BlockEntry) compile() error {
+	if e.Pattern == "" || len(e.Pattern) > maxBlockPatternLength || len(e.Actions) == 0 {
+		return ErrInvalidBlockEntry
+	}
+
+	for _, action := range e.Actions {
+		switch action {
+		case types.BlockRejectFetch, types.BlockHideFromDiscover, types.BlockStripFromConversations:
+		default:
+			return ErrInvalidBlockEntry
+		}
+	}
+
+	switch e.Kind {
+	case types.BlockDomain:
+	case types.BlockPrefix:
+		e.prefix = lowerSchemeAndHost(e.Pattern)
+	case types.BlockRegex:
+		re, err := regexp.Compile(e.Pattern)
+		if err != nil {
+			return ErrInvalidBlockEntry
+		}
+		e.re = re
+	default:
+		return ErrInvalidBlockEntry
+	}
+
+	return nil
+}
+
+// Has returns true if the entry takes the action on matching feeds
+func (e *BlockEntry) Has(action types.BlockAction) bool {
+	for _, a := range e.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns true if the feed uri matches the entry
+func (e *BlockEntry) Match(uri string) bool {
+	switch e.Kind {
+	case types.BlockDomain:
+		u, err := url.Parse(uri)
+		if err != nil {
+			return false
+		}
+		host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		return host == e.Pattern || strings.HasSuffix(host, "."+e.Pattern)
+	case types.BlockPrefix:
+		return strings.HasPrefix(lowerSchemeAndHost(uri), e.prefix)
+	case types.BlockRegex:
+		return e.re != nil && e.re.MatchString(uri)
+	}
+	return false
+}
+
+// lowerSchemeAndHost lowercases the scheme and host of a (possibly partial)
+// uri as they are case-insensitive unlike the rest of the uri
+func lowerSchemeAndHost(uri string) string {
+	i := strings.Index(uri, "://")
+	if i < 0 {
+		return strings.ToLower(uri)
+	}
+	i += len("://")
+
+	j := strings.IndexAny(uri[i:], "/?#")
+	if j < 0 {
+		return strings.ToLower(uri)
+	}
+	j += i
+
+	return strings.ToLower(uri[:j]) + uri[j:]
+}
+
+// String returns the entry's kind, pattern and actions
+func (e *BlockEntry) String() string {
+	actions := make([]string, len(e.Actions))
+	for i, action := range e.Actions {
+		actions[i] = string(action)
+	}
+	return fmt.Sprintf("%s:%s (%s)", e.Kind, e.Pattern, strings.Join(actions, ", "))
+}
+
+// GetBlocklist returns the entries of the pod's blocklist, the returned slice
+// must not be modified
+func (c *Config) GetBlocklist() []*BlockEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Blocklist
+}
+
+// updateBlocklist replaces the pod's blocklist with the entries returned by
+// update which is called with the current entries whilst holding the lock,
+// update must return a new slice rather than modifying the current one
+func (c *Config) updateBlocklist(update func(blocklist []*BlockEntry) ([]*BlockEntry, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blocklist, err := update(c.Blocklist)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range blocklist {
+		if err := e.compile(); err != nil {
+			return fmt.Errorf("%w: %s:%s", err, e.Kind, e.Pattern)
+		}
+	}
+
+	c.Blocklist = blocklist
+	return nil
+}
+
+// Blocked returns true if the feed uri matches any entry of the pod's
+// blocklist taking the action, the pod itself cannot be blocked.
+func (c *Config) Blocked(uri string, action types.BlockAction) bool {
+	if c.IsLocalURL(uri) {
+		return false
+	}
+
+	for _, e := range c.GetBlocklist() {
+		if e.Has(action) && e.Match(uri) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddBlockEntry adds the entry to the pod's blocklist
+func (c *Config) AddBlockEntry(entry *BlockEntry) error {
+	return c.updateBlocklist(func(blocklist []*BlockEntry) ([]*BlockEntry, error) {
+		for _, e := range blocklist {
+			if e.Kind == entry.Kind && e.Pattern == entry.Pattern {
+				return nil, ErrBlockEntryExists
+			}
+		}
+		// Copy the entries as readers may be ranging over them
+		return append(blocklist[:len(blocklist):len(blocklist)], entry), nil
+	})
+}
+
+// RemoveBlockEntry removes the entry with the given id from the pod's
+// blocklist returning the removed entry
+func (c *Config) RemoveBlockEntry(id string) (removed *BlockEntry, err error) {
+	err = c.updateBlocklist(func(blocklist []*BlockEntry) ([]*BlockEntry, error) {
+		var entries []*BlockEntry
+		for _, e := range blocklist {
+			if e.ID == id {
+				removed = e
+				continue
+			}
+			entries = append(entries, e)
+		}
+
+		if removed == nil {
+			return nil, ErrBlockEntryNotFound
+		}
+
+		return entries, nil
+	})
+	return
+}
+
+// RemoveBlocklistSources removes the entries synced from subscribed
+// blocklists whose urls are not in sources
+func (c *Config) RemoveBlocklistSources(sources []string) error {
+	return c.updateBlocklist(func(blocklist []*BlockEntry) ([]*BlockEntry, error) {
+		var entries []*BlockEntry
+		for _, e := range blocklist {
+			if e.Source == "" || HasString(sources, e.Source) {
+				entries = append(entries, e)
+			}
+		}
+		return entries, nil
+	})
+}
+
+// ImportBlocklist adds the entries of a shared blocklist to the pod's
+// blocklist skipping invalid entries and entries the pod already has. If
+// source is the url of a subscribed blocklist the entries previously synced
+// from it are replaced, otherwise the entries are recorded as created by actor.
+func (c *Config) ImportBlocklist(blocklist types.Blocklist, source, actor string) (added int, err error) {
+	if blocklist.Version != types.BlocklistVersion {
+		return 0, ErrInvalidBlocklist
+	}
+
+	err = c.updateBlocklist(func(current []*BlockEntry) ([]*BlockEntry, error) {
+		var entries []*BlockEntry
+		for _, e := range current {
+			if source == "" || e.Source != source {
+				entries = append(entries, e)
+			}
+		}
+
+		has := func(kind types.BlockKind, pattern string) bool {
+			for _, e := range entries {
+				if e.Kind == kind && e.Pattern == pattern {
+					return true
+				}
+			}
+			return false
+		}
+
+		for _, imported := range blocklist.Entries {
+			entry, err := NewBlockEntry(imported.Kind, imported.Pattern, imported.Actions, imported.Reason)
+			if err != nil {
+				log.WithError(err).Warnf("skipping invalid blocklist entry %s:%s", imported.Kind, imported.Pattern)
+				continue
+			}
+			if has(entry.Kind, entry.Pattern) {
+				continue
+			}
+
+			entry.Source = source
+			if source == "" {
+				entry.CreatedBy = actor
+			}
+			entries = append(entries, entry)
+			added++
+		}
+
+		return entries, nil
+	})
+	return
+}
+
+// FetchBlocklist fetches the blocklist another pod published at uri
+func FetchBlocklist(conf *Config, uri string) (blocklist types.Blocklist, err error) {
+	res, err := Request(conf, http.MethodGet, uri, nil)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return blocklist, fmt.Errorf("error fetching blocklist: %s", res.Status)
+	}
+
+	return types.DecodeBlocklist(io.LimitReader(res.Body, maxBlocklistSize))
+}
+
+// ExportBlocklist returns the pod's blocklist in the format pods share
+// blocklists in, entries synced from subscribed blocklists are not exported
+// so that blocklists do not propagate beyond the pods subscribing to them.
+func (c *Config) ExportBlocklist() types.Blocklist {
+	blocklist := types.Blocklist{
+		Version: types.BlocklistVersion,
+		Source:  c.BaseURL,
+		Entries: []types.BlocklistEntry{},
+	}
+
+	for _, e := range c.GetBlocklist() {
+		if e.Source != "" {
+			continue
+		}
+		blocklist.Entries = append(blocklist.Entries, e.BlocklistEntry)
+		if e.CreatedAt.After(blocklist.UpdatedAt) {
+			blocklist.UpdatedAt = e.CreatedAt
+		}
+	}
+
+	return blocklist
+}
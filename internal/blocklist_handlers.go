@@ -0,0 +1,293 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+// BlocklistHandler serves the pod's blocklist in the format pods share
+// blocklists in so that allied pods can subscribe to it, if the pod's
+// operator chose to publish it
+func (s *Server) BlocklistHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if !s.config.PublishBlocklist {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+
+		data, err := s.config.ExportBlocklist().Bytes()
+		if err != nil {
+			log.WithError(err).Error("error serializing blocklist")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		_, _ = w.Write(data)
+	}
+}
+
+// ManageBlocklistHandler lists the pod's blocklist, exports it (format=json)
+// and adds entries to it
+func (s *Server) ManageBlocklistHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionManageFeeds) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			if r.FormValue("format") == "json" {
+				data, err := s.config.ExportBlocklist().Bytes()
+				if err != nil {
+					log.WithError(err).Error("error exporting blocklist")
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set(
+					"Content-Disposition",
+					fmt.Sprintf("attachment; filename=blocklist-%s.json", time.Now().Format("20060102")),
+				)
+				_, _ = w.Write(data)
+				return
+			}
+
+			ctx.Title = s.tr(ctx, "ManageBlocklistTitle")
+			ctx.Blocklist = s.config.GetBlocklist()
+			ctx.BlockKinds = BlockKinds
+			ctx.BlockActions = BlockActions
+			ctx.BlocklistSubscriptions = s.config.BlocklistSubscriptions
+			ctx.PublishBlocklist = s.config.PublishBlocklist
+			s.render("manageBlocklist", w, ctx)
+			return
+		}
+
+		// FormValue parses the form (multipart or not) populating r.Form
+		kind := types.BlockKind(r.FormValue("kind"))
+
+		var actions []types.BlockAction
+		for _, action := range r.Form["actions"] {
+			actions = append(actions, types.BlockAction(action))
+		}
+
+		entry, err := NewBlockEntry(kind, r.FormValue("pattern"), actions, r.FormValue("reason"))
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidBlockEntry")
+			s.render("error", w, ctx)
+			return
+		}
+		entry.CreatedBy = ctx.Username
+
+		if err := s.config.AddBlockEntry(entry); err != nil {
+			ctx.Error = true
+			if err == ErrBlockEntryExists {
+				ctx.Message = s.tr(ctx, "ErrorBlockEntryExists")
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorInvalidBlockEntry")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.saveBlocklist(); err != nil {
+			log.WithError(err).Error("error saving pod settings")
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorSavingBlocklist")
+			s.render("error", w, ctx)
+			return
+		}
+
+		Audit(s.db, ctx.Username, AuditAddBlock, entry.String(), "", "", entry.Reason)
+
+		http.Redirect(w, r, "/manage/blocklist", http.StatusFound)
+	}
+}
+
+// DelBlockEntryHandler removes an entry from the pod's blocklist
+func (s *Server) DelBlockEntryHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionManageFeeds) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		entry, err := s.config.RemoveBlockEntry(strings.TrimSpace(r.FormValue("id")))
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorBlockEntryNotFound")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.saveBlocklist(); err != nil {
+			log.WithError(err).Error("error saving pod settings")
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorSavingBlocklist")
+			s.render("error", w, ctx)
+			return
+		}
+
+		Audit(s.db, ctx.Username, AuditDelBlock, entry.String(), "", "", entry.Reason)
+
+		http.Redirect(w, r, "/manage/blocklist", http.StatusFound)
+	}
+}
+
+// ImportBlocklistHandler imports a blocklist exported by another pod from an
+// uploaded file (blocklist_file) or pasted text (blocklist)
+func (s *Server) ImportBlocklistHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionManageFeeds) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		var src io.Reader = strings.NewReader(r.FormValue("blocklist"))
+		if f, _, err := r.FormFile("blocklist_file"); err == nil {
+			defer f.Close()
+			src = f
+		}
+
+		blocklist, err := types.DecodeBlocklist(io.LimitReader(src, maxBlocklistSize))
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidBlocklist")
+			s.render("error", w, ctx)
+			return
+		}
+
+		n, err := s.config.ImportBlocklist(blocklist, "", ctx.Username)
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidBlocklist")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.saveBlocklist(); err != nil {
+			log.WithError(err).Error("error saving pod settings")
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorSavingBlocklist")
+			s.render("error", w, ctx)
+			return
+		}
+
+		Audit(
+			s.db, ctx.Username, AuditImportBlocklist, blocklist.Source,
+			"", fmt.Sprintf("%d", n), "",
+		)
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgBlocklistImported", map[string]interface{}{"Count": n})
+		s.render("error", w, ctx)
+	}
+}
+
+// BlocklistSubscriptionsHandler updates the urls of the blocklists the pod
+// subscribes to and whether the pod publishes its own blocklist
+func (s *Server) BlocklistSubscriptionsHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionEditSettings) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		before := SnapshotSettings(s.config.Settings())
+
+		var subscriptions []string
+		for _, uri := range strings.Split(strings.ReplaceAll(r.FormValue("subscriptions"), "\r\n", "\n"), "\n") {
+			if uri = strings.TrimSpace(uri); uri == "" {
+				continue
+			}
+			if !strings.HasPrefix(uri, "https://") && !strings.HasPrefix(uri, "http://") {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorInvalidBlocklistSubscription", map[string]interface{}{"URL": uri})
+				s.render("error", w, ctx)
+				return
+			}
+			if !HasString(subscriptions, uri) {
+				subscriptions = append(subscriptions, uri)
+			}
+		}
+
+		s.config.BlocklistSubscriptions = subscriptions
+		s.config.PublishBlocklist = r.FormValue("publish") == "on"
+
+		// Drop the entries synced from blocklists no longer subscribed to
+		if err := s.config.RemoveBlocklistSources(subscriptions); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorSavingBlocklist")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.saveBlocklist(); err != nil {
+			log.WithError(err).Error("error saving pod settings")
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorSavingBlocklist")
+			s.render("error", w, ctx)
+			return
+		}
+
+		AuditSettings(s.db, ctx.Username, before, SnapshotSettings(s.config.Settings()))
+
+		s.tasks.DispatchFunc(func() error {
+			NewSyncBlocklistsJob(s.config, s.cache, s.archive, s.db).Run()
+			return nil
+		})
+
+		http.Redirect(w, r, "/manage/blocklist", http.StatusFound)
+	}
+}
+
+// saveBlocklist saves the pod's settings and applies the blocklist to the
+// feeds already cached
+func (s *Server) saveBlocklist() error {
+	if err := s.config.Settings().Save(filepath.Join(s.config.Data, "settings.yaml")); err != nil {
+		return err
+	}
+	s.cache.DeleteBlockedFeeds()
+	return nil
+}
@@ -0,0 +1,337 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabstv/merger"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestBlockEntryMatch(t *testing.T) {
+	rejectFetch := []types.BlockAction{types.BlockRejectFetch}
+
+	testCases := []struct {
+		kind    types.BlockKind
+		pattern string
+		uri     string
+		match   bool
+	}{
+		{types.BlockDomain, "Example.com", "https://example.com/twtxt.txt", true},
+		{types.BlockDomain, "www.example.com", "https://feeds.example.com/bob.txt", true},
+		{types.BlockDomain, "example.com", "https://notexample.com/twtxt.txt", false},
+		{types.BlockPrefix, "https://example.com/~bob/", "https://example.com/~bob/twtxt.txt", true},
+		{types.BlockPrefix, "https://example.com/~bob/", "https://example.com/~alice/twtxt.txt", false},
+		{types.BlockPrefix, "HTTPS://Example.COM/~bob/", "https://example.com/~bob/twtxt.txt", true},
+		{types.BlockPrefix, "https://example.com/~bob/", "https://EXAMPLE.com/~bob/twtxt.txt", true},
+		{types.BlockPrefix, "https://Spam", "https://spam.example.com/twtxt.txt", true},
+		{types.BlockPrefix, "https://example.com/~Bob/", "https://example.com/~bob/twtxt.txt", false},
+		{types.BlockRegex, `^https?://[^/]+/spam/`, "http://example.net/spam/twtxt.txt", true},
+		{types.BlockRegex, `^https?://[^/]+/spam/`, "http://example.net/ham/twtxt.txt", false},
+	}
+
+	for _, testCase := range testCases {
+		e, err := NewBlockEntry(testCase.kind, testCase.pattern, rejectFetch, "")
+		require.NoError(t, err)
+		assert.Equal(t, testCase.match, e.Match(testCase.uri), "%s %s", e, testCase.uri)
+	}
+
+	for _, invalid := range []struct {
+		kind    types.BlockKind
+		pattern string
+		actions []types.BlockAction
+	}{
+		{types.BlockDomain, "", rejectFetch},
+		{types.BlockRegex, "(", rejectFetch},
+		{types.BlockKind("host"), "example.com", rejectFetch},
+		{types.BlockDomain, "example.com", nil},
+		{types.BlockDomain, "example.com", []types.BlockAction{"delete"}},
+	} {
+		_, err := NewBlockEntry(invalid.kind, invalid.pattern, invalid.actions, "")
+		assert.Equal(t, ErrInvalidBlockEntry, err, "%s:%s", invalid.kind, invalid.pattern)
+	}
+}
+
+func TestConfigBlocklist(t *testing.T) {
+	api := newTestAPI(t)
+	conf := api.config
+
+	spam, err := NewBlockEntry(types.BlockDomain, "spam.example", []types.BlockAction{types.BlockRejectFetch, types.BlockHideFromDiscover}, "spam")
+	require.NoError(t, err)
+	require.NoError(t, conf.AddBlockEntry(spam))
+
+	dup, err := NewBlockEntry(types.BlockDomain, "SPAM.example", []types.BlockAction{types.BlockHideFromDiscover}, "")
+	require.NoError(t, err)
+	assert.Equal(t, ErrBlockEntryExists, conf.AddBlockEntry(dup))
+
+	assert.True(t, conf.Blocked("https://spam.example/twtxt.txt", types.BlockRejectFetch))
+	assert.True(t, conf.Blocked("https://spam.example/twtxt.txt", types.BlockHideFromDiscover))
+	assert.False(t, conf.Blocked("https://spam.example/twtxt.txt", types.BlockStripFromConversations))
+
+	// The pod itself cannot be blocked
+	local, err := NewBlockEntry(types.BlockPrefix, conf.BaseURL, []types.BlockAction{types.BlockRejectFetch}, "")
+	require.NoError(t, err)
+	require.NoError(t, conf.AddBlockEntry(local))
+	assert.False(t, conf.Blocked(URLForUser(conf.BaseURL, "alice"), types.BlockRejectFetch))
+
+	_, err = conf.RemoveBlockEntry(local.ID)
+	require.NoError(t, err)
+	_, err = conf.RemoveBlockEntry(local.ID)
+	assert.Equal(t, ErrBlockEntryNotFound, err)
+
+	// Entries synced from a subscribed blocklist are replaced on every sync
+	// and are not exported
+	shared := types.Blocklist{
+		Version: types.BlocklistVersion,
+		Source:  "https://allied.example",
+		Entries: []types.BlocklistEntry{
+			{Kind: types.BlockDomain, Pattern: "spam.example", Actions: []types.BlockAction{types.BlockRejectFetch}},
+			{Kind: types.BlockRegex, Pattern: "bots", Actions: []types.BlockAction{types.BlockStripFromConversations}, Reason: "bots"},
+			{Kind: types.BlockRegex, Pattern: "(", Actions: []types.BlockAction{types.BlockRejectFetch}},
+		},
+	}
+
+	n, err := conf.ImportBlocklist(shared, "https://allied.example/blocklist.json", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.Len(t, conf.Blocklist, 2)
+
+	shared.Entries = shared.Entries[:1]
+	n, err = conf.ImportBlocklist(shared, "https://allied.example/blocklist.json", "")
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	require.Len(t, conf.Blocklist, 1)
+
+	_, err = conf.ImportBlocklist(types.Blocklist{Version: 2}, "", "admin")
+	assert.Equal(t, ErrInvalidBlocklist, err)
+
+	exported := conf.ExportBlocklist()
+	assert.Equal(t, types.BlocklistVersion, exported.Version)
+	assert.Equal(t, conf.BaseURL, exported.Source)
+	require.Len(t, exported.Entries, 1)
+	assert.Equal(t, "spam", exported.Entries[0].Reason)
+
+	// The blocklist is persisted with the pod's settings
+	fn := filepath.Join(conf.Data, "settings.yaml")
+	require.NoError(t, conf.Settings().Save(fn))
+
+	settings, err := LoadSettings(fn)
+	require.NoError(t, err)
+
+	loaded := NewConfig()
+	loaded.BaseURL = conf.BaseURL
+	require.NoError(t, merger.MergeOverwrite(loaded, settings))
+	require.NoError(t, WithBlocklist(loaded.Blocklist)(loaded))
+	require.Len(t, loaded.Blocklist, 1)
+	assert.Equal(t, spam.ID, loaded.Blocklist[0].ID)
+	assert.Equal(t, "spam", loaded.Blocklist[0].Reason)
+	assert.True(t, loaded.Blocked("https://www.spam.example/twtxt.txt", types.BlockHideFromDiscover))
+}
+
+func TestConfigBlocklistConcurrentUpdates(t *testing.T) {
+	conf := NewConfig()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			e, err := NewBlockEntry(types.BlockDomain, fmt.Sprintf("spam%d.example", i), []types.BlockAction{types.BlockRejectFetch}, "")
+			assert.NoError(t, err)
+			assert.NoError(t, conf.AddBlockEntry(e))
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, err := conf.ImportBlocklist(types.Blocklist{
+				Version: types.BlocklistVersion,
+				Entries: []types.BlocklistEntry{
+					{Kind: types.BlockDomain, Pattern: fmt.Sprintf("bots%d.example", i), Actions: []types.BlockAction{types.BlockRejectFetch}},
+				},
+			}, "", "admin")
+			assert.NoError(t, err)
+		}(i)
+		go func() {
+			defer wg.Done()
+			conf.Blocked("https://spam.example/twtxt.txt", types.BlockRejectFetch)
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, conf.GetBlocklist(), 20, "no entries are lost")
+}
+
+func TestCacheBlocklist(t *testing.T) {
+	api := newTestAPI(t)
+	conf := api.config
+	cache := api.cache
+
+	local := types.Twter{Nick: "alice", URI: URLForUser(conf.BaseURL, "alice")}
+	spammer := types.Twter{Nick: "spammer", URI: "https://spam.example/twtxt.txt"}
+	troll := types.Twter{Nick: "troll", URI: "https://trolls.example/twtxt.txt"}
+
+	root := types.MakeTwt(local, time.Now().Add(-time.Hour), "Hello World!")
+	spam := types.MakeTwt(spammer, time.Now().Add(-time.Minute), "Buy now!")
+	reply := types.MakeTwt(troll, time.Now(), fmt.Sprintf("(#%s) You're wrong!", root.Hash()))
+
+	cache.UpdateFeed(local.URI, "", types.Twts{root})
+	cache.UpdateFeed(spammer.URI, "", types.Twts{spam})
+	cache.UpdateFeed(troll.URI, "", types.Twts{reply})
+	cache.Refresh()
+
+	subject := fmt.Sprintf("subject:(#%s)", root.Hash())
+	assert.Len(t, cache.GetByView(discoverViewKey), 3)
+	assert.Len(t, cache.GetByView(subject), 2)
+
+	for _, e := range []struct {
+		pattern string
+		action  types.BlockAction
+	}{
+		{"spam.example", types.BlockHideFromDiscover},
+		{"trolls.example", types.BlockStripFromConversations},
+	} {
+		entry, err := NewBlockEntry(types.BlockDomain, e.pattern, []types.BlockAction{e.action}, "")
+		require.NoError(t, err)
+		require.NoError(t, conf.AddBlockEntry(entry))
+	}
+	cache.DeleteBlockedFeeds()
+
+	assert.Equal(t, []string{reply.Hash(), root.Hash()}, hashesOf(cache.GetByView(discoverViewKey)))
+	assert.Equal(t, []string{root.Hash()}, hashesOf(cache.GetByView(subject)))
+
+	// Feeds the blocklist rejects fetching are dropped from the cache
+	entry, err := NewBlockEntry(types.BlockPrefix, "https://spam.example/", []types.BlockAction{types.BlockRejectFetch}, "")
+	require.NoError(t, err)
+	require.NoError(t, conf.AddBlockEntry(entry))
+	cache.DeleteBlockedFeeds()
+
+	assert.Empty(t, cache.GetByURL(spammer.URI))
+	assert.Len(t, cache.GetByURL(troll.URI), 1)
+}
+
+func TestManageBlocklist(t *testing.T) {
+	server := newTestServer(t)
+	server.config.AdminUser = "admin"
+
+	admin := NewUser()
+	admin.Username = "admin"
+	require.NoError(t, server.db.SetUser(admin.Username, admin))
+
+	mod := NewUser()
+	mod.Username = "mod"
+	mod.Role = RoleModerator
+	require.NoError(t, server.db.SetUser(mod.Username, mod))
+
+	post := func(handler httprouter.Handle, user *User, path string, form url.Values) *httptest.ResponseRecorder {
+		sess := session.NewSession(server.sc)
+		sess.ID = GenerateRandomToken()
+		sess.Data = session.Map{"username": user.Username}
+
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+
+		w := httptest.NewRecorder()
+		handler(w, r, nil)
+		return w
+	}
+
+	w := post(server.ManageBlocklistHandler(), mod, "/manage/blocklist", url.Values{
+		"kind":    {"domain"},
+		"pattern": {"spam.example"},
+		"actions": {"reject_fetch", "hide_from_discover"},
+		"reason":  {"spam"},
+	})
+	assert.Equal(t, http.StatusFound, w.Code)
+	require.Len(t, server.config.Blocklist, 1)
+	assert.Equal(t, "mod", server.config.Blocklist[0].CreatedBy)
+	assert.True(t, server.config.Blocked("https://spam.example/twtxt.txt", types.BlockHideFromDiscover))
+
+	w = post(server.ManageBlocklistHandler(), mod, "/manage/blocklist", url.Values{"kind": {"domain"}, "pattern": {"spam.example"}, "actions": {"reject_fetch"}})
+	assert.Contains(t, w.Body.String(), "already has an entry")
+
+	w = post(server.ManageBlocklistHandler(), mod, "/manage/blocklist", url.Values{"kind": {"regex"}, "pattern": {"("}, "actions": {"reject_fetch"}})
+	assert.Contains(t, w.Body.String(), "Invalid blocklist entry")
+
+	// Moderators can import blocklists but not change subscriptions
+	shared := `{"version":1,"source":"https://allied.example","entries":[{"kind":"prefix","pattern":"https://bots.example/","actions":["strip_from_conversations"],"reason":"bots"}]}`
+	w = post(server.ImportBlocklistHandler(), mod, "/manage/blocklist/import", url.Values{"blocklist": {shared}})
+	assert.Contains(t, w.Body.String(), "Imported 1 blocklist entries")
+	require.Len(t, server.config.Blocklist, 2)
+
+	w = post(server.BlocklistSubscriptionsHandler(), mod, "/manage/blocklist/subscriptions", url.Values{"publish": {"on"}})
+	assert.Contains(t, w.Body.String(), "You do not have permission to do this!")
+
+	// The blocklist is only published if the pod's operator chose to
+	w = httptest.NewRecorder()
+	server.BlocklistHandler()(w, httptest.NewRequest(http.MethodGet, "/blocklist.json", nil), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	server.config.PublishBlocklist = true
+	w = httptest.NewRecorder()
+	server.BlocklistHandler()(w, httptest.NewRequest(http.MethodGet, "/blocklist.json", nil), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	published, err := types.DecodeBlocklist(w.Body)
+	require.NoError(t, err)
+	assert.Len(t, published.Entries, 2)
+
+	w = post(server.DelBlockEntryHandler(), mod, "/manage/blocklist/remove", url.Values{"id": {server.config.Blocklist[0].ID}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	require.Len(t, server.config.Blocklist, 1)
+
+	entries, err := server.db.GetAllAuditEntries()
+	require.NoError(t, err)
+	assert.Len(t, entries.Filter(AuditFilter{Action: AuditAddBlock}), 1)
+	assert.Len(t, entries.Filter(AuditFilter{Action: AuditImportBlocklist}), 1)
+	assert.Len(t, entries.Filter(AuditFilter{Action: AuditDelBlock, Target: "spam.example"}), 1)
+
+	settings, err := LoadSettings(filepath.Join(server.config.Data, "settings.yaml"))
+	require.NoError(t, err)
+	require.Len(t, settings.Blocklist, 1)
+	assert.Equal(t, "bots", settings.Blocklist[0].Reason)
+}
+
+func TestSyncBlocklistsJob(t *testing.T) {
+	api := newTestAPI(t)
+
+	blocklist := types.Blocklist{
+		Version: types.BlocklistVersion,
+		Source:  "https://allied.example",
+		Entries: []types.BlocklistEntry{
+			{Kind: types.BlockDomain, Pattern: "spam.example", Actions: []types.BlockAction{types.BlockRejectFetch}, Reason: "spam"},
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := blocklist.Bytes()
+		_, _ = w.Write(data)
+	}))
+	defer ts.Close()
+
+	api.config.BlocklistSubscriptions = []string{ts.URL + "/blocklist.json"}
+
+	job := NewSyncBlocklistsJob(api.config, api.cache, api.archive, api.db)
+	job.Run()
+	require.Len(t, api.config.Blocklist, 1)
+	assert.Equal(t, ts.URL+"/blocklist.json", api.config.Blocklist[0].Source)
+	assert.True(t, api.config.Blocked("https://spam.example/twtxt.txt", types.BlockRejectFetch))
+
+	// Entries removed from the subscribed blocklist are removed on sync
+	blocklist.Entries = nil
+	job.Run()
+	assert.Empty(t, api.config.Blocklist)
+	assert.False(t, api.config.Blocked("https://spam.example/twtxt.txt", types.BlockRejectFetch))
+}
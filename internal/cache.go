@@ -63,10 +63,31 @@ func FilterOutFeedsAndBotsFactory(conf *Config) FilterFunc {
 		if isLocal(twter.URI) && HasString(automatedFeeds, twter.Nick) {
 			return false
 		}
+		if conf.MutedFeed(twter.URI) || conf.SilencedFeed(twter.URI) {
+			return false
+		}
+		if conf.Blocked(twter.URI, types.BlockHideFromDiscover) {
+			return false
+		}
 		return true
 	}
 }
 
+// FilterOutBlockedFromConversationsFactory filters out twts of feeds the
+// pod's blocklist strips from conversations
+func FilterOutBlockedFromConversationsFactory(conf *Config) FilterFunc {
+	return func(twt types.Twt) bool {
+		return !conf.Blocked(twt.Twter().URI, types.BlockStripFromConversations)
+	}
+}
+
+// FilterOutHiddenTwtsFactory filters out twts moderators hid pod-wide
+func FilterOutHiddenTwtsFactory(conf *Config) FilterFunc {
+	return func(twt types.Twt) bool {
+		return !conf.HiddenTwt(twt)
+	}
+}
+
 func FilterByMentionFactory(u *User) FilterFunc {
 	return func(twt types.Twt) bool {
 		for _, mention := range twt.Mentions() {
@@ -177,6 +198,9 @@ type Cached struct {
 	LastFetched   time.Time
 	LastModified  string
 	MovingAverage float64
+
+	// Signatures holds the detached signatures of the feed's twts
+	Signatures Signatures
 }
 
 func NewCached() *Cached {
@@ -314,6 +338,33 @@ func (cached *Cached) SetError(err error) {
 	cached.LastError = err.Error()
 }
 
+// GetSignature ...
+func (cached *Cached) GetSignature(hash string) string {
+	cached.mu.RLock()
+	defer cached.mu.RUnlock()
+
+	return cached.Signatures[hash]
+}
+
+// SetSignature ...
+func (cached *Cached) SetSignature(hash, signature string) {
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+
+	if cached.Signatures == nil {
+		cached.Signatures = make(Signatures)
+	}
+	cached.Signatures[hash] = signature
+}
+
+// SetSignatures ...
+func (cached *Cached) SetSignatures(signatures Signatures) {
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+
+	cached.Signatures = signatures
+}
+
 // SetLastFetched ...
 func (cached *Cached) SetLastFetched() {
 	cached.mu.Lock()
@@ -356,50 +407,52 @@ func (p *Peer) ShouldRefresh() bool {
 	return time.Since(p.LastUpdated) > podInfoUpdateTTL
 }
 
-func (p *Peer) makeJsonRequest(conf *Config, path string) ([]byte, error) {
+func (p *Peer) makeJsonRequest(conf *Config, path string) ([]byte, http.Header, error) {
 	headers := make(http.Header)
 	headers.Set("Accept", "application/json")
 
 	res, err := Request(conf, http.MethodGet, p.URI+path, headers)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("non-success HTTP %s response for %s%s", res.Status, p.URI, path)
+		return nil, nil, fmt.Errorf("non-success HTTP %s response for %s%s", res.Status, p.URI, path)
 	}
 
 	if ctype := res.Header.Get("Content-Type"); ctype != "" {
 		mediaType, _, err := mime.ParseMediaType(ctype)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if mediaType != "application/json" {
-			return nil, fmt.Errorf("non-JSON response content type '%s' for %s%s", ctype, p.URI, path)
+			return nil, nil, fmt.Errorf("non-JSON response content type '%s' for %s%s", ctype, p.URI, path)
 		}
 	}
 
 	data, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return data, nil
+	return data, res.Header, nil
 }
 
-func (p *Peer) GetTwt(conf *Config, hash string) (types.Twt, error) {
-	data, err := p.makeJsonRequest(conf, "/twt/"+hash)
+// GetTwt fetches a twt from the peer along with its detached signature
+// (if the peer knows of one).
+func (p *Peer) GetTwt(conf *Config, hash string) (types.Twt, string, error) {
+	data, headers, err := p.makeJsonRequest(conf, "/twt/"+hash)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	twt, err := types.DecodeJSON(data)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return twt, nil
+	return twt, headers.Get(TwtSignatureHeader), nil
 }
 
 type Peers []*Peer
@@ -425,6 +478,9 @@ type Cache struct {
 	Followers map[string]types.Followers
 	Twters    map[string]*types.Twter
 	Events    map[string]*Cached
+
+	// Spam scores twts of external feeds as they are fetched
+	Spam *SpamFilter
 }
 
 func NewCache(conf *Config) *Cache {
@@ -441,6 +497,8 @@ func NewCache(conf *Config) *Cache {
 		Followers: make(map[string]types.Followers),
 		Twters:    make(map[string]*types.Twter),
 		Events:    make(map[string]*Cached),
+
+		Spam: NewSpamFilter(conf, DefaultSpamRules()...),
 	}
 }
 
@@ -609,6 +667,10 @@ func LoadCache(conf *Config) (*Cache, error) {
 		log.WithError(err).Warn("error decoding cache.Events, removing corrupt file")
 	}
 
+	if err := dec.Decode(&cache.Spam.verdicts); err != nil {
+		log.WithError(err).Warn("error decoding cache.Spam, ignoring spam verdicts")
+	}
+
 	log.Infof("Cache version %d", cache.Version)
 
 	return cache, nil
@@ -659,6 +721,14 @@ func (cache *Cache) Store(conf *Config) error {
 		return err
 	}
 
+	cache.Spam.mu.RLock()
+	defer cache.Spam.mu.RUnlock()
+
+	if err := enc.Encode(cache.Spam.verdicts); err != nil {
+		log.WithError(err).Error("error encoding cache.Spam")
+		return err
+	}
+
 	return nil
 }
 
@@ -891,6 +961,12 @@ func (cache *Cache) FetchFeeds(conf *Config, archive Archiver, feeds types.Feeds
 			continue
 		}
 
+		// Skip feeds the pod's blocklist rejects fetching.
+		if cache.conf.Blocked(feed.URL, types.BlockRejectFetch) {
+			log.Warnf("attempt to fetch blocked feed %s", feed)
+			continue
+		}
+
 		wg.Add(1)
 		seenFeeds[feed.URL] = true
 		fetchers <- struct{}{}
@@ -979,6 +1055,10 @@ func (cache *Cache) FetchFeeds(conf *Config, archive Archiver, feeds types.Feeds
 				archiveTwts(old)
 				archiveTwts(twts)
 
+				if !isLocalURL(feed.URL) {
+					cache.Spam.Check(tf.Twts())
+				}
+
 				cache.UpdateFeed(feed.URL, "", twts)
 
 				twtsch <- twts
@@ -1085,6 +1165,20 @@ func (cache *Cache) FetchFeeds(conf *Config, archive Archiver, feeds types.Feeds
 				archiveTwts(old)
 				archiveTwts(twts)
 
+				// Fetch the feed's detached signatures (if any) so we can
+				// verify its twts offline when gossiped by peers.
+				if twter.Metadata.Get(signaturesMetadataKey) != "" {
+					if signatures, err := FetchSignatures(conf, twter); err != nil {
+						log.WithError(err).Warnf("error fetching signatures for feed %s", feed)
+					} else {
+						cachedFeed.SetSignatures(signatures)
+					}
+				}
+
+				if !isLocalURL(feed.URL) {
+					cache.Spam.Check(tf.Twts())
+				}
+
 				lastmodified := res.Header.Get("Last-Modified")
 				cache.UpdateFeed(feed.URL, lastmodified, twts)
 			case http.StatusNotModified: // 304
@@ -1108,10 +1202,61 @@ func (cache *Cache) FetchFeeds(conf *Config, archive Archiver, feeds types.Feeds
 	for range twtsch {
 	}
 
+	// Forget spam scores of twts expired from the cache
+	cache.Spam.Prune(time.Now().Add(-conf.MaxCacheTTL))
+
 	// Bust and repopulate twts for GetAll()
 	cache.Refresh()
 }
 
+// GetSignature returns the detached signature of a twt (if known)
+func (cache *Cache) GetSignature(twt types.Twt) string {
+	cache.mu.RLock()
+	cached, ok := cache.Feeds[twt.Twter().URI]
+	cache.mu.RUnlock()
+
+	if !ok {
+		return ""
+	}
+
+	return cached.GetSignature(twt.Hash())
+}
+
+// SetSignature stores the detached signature of a twt
+func (cache *Cache) SetSignature(twt types.Twt, signature string) {
+	cache.GetOrSetCachedFeed(twt.Twter().URI).SetSignature(twt.Hash(), signature)
+}
+
+// VerifyTwt verifies the signature of a twt offline against the public key
+// published by the twt's author. If signature is empty the signature fetched
+// from the author's feed (if any) is used.
+func (cache *Cache) VerifyTwt(twt types.Twt, signature string) error {
+	key, err := PublicKeyForTwter(cache.GetTwter(twt.Twter().URI))
+	if err != nil {
+		return err
+	}
+
+	if signature == "" {
+		signature = cache.GetSignature(twt)
+	}
+
+	return VerifyTwtSignature(key, twt, signature)
+}
+
+// IsVerified returns true if the twt's signature is valid for its author
+func (cache *Cache) IsVerified(twt types.Twt) bool {
+	return cache.VerifyTwt(twt, "") == nil
+}
+
+// GetPoll returns the tally of a poll twt's votes from the replies in its
+// conversation view or nil if the twt is not a poll
+func (cache *Cache) GetPoll(twt types.Twt) *types.Poll {
+	if twt.PollOptions() == nil {
+		return nil
+	}
+	return types.TallyPoll(twt, cache.GetByView(fmt.Sprintf("subject:(#%s)", twt.Hash())))
+}
+
 // Lookup ...
 func (cache *Cache) Lookup(hash string) (types.Twt, bool) {
 	cache.mu.RLock()
@@ -1204,19 +1349,24 @@ func (cache *Cache) Converge(archive Archiver) {
 		var (
 			peer       *Peer
 			missingTwt types.Twt
+			signature  string
 		)
 		for _, possiblePeer := range peers {
 			if !cache.conf.IsLocalURL(possiblePeer.URI) {
-				if twt, err := possiblePeer.GetTwt(cache.conf, hash); err == nil {
+				if twt, sig, err := possiblePeer.GetTwt(cache.conf, hash); err == nil {
 					missingTwt = twt
+					signature = sig
 					peer = possiblePeer
 					break
 				}
 			}
 		}
 		if missingTwt != nil {
-			if IsTwtAuthentic(cache.conf, missingTwt) {
+			if IsTwtAuthentic(cache.conf, cache, missingTwt, signature) {
 				cache.InjectFeed(missingTwt.Twter().URI, missingTwt)
+				if signature != "" {
+					cache.SetSignature(missingTwt, signature)
+				}
 				GetExternalAvatar(cache.conf, missingTwt.Twter())
 			} else {
 				log.Warnf("peer %s has possible forged twt %s", peer, missingTwt.Hash())
@@ -1237,7 +1387,8 @@ func (cache *Cache) Refresh() {
 	}
 	cache.mu.RUnlock()
 
-	allTwts = UniqTwts(allTwts)
+	allTwts = FilterTwtsBy(UniqTwts(allTwts), FilterOutHiddenTwtsFactory(cache.conf))
+	allTwts = FilterTwtsBy(allTwts, FilterOutSpamFactory(cache.Spam, SpamHold))
 	sort.Sort(allTwts)
 
 	//
@@ -1253,15 +1404,29 @@ func (cache *Cache) Refresh() {
 	byTags := make(map[string]types.Twts)
 	bySubjects := make(map[string]types.Twts)
 
+	// Twts are also indexed under the hashes of other hash versions so twts
+	// (and conversations) referenced by old hashes still resolve if a feed
+	// changes hash version (See: types.TwtHashVersion)
+	aliases := make(map[string]string)
+
 	filterOutFeedsAndBots := FilterOutFeedsAndBotsFactory(cache.conf)
+	filterOutSpam := FilterOutSpamFactory(cache.Spam, SpamHide)
+	filterOutBlockedFromConversations := FilterOutBlockedFromConversationsFactory(cache.conf)
 	for _, twt := range allTwts {
-		byHash[twt.Hash()] = twt
+		hashes := twt.Hashes()
+		byHash[hashes[0]] = twt
+		for _, alias := range hashes[1:] {
+			if _, ok := byHash[alias]; !ok {
+				byHash[alias] = twt
+			}
+			aliases[alias] = hashes[0]
+		}
 
 		if cache.conf.IsLocalURL(twt.Twter().URI) {
 			localTwts = append(localTwts, twt)
 		}
 
-		if filterOutFeedsAndBots(twt) {
+		if filterOutFeedsAndBots(twt) && filterOutSpam(twt) {
 			discoverTwts = append(discoverTwts, twt)
 		}
 
@@ -1269,8 +1434,22 @@ func (cache *Cache) Refresh() {
 			byTags[k] = append(byTags[k], twt)
 		}
 
-		for _, k := range GroupBySubject(twt) {
-			bySubjects[k] = append(bySubjects[k], twt)
+		if filterOutBlockedFromConversations(twt) {
+			for _, k := range GroupBySubject(twt) {
+				bySubjects[k] = append(bySubjects[k], twt)
+			}
+		}
+	}
+
+	// Merge conversations started under a twt's other hashes
+	for alias, hash := range aliases {
+		aliasKey := fmt.Sprintf("(#%s)", alias)
+		if twts, ok := bySubjects[aliasKey]; ok {
+			key := fmt.Sprintf("(#%s)", hash)
+			merged := UniqTwts(append(bySubjects[key], twts...))
+			sort.Sort(merged)
+			bySubjects[key] = merged
+			bySubjects[aliasKey] = merged
 		}
 	}
 
@@ -1278,7 +1457,7 @@ func (cache *Cache) Refresh() {
 	// This is mostly to support "forked" conversations
 	for k, v := range bySubjects {
 		hash := ExtractHashFromSubject(k)
-		if twt, ok := byHash[hash]; ok {
+		if twt, ok := byHash[hash]; ok && filterOutBlockedFromConversations(twt) {
 			if len(v) > 0 && v[(len(v)-1)].Hash() != twt.Hash() {
 				bySubjects[k] = append(bySubjects[k], twt)
 			}
@@ -1353,7 +1532,9 @@ func (cache *Cache) InjectFeed(url string, twt types.Twt) {
 	defer cache.mu.Unlock()
 
 	// Update Cache.Map (hash -> Twt)
-	cache.Map[twt.Hash()] = twt
+	for _, hash := range twt.Hashes() {
+		cache.Map[hash] = twt
+	}
 
 	// Update Cache.List ([]Twt)
 	cache.List.Inject(twt)
@@ -1374,6 +1555,9 @@ func (cache *Cache) InjectFeed(url string, twt types.Twt) {
 
 	tags := GroupByTag(twt)
 	subjects := GroupBySubject(twt)
+	if !FilterOutBlockedFromConversationsFactory(cache.conf)(twt) {
+		subjects = nil
+	}
 
 	for _, tag := range tags {
 		key := "tag:" + tag
@@ -1648,7 +1832,7 @@ func (cache *Cache) GetMentions(u *User, refresh bool) types.Twts {
 		return cached.GetTwts()
 	}
 
-	twts := cache.FilterBy(FilterByMentionFactory(u))
+	twts := FilterTwts(u, cache.FilterBy(FilterByMentionFactory(u)))
 
 	cache.mu.Lock()
 	cache.Views[key] = NewCachedTwts(twts, "")
@@ -1778,7 +1962,11 @@ func (cache *Cache) GetByURL(url string) types.Twts {
 	defer cache.mu.RUnlock()
 
 	if cached, ok := cache.Feeds[url]; ok {
-		return cached.GetTwts()
+		twts := cached.GetTwts()
+		if cache.conf.HasHiddenTwts() {
+			twts = FilterTwtsBy(twts, FilterOutHiddenTwtsFactory(cache.conf))
+		}
+		return FilterTwtsBy(twts, FilterOutSpamFactory(cache.Spam, SpamHold))
 	}
 	return types.Twts{}
 }
@@ -1821,6 +2009,19 @@ func (cache *Cache) DeleteFeeds(feeds types.Feeds) {
 	cache.Refresh()
 }
 
+// DeleteBlockedFeeds deletes the feeds the pod's blocklist rejects fetching
+// from the cache and refreshes the cache's views
+func (cache *Cache) DeleteBlockedFeeds() {
+	cache.mu.Lock()
+	for url := range cache.Feeds {
+		if cache.conf.Blocked(url, types.BlockRejectFetch) {
+			delete(cache.Feeds, url)
+		}
+	}
+	cache.mu.Unlock()
+	cache.Refresh()
+}
+
 // Reset ...
 func (cache *Cache) Reset() {
 	cache.mu.Lock()
@@ -461,3 +461,30 @@ func TestCache_InjectAndSnipe(t *testing.T) {
 	cache.SnipeFeed(twt1.Twter().URL, twt1)
 	assert.Equal(t, 2, cache.TwtCount())
 }
+
+func TestCache_HashVersions(t *testing.T) {
+	twter := types.Twter{Nick: testLocalNick, URI: testLocalFeed, HashVersion: types.TwtHashV2}
+	root := types.MakeTwt(twter, time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC), "Hello World!")
+
+	hashes := root.Hashes()
+	assert.Len(t, hashes, 2)
+	newHash, oldHash := hashes[0], hashes[1]
+
+	cache := NewCache(testConfig)
+	cache.UpdateFeed(testLocalFeed, "", types.Twts{root})
+	cache.UpdateFeed(testExternalFeed, "", types.Twts{
+		types.MakeTwt(testExternalTwter, time.Date(2021, 12, 1, 11, 0, 0, 0, time.UTC), fmt.Sprintf("(#%s) Old reply", oldHash)),
+		types.MakeTwt(testExternalTwter, time.Date(2021, 12, 1, 12, 0, 0, 0, time.UTC), fmt.Sprintf("(#%s) New reply", newHash)),
+	})
+	cache.Refresh()
+
+	for _, hash := range hashes {
+		twt, ok := cache.Lookup(hash)
+		assert.True(t, ok)
+		assert.Equal(t, newHash, twt.Hash())
+
+		view, ok := cache.Views["subject:"+fmt.Sprintf("(#%s)", hash)]
+		require.True(t, ok)
+		assert.Len(t, view.GetTwts(), 3)
+	}
+}
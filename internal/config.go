@@ -6,11 +6,13 @@ import (
 	"io/fs"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"git.mills.io/yarnsocial/yarn"
@@ -50,11 +52,31 @@ type Settings struct {
 
 	OpenProfiles      bool `yaml:"open_profiles"`
 	OpenRegistrations bool `yaml:"open_registrations"`
+	InviteQuota       int  `yaml:"invite_quota"`
+	RequireApproval   bool `yaml:"require_approval"`
+	RegisterCaptcha   bool `yaml:"register_captcha"`
+
+	// Registration checks (See: CheckSignup)
+	DeniedUsernames        []string `yaml:"denied_usernames"`
+	DisposableEmailDomains []string `yaml:"disposable_email_domains"`
+
+	// Spam thresholds (See: SpamFilter)
+	SpamHideThreshold float64 `yaml:"spam_hide_threshold"`
+	SpamHoldThreshold float64 `yaml:"spam_hold_threshold"`
 
 	WhitelistedImages []string      `yaml:"whitelisted_images"`
 	BlacklistedFeeds  []string      `yaml:"blacklisted_feeds"`
 	Features          *FeatureFlags `yaml:"features"`
 
+	// Moderation decisions (See: Report)
+	HiddenTwts []string `yaml:"hidden_twts" json:"-"`
+	MutedFeeds []string `yaml:"muted_feeds" json:"-"`
+
+	// Blocklist (See: BlockEntry)
+	Blocklist              []*BlockEntry `yaml:"blocklist" json:"-"`
+	BlocklistSubscriptions []string      `yaml:"blocklist_subscriptions" json:"-"`
+	PublishBlocklist       bool          `yaml:"publish_blocklist"`
+
 	// Pod Level Settings (overridable by Users)
 	DisplayDatesInTimezone  string `yaml:"display_dates_in_timezone"`
 	DisplayTimePreference   string `yaml:"display_time_preference"`
@@ -101,6 +123,7 @@ type Config struct {
 	TwtsPerPage       int
 	MaxUploadSize     int64
 	MaxTwtLength      int
+	MaxThreadLength   int
 	MediaResolution   int
 	AvatarResolution  int
 	MaxCacheTTL       time.Duration
@@ -108,6 +131,11 @@ type Config struct {
 	MaxCacheItems     int
 	OpenProfiles      bool
 	OpenRegistrations bool
+	InviteQuota       int
+	RequireApproval   bool
+	RegisterCaptcha   bool
+	SpamHideThreshold float64
+	SpamHoldThreshold float64
 	DisableGzip       bool
 	DisableLogger     bool
 	DisableMedia      bool
@@ -118,6 +146,11 @@ type Config struct {
 
 	MagicLinkSecret string
 
+	// OldMagicLinkSecrets are previous MagicLinkSecrets kept after rotating
+	// the secret so digest addresses encrypted with them can be encrypted
+	// again with the current secret (See: RotateDigestEmails)
+	OldMagicLinkSecrets []string
+
 	SMTPHost string
 	SMTPPort int
 	SMTPUser string
@@ -127,9 +160,46 @@ type Config struct {
 	MaxCacheFetchers int
 	MaxFetchLimit    int64
 
+	// TwtHashVersion is the hash version local feeds declare their twts are
+	// hashed with (See: types.TwtHashVersion)
+	TwtHashVersion types.TwtHashVersion
+
 	APISessionTime time.Duration
 	APISigningKey  string
 
+	// APILegacyTokens accepts the deprecated tokens older versions issued
+	// without an ID, expiry or scopes (See: WithAPILegacyTokens)
+	APILegacyTokens bool
+
+	// RateLimits are the request budgets of rate limited routes keyed by
+	// name (See: DefaultRateLimits)
+	RateLimits map[string]RateLimit
+
+	// TrustedProxies are the addresses or networks of reverse proxies whose
+	// X-Forwarded-For headers are trusted (See: ForwardedFor)
+	trustedProxies []*net.IPNet
+	TrustedProxies []string
+
+	// OIDCIssuer is the issuer URL of an OpenID Connect provider users can
+	// login with, OpenID Connect login is disabled if it is empty
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string `json:"-"`
+
+	// OIDCName is the name of the provider shown on the login button
+	OIDCName string
+
+	// OIDCUsernameClaim is the claim new accounts take their username from
+	OIDCUsernameClaim string
+
+	// OIDCAutoProvision creates accounts for users logging in with the
+	// provider who have all of the OIDCRequiredClaims
+	OIDCAutoProvision  bool
+	OIDCRequiredClaims map[string]string
+
+	// OIDCDisablePasswords disables password login for linked accounts
+	OIDCDisablePasswords bool
+
 	baseURL *url.URL
 
 	whitelistedImages []*regexp.Regexp
@@ -138,6 +208,42 @@ type Config struct {
 	blacklistedFeeds []*regexp.Regexp
 	BlacklistedFeeds []string
 
+	// DeniedUsernames are patterns (regexes) of usernames people cannot
+	// register with
+	deniedUsernames []*regexp.Regexp
+	DeniedUsernames []string
+
+	// DisposableEmailDomains are the domains (and their subdomains) of
+	// disposable email providers people cannot register with
+	DisposableEmailDomains []string
+
+	// mu guards the moderation settings below as they change at runtime, they
+	// are replaced (never modified in place) whilst holding it
+	mu sync.RWMutex
+
+	// HiddenTwts are the hashes of twts hidden pod-wide by moderators
+	hiddenTwts map[string]bool
+	HiddenTwts []string
+
+	// MutedFeeds are the uris of feeds muted pod-wide by moderators, their
+	// twts are hidden from the discover view but can still be followed
+	mutedFeeds map[string]bool
+	MutedFeeds []string
+
+	// silencedFeeds are the urls of silenced users' feeds hidden from
+	// discover, suspendedFeeds are the urls of suspended users' feeds served
+	// as gone (See: ApplyAccountLimits)
+	silencedFeeds  map[string]bool
+	suspendedFeeds map[string]bool
+
+	// Blocklist is the pod's live editable blocklist of domains, uri prefixes
+	// and regular expressions, BlocklistSubscriptions are the urls of other
+	// pods' published blocklists synced into it (See: SyncBlocklistsJob).
+	// The blocklist must be read with GetBlocklist.
+	Blocklist              []*BlockEntry
+	BlocklistSubscriptions []string
+	PublishBlocklist       bool
+
 	Features *FeatureFlags
 
 	// Pod Level Settings (overridable by Users)
@@ -160,6 +266,18 @@ func (c *Config) IsLocalURL(url string) bool {
 	}
 	return strings.HasPrefix(NormalizeURL(url), NormalizeURL(c.BaseURL))
 }
+
+// OIDCEnabled returns true if users can login with an OpenID Connect provider
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuer != "" && c.OIDCClientID != ""
+}
+
+// PasswordLoginDisabled returns true if the user must login with the pod's
+// OpenID Connect provider rather than their local password
+func (c *Config) PasswordLoginDisabled(user *User) bool {
+	return c.OIDCEnabled() && c.OIDCDisablePasswords && user.OIDCSubject != ""
+}
+
 func (c *Config) LocalURL() *url.URL                    { return c.baseURL }
 func (c *Config) ExternalURL(nick, uri string) string   { return URLForExternalProfile(c, nick, uri) }
 func (c *Config) UserURL(url string) string             { return UserURL(url) }
@@ -171,6 +289,9 @@ func (c *Config) URLForMedia(name string) string        { return URLForMedia(c.B
 // Settings returns a `Settings` struct containing pod settings that can
 // then be persisted to disk to override some configuration options.
 func (c *Config) Settings() *Settings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	settings := &Settings{}
 
 	if err := merger.MergeOverwrite(settings, c); err != nil {
@@ -215,12 +336,148 @@ func (c *Config) BlacklistedFeed(uri string) bool {
 	return false
 }
 
+// DeniedUsername returns true if the username matches any of the pod's denied
+// usernames
+func (c *Config) DeniedUsername(username string) bool {
+	for _, re := range c.deniedUsernames {
+		if re.MatchString(username) {
+			return true
+		}
+	}
+	return false
+}
+
+// DisposableEmail returns true if the email address is at the domain (or a
+// subdomain) of any of the pod's disposable email domains
+func (c *Config) DisposableEmail(email string) bool {
+	idx := strings.LastIndex(email, "@")
+	if idx == -1 {
+		return false
+	}
+	domain := strings.TrimSuffix(strings.ToLower(email[idx+1:]), ".")
+
+	for _, disposable := range c.DisposableEmailDomains {
+		if domain == disposable || strings.HasSuffix(domain, "."+disposable) {
+			return true
+		}
+	}
+	return false
+}
+
+// HiddenTwt returns true if moderators hid the twt pod-wide
+func (c *Config) HiddenTwt(twt types.Twt) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hiddenTwts[twt.Hash()]
+}
+
+// HasHiddenTwts returns true if moderators hid any twts pod-wide
+func (c *Config) HasHiddenTwts() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.hiddenTwts) > 0
+}
+
+// MutedFeed returns true if moderators muted the feed pod-wide
+func (c *Config) MutedFeed(uri string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.mutedFeeds[NormalizeURL(uri)]
+}
+
+// SilencedFeed returns true if the feed belongs to a silenced local user
+func (c *Config) SilencedFeed(uri string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.silencedFeeds[NormalizeURL(uri)]
+}
+
+// SuspendedFeed returns true if the feed belongs to a suspended local user
+func (c *Config) SuspendedFeed(uri string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.suspendedFeeds[NormalizeURL(uri)]
+}
+
+// HideTwt hides the twt with the given hash pod-wide
+func (c *Config) HideTwt(hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if HasString(c.HiddenTwts, hash) {
+		return nil
+	}
+	c.setHiddenTwts(append(c.HiddenTwts[:len(c.HiddenTwts):len(c.HiddenTwts)], hash))
+	return nil
+}
+
+// MuteFeed mutes the feed with the given uri pod-wide
+func (c *Config) MuteFeed(uri string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	uri = NormalizeURL(uri)
+	if HasString(c.MutedFeeds, uri) {
+		return nil
+	}
+	c.setMutedFeeds(append(c.MutedFeeds[:len(c.MutedFeeds):len(c.MutedFeeds)], uri))
+	return nil
+}
+
+// setHiddenTwts sets the hashes of twts hidden pod-wide, mu must be held
+func (c *Config) setHiddenTwts(hiddenTwts []string) {
+	hidden := make(map[string]bool)
+	for _, hash := range hiddenTwts {
+		if hash != "" {
+			hidden[hash] = true
+		}
+	}
+	c.HiddenTwts = hiddenTwts
+	c.hiddenTwts = hidden
+}
+
+// setMutedFeeds sets the uris of feeds muted pod-wide, mu must be held
+func (c *Config) setMutedFeeds(mutedFeeds []string) {
+	muted := make(map[string]bool)
+	for _, uri := range mutedFeeds {
+		if uri = NormalizeURL(uri); uri != "" {
+			muted[uri] = true
+		}
+	}
+	c.MutedFeeds = mutedFeeds
+	c.mutedFeeds = muted
+}
+
+// BlacklistFeed blacklists the feed with the given uri so it is no longer
+// fetched by the global feed cache
+func (c *Config) BlacklistFeed(uri string) error {
+	if c.BlacklistedFeed(uri) {
+		return nil
+	}
+	pattern := fmt.Sprintf("^%s$", regexp.QuoteMeta(uri))
+	return WithBlacklistedFeeds(append(c.BlacklistedFeeds, pattern))(c)
+}
+
 // RandomTwtPrompt returns a random  Twt Prompt for display by the UI
 func (c *Config) RandomTwtPrompt() string {
 	n := rand.Int() % len(c.TwtPrompts)
 	return c.TwtPrompts[n]
 }
 
+// IsTrustedProxy returns true if the address is a trusted reverse proxy
+func (c *Config) IsTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range c.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // Validate validates the configuration is valid which for the most part
 // just ensures that default secrets are actually configured correctly
 func (c *Config) Validate() error {
@@ -236,6 +493,26 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("error applying blacklisted feeds: %w", err)
 	}
 
+	if err := WithDeniedUsernames(c.DeniedUsernames)(c); err != nil {
+		return fmt.Errorf("error applying denied usernames: %w", err)
+	}
+
+	if err := WithDisposableEmailDomains(c.DisposableEmailDomains)(c); err != nil {
+		return fmt.Errorf("error applying disposable email domains: %w", err)
+	}
+
+	if err := WithHiddenTwts(c.HiddenTwts)(c); err != nil {
+		return fmt.Errorf("error applying hidden twts: %w", err)
+	}
+
+	if err := WithMutedFeeds(c.MutedFeeds)(c); err != nil {
+		return fmt.Errorf("error applying muted feeds: %w", err)
+	}
+
+	if err := WithBlocklist(c.Blocklist)(c); err != nil {
+		return fmt.Errorf("error applying blocklist: %w", err)
+	}
+
 	// Automatically correct missing Scheme in Pod Base URL
 	if c.baseURL.Scheme == "" {
 		log.Warnf("pod base url (-u/--base-url) %s is missing the scheme", c.BaseURL)
@@ -56,6 +56,11 @@ type Context struct {
 	AvatarResolution  int
 	MediaResolution   int
 	RegisterDisabled  bool
+	InviteQuota       int
+	RequireApproval   bool
+	RegisterCaptcha   bool
+	SpamHideThreshold float64
+	SpamHoldThreshold float64
 	OpenProfiles      bool
 	DisableMedia      bool
 	DisableFfmpeg     bool
@@ -63,6 +68,14 @@ type Context struct {
 	BlacklistedFeeds  []string
 	EnabledFeatures   []string
 
+	// Registration checks
+	DeniedUsernames        []string
+	DisposableEmailDomains []string
+
+	// OIDCName is the name of the OpenID Connect provider users can login
+	// with or empty if OpenID Connect login is disabled
+	OIDCName string
+
 	Timezones []*timezones.Zoneinfo
 
 	Reply         string
@@ -72,6 +85,7 @@ type Context struct {
 	Profile       types.Profile
 	Authenticated bool
 	IsAdmin       bool
+	Role          Role
 
 	DisplayDatesInTimezone  string
 	DisplayTimePreference   string
@@ -107,6 +121,11 @@ type Context struct {
 	DiscoverUpdatedAt time.Time
 	LastMentionedAt   time.Time
 
+	// Notifications
+	Notifications       []*types.Notification
+	NotificationTwts    map[string]types.Twt
+	UnreadNotifications int
+
 	// Discovered Pods peering with us
 	Peers Peers
 
@@ -122,10 +141,55 @@ type Context struct {
 	// Report abuse
 	ReportNick string
 	ReportURL  string
+	ReportHash string
+
+	// Moderation queue
+	Reports      Reports
+	ReportStatus ReportStatus
+	SpamVerdicts SpamVerdicts
+
+	// Staff and their roles
+	Staff StaffMembers
+
+	// Limited users and the limits that can be placed on users
+	LimitedUsers  []*User
+	AccountLimits []AccountLimit
+
+	// Users pending approval
+	PendingUsers []*User
+
+	// Invites, how many more invites the user can create (-1 is unlimited)
+	// and the code of the invite being registered with
+	Invites     Invites
+	InvitesLeft int
+	InviteCode  string
+
+	// Blocklist
+	Blocklist              []*BlockEntry
+	BlockKinds             []types.BlockKind
+	BlockActions           []types.BlockAction
+	BlocklistSubscriptions []string
+	PublishBlocklist       bool
+
+	// Audit log
+	AuditEntries AuditEntries
+	AuditFilter  AuditFilter
+	AuditActions []AuditAction
 
 	// Reset Password Token
 	PasswordResetToken string
 
+	// Active sessions and API tokens
+	Sessions []types.Session
+
+	// Two-Factor Authentication enrolment
+	TOTPSecret    string
+	TOTPQRCode    template.URL
+	RecoveryCodes []string
+
+	// IndieAuth authorization consent
+	Authorization *AuthorizationRequest
+
 	// CSRF Token
 	CSRFToken string
 
@@ -161,6 +225,11 @@ func NewContext(s *Server, req *http.Request) *Context {
 		AvatarResolution:  conf.AvatarResolution,
 		MediaResolution:   conf.MediaResolution,
 		RegisterDisabled:  !conf.OpenRegistrations,
+		InviteQuota:       conf.InviteQuota,
+		RequireApproval:   conf.RequireApproval,
+		RegisterCaptcha:   conf.RegisterCaptcha,
+		SpamHideThreshold: conf.SpamHideThreshold,
+		SpamHoldThreshold: conf.SpamHoldThreshold,
 		OpenProfiles:      conf.OpenProfiles,
 		DisableMedia:      conf.DisableMedia,
 		DisableFfmpeg:     conf.DisableFfmpeg,
@@ -169,6 +238,9 @@ func NewContext(s *Server, req *http.Request) *Context {
 		BlacklistedFeeds:  conf.BlacklistedFeeds,
 		EnabledFeatures:   conf.Features.AsStrings(),
 
+		DeniedUsernames:        conf.DeniedUsernames,
+		DisposableEmailDomains: conf.DisposableEmailDomains,
+
 		DisplayDatesInTimezone:  conf.DisplayDatesInTimezone,
 		DisplayTimePreference:   conf.DisplayTimePreference,
 		OpenLinksInPreference:   conf.OpenLinksInPreference,
@@ -213,6 +285,10 @@ func NewContext(s *Server, req *http.Request) *Context {
 		CSRFToken: nosurf.Token(req),
 	}
 
+	if conf.OIDCEnabled() {
+		ctx.OIDCName = conf.OIDCName
+	}
+
 	if sess := req.Context().Value(session.SessionKey); sess != nil {
 		if username, ok := sess.(*session.Session).Get("username"); ok {
 			ctx.Authenticated = true
@@ -228,6 +304,7 @@ func NewContext(s *Server, req *http.Request) *Context {
 				}
 				ctx.User = user
 				ctx.IsAdmin = strings.EqualFold(username, conf.AdminUser)
+				ctx.Role = RoleOf(conf, user)
 
 				// Every registered new user follows themselves
 				if user.Following == nil {
@@ -235,9 +312,7 @@ func NewContext(s *Server, req *http.Request) *Context {
 				}
 				user.Following[user.Username] = user.URL
 
-				// TODO: Use event sourcing for this?
-				user.LastSeenAt = time.Now().Round(24 * time.Hour)
-				if err := db.SetUser(user.Username, user); err != nil {
+				if err := TouchUser(db, user, nil, "", time.Now()); err != nil {
 					log.WithError(err).Warnf("error updating user.LastSeenAt for %s", user.Username)
 				}
 			}
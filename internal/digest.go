@@ -0,0 +1,318 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"git.mills.io/yarnsocial/yarn/types"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DigestDaily sends a digest of notifications once a day
+	DigestDaily = "daily"
+
+	// DigestWeekly sends a digest of notifications once a week
+	DigestWeekly = "weekly"
+)
+
+var (
+	// ErrInvalidDigestFrequency is returned for unknown digest frequencies
+	ErrInvalidDigestFrequency = errors.New("error: invalid digest frequency")
+
+	// ErrInvalidDigestEmail is returned when a digest address cannot be
+	// decrypted, e.g: because the pod's MagicLinkSecret was rotated without
+	// keeping the old secret in OldMagicLinkSecrets
+	ErrInvalidDigestEmail = errors.New("error: invalid digest email")
+
+	// digestNotificationTypes are the types of notifications sent in digests
+	digestNotificationTypes = map[types.NotificationType]bool{
+		types.NotificationMention:  true,
+		types.NotificationReply:    true,
+		types.NotificationFollower: true,
+	}
+)
+
+// DigestPeriod returns how often digests of the given frequency are sent
+func DigestPeriod(frequency string) (time.Duration, error) {
+	switch frequency {
+	case DigestDaily:
+		return 24 * time.Hour, nil
+	case DigestWeekly:
+		return 7 * 24 * time.Hour, nil
+	default:
+		return 0, ErrInvalidDigestFrequency
+	}
+}
+
+// emailCipher returns the cipher addresses stored for the given purpose are
+// encrypted with, its key is derived from one of the pod's MagicLinkSecrets
+func emailCipher(secret, purpose string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptEmail(conf *Config, purpose, email string) (string, error) {
+	gcm, err := emailCipher(conf.MagicLinkSecret, purpose)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(email), nil)), nil
+}
+
+// decryptEmail decrypts an address with the pod's MagicLinkSecret or any of
+// its OldMagicLinkSecrets, rotated is true if an old secret was used and the
+// address should be encrypted again with the current secret.
+func decryptEmail(conf *Config, purpose, encrypted string) (email string, rotated bool, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", false, ErrInvalidDigestEmail
+	}
+
+	secrets := append([]string{conf.MagicLinkSecret}, conf.OldMagicLinkSecrets...)
+	for i, secret := range secrets {
+		gcm, err := emailCipher(secret, purpose)
+		if err != nil {
+			return "", false, err
+		}
+		if len(data) < gcm.NonceSize() {
+			return "", false, ErrInvalidDigestEmail
+		}
+
+		if email, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil); err == nil {
+			return string(email), i > 0, nil
+		}
+	}
+	return "", false, ErrInvalidDigestEmail
+}
+
+// EncryptDigestEmail encrypts an address for storage, addresses are only
+// ever stored encrypted and only for users who subscribed to digests.
+func EncryptDigestEmail(conf *Config, email string) (string, error) {
+	return encryptEmail(conf, "digest-email", email)
+}
+
+// DecryptDigestEmail decrypts an address encrypted by EncryptDigestEmail
+func DecryptDigestEmail(conf *Config, encrypted string) (string, error) {
+	email, _, err := decryptEmail(conf, "digest-email", encrypted)
+	return email, err
+}
+
+// RotateDigestEmails encrypts the digest addresses of users that were
+// encrypted with one of the pod's OldMagicLinkSecrets with its current
+// MagicLinkSecret. Subscriptions whose addresses cannot be decrypted with any
+// secret are cancelled, the users have to subscribe to digests again.
+func RotateDigestEmails(conf *Config, db Store) error {
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if user.DigestEmail == "" {
+			continue
+		}
+
+		email, rotated, err := decryptEmail(conf, "digest-email", user.DigestEmail)
+		switch {
+		case err == ErrInvalidDigestEmail:
+			log.Warnf("cancelling digest subscription of %s as its address cannot be decrypted", user.Username)
+			user.UnsubscribeDigest()
+		case err != nil:
+			return err
+		case rotated:
+			if user.DigestEmail, err = EncryptDigestEmail(conf, email); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+
+		if err := db.SetUser(user.Username, user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HasDigest returns true if the user is subscribed to email digests
+func (u *User) HasDigest() bool {
+	return u.DigestEmail != "" && u.DigestFrequency != ""
+}
+
+// SubscribeDigest subscribes the user to digests sent to a verified address
+// (encrypted by EncryptDigestEmail) and issues a new unsubscribe token
+func (u *User) SubscribeDigest(email, frequency string) error {
+	if _, err := DigestPeriod(frequency); err != nil {
+		return err
+	}
+
+	u.DigestEmail = email
+	u.DigestFrequency = frequency
+	u.DigestToken = GenerateRandomToken()
+	u.DigestSentAt = time.Now()
+
+	return nil
+}
+
+// UnsubscribeDigest unsubscribes the user from digests forgetting the
+// user's address
+func (u *User) UnsubscribeDigest() {
+	u.DigestEmail = ""
+	u.DigestFrequency = ""
+	u.DigestToken = ""
+	u.DigestSentAt = time.Time{}
+}
+
+// IsDigestToken returns true if token is the user's unsubscribe token
+func (u *User) IsDigestToken(token string) bool {
+	return u.DigestToken != "" && subtle.ConstantTimeCompare([]byte(u.DigestToken), []byte(token)) == 1
+}
+
+// IsDigestDue returns true if the user's next digest is due
+func (u *User) IsDigestDue(now time.Time) bool {
+	if !u.HasDigest() {
+		return false
+	}
+
+	period, err := DigestPeriod(u.DigestFrequency)
+	if err != nil {
+		return false
+	}
+
+	return !now.Before(u.DigestSentAt.Add(period))
+}
+
+// DigestNotifications returns the unread notifications of mentions, replies
+// and new followers that haven't been sent in a digest yet
+func (n *Notifications) DigestNotifications() []*types.Notification {
+	var items []*types.Notification
+	for _, item := range n.Items {
+		if item.Read || n.Digested[item.ID] || !digestNotificationTypes[item.Type] {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// MarkDigested records the notifications as sent in a digest, notifications
+// no longer in the inbox are forgotten
+func (n *Notifications) MarkDigested(items ...*types.Notification) {
+	digested := make(map[string]bool)
+	for _, item := range n.Items {
+		if n.Digested[item.ID] {
+			digested[item.ID] = true
+		}
+	}
+	for _, item := range items {
+		digested[item.ID] = true
+	}
+	n.Digested = digested
+}
+
+// markNotificationsDigested records the user's notifications as sent in a
+// digest
+func markNotificationsDigested(db Store, username string, items []*types.Notification) error {
+	notificationsMu.Lock()
+	defer notificationsMu.Unlock()
+
+	n, err := db.GetNotifications(username)
+	if err != nil {
+		return err
+	}
+
+	n.MarkDigested(items...)
+
+	return db.SetNotifications(username, n)
+}
+
+// digestActions describe each type of notification in digests
+var digestActions = map[types.NotificationType]string{
+	types.NotificationMention:  "mentioned you",
+	types.NotificationReply:    "replied to your conversation",
+	types.NotificationFollower: "started following you",
+}
+
+// digestItems returns the notifications as they appear in a digest email
+func digestItems(conf *Config, cache *Cache, archive Archiver, notifications []*types.Notification) []DigestItem {
+	twts := lookupNotificationTwts(cache, archive, notifications)
+	isLocalURL := IsLocalURLFactory(conf)
+
+	var items []DigestItem
+	for _, n := range notifications {
+		item := DigestItem{Nick: n.Nick, Action: digestActions[n.Type]}
+
+		switch {
+		case n.Hash != "":
+			item.URL = URLForTwt(conf.BaseURL, n.Hash)
+			if twt, ok := twts[n.Hash]; ok {
+				item.Text = twt.FormatText(types.TextFmt, conf)
+			}
+		case isLocalURL(n.URI):
+			item.URL = strings.TrimSuffix(n.URI, "/twtxt.txt")
+		default:
+			item.URL = URLForExternalProfile(conf, n.Nick, n.URI)
+		}
+
+		items = append(items, item)
+	}
+	return items
+}
+
+// SendDigest sends the user a digest of the notifications they haven't seen
+// since their last digest, no email is sent if there is nothing new.
+func SendDigest(conf *Config, cache *Cache, archive Archiver, db Store, user *User) error {
+	email, err := DecryptDigestEmail(conf, user.DigestEmail)
+	if err != nil {
+		return err
+	}
+
+	n, err := db.GetNotifications(user.Username)
+	if err != nil {
+		return err
+	}
+
+	if notifications := n.DigestNotifications(); len(notifications) > 0 {
+		if err := SendDigestEmail(conf, user, email, digestItems(conf, cache, archive, notifications)); err != nil {
+			return err
+		}
+		if err := markNotificationsDigested(db, user.Username, notifications); err != nil {
+			return err
+		}
+	}
+
+	// Reload the user so changes made whilst sending aren't lost
+	user, err = db.GetUser(user.Username)
+	if err != nil {
+		return err
+	}
+	if !user.HasDigest() {
+		return nil
+	}
+
+	user.DigestSentAt = time.Now()
+
+	return db.SetUser(user.Username, user)
+}
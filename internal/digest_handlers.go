@@ -0,0 +1,239 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// DigestSettingsHandler subscribes the user to email digests by sending a
+// verification link to the given address, changes the frequency of an
+// existing subscription or unsubscribes the user
+func (s *Server) DigestSettingsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		email := strings.TrimSpace(r.FormValue("email"))
+		frequency := r.FormValue("frequency")
+
+		if frequency == "" {
+			user.UnsubscribeDigest()
+
+			if err := s.db.SetUser(ctx.Username, user); err != nil {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+				s.render("error", w, ctx)
+				return
+			}
+
+			ctx.Error = false
+			ctx.Message = s.tr(ctx, "MsgDigestUnsubscribed")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if _, err := DigestPeriod(frequency); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidDigestFrequency")
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Changing the frequency of an existing subscription doesn't need
+		// the address to be verified again
+		if email == "" {
+			if !user.HasDigest() {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorDigestEmailRequired")
+				s.render("error", w, ctx)
+				return
+			}
+
+			user.DigestFrequency = frequency
+
+			if err := s.db.SetUser(ctx.Username, user); err != nil {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+				s.render("error", w, ctx)
+				return
+			}
+
+			ctx.Error = false
+			ctx.Message = s.tr(ctx, "MsgDigestUpdated")
+			s.render("error", w, ctx)
+			return
+		}
+
+		encrypted, err := EncryptDigestEmail(s.config, email)
+		if err != nil {
+			log.WithError(err).Error("error encrypting digest email")
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		// TODO: Make the token expiration configurable.
+		expiryTime := time.Now().Add(30 * time.Minute).Unix()
+
+		// The address is carried encrypted in the token so it is only ever
+		// stored once verified
+		token := jwt.NewWithClaims(
+			jwt.SigningMethodHS256,
+			jwt.MapClaims{
+				"username":  ctx.Username,
+				"email":     encrypted,
+				"frequency": frequency,
+				"expiresAt": expiryTime,
+			},
+		)
+		tokenString, err := token.SignedString([]byte(s.config.MagicLinkSecret))
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = err.Error()
+			s.render("error", w, ctx)
+			return
+		}
+		parts := strings.SplitN(tokenString, ".", 3)
+		tokenCache.Inc(parts[2])
+
+		if err := SendDigestVerificationEmail(s.config, user, email, frequency, tokenString); err != nil {
+			log.WithError(err).Errorf("unable to send digest verification email to %s", user.Username)
+			ctx.Error = true
+			ctx.Message = err.Error()
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgDigestVerificationSent")
+		s.render("error", w, ctx)
+	}
+}
+
+// VerifyDigestHandler subscribes a user to email digests once they followed
+// the verification link sent to their address
+func (s *Server) VerifyDigestHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		tokenString := r.FormValue("token")
+		if tokenString == "" {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidToken")
+			s.render("error", w, ctx)
+			return
+		}
+
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+
+			return []byte(s.config.MagicLinkSecret), nil
+		})
+		if err != nil || tokenCache.Get(token.Signature) == 0 {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidToken")
+			s.render("error", w, ctx)
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || !token.Valid {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidToken")
+			s.render("error", w, ctx)
+			return
+		}
+
+		username, _ := claims["username"].(string)
+		email, _ := claims["email"].(string)
+		frequency, _ := claims["frequency"].(string)
+		expiresAt, _ := claims["expiresAt"].(float64)
+
+		if time.Now().Unix() > int64(expiresAt) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorTokenExpired")
+			s.render("error", w, ctx)
+			return
+		}
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorGetUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := user.SubscribeDigest(email, frequency); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidDigestFrequency")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.db.SetUser(user.Username, user); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+		tokenCache.Dec(token.Signature)
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgDigestSubscribed")
+		s.render("error", w, ctx)
+	}
+}
+
+// UnsubscribeDigestHandler unsubscribes a user from email digests using the
+// link in each digest, a POST unsubscribes immediately so that mail clients
+// can offer one-click unsubscribe (See: RFC 8058) whereas a GET asks the user
+// to confirm so that link scanners don't unsubscribe users.
+func (s *Server) UnsubscribeDigestHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		username := NormalizeUsername(r.FormValue("user"))
+		token := r.FormValue("token")
+
+		user, err := s.db.GetUser(username)
+		if err != nil || !user.IsDigestToken(token) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidToken")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			ctx.Title = s.tr(ctx, "PageDigestUnsubscribeTitle")
+			s.render("digestUnsubscribe", w, ctx)
+			return
+		}
+
+		user.UnsubscribeDigest()
+
+		if err := s.db.SetUser(user.Username, user); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgDigestUnsubscribed")
+		s.render("error", w, ctx)
+	}
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestDigestEmailEncryption(t *testing.T) {
+	conf := &Config{MagicLinkSecret: "secret"}
+
+	encrypted, err := EncryptDigestEmail(conf, "alice@example.com")
+	require.NoError(t, err)
+	assert.NotContains(t, encrypted, "alice")
+
+	email, err := DecryptDigestEmail(conf, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", email)
+
+	_, err = DecryptDigestEmail(&Config{MagicLinkSecret: "rotated"}, encrypted)
+	assert.Equal(t, ErrInvalidDigestEmail, err)
+
+	_, err = DecryptDigestEmail(conf, "garbage")
+	assert.Equal(t, ErrInvalidDigestEmail, err)
+}
+
+func TestRotateDigestEmails(t *testing.T) {
+	api := newTestAPI(t)
+	api.config.MagicLinkSecret = "secret"
+
+	subscribe := func(username, email string) {
+		encrypted, err := EncryptDigestEmail(api.config, email)
+		require.NoError(t, err)
+
+		user := NewUser()
+		user.Username = username
+		require.NoError(t, user.SubscribeDigest(encrypted, DigestDaily))
+		require.NoError(t, api.db.SetUser(username, user))
+	}
+	subscribe("alice", "alice@example.com")
+	subscribe("bob", "bob@example.com")
+
+	// Rotate the secret keeping the old one
+	api.config.MagicLinkSecret = "rotated"
+	api.config.OldMagicLinkSecrets = []string{"secret"}
+	require.NoError(t, RotateDigestEmails(api.config, api.db))
+
+	// Subscriptions encrypted with the old secret are still decrypted, the
+	// old secret is no longer needed once they have been rotated
+	api.config.OldMagicLinkSecrets = nil
+	for username, email := range map[string]string{"alice": "alice@example.com", "bob": "bob@example.com"} {
+		user, err := api.db.GetUser(username)
+		require.NoError(t, err)
+		require.True(t, user.HasDigest())
+
+		decrypted, err := DecryptDigestEmail(api.config, user.DigestEmail)
+		require.NoError(t, err)
+		assert.Equal(t, email, decrypted)
+	}
+
+	// Rotating the secret without the old one cancels subscriptions
+	api.config.MagicLinkSecret = "lost"
+	require.NoError(t, RotateDigestEmails(api.config, api.db))
+
+	alice, err := api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.False(t, alice.HasDigest())
+	assert.Empty(t, alice.DigestEmail)
+}
+
+func TestDigestSubscription(t *testing.T) {
+	user := NewUser()
+	assert.False(t, user.HasDigest())
+	assert.False(t, user.IsDigestDue(time.Now()))
+
+	assert.Equal(t, ErrInvalidDigestFrequency, user.SubscribeDigest("encrypted", "hourly"))
+	require.NoError(t, user.SubscribeDigest("encrypted", DigestWeekly))
+
+	assert.True(t, user.HasDigest())
+	assert.True(t, user.IsDigestToken(user.DigestToken))
+	assert.False(t, user.IsDigestToken(""))
+	assert.False(t, user.IsDigestToken("foo"))
+
+	assert.False(t, user.IsDigestDue(time.Now().Add(6*24*time.Hour)))
+	assert.True(t, user.IsDigestDue(time.Now().Add(7*24*time.Hour)))
+
+	token := user.DigestToken
+	user.UnsubscribeDigest()
+	assert.False(t, user.HasDigest())
+	assert.Empty(t, user.DigestEmail)
+	assert.False(t, user.IsDigestToken(token))
+}
+
+func TestDigestNotifications(t *testing.T) {
+	now := time.Now()
+
+	n := NewNotifications()
+	n.Add(
+		&types.Notification{ID: "mention", Type: types.NotificationMention, CreatedAt: now},
+		&types.Notification{ID: "read", Type: types.NotificationReply, CreatedAt: now, Read: true},
+		&types.Notification{ID: "fork", Type: types.NotificationFork, CreatedAt: now},
+		&types.Notification{ID: "follower", Type: types.NotificationFollower, CreatedAt: now},
+	)
+
+	items := n.DigestNotifications()
+	require.Len(t, items, 2)
+
+	n.MarkDigested(items...)
+	assert.Empty(t, n.DigestNotifications(), "notifications are only sent in one digest")
+
+	n.Add(&types.Notification{ID: "reply", Type: types.NotificationReply, CreatedAt: now.Add(time.Minute)})
+	items = n.DigestNotifications()
+	require.Len(t, items, 1)
+	assert.Equal(t, "reply", items[0].ID)
+}
+
+func TestDigestEmailTemplates(t *testing.T) {
+	ctx := DigestEmailContext{
+		Pod:            "Yarn",
+		BaseURL:        "https://example.com",
+		Username:       "alice",
+		Frequency:      DigestDaily,
+		UnsubscribeURL: "https://example.com/digest/unsubscribe?user=alice&token=foo",
+		Items: []DigestItem{
+			{Nick: "bob", Action: "mentioned you", Text: "Hi <alice>", URL: "https://example.com/twt/abcdefg"},
+		},
+	}
+
+	text := &bytes.Buffer{}
+	require.NoError(t, digestEmailTextTemplate.Execute(text, ctx))
+	assert.Contains(t, text.String(), "- bob mentioned you: Hi <alice>")
+	assert.Contains(t, text.String(), ctx.UnsubscribeURL)
+
+	html := &bytes.Buffer{}
+	require.NoError(t, digestEmailHTMLTemplate.Execute(html, ctx))
+	assert.Contains(t, html.String(), "Hi &lt;alice&gt;")
+	assert.Contains(t, html.String(), `href="https://example.com/digest/unsubscribe?user=alice&amp;token=foo"`)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	htmltemplate "html/template"
 	"strings"
 	"text/template"
 
@@ -78,6 +79,23 @@ Kind regards,
 	{{ .Pod }} Support
 `))
 
+	pendingApprovalEmailTemplate = template.Must(template.New("email").Parse(`Hello {{ .AdminUser }},
+
+{{ .Username }} has registered on {{ .Pod }} and is pending approval.
+{{ if .Reason }}
+Their reason for joining is:
+
+{{ .Reason }}
+{{ end }}
+To approve or reject them visit:
+
+{{ .BaseURL }}/manage/users
+
+Kind regards,
+
+{{ .Pod }} Support
+`))
+
 	reportAbuseEmailTemplate = template.Must(template.New("email").Parse(`Hello {{ .AdminUser }},
 
 {{ .Name }} <{{ .Email }} from {{ .Pod }} has sent the following abuse report:
@@ -90,10 +108,81 @@ The offending user/feed in question is:
 
 - Nick: {{ .Nick }}
 - URL: {{ .URL }}
+{{ if .Hash }}- Twt: {{ .BaseURL }}/twt/{{ .Hash }}
+{{ end }}
+You can review this report in the moderation queue at:
+
+{{ .BaseURL }}/manage/reports
 
 Kind regards,
 
 {{ .Pod }} Support
+`))
+
+	reportReplyEmailTemplate = template.Must(template.New("email").Parse(`Hello {{ .Name }},
+
+Thank you for your abuse report about {{ .Nick }} on {{ .Pod }}, the pod operator has replied:
+
+{{ .Message }}
+
+Kind regards,
+
+{{ .Pod }} Support
+`))
+
+	digestVerificationEmailTemplate = template.Must(template.New("email").Parse(`Hello {{ .Username }},
+
+You have requested to receive {{ .Frequency }} email digests of your mentions, replies and new followers on {{ .Pod }}.
+
+**IMPORTANT:** If this was __NOT__ initiated by you, please ignore this email and you will not receive any digests.
+
+To confirm your subscription, please visit the following link:
+
+{{ .BaseURL }}/digest/verify?token={{ .Token }}
+
+Kind regards,
+
+{{ .Pod }} Support
+`))
+
+	digestEmailTextTemplate = template.Must(template.New("email").Parse(`Hello {{ .Username }},
+
+Here is what you missed on {{ .Pod }}:
+{{ range .Items }}
+- {{ .Nick }} {{ .Action }}{{ with .Text }}: {{ . }}{{ end }}
+  {{ .URL }}
+{{ end }}
+To see all your notifications visit:
+
+{{ .BaseURL }}/notifications
+
+You are receiving this {{ .Frequency }} digest because you subscribed to it in your settings.
+To unsubscribe visit:
+
+{{ .UnsubscribeURL }}
+
+Kind regards,
+
+{{ .Pod }} Support
+`))
+
+	digestEmailHTMLTemplate = htmltemplate.Must(htmltemplate.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body>
+<p>Hello {{ .Username }},</p>
+<p>Here is what you missed on {{ .Pod }}:</p>
+<ul>
+{{ range .Items }}
+<li><strong>{{ .Nick }}</strong> {{ .Action }}{{ with .Text }}: {{ . }}{{ end }} (<a href="{{ .URL }}">view</a>)</li>
+{{ end }}
+</ul>
+<p><a href="{{ .BaseURL }}/notifications">See all your notifications</a></p>
+<p><small>You are receiving this {{ .Frequency }} digest because you subscribed to it in your settings.
+<a href="{{ .UnsubscribeURL }}">Unsubscribe</a></small></p>
+<p>Kind regards,</p>
+<p>{{ .Pod }} Support</p>
+</body>
+</html>
 `))
 )
 
@@ -142,12 +231,23 @@ type CandidatesForDeletionEmailContext struct {
 	Candidates []DeletionCandidate
 }
 
+type PendingApprovalEmailContext struct {
+	Pod       string
+	BaseURL   string
+	AdminUser string
+
+	Username string
+	Reason   string
+}
+
 type ReportAbuseEmailContext struct {
 	Pod       string
+	BaseURL   string
 	AdminUser string
 
 	Nick string
 	URL  string
+	Hash string
 
 	Name     string
 	Email    string
@@ -155,6 +255,42 @@ type ReportAbuseEmailContext struct {
 	Message  string
 }
 
+type ReportReplyEmailContext struct {
+	Pod string
+
+	Name    string
+	Nick    string
+	Message string
+}
+
+type DigestVerificationEmailContext struct {
+	Pod     string
+	BaseURL string
+
+	Token     string
+	Username  string
+	Frequency string
+}
+
+// DigestItem is a notification as it appears in a digest email
+type DigestItem struct {
+	Nick   string
+	Action string
+	Text   string
+	URL    string
+}
+
+type DigestEmailContext struct {
+	Pod     string
+	BaseURL string
+
+	Username       string
+	Frequency      string
+	UnsubscribeURL string
+
+	Items []DigestItem
+}
+
 // indents a block of text with an indent string
 func Indent(text, indent string) string {
 	if text[len(text)-1:] == "\n" {
@@ -179,6 +315,10 @@ func SendEmail(conf *Config, recipients []string, replyTo, subject string, body
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/plain", body)
 
+	return dialAndSend(conf, m)
+}
+
+func dialAndSend(conf *Config, m *mail.Message) error {
 	d := mail.NewDialer(conf.SMTPHost, conf.SMTPPort, conf.SMTPUser, conf.SMTPPass)
 
 	err := d.DialAndSend(m)
@@ -304,7 +444,40 @@ func SendCandidatesForDeletionEmail(conf *Config, candidates []DeletionCandidate
 	return nil
 }
 
-func SendReportAbuseEmail(conf *Config, nick, url, name, email, category, message string) error {
+// SendPendingApprovalEmail tells the pod operator a user has registered and
+// is pending approval
+func SendPendingApprovalEmail(conf *Config, user *User) error {
+	recipients := []string{conf.AdminEmail}
+	emailSubject := fmt.Sprintf(
+		"[%s Pending Approval]: %s",
+		conf.Name, user.Username,
+	)
+	ctx := PendingApprovalEmailContext{
+		Pod:       conf.Name,
+		BaseURL:   conf.BaseURL,
+		AdminUser: conf.AdminUser,
+
+		Username: user.Username,
+	}
+	if user.JoinReason != "" {
+		ctx.Reason = Indent(user.JoinReason, "> ")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := pendingApprovalEmailTemplate.Execute(buf, ctx); err != nil {
+		log.WithError(err).Error("error rendering email template")
+		return err
+	}
+
+	if err := SendEmail(conf, recipients, conf.SMTPFrom, emailSubject, buf.String()); err != nil {
+		log.WithError(err).Errorf("error sending pending approval email to %s", recipients[0])
+		return err
+	}
+
+	return nil
+}
+
+func SendReportAbuseEmail(conf *Config, nick, url, hash, name, email, category, message string) error {
 	recipients := []string{conf.AdminEmail, email}
 	emailSubject := fmt.Sprintf(
 		"[%s Report Abuse]: %s",
@@ -312,10 +485,12 @@ func SendReportAbuseEmail(conf *Config, nick, url, name, email, category, messag
 	)
 	ctx := ReportAbuseEmailContext{
 		Pod:       conf.Name,
+		BaseURL:   conf.BaseURL,
 		AdminUser: conf.AdminUser,
 
 		Nick: nick,
 		URL:  url,
+		Hash: hash,
 
 		Name:     name,
 		Email:    email,
@@ -336,3 +511,113 @@ func SendReportAbuseEmail(conf *Config, nick, url, name, email, category, messag
 
 	return nil
 }
+
+// SendReportReplyEmail sends the pod operator's reply to the reporter of an
+// abuse report
+func SendReportReplyEmail(conf *Config, report *Report, email, message string) error {
+	recipients := []string{email}
+	emailSubject := fmt.Sprintf(
+		"[%s Report Abuse]: Re: %s",
+		conf.Name, report.Category,
+	)
+	ctx := ReportReplyEmailContext{
+		Pod: conf.Name,
+
+		Name:    report.ReporterName,
+		Nick:    report.Nick,
+		Message: Indent(message, "> "),
+	}
+
+	buf := &bytes.Buffer{}
+	if err := reportReplyEmailTemplate.Execute(buf, ctx); err != nil {
+		log.WithError(err).Error("error rendering email template")
+		return err
+	}
+
+	if err := SendEmail(conf, recipients, conf.SMTPFrom, emailSubject, buf.String()); err != nil {
+		log.WithError(err).Errorf("error sending report reply to %s", recipients[0])
+		return err
+	}
+
+	return nil
+}
+
+func SendDigestVerificationEmail(conf *Config, user *User, email, frequency, token string) error {
+	recipients := []string{email}
+	subject := fmt.Sprintf(
+		"[%s]: Confirm Email Digests for %s",
+		conf.Name, user.Username,
+	)
+	ctx := DigestVerificationEmailContext{
+		Pod:     conf.Name,
+		BaseURL: conf.BaseURL,
+
+		Token:     token,
+		Username:  user.Username,
+		Frequency: frequency,
+	}
+
+	buf := &bytes.Buffer{}
+	if err := digestVerificationEmailTemplate.Execute(buf, ctx); err != nil {
+		log.WithError(err).Error("error rendering email template")
+		return err
+	}
+
+	if err := SendEmail(conf, recipients, conf.SMTPFrom, subject, buf.String()); err != nil {
+		log.WithError(err).Errorf("error sending digest verification to %s", user.Username)
+		return err
+	}
+
+	return nil
+}
+
+// SendDigestEmail sends a digest of notifications as a HTML and plain text
+// email with a one-click unsubscribe link (See: RFC 8058)
+func SendDigestEmail(conf *Config, user *User, email string, items []DigestItem) error {
+	subject := fmt.Sprintf(
+		"[%s]: Your %s digest (%d new)",
+		conf.Name, user.DigestFrequency, len(items),
+	)
+	unsubscribeURL := fmt.Sprintf(
+		"%s/digest/unsubscribe?user=%s&token=%s",
+		conf.BaseURL, user.Username, user.DigestToken,
+	)
+	ctx := DigestEmailContext{
+		Pod:     conf.Name,
+		BaseURL: conf.BaseURL,
+
+		Username:       user.Username,
+		Frequency:      user.DigestFrequency,
+		UnsubscribeURL: unsubscribeURL,
+
+		Items: items,
+	}
+
+	text := &bytes.Buffer{}
+	if err := digestEmailTextTemplate.Execute(text, ctx); err != nil {
+		log.WithError(err).Error("error rendering email template")
+		return err
+	}
+
+	html := &bytes.Buffer{}
+	if err := digestEmailHTMLTemplate.Execute(html, ctx); err != nil {
+		log.WithError(err).Error("error rendering email template")
+		return err
+	}
+
+	m := mail.NewMessage()
+	m.SetHeader("From", conf.SMTPFrom)
+	m.SetHeader("To", email)
+	m.SetHeader("Subject", subject)
+	m.SetHeader("List-Unsubscribe", fmt.Sprintf("<%s>", unsubscribeURL))
+	m.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	m.SetBody("text/plain", text.String())
+	m.AddAlternative("text/html", html.String())
+
+	if err := dialAndSend(conf, m); err != nil {
+		log.WithError(err).Errorf("error sending digest to %s", user.Username)
+		return err
+	}
+
+	return nil
+}
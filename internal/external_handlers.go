@@ -115,6 +115,9 @@ func (s *Server) ExternalHandler() httprouter.Handle {
 			NFollowing: ctx.Twter.Following,
 			NFollowers: ctx.Twter.Followers,
 
+			Links:    types.NewLinksFromMetadata(ctx.Twter.Metadata),
+			Metadata: ctx.Twter.Metadata,
+
 			ShowFollowing: true,
 			ShowFollowers: true,
 
@@ -219,6 +222,9 @@ func (s *Server) ExternalFollowingHandler() httprouter.Handle {
 			NFollowing: ctx.Twter.Following,
 			NFollowers: ctx.Twter.Followers,
 
+			Links:    types.NewLinksFromMetadata(ctx.Twter.Metadata),
+			Metadata: ctx.Twter.Metadata,
+
 			ShowFollowing: true,
 			ShowFollowers: true,
 
@@ -194,6 +194,18 @@ func (s *Server) ManageFeedHandler() httprouter.Handle {
 				}
 			}
 
+			if _, ok := r.Form["metadata_key"]; ok {
+				metadata, err := ValidateMetadata(ParseMetadataForm(r))
+				if err != nil {
+					ctx.Error = true
+					trdata["Error"] = err.Error()
+					ctx.Message = s.tr(ctx, "ErrorInvalidMetadata", trdata)
+					s.render("error", w, ctx)
+					return
+				}
+				feed.Metadata = metadata
+			}
+
 			if err := s.db.SetFeed(feed.Name, feed); err != nil {
 				log.WithError(err).Warnf("error updating user object for followee %s", feed.Name)
 
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+const (
+	// maxUserFilters is the maximum number of mute filters a user can have
+	maxUserFilters = 100
+
+	// maxFilterPatternLength is the maximum length of a filter's pattern
+	maxFilterPatternLength = 256
+)
+
+var (
+	// ErrInvalidMuteFilter is returned when adding a filter with an unknown
+	// kind or action, or a missing or invalid pattern
+	ErrInvalidMuteFilter = errors.New("error: invalid mute filter")
+
+	// ErrTooManyMuteFilters is returned when a user has too many filters
+	ErrTooManyMuteFilters = errors.New("error: too many mute filters")
+)
+
+// MuteFilter is a user defined filter that hides twts or collapses them
+// behind a warning by word, hashtag, regular expression or because they reply
+// to muted feeds.
+type MuteFilter struct {
+	types.MuteFilter
+
+	re *regexp.Regexp
+}
+
+// NewMuteFilter returns a new filter expiring after expiresIn or never if
+// expiresIn is zero
+func NewMuteFilter(kind types.MuteFilterKind, pattern string, action types.MuteFilterAction, expiresIn time.Duration) (*MuteFilter, error) {
+	kind = types.MuteFilterKind(strings.ToLower(strings.TrimSpace(string(kind))))
+	action = types.MuteFilterAction(strings.ToLower(strings.TrimSpace(string(action))))
+	pattern = strings.TrimSpace(pattern)
+
+	if action == "" {
+		action = types.MuteFilterHide
+	}
+	if action != types.MuteFilterHide && action != types.MuteFilterCollapse {
+		return nil, ErrInvalidMuteFilter
+	}
+
+	switch kind {
+	case types.MuteFilterWord, types.MuteFilterRegex:
+	case types.MuteFilterHashtag:
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "#"))
+	case types.MuteFilterReplies:
+		pattern = ""
+	default:
+		return nil, ErrInvalidMuteFilter
+	}
+
+	if kind != types.MuteFilterReplies && pattern == "" {
+		return nil, ErrInvalidMuteFilter
+	}
+	if len(pattern) > maxFilterPatternLength || expiresIn < 0 {
+		return nil, ErrInvalidMuteFilter
+	}
+
+	now := time.Now()
+
+	f := &MuteFilter{
+		MuteFilter: types.MuteFilter{
+			ID:        GenerateRandomToken(),
+			Kind:      kind,
+			Pattern:   pattern,
+			Action:    action,
+			CreatedAt: now,
+		},
+	}
+	if expiresIn > 0 {
+		f.ExpiresAt = now.Add(expiresIn)
+	}
+
+	if err := f.compile(); err != nil {
+		return nil, ErrInvalidMuteFilter
+	}
+
+	return f, nil
+}
+
+// compile compiles the regular expression word and regex filters match the
+// twt's text with
+func (f *MuteFilter) compile() (err error) {
+	switch f.Kind {
+	case types.MuteFilterWord:
+		f.re, err = regexp.Compile(fmt.Sprintf(`(?i)(^|\W)%s($|\W)`, regexp.QuoteMeta(f.Pattern)))
+	case types.MuteFilterRegex:
+		f.re, err = regexp.Compile(f.Pattern)
+	}
+	return
+}
+
+// IsExpired returns true if the filter has expired
+func (f *MuteFilter) IsExpired() bool {
+	return !f.ExpiresAt.IsZero() && time.Now().After(f.ExpiresAt)
+}
+
+// Match returns true if the filter matches the twt, text returns the twt's
+// plain text and is only called for filters that need it
+func (f *MuteFilter) Match(u *User, twt types.Twt, text func() string) bool {
+	switch f.Kind {
+	case types.MuteFilterWord, types.MuteFilterRegex:
+		return f.re != nil && f.re.MatchString(text())
+	case types.MuteFilterHashtag:
+		for _, tag := range twt.Tags() {
+			if strings.EqualFold(tag.Text(), f.Pattern) {
+				return true
+			}
+		}
+	case types.MuteFilterReplies:
+		for _, mention := range twt.Mentions() {
+			if u.HasMuted(mention.Twter().URI) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MuteFilters is a list of filters sorted by most recently created
+type MuteFilters []*MuteFilter
+
+func (filters MuteFilters) Len() int { return len(filters) }
+func (filters MuteFilters) Less(i, j int) bool {
+	return filters[i].CreatedAt.After(filters[j].CreatedAt)
+}
+func (filters MuteFilters) Swap(i, j int) { filters[i], filters[j] = filters[j], filters[i] }
+
+// AsMuteFilters returns the filters as they are represented in the API
+func (filters MuteFilters) AsMuteFilters() []types.MuteFilter {
+	res := make([]types.MuteFilter, len(filters))
+	for i, f := range filters {
+		res[i] = f.MuteFilter
+	}
+	return res
+}
+
+// AddMuteFilter adds a filter for the user pruning expired filters
+func (u *User) AddMuteFilter(f *MuteFilter) error {
+	if u.Filters == nil {
+		u.Filters = make(map[string]*MuteFilter)
+	}
+
+	for id, filter := range u.Filters {
+		if filter.IsExpired() {
+			delete(u.Filters, id)
+		}
+	}
+
+	if len(u.Filters) >= maxUserFilters {
+		return ErrTooManyMuteFilters
+	}
+
+	u.Filters[f.ID] = f
+	return nil
+}
+
+// RemoveMuteFilter removes the user's filter with the given ID returning
+// false if the user has no such filter
+func (u *User) RemoveMuteFilter(id string) bool {
+	if _, ok := u.Filters[id]; !ok {
+		return false
+	}
+	delete(u.Filters, id)
+	return true
+}
+
+// GetMuteFilters returns the user's unexpired filters
+func (u *User) GetMuteFilters() MuteFilters {
+	var filters MuteFilters
+	for _, f := range u.Filters {
+		if !f.IsExpired() {
+			filters = append(filters, f)
+		}
+	}
+	sort.Sort(filters)
+	return filters
+}
+
+// MatchMuteFilter returns the user's unexpired filter matching the twt or nil
+// if no filter matches, filters that hide twts take precedence over filters
+// that collapse them
+func (u *User) MatchMuteFilter(twt types.Twt) *MuteFilter {
+	var (
+		text    string
+		matched *MuteFilter
+	)
+
+	textOf := func() string {
+		if text == "" {
+			text = twt.FormatText(types.TextFmt, nil)
+		}
+		return text
+	}
+
+	for _, f := range u.Filters {
+		if f.IsExpired() || !f.Match(u, twt, textOf) {
+			continue
+		}
+		if f.Action == types.MuteFilterHide {
+			return f
+		}
+		matched = f
+	}
+
+	return matched
+}
+
+// CollapsedBy returns the user's filter that collapses the twt behind a
+// warning or nil if the twt is not collapsed
+func (u *User) CollapsedBy(twt types.Twt) *MuteFilter {
+	if f := u.MatchMuteFilter(twt); f != nil && f.Action == types.MuteFilterCollapse {
+		return f
+	}
+	return nil
+}
+
+// CollapsedTwts returns the filters that collapse any of the twts keyed by
+// the twt's hash
+func CollapsedTwts(user *User, twts types.Twts) map[string]types.MuteFilter {
+	if user == nil || len(user.Filters) == 0 {
+		return nil
+	}
+
+	collapsed := make(map[string]types.MuteFilter)
+	for _, twt := range twts {
+		if f := user.CollapsedBy(twt); f != nil {
+			collapsed[twt.Hash()] = f.MuteFilter
+		}
+	}
+	return collapsed
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestNewMuteFilter(t *testing.T) {
+	testCases := []struct {
+		kind    types.MuteFilterKind
+		pattern string
+		action  types.MuteFilterAction
+		valid   bool
+	}{
+		{"word", "spoilers", "hide", true},
+		{"WORD", " spoilers ", "", true},
+		{"hashtag", "#Politics", "collapse", true},
+		{"regex", `(?i)crypto\w*`, "hide", true},
+		{"replies", "", "collapse", true},
+		{"word", "", "hide", false},
+		{"hashtag", "#", "hide", false},
+		{"regex", "(unclosed", "hide", false},
+		{"keyword", "spoilers", "hide", false},
+		{"word", "spoilers", "delete", false},
+	}
+
+	for _, testCase := range testCases {
+		_, err := NewMuteFilter(testCase.kind, testCase.pattern, testCase.action, 0)
+		assert.Equal(t, testCase.valid, err == nil, "%s %q %s", testCase.kind, testCase.pattern, testCase.action)
+	}
+
+	f, err := NewMuteFilter("hashtag", "#Politics", "", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, types.MuteFilterHashtag, f.Kind)
+	assert.Equal(t, "politics", f.Pattern)
+	assert.Equal(t, types.MuteFilterHide, f.Action)
+	assert.False(t, f.ExpiresAt.IsZero())
+
+	_, err = NewMuteFilter("word", "spoilers", "hide", -time.Hour)
+	assert.Equal(t, ErrInvalidMuteFilter, err)
+}
+
+func TestUserMuteFilters(t *testing.T) {
+	bob := types.Twter{Nick: "bob", URI: "https://example.com/bob.txt"}
+	carol := types.Twter{Nick: "carol", URI: "https://example.com/carol.txt"}
+
+	now := time.Now()
+	spoiler := types.MakeTwt(bob, now, "The butler did it! #spoilers")
+	spoilerWord := types.MakeTwt(bob, now, "No SPOILERS please")
+	notSpoiler := types.MakeTwt(bob, now, "nospoilers here")
+	crypto := types.MakeTwt(bob, now, "Buy Cryptocurrency now")
+	reply := types.MakeTwt(bob, now, fmt.Sprintf("@<carol %s> Hi!", carol.URI))
+	other := types.MakeTwt(bob, now, "Hello World!")
+	twts := types.Twts{spoiler, spoilerWord, notSpoiler, crypto, reply, other}
+
+	hashes := func(twts ...types.Twt) (res []string) {
+		for _, twt := range twts {
+			res = append(res, twt.Hash())
+		}
+		return
+	}
+
+	user := NewUser()
+	user.Username = "alice"
+	assert.Len(t, user.Filter(twts), len(twts))
+
+	add := func(kind types.MuteFilterKind, pattern string, action types.MuteFilterAction) *MuteFilter {
+		f, err := NewMuteFilter(kind, pattern, action, 0)
+		require.NoError(t, err)
+		require.NoError(t, user.AddMuteFilter(f))
+		return f
+	}
+
+	add("hashtag", "spoilers", "collapse")
+	add("word", "spoilers", "hide")
+	add("regex", `(?i)crypto\w*`, "collapse")
+	add("replies", "", "hide")
+
+	// Replies only match once the mentioned feed is muted
+	assert.Equal(t, hashes(notSpoiler, crypto, reply, other), hashes(user.Filter(twts)...))
+	user.Mute(carol.Nick, carol.URI)
+	assert.Equal(t, hashes(notSpoiler, crypto, other), hashes(user.Filter(twts)...))
+
+	assert.Nil(t, user.CollapsedBy(other))
+	require.NotNil(t, user.CollapsedBy(crypto))
+	assert.Equal(t, types.MuteFilterRegex, user.CollapsedBy(crypto).Kind)
+	assert.Nil(t, user.CollapsedBy(spoiler), "hide filters take precedence over collapse filters")
+
+	collapsed := CollapsedTwts(user, types.Twts{notSpoiler, crypto, other})
+	assert.Len(t, collapsed, 1)
+	assert.Equal(t, types.MuteFilterRegex, collapsed[crypto.Hash()].Kind)
+
+	// Filters survive a round trip through the store
+	data, err := user.Bytes()
+	require.NoError(t, err)
+	loaded, err := LoadUser(data)
+	require.NoError(t, err)
+	assert.Len(t, loaded.GetMuteFilters(), 4)
+	assert.Equal(t, hashes(notSpoiler, crypto, other), hashes(loaded.Filter(twts)...))
+
+	// Expired filters no longer apply
+	for _, f := range user.Filters {
+		f.ExpiresAt = now.Add(-time.Minute)
+	}
+	assert.Empty(t, user.GetMuteFilters())
+	assert.Equal(t, hashes(twts...), hashes(user.Filter(twts)...))
+}
+
+func TestMuteFilterEndpoints(t *testing.T) {
+	api := newTestAPI(t)
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	var res types.MuteFiltersResponse
+	assert.Equal(t, http.StatusBadRequest, callEndpoint(t, api.AddMuteFilterEndpoint(), user, http.MethodPost, types.AddMuteFilterRequest{Kind: "regex", Pattern: "("}, &res))
+
+	req := types.AddMuteFilterRequest{Kind: "word", Pattern: "spoilers", Action: "collapse", ExpiresIn: 3600}
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.AddMuteFilterEndpoint(), user, http.MethodPost, req, &res))
+	require.Len(t, res.Filters, 1)
+	assert.Equal(t, types.MuteFilterWord, res.Filters[0].Kind)
+	assert.Equal(t, types.MuteFilterCollapse, res.Filters[0].Action)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), res.Filters[0].ExpiresAt, time.Minute)
+
+	saved, err := api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Len(t, saved.GetMuteFilters(), 1)
+
+	res = types.MuteFiltersResponse{}
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.MuteFiltersEndpoint(), saved, http.MethodPost, nil, &res))
+	require.Len(t, res.Filters, 1)
+
+	id := res.Filters[0].ID
+	assert.Equal(t, http.StatusNotFound, callEndpoint(t, api.RemoveMuteFilterEndpoint(), saved, http.MethodPost, types.RemoveMuteFilterRequest{ID: "invalid"}, nil))
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.RemoveMuteFilterEndpoint(), saved, http.MethodPost, types.RemoveMuteFilterRequest{ID: id}, nil))
+
+	saved, err = api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Empty(t, saved.GetMuteFilters())
+}
@@ -54,6 +54,12 @@ func (s *Server) UserConfigHandler() httprouter.Handle {
 
 		nick = NormalizeUsername(nick)
 
+		// The feeds of suspended users are gone whilst they are suspended
+		if s.config.SuspendedFeed(URLForUser(s.config.BaseURL, nick)) {
+			http.Error(w, "Feed Gone", http.StatusGone)
+			return
+		}
+
 		var (
 			url       string
 			following map[string]string
@@ -385,6 +391,12 @@ func (s *Server) SyndicationHandler() httprouter.Handle {
 
 		nick := NormalizeUsername(p.ByName("nick"))
 		if nick != "" {
+			// The feeds of suspended users are gone whilst they are suspended
+			if s.config.SuspendedFeed(URLForUser(s.config.BaseURL, nick)) {
+				http.Error(w, "Feed Gone", http.StatusGone)
+				return
+			}
+
 			if s.db.HasUser(nick) {
 				if user, err := s.db.GetUser(nick); err == nil {
 					profile = user.Profile(s.config.BaseURL, nil)
@@ -665,6 +677,10 @@ func (s *Server) DeleteHandler() httprouter.Handle {
 			return
 		}
 
+		if err := s.db.DelNotifications(ctx.Username); err != nil {
+			log.WithError(err).Warnf("error deleting notifications for %s", ctx.Username)
+		}
+
 		// Delete user's feed from cache
 		s.cache.DeleteFeeds(ctx.User.Source())
 
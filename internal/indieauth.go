@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	// authorizationCodeTTL is how long an authorization code issued by the
+	// IndieAuth authorization endpoint can be exchanged for
+	authorizationCodeTTL = 10 * time.Minute
+
+	// codeChallengeMethodS256 is the only PKCE code challenge method supported
+	codeChallengeMethodS256 = "S256"
+
+	// ScopeProfile is the IndieAuth scope requesting the user's profile URL
+	// only, it grants no access to the API on its own
+	ScopeProfile = "profile"
+)
+
+var (
+	// ErrInvalidClientID is returned for IndieAuth client identifiers that
+	// are not valid URLs
+	ErrInvalidClientID = errors.New("error: invalid client_id")
+
+	// ErrInvalidRedirectURI is returned for redirect URIs that do not belong
+	// to the client
+	ErrInvalidRedirectURI = errors.New("error: invalid redirect_uri")
+
+	// ErrInvalidCodeChallenge is returned for authorization requests without
+	// a supported PKCE code challenge
+	ErrInvalidCodeChallenge = errors.New("error: invalid code_challenge")
+
+	// ErrInvalidGrant is returned when exchanging an authorization code that
+	// is unknown, expired, already used or was issued to another client
+	ErrInvalidGrant = errors.New("error: invalid authorization code")
+)
+
+// IndieAuthMetadata is the authorization server metadata (RFC 8414) clients
+// discover from the `indieauth-metadata` link of a user's profile URL
+type IndieAuthMetadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	ScopesSupported               []string `json:"scopes_supported"`
+	ResponseTypesSupported        []string `json:"response_types_supported"`
+	GrantTypesSupported           []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+	AuthorizationResponseISS      bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+// IndieAuthProfileResponse is returned when redeeming an authorization code
+// for the user's profile URL only
+type IndieAuthProfileResponse struct {
+	Me string `json:"me"`
+}
+
+// IndieAuthTokenResponse is returned by the token endpoint when exchanging
+// an authorization code for an access token
+type IndieAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Scope       string `json:"scope"`
+	Me          string `json:"me"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// IndieAuthErrorResponse is an OAuth2 error response
+type IndieAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// AuthorizationRequest is an IndieAuth authorization request from a client
+// asking for the user's identity and optionally access to the API
+type AuthorizationRequest struct {
+	ClientID      string
+	RedirectURI   string
+	State         string
+	CodeChallenge string
+	Me            string
+	Scopes        []TokenScope
+}
+
+// AuthorizationCode is a single use code issued to a client once the user
+// approves its AuthorizationRequest
+type AuthorizationCode struct {
+	Username      string
+	ClientID      string
+	RedirectURI   string
+	CodeChallenge string
+	Scopes        []TokenScope
+}
+
+// AuthorizationCodes holds the authorization codes that have been issued
+// but not yet exchanged
+type AuthorizationCodes struct {
+	codes *cache.Cache
+}
+
+// NewAuthorizationCodes ...
+func NewAuthorizationCodes() *AuthorizationCodes {
+	return &AuthorizationCodes{
+		codes: cache.New(authorizationCodeTTL, time.Minute*5),
+	}
+}
+
+// Issue returns a new authorization code for the user's approved request
+func (ac *AuthorizationCodes) Issue(username string, req *AuthorizationRequest) string {
+	code := GenerateRandomToken()
+	ac.codes.Set(code, &AuthorizationCode{
+		Username:      username,
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		Scopes:        req.Scopes,
+	}, cache.DefaultExpiration)
+	return code
+}
+
+// Exchange redeems an authorization code verifying that it was issued to the
+// same client and redirect URI and that the PKCE code verifier matches its
+// code challenge. Codes can only be exchanged once, even unsuccessfully.
+func (ac *AuthorizationCodes) Exchange(code, clientID, redirectURI, codeVerifier string) (*AuthorizationCode, error) {
+	val, ok := ac.codes.Get(code)
+	if !ok || code == "" {
+		return nil, ErrInvalidGrant
+	}
+	ac.codes.Delete(code)
+
+	authCode := val.(*AuthorizationCode)
+	if authCode.ClientID != clientID || authCode.RedirectURI != redirectURI {
+		return nil, ErrInvalidGrant
+	}
+	if !VerifyCodeChallenge(authCode.CodeChallenge, codeVerifier) {
+		return nil, ErrInvalidGrant
+	}
+
+	return authCode, nil
+}
+
+// ParseAuthorizationRequest parses and validates an IndieAuth authorization
+// request. Errors for the client_id and redirect_uri must be shown to the
+// user as the client cannot safely be redirected back to.
+func ParseAuthorizationRequest(form url.Values) (*AuthorizationRequest, error) {
+	req := &AuthorizationRequest{
+		ClientID:      strings.TrimSpace(form.Get("client_id")),
+		RedirectURI:   strings.TrimSpace(form.Get("redirect_uri")),
+		State:         form.Get("state"),
+		CodeChallenge: form.Get("code_challenge"),
+		Me:            form.Get("me"),
+		Scopes:        ParseIndieAuthScopes(form.Get("scope")),
+	}
+
+	if err := ValidateClientID(req.ClientID); err != nil {
+		return nil, err
+	}
+	if err := ValidateRedirectURI(req.ClientID, req.RedirectURI); err != nil {
+		return nil, err
+	}
+
+	if form.Get("code_challenge_method") != codeChallengeMethodS256 || req.CodeChallenge == "" {
+		return req, ErrInvalidCodeChallenge
+	}
+
+	return req, nil
+}
+
+// ParseIndieAuthScopes parses the space separated scopes of an IndieAuth
+// request. Unknown scopes are ignored as clients may ask for more than we
+// support and the admin scope is never granted to third-party clients.
+func ParseIndieAuthScopes(scope string) []TokenScope {
+	seen := make(map[TokenScope]bool)
+
+	var scopes []TokenScope
+	for _, s := range strings.Fields(scope) {
+		scope := TokenScope(strings.ToLower(s))
+		switch scope {
+		case ScopeRead, ScopePost, ScopeFollow:
+		default:
+			continue
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// FormatIndieAuthScopes formats scopes as the space separated scope of an
+// IndieAuth response
+func FormatIndieAuthScopes(scopes []TokenScope) string {
+	var s []string
+	for _, scope := range scopes {
+		s = append(s, string(scope))
+	}
+	return strings.Join(s, " ")
+}
+
+// ValidateClientID checks that an IndieAuth client_id is a URL with a http
+// or https scheme and a host name as required by the IndieAuth spec
+func ValidateClientID(clientID string) error {
+	u, err := url.Parse(clientID)
+	if err != nil || clientID == "" {
+		return ErrInvalidClientID
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return ErrInvalidClientID
+	}
+	if u.Host == "" || u.User != nil || u.Fragment != "" {
+		return ErrInvalidClientID
+	}
+	if u.Path != "" {
+		for _, segment := range strings.Split(u.Path, "/") {
+			if segment == "." || segment == ".." {
+				return ErrInvalidClientID
+			}
+		}
+	}
+
+	// IP addresses other than the loopback addresses are not allowed
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !ip.IsLoopback() {
+		return ErrInvalidClientID
+	}
+
+	return nil
+}
+
+// ValidateRedirectURI checks that redirectURI has the same scheme and host
+// as the client's client_id so authorization codes are only ever sent back
+// to the client that asked for them
+func ValidateRedirectURI(clientID, redirectURI string) error {
+	client, err := url.Parse(clientID)
+	if err != nil {
+		return ErrInvalidClientID
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil || redirectURI == "" {
+		return ErrInvalidRedirectURI
+	}
+
+	if u.Scheme != client.Scheme || !strings.EqualFold(u.Host, client.Host) || u.Fragment != "" {
+		return ErrInvalidRedirectURI
+	}
+
+	return nil
+}
+
+// VerifyCodeChallenge returns true if the PKCE code verifier matches the S256
+// code challenge
+func VerifyCodeChallenge(codeChallenge, codeVerifier string) bool {
+	if codeChallenge == "" || codeVerifier == "" {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(codeVerifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(codeChallenge)) == 1
+}
+
+// AuthorizationRedirectURL returns the redirect URI with the parameters of
+// an authorization response added to its query
+func AuthorizationRedirectURL(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	q := u.Query()
+	for k, vs := range params {
+		for _, v := range vs {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
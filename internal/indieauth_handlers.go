@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// indieAuthLinks returns the HTTP Link header values and HTML links clients
+// use to discover the pod's IndieAuth endpoints from a user's profile URL
+func (s *Server) indieAuthLinks() Links {
+	return Links{
+		{Href: fmt.Sprintf("%s/.well-known/oauth-authorization-server", s.config.BaseURL), Rel: "indieauth-metadata"},
+		{Href: fmt.Sprintf("%s/indieauth/auth", s.config.BaseURL), Rel: "authorization_endpoint"},
+		{Href: fmt.Sprintf("%s/indieauth/token", s.config.BaseURL), Rel: "token_endpoint"},
+	}
+}
+
+// writeIndieAuthJSON writes an IndieAuth JSON response which must never be
+// cached as it may contain an access token
+func writeIndieAuthJSON(w http.ResponseWriter, status int, res interface{}) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		log.WithError(err).Error("error serializing indieauth response")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// redirectAuthorizationError redirects back to the client with an OAuth2
+// error response for its authorization request
+func (s *Server) redirectAuthorizationError(w http.ResponseWriter, r *http.Request, req *AuthorizationRequest, code, description string) {
+	params := url.Values{}
+	params.Set("error", code)
+	params.Set("error_description", description)
+	params.Set("iss", s.config.BaseURL)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	http.Redirect(w, r, AuthorizationRedirectURL(req.RedirectURI, params), http.StatusFound)
+}
+
+// IndieAuthMetadataHandler ...
+func (s *Server) IndieAuthMetadataHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		writeIndieAuthJSON(w, http.StatusOK, IndieAuthMetadata{
+			Issuer:                        s.config.BaseURL,
+			AuthorizationEndpoint:         fmt.Sprintf("%s/indieauth/auth", s.config.BaseURL),
+			TokenEndpoint:                 fmt.Sprintf("%s/indieauth/token", s.config.BaseURL),
+			ScopesSupported:               []string{ScopeProfile, string(ScopeRead), string(ScopePost), string(ScopeFollow)},
+			ResponseTypesSupported:        []string{"code"},
+			GrantTypesSupported:           []string{"authorization_code"},
+			CodeChallengeMethodsSupported: []string{codeChallengeMethodS256},
+			AuthorizationResponseISS:      true,
+		})
+	}
+}
+
+// AuthorizationHandler shows the consent screen for an IndieAuth
+// authorization request asking anonymous users to login first
+func (s *Server) AuthorizationHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		req, err := ParseAuthorizationRequest(r.URL.Query())
+		if err == ErrInvalidClientID || err == ErrInvalidRedirectURI {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidAuthorizationRequest")
+			s.render("error", w, ctx)
+			return
+		}
+		if err != nil {
+			s.redirectAuthorizationError(w, r, req, "invalid_request", "code_challenge with code_challenge_method S256 is required")
+			return
+		}
+
+		if r.URL.Query().Get("response_type") != "code" {
+			s.redirectAuthorizationError(w, r, req, "unsupported_response_type", "only the code response_type is supported")
+			return
+		}
+
+		if !ctx.Authenticated {
+			ctx.Title = s.tr(ctx, "LoginTitle")
+			ctx.Referer = r.URL.RequestURI()
+			s.render("login", w, ctx)
+			return
+		}
+
+		ctx.Title = s.tr(ctx, "PageAuthorizeTitle")
+		ctx.Authorization = req
+		ctx.Profile = ctx.User.Profile(s.config.BaseURL, ctx.User)
+		s.render("authorize", w, ctx)
+	}
+}
+
+// ConsentHandler issues an authorization code to the client if the user
+// approves its request and redirects back to the client
+func (s *Server) ConsentHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if err := r.ParseForm(); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidAuthorizationRequest")
+			s.render("error", w, ctx)
+			return
+		}
+
+		// The user may have unchecked some of the requested scopes
+		form := r.PostForm
+		form.Set("scope", strings.Join(form["scope"], " "))
+
+		req, err := ParseAuthorizationRequest(form)
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidAuthorizationRequest")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if r.FormValue("approve") != "true" {
+			s.redirectAuthorizationError(w, r, req, "access_denied", "the user denied the request")
+			return
+		}
+
+		params := url.Values{}
+		params.Set("code", s.codes.Issue(ctx.Username, req))
+		params.Set("iss", s.config.BaseURL)
+		if req.State != "" {
+			params.Set("state", req.State)
+		}
+
+		http.Redirect(w, r, AuthorizationRedirectURL(req.RedirectURI, params), http.StatusFound)
+	}
+}
+
+// ProfileCodeHandler redeems an authorization code for the user's profile
+// URL only for clients that use IndieAuth to sign users in
+func (s *Server) ProfileCodeHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if r.FormValue("grant_type") != "authorization_code" {
+			writeIndieAuthJSON(w, http.StatusBadRequest, IndieAuthErrorResponse{Error: "unsupported_grant_type"})
+			return
+		}
+
+		code, err := s.codes.Exchange(r.FormValue("code"), r.FormValue("client_id"), r.FormValue("redirect_uri"), r.FormValue("code_verifier"))
+		if err != nil || !s.db.HasUser(code.Username) {
+			writeIndieAuthJSON(w, http.StatusBadRequest, IndieAuthErrorResponse{Error: "invalid_grant"})
+			return
+		}
+
+		writeIndieAuthJSON(w, http.StatusOK, IndieAuthProfileResponse{
+			Me: URLForUser(s.config.BaseURL, code.Username),
+		})
+	}
+}
+
+// TokenHandler exchanges an authorization code for a scoped API token
+func (s *Server) TokenHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if r.FormValue("grant_type") != "authorization_code" {
+			writeIndieAuthJSON(w, http.StatusBadRequest, IndieAuthErrorResponse{Error: "unsupported_grant_type"})
+			return
+		}
+
+		code, err := s.codes.Exchange(r.FormValue("code"), r.FormValue("client_id"), r.FormValue("redirect_uri"), r.FormValue("code_verifier"))
+		if err != nil {
+			writeIndieAuthJSON(w, http.StatusBadRequest, IndieAuthErrorResponse{Error: "invalid_grant"})
+			return
+		}
+
+		if len(code.Scopes) == 0 {
+			writeIndieAuthJSON(w, http.StatusBadRequest, IndieAuthErrorResponse{
+				Error:            "invalid_grant",
+				ErrorDescription: "the authorization code was issued without any scope",
+			})
+			return
+		}
+
+		user, err := s.db.GetUser(code.Username)
+		if err != nil {
+			writeIndieAuthJSON(w, http.StatusBadRequest, IndieAuthErrorResponse{Error: "invalid_grant"})
+			return
+		}
+
+		token, err := s.api.CreateClientToken(user, r, code.Scopes, code.ClientID)
+		if err != nil {
+			log.WithError(err).Errorf("error creating token for %s", user.Username)
+			writeIndieAuthJSON(w, http.StatusInternalServerError, IndieAuthErrorResponse{Error: "server_error"})
+			return
+		}
+
+		writeIndieAuthJSON(w, http.StatusOK, IndieAuthTokenResponse{
+			AccessToken: token.Value,
+			TokenType:   "Bearer",
+			Scope:       FormatIndieAuthScopes(token.Scopes),
+			Me:          URLForUser(s.config.BaseURL, user.Username),
+			ExpiresIn:   int64(token.ExpiresAt.Sub(token.CreatedAt).Seconds()),
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// CreateInviteHandler creates an invite for the user to share, staff who
+// manage invites choose how many times it can be used and when it expires
+func (s *Server) CreateInviteHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		maxUses := SafeParseInt(r.FormValue("maxUses"), 1)
+
+		// An empty expiry creates an invite that does not expire (staff only)
+		var expiresIn time.Duration
+		if expiry := strings.TrimSpace(r.FormValue("expiry")); expiry != "" {
+			d, err := time.ParseDuration(expiry)
+			if err != nil || d <= 0 {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorInvalidInviteExpiry")
+				s.render("error", w, ctx)
+				return
+			}
+			expiresIn = d
+		}
+
+		invite, err := CreateInvite(s.config, s.db, ctx.User, maxUses, expiresIn)
+		if err != nil {
+			log.WithError(err).Errorf("error creating invite for %s", ctx.Username)
+			ctx.Error = true
+			if err == ErrInviteQuotaExceeded {
+				ctx.Message = s.tr(ctx, "ErrorInviteQuotaExceeded")
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorCreatingInvite")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if hasPermission(ctx.User, PermissionManageInvites) {
+			after := fmt.Sprintf("max uses %d", invite.MaxUses)
+			if !invite.ExpiresAt.IsZero() {
+				after = fmt.Sprintf("%s until %s", after, invite.ExpiresAt.UTC().Format(time.RFC3339))
+			}
+			Audit(s.db, ctx.Username, AuditCreateInvite, invite.Code, "", after, "")
+		}
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgInviteCreated", map[string]interface{}{
+			"URL": invite.URL(s.config.BaseURL),
+		})
+		s.render("error", w, ctx)
+	}
+}
+
+// RevokeInviteHandler revokes one of the user's invites, staff who manage
+// invites can revoke anyone's invites
+func (s *Server) RevokeInviteHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		code := strings.TrimSpace(r.FormValue("code"))
+
+		invite, err := RevokeInvite(s.config, s.db, ctx.User, code)
+		if err != nil {
+			log.WithError(err).Errorf("error revoking invite %s", code)
+			ctx.Error = true
+			switch err {
+			case ErrPermissionDenied:
+				ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			case ErrInviteNotFound:
+				ctx.Message = s.tr(ctx, "ErrorInvalidInvite")
+			default:
+				ctx.Message = s.tr(ctx, "ErrorRevokingInvite")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if hasPermission(ctx.User, PermissionManageInvites) {
+			Audit(s.db, ctx.Username, AuditRevokeInvite, invite.Code, invite.CreatedBy, "", "")
+		}
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgInviteRevoked")
+		s.render("error", w, ctx)
+	}
+}
+
+// ManageInvitesHandler lists every invite, who created it and who registered
+// with it
+func (s *Server) ManageInvitesHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionManageInvites) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		invites, err := s.db.GetAllInvites()
+		if err != nil {
+			log.WithError(err).Error("error loading invites")
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorLoadingInvites")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.Title = s.tr(ctx, "ManageInvitesTitle")
+		ctx.Invites = invites
+		ctx.InvitesLeft = -1
+		s.render("manageInvites", w, ctx)
+	}
+}
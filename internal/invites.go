@@ -0,0 +1,278 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInviteNotFound is returned when looking up an invite that does not
+	// exist
+	ErrInviteNotFound = errors.New("error: invite not found")
+
+	// ErrInvalidInvite is returned when registering with an invite that does
+	// not exist, has expired or has been used up
+	ErrInvalidInvite = errors.New("error: invalid or expired invite")
+
+	// ErrInviteQuotaExceeded is returned when a user creates more invites than
+	// the pod's invite quota allows
+	ErrInviteQuotaExceeded = errors.New("error: invite quota exceeded")
+)
+
+// Invite is an invite code that lets people register on the pod when open
+// registrations are disabled, invites are tracked to see who invited whom
+type Invite struct {
+	Code      string
+	CreatedBy string
+	CreatedAt time.Time
+
+	// ExpiresAt is when the invite expires or the zero time if it does not
+	ExpiresAt time.Time
+
+	// MaxUses is how many people can register with the invite, zero is
+	// unlimited
+	MaxUses int
+
+	// UsedBy are the usernames of the users who registered with the invite
+	UsedBy []string
+}
+
+// NewInvite returns a new invite created by the user that expires after the
+// duration (if non-zero) and can be used at most maxUses times (if non-zero)
+func NewInvite(createdBy string, maxUses int, expiresIn time.Duration) *Invite {
+	now := time.Now()
+
+	invite := &Invite{
+		Code:      GenerateRandomToken(),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		MaxUses:   maxUses,
+	}
+	if expiresIn > 0 {
+		invite.ExpiresAt = now.Add(expiresIn)
+	}
+
+	return invite
+}
+
+// LoadInvite ...
+func LoadInvite(data []byte) (invite *Invite, err error) {
+	if err := json.Unmarshal(data, &invite); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Bytes ...
+func (i *Invite) Bytes() ([]byte, error) {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// IsExpired returns true if the invite has expired
+func (i *Invite) IsExpired() bool {
+	return !i.ExpiresAt.IsZero() && time.Now().After(i.ExpiresAt)
+}
+
+// IsUsedUp returns true if the invite has been used the maximum number of
+// times
+func (i *Invite) IsUsedUp() bool {
+	return i.MaxUses > 0 && len(i.UsedBy) >= i.MaxUses
+}
+
+// IsValid returns true if people can still register with the invite
+func (i *Invite) IsValid() bool {
+	return !i.IsExpired() && !i.IsUsedUp()
+}
+
+// URL returns the link to register on the pod with the invite
+func (i *Invite) URL(baseURL string) string {
+	return fmt.Sprintf("%s/register?invite=%s", strings.TrimSuffix(baseURL, "/"), i.Code)
+}
+
+// Invites is a list of invites sorted by most recently created
+type Invites []*Invite
+
+func (invites Invites) Len() int { return len(invites) }
+func (invites Invites) Less(i, j int) bool {
+	return invites[i].CreatedAt.After(invites[j].CreatedAt)
+}
+func (invites Invites) Swap(i, j int) { invites[i], invites[j] = invites[j], invites[i] }
+
+// CreatedBy returns the invites created by the user
+func (invites Invites) CreatedBy(username string) (filtered Invites) {
+	for _, invite := range invites {
+		if invite.CreatedBy == username {
+			filtered = append(filtered, invite)
+		}
+	}
+	return
+}
+
+// InviteQuota returns how many invites the user can create, staff who manage
+// invites are not limited by the pod's invite quota. Invites count towards
+// the quota until they are revoked, invites people registered with always
+// count (See: RevokeInvite).
+func InviteQuota(conf *Config, db Store, user *User) (int, error) {
+	if HasPermissionFactory(conf)(user, PermissionManageInvites) {
+		return -1, nil
+	}
+
+	if conf.InviteQuota <= 0 || user.IsSilenced() {
+		return 0, nil
+	}
+
+	invites, err := db.GetAllInvites()
+	if err != nil {
+		return 0, err
+	}
+
+	if n := conf.InviteQuota - len(invites.CreatedBy(user.Username)); n > 0 {
+		return n, nil
+	}
+	return 0, nil
+}
+
+// CreateInvite creates an invite on behalf of the user, staff who manage
+// invites choose how many times the invite can be used and when it expires,
+// other users' invites count towards the pod's invite quota and can be used
+// once within DefaultInviteExpiry.
+func CreateInvite(conf *Config, db Store, user *User, maxUses int, expiresIn time.Duration) (*Invite, error) {
+	quota, err := InviteQuota(conf, db, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if quota == 0 {
+		return nil, ErrInviteQuotaExceeded
+	}
+
+	if quota > 0 {
+		maxUses = 1
+		if expiresIn <= 0 || expiresIn > DefaultInviteExpiry {
+			expiresIn = DefaultInviteExpiry
+		}
+	}
+
+	if maxUses < 0 {
+		maxUses = 0
+	}
+
+	invite := NewInvite(user.Username, maxUses, expiresIn)
+	if err := db.SetInvite(invite.Code, invite); err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// redeemLock serialises redeeming invites so that concurrent registrations
+// with the same invite cannot use it more often than it allows
+var redeemLock sync.Mutex
+
+// ReserveInvite uses the invite for the username before their account is
+// created so that concurrent registrations cannot use up the invite, the
+// reservation must be released if creating the account fails (See:
+// ReleaseInvite). New users are recorded as invited by the invite's creator.
+func ReserveInvite(db Store, code, username string) (*Invite, error) {
+	redeemLock.Lock()
+	defer redeemLock.Unlock()
+
+	invite, err := db.GetInvite(strings.TrimSpace(code))
+	if err != nil {
+		if err == ErrInviteNotFound {
+			return nil, ErrInvalidInvite
+		}
+		return nil, err
+	}
+
+	if !invite.IsValid() {
+		return nil, ErrInvalidInvite
+	}
+
+	invite.UsedBy = append(invite.UsedBy, username)
+	if err := db.SetInvite(invite.Code, invite); err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// ReleaseInvite releases the username's reservation of the invite when
+// creating their account failed
+func ReleaseInvite(db Store, code, username string) error {
+	redeemLock.Lock()
+	defer redeemLock.Unlock()
+
+	invite, err := db.GetInvite(strings.TrimSpace(code))
+	if err != nil {
+		if err == ErrInviteNotFound {
+			return nil
+		}
+		return err
+	}
+
+	for i, usedBy := range invite.UsedBy {
+		if usedBy == username {
+			invite.UsedBy = append(invite.UsedBy[:i], invite.UsedBy[i+1:]...)
+			return db.SetInvite(invite.Code, invite)
+		}
+	}
+
+	return nil
+}
+
+// ValidInvite returns true if people can register with the invite code
+func ValidInvite(db Store, code string) bool {
+	if code = strings.TrimSpace(code); code == "" {
+		return false
+	}
+
+	invite, err := db.GetInvite(code)
+	if err != nil {
+		return false
+	}
+	return invite.IsValid()
+}
+
+// RevokeInvite revokes the invite on behalf of the user, users can only
+// revoke their own invites unless they are staff who manage invites. Unused
+// invites are deleted, invites people registered with are expired instead so
+// they keep counting towards their creator's invite quota and users who
+// registered with them are still recorded as invited by their creator.
+func RevokeInvite(conf *Config, db Store, user *User, code string) (*Invite, error) {
+	redeemLock.Lock()
+	defer redeemLock.Unlock()
+
+	invite, err := db.GetInvite(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if invite.CreatedBy != user.Username && !HasPermissionFactory(conf)(user, PermissionManageInvites) {
+		return nil, ErrPermissionDenied
+	}
+
+	if len(invite.UsedBy) > 0 {
+		if !invite.IsExpired() {
+			invite.ExpiresAt = time.Now()
+		}
+		if err := db.SetInvite(invite.Code, invite); err != nil {
+			return nil, err
+		}
+		return invite, nil
+	}
+
+	if err := db.DelInvite(invite.Code); err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
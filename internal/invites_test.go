@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/passwords"
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestInvite(t *testing.T) {
+	invite := NewInvite("admin", 2, time.Hour)
+	assert.NotEmpty(t, invite.Code)
+	assert.True(t, invite.IsValid())
+	assert.Equal(t, "https://example.com/register?invite="+invite.Code, invite.URL("https://example.com/"))
+
+	invite.UsedBy = []string{"alice", "bob"}
+	assert.True(t, invite.IsUsedUp())
+	assert.False(t, invite.IsValid())
+
+	invite = NewInvite("admin", 0, 0)
+	invite.UsedBy = []string{"alice", "bob"}
+	assert.True(t, invite.ExpiresAt.IsZero())
+	assert.True(t, invite.IsValid())
+
+	invite.ExpiresAt = time.Now().Add(-time.Minute)
+	assert.True(t, invite.IsExpired())
+	assert.False(t, invite.IsValid())
+}
+
+func TestCreateInvite(t *testing.T) {
+	api := newTestAPI(t)
+	conf := api.config
+	conf.AdminUser = "admin"
+
+	admin := &User{Username: "admin"}
+	alice := &User{Username: "alice"}
+	bob := &User{Username: "bob"}
+	for _, user := range []*User{admin, alice, bob} {
+		require.NoError(t, api.db.SetUser(user.Username, user))
+	}
+
+	// Users cannot create invites unless the pod has an invite quota
+	_, err := CreateInvite(conf, api.db, alice, 1, 0)
+	assert.Equal(t, ErrInviteQuotaExceeded, err)
+
+	conf.InviteQuota = 1
+
+	// Users' invites can be used once within DefaultInviteExpiry
+	invite, err := CreateInvite(conf, api.db, alice, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, invite.MaxUses)
+	assert.WithinDuration(t, time.Now().Add(DefaultInviteExpiry), invite.ExpiresAt, time.Minute)
+
+	_, err = CreateInvite(conf, api.db, alice, 1, 0)
+	assert.Equal(t, ErrInviteQuotaExceeded, err)
+
+	quota, err := InviteQuota(conf, api.db, bob)
+	require.NoError(t, err)
+	assert.Equal(t, 1, quota)
+
+	// Staff who manage invites are not limited by the quota
+	quota, err = InviteQuota(conf, api.db, admin)
+	require.NoError(t, err)
+	assert.Equal(t, -1, quota)
+
+	staff, err := CreateInvite(conf, api.db, admin, 5, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 5, staff.MaxUses)
+	assert.True(t, staff.ExpiresAt.IsZero())
+
+	invites, err := api.db.GetAllInvites()
+	require.NoError(t, err)
+	assert.Len(t, invites, 2)
+	require.Len(t, invites.CreatedBy("alice"), 1)
+	assert.Equal(t, invite.Code, invites.CreatedBy("alice")[0].Code)
+
+	// Users can only revoke their own invites
+	_, err = RevokeInvite(conf, api.db, bob, invite.Code)
+	assert.Equal(t, ErrPermissionDenied, err)
+
+	_, err = RevokeInvite(conf, api.db, admin, invite.Code)
+	require.NoError(t, err)
+	assert.False(t, ValidInvite(api.db, invite.Code))
+
+	_, err = RevokeInvite(conf, api.db, alice, invite.Code)
+	assert.Equal(t, ErrInviteNotFound, err)
+
+	// Revoking used invites does not free up the quota
+	conf.InviteQuota = 2
+	used, err := CreateInvite(conf, api.db, bob, 1, 0)
+	require.NoError(t, err)
+	_, err = ReserveInvite(api.db, used.Code, "carol")
+	require.NoError(t, err)
+
+	_, err = RevokeInvite(conf, api.db, bob, used.Code)
+	require.NoError(t, err)
+	revoked, err := api.db.GetInvite(used.Code)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol"}, revoked.UsedBy)
+	assert.False(t, revoked.IsValid())
+
+	_, err = CreateInvite(conf, api.db, bob, 1, 0)
+	require.NoError(t, err)
+	_, err = CreateInvite(conf, api.db, bob, 1, 0)
+	assert.Equal(t, ErrInviteQuotaExceeded, err)
+}
+
+func TestReserveInvite(t *testing.T) {
+	api := newTestAPI(t)
+
+	invite := NewInvite("admin", 1, time.Hour)
+	require.NoError(t, api.db.SetInvite(invite.Code, invite))
+
+	_, err := ReserveInvite(api.db, "invalid", "alice")
+	assert.Equal(t, ErrInvalidInvite, err)
+
+	reserved, err := ReserveInvite(api.db, invite.Code, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", reserved.CreatedBy)
+
+	// Concurrent registrations cannot use up the same invite
+	_, err = ReserveInvite(api.db, invite.Code, "bob")
+	assert.Equal(t, ErrInvalidInvite, err)
+
+	// Failed registrations release their reservation
+	require.NoError(t, ReleaseInvite(api.db, invite.Code, "alice"))
+	assert.True(t, ValidInvite(api.db, invite.Code))
+
+	_, err = ReserveInvite(api.db, invite.Code, "bob")
+	require.NoError(t, err)
+
+	invite, err = api.db.GetInvite(invite.Code)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, invite.UsedBy)
+}
+
+func TestRegisterWithInvite(t *testing.T) {
+	api := newTestAPI(t)
+	api.pm = passwords.NewScryptPasswords(nil)
+	api.config.OpenRegistrations = false
+
+	invite := NewInvite("admin", 1, time.Hour)
+	require.NoError(t, api.db.SetInvite(invite.Code, invite))
+
+	register := func(username, code string) int {
+		req := types.RegisterRequest{Username: username, Password: "secret", Email: username + "@example.com", Invite: code}
+		return callEndpoint(t, api.RegisterEndpoint(), nil, http.MethodPost, req, nil)
+	}
+
+	assert.Equal(t, http.StatusForbidden, register("alice", ""))
+	assert.Equal(t, http.StatusForbidden, register("alice", "invalid"))
+	assert.Equal(t, http.StatusOK, register("alice", invite.Code))
+
+	alice, err := api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", alice.InvitedBy)
+
+	invite, err = api.db.GetInvite(invite.Code)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, invite.UsedBy)
+
+	// The invite has been used up
+	assert.Equal(t, http.StatusForbidden, register("bob", invite.Code))
+	assert.False(t, api.db.HasUser("bob"))
+}
+
+func TestInviteHandlers(t *testing.T) {
+	server := newTestServer(t)
+	server.config.AdminUser = "admin"
+	server.config.BaseURL = "https://example.com"
+
+	admin := &User{Username: "admin"}
+	alice := &User{Username: "alice"}
+	for _, user := range []*User{admin, alice} {
+		require.NoError(t, server.db.SetUser(user.Username, user))
+	}
+
+	post := func(user *User, form url.Values) string {
+		sess := session.NewSession(server.sc)
+		sess.ID = GenerateRandomToken()
+		sess.Data = session.Map{"username": user.Username}
+
+		r := httptest.NewRequest(http.MethodPost, "/settings/invites", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+
+		w := httptest.NewRecorder()
+		server.CreateInviteHandler()(w, r, nil)
+		return w.Body.String()
+	}
+
+	assert.Contains(t, post(alice, url.Values{}), "You have no invites left to create.")
+	assert.Contains(t, post(admin, url.Values{"expiry": {"forever"}}), "Invalid invite expiry")
+	assert.Contains(t, post(admin, url.Values{"maxUses": {"3"}, "expiry": {"24h"}}), "https://example.com/register?invite=")
+
+	invites, err := server.db.GetAllInvites()
+	require.NoError(t, err)
+	require.Len(t, invites, 1)
+	assert.Equal(t, 3, invites[0].MaxUses)
+
+	entries, err := server.db.GetAllAuditEntries()
+	require.NoError(t, err)
+	created := entries.Filter(AuditFilter{Action: AuditCreateInvite, Target: invites[0].Code})
+	require.Len(t, created, 1)
+	assert.Equal(t, "admin", created[0].Actor)
+}
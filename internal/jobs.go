@@ -38,8 +38,13 @@ func InitJobs(conf *Config) {
 		"UpdateFeeds":       NewJobSpec(conf.FetchInterval, NewUpdateFeedsJob),
 		"UpdateFeedSources": NewJobSpec("@every 15m", NewUpdateFeedSourcesJob),
 
+		"SendDigests": NewJobSpec("@hourly", NewSendDigestsJob),
+
 		"ActiveUsers":       NewJobSpec("@hourly", NewActiveUsersJob),
 		"DeleteOldSessions": NewJobSpec("@hourly", NewDeleteOldSessionsJob),
+		"SyncBlocklists":    NewJobSpec("@hourly", NewSyncBlocklistsJob),
+
+		"LiftAccountLimits": NewJobSpec("@every 5m", NewLiftAccountLimitsJob),
 
 		"Stats":          NewJobSpec("@daily", NewStatsJob),
 		"RotateFeeds":    NewJobSpec("0 0 1 * * 0", NewRotateFeedsJob),
@@ -48,6 +53,9 @@ func InitJobs(conf *Config) {
 
 		"CreateAdminFeeds":     NewJobSpec("", NewCreateAdminFeedsJob),
 		"CreateAutomatedFeeds": NewJobSpec("", NewCreateAutomatedFeedsJob),
+		"MigrateSigningKeys":   NewJobSpec("", NewMigrateSigningKeysJob),
+		"LinkArchiveAliases":   NewJobSpec("", NewLinkArchiveAliasesJob),
+		"RotateDigestEmails":   NewJobSpec("", NewRotateDigestEmailsJob),
 	}
 
 	StartupJobs = map[string]JobSpec{
@@ -57,6 +65,9 @@ func InitJobs(conf *Config) {
 		"CreateAdminFeeds":     Jobs["CreateAdminFeeds"],
 		"CreateAutomatedFeeds": Jobs["CreateAutomatedFeeds"],
 		"DeleteOldSessions":    Jobs["DeleteOldSessions"],
+		"MigrateSigningKeys":   Jobs["MigrateSigningKeys"],
+		"LinkArchiveAliases":   Jobs["LinkArchiveAliases"],
+		"RotateDigestEmails":   Jobs["RotateDigestEmails"],
 	}
 
 }
@@ -237,6 +248,14 @@ func (job *UpdateFeedsJob) Run() {
 	log.Infof("converging cache with %d potential peers", len(job.cache.GetPeers()))
 	job.cache.Converge(job.archive)
 
+	// Notifications only change as feeds are fetched
+	log.Infof("updating notifications for %d users", len(users))
+	for _, user := range users {
+		if _, err := UpdateNotifications(job.conf, job.cache, job.db, user); err != nil {
+			log.WithError(err).Warnf("error updating notifications for %s", user.Username)
+		}
+	}
+
 	log.Info("syncing feed cache")
 	if err := job.cache.Store(job.conf); err != nil {
 		log.WithError(err).Warn("error saving feed cache")
@@ -378,6 +397,38 @@ func (job *ActiveUsersJob) Run() {
 	metrics.Gauge("server", "mau").Set(float64(mau))
 }
 
+type SendDigestsJob struct {
+	conf    *Config
+	cache   *Cache
+	archive Archiver
+	db      Store
+}
+
+func NewSendDigestsJob(conf *Config, cache *Cache, archive Archiver, db Store) Job {
+	return &SendDigestsJob{conf: conf, cache: cache, archive: archive, db: db}
+}
+
+func (job *SendDigestsJob) String() string { return "SendDigests" }
+
+func (job *SendDigestsJob) Run() {
+	users, err := job.db.GetAllUsers()
+	if err != nil {
+		log.WithError(err).Warn("unable to get all users from database")
+		return
+	}
+
+	now := time.Now()
+
+	for _, user := range users {
+		if !user.IsDigestDue(now) {
+			continue
+		}
+		if err := SendDigest(job.conf, job.cache, job.archive, job.db, user); err != nil {
+			log.WithError(err).Warnf("error sending digest to %s", user.Username)
+		}
+	}
+}
+
 type DeleteOldSessionsJob struct {
 	conf    *Config
 	cache   *Cache
@@ -410,6 +461,117 @@ func (job *DeleteOldSessionsJob) Run() {
 	}
 }
 
+type SyncBlocklistsJob struct {
+	conf    *Config
+	cache   *Cache
+	archive Archiver
+	db      Store
+}
+
+func NewSyncBlocklistsJob(conf *Config, cache *Cache, archive Archiver, db Store) Job {
+	return &SyncBlocklistsJob{conf: conf, cache: cache, archive: archive, db: db}
+}
+
+func (job *SyncBlocklistsJob) String() string { return "SyncBlocklists" }
+
+func (job *SyncBlocklistsJob) Run() {
+	if len(job.conf.BlocklistSubscriptions) == 0 {
+		return
+	}
+
+	log.Info("syncing subscribed blocklists")
+
+	for _, uri := range job.conf.BlocklistSubscriptions {
+		blocklist, err := FetchBlocklist(job.conf, uri)
+		if err != nil {
+			log.WithError(err).Warnf("error fetching blocklist %s", uri)
+			continue
+		}
+
+		n, err := job.conf.ImportBlocklist(blocklist, uri, "")
+		if err != nil {
+			log.WithError(err).Warnf("error syncing blocklist %s", uri)
+			continue
+		}
+		log.Infof("synced %d entries from blocklist %s", n, uri)
+	}
+
+	if err := job.conf.Settings().Save(filepath.Join(job.conf.Data, "settings.yaml")); err != nil {
+		log.WithError(err).Error("error saving pod settings")
+	}
+
+	job.cache.DeleteBlockedFeeds()
+}
+
+type LiftAccountLimitsJob struct {
+	conf    *Config
+	cache   *Cache
+	archive Archiver
+	db      Store
+}
+
+func NewLiftAccountLimitsJob(conf *Config, cache *Cache, archive Archiver, db Store) Job {
+	return &LiftAccountLimitsJob{conf: conf, cache: cache, archive: archive, db: db}
+}
+
+func (job *LiftAccountLimitsJob) String() string { return "LiftAccountLimits" }
+
+func (job *LiftAccountLimitsJob) Run() {
+	lifted, err := ApplyAccountLimits(job.conf, job.db)
+	if err != nil {
+		log.WithError(err).Error("error lifting expired account limits")
+		return
+	}
+
+	if len(lifted) == 0 {
+		return
+	}
+
+	for username, limits := range lifted {
+		log.Infof("lifted expired limits %v of %s", limits, username)
+	}
+
+	job.cache.Refresh()
+}
+
+type MigrateSigningKeysJob struct {
+	conf    *Config
+	cache   *Cache
+	archive Archiver
+	db      Store
+}
+
+func NewMigrateSigningKeysJob(conf *Config, cache *Cache, archive Archiver, db Store) Job {
+	return &MigrateSigningKeysJob{conf: conf, cache: cache, archive: archive, db: db}
+}
+
+func (job *MigrateSigningKeysJob) String() string { return "MigrateSigningKeys" }
+
+func (job *MigrateSigningKeysJob) Run() {
+	if err := MigrateSigningKeys(job.conf, job.db); err != nil {
+		log.WithError(err).Error("error migrating signing keys")
+	}
+}
+
+type LinkArchiveAliasesJob struct {
+	conf    *Config
+	cache   *Cache
+	archive Archiver
+	db      Store
+}
+
+func NewLinkArchiveAliasesJob(conf *Config, cache *Cache, archive Archiver, db Store) Job {
+	return &LinkArchiveAliasesJob{conf: conf, cache: cache, archive: archive, db: db}
+}
+
+func (job *LinkArchiveAliasesJob) String() string { return "LinkArchiveAliases" }
+
+func (job *LinkArchiveAliasesJob) Run() {
+	if err := job.archive.LinkAliases(); err != nil {
+		log.WithError(err).Error("error linking archived twts under all hash versions")
+	}
+}
+
 type RotateFeedsJob struct {
 	conf    *Config
 	cache   *Cache
@@ -538,3 +700,22 @@ func (job *PruneUsersJob) Run() {
 		}
 	}
 }
+
+type RotateDigestEmailsJob struct {
+	conf    *Config
+	cache   *Cache
+	archive Archiver
+	db      Store
+}
+
+func NewRotateDigestEmailsJob(conf *Config, cache *Cache, archive Archiver, db Store) Job {
+	return &RotateDigestEmailsJob{conf: conf, cache: cache, archive: archive, db: db}
+}
+
+func (job *RotateDigestEmailsJob) String() string { return "RotateDigestEmails" }
+
+func (job *RotateDigestEmailsJob) Run() {
+	if err := RotateDigestEmails(job.conf, job.db); err != nil {
+		log.WithError(err).Error("error rotating digest email encryption")
+	}
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// LimitUserHandler silences, disables posting of or suspends a user with a
+// reason and an optional duration after which the limit is lifted
+func (s *Server) LimitUserHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionLimitUsers) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		username := NormalizeUsername(r.FormValue("username"))
+		reason := strings.TrimSpace(r.FormValue("reason"))
+
+		trdata := map[string]interface{}{}
+		trdata["Nick"] = username
+
+		limit, err := ParseAccountLimit(r.FormValue("limit"))
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidAccountLimit")
+			s.render("error", w, ctx)
+			return
+		}
+		trdata["Limit"] = limit
+
+		// An empty duration places the limit indefinitely
+		var until time.Time
+		if duration := strings.TrimSpace(r.FormValue("duration")); duration != "" {
+			d, err := time.ParseDuration(duration)
+			if err != nil || d <= 0 {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorInvalidAccountLimit")
+				s.render("error", w, ctx)
+				return
+			}
+			until = time.Now().Add(d)
+		}
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading user object for %s", username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorGetUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := LimitUser(s.config, s.db, s.sc, ctx.User, user, limit, reason, until); err != nil {
+			log.WithError(err).Errorf("error limiting %s", username)
+			ctx.Error = true
+			if err == ErrPermissionDenied {
+				ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		s.cache.Refresh()
+
+		log.Infof("%s placed on %s by %s", limit, username, ctx.Username)
+
+		after := string(limit)
+		if !until.IsZero() {
+			after = fmt.Sprintf("%s until %s", limit, until.UTC().Format(time.RFC3339))
+		}
+		Audit(s.db, ctx.Username, AuditLimitUser, username, "", after, reason)
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgAccountLimitPlaced", trdata)
+		s.render("error", w, ctx)
+	}
+}
+
+// LiftLimitHandler lifts a limit placed on a user before it expires
+func (s *Server) LiftLimitHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionLimitUsers) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		username := NormalizeUsername(r.FormValue("username"))
+
+		trdata := map[string]interface{}{}
+		trdata["Nick"] = username
+
+		limit, err := ParseAccountLimit(r.FormValue("limit"))
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidAccountLimit")
+			s.render("error", w, ctx)
+			return
+		}
+		trdata["Limit"] = limit
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading user object for %s", username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorGetUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := LiftUserLimit(s.config, s.db, ctx.User, user, limit); err != nil {
+			log.WithError(err).Errorf("error lifting %s of %s", limit, username)
+			ctx.Error = true
+			if err == ErrPermissionDenied {
+				ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		s.cache.Refresh()
+
+		log.Infof("%s of %s lifted by %s", limit, username, ctx.Username)
+
+		Audit(s.db, ctx.Username, AuditLiftLimit, username, string(limit), "", "")
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgAccountLimitLifted", trdata)
+		s.render("error", w, ctx)
+	}
+}
+
+// limitMessage returns the message telling the user of the limit placed on
+// their account and when it is lifted (if ever)
+func (s *Server) limitMessage(ctx *Context, user *User, limit AccountLimit) string {
+	msgID := "ErrorPostingDisabled"
+	if limit == LimitSuspend {
+		msgID = "ErrorAccountSuspended"
+	}
+
+	until := user.LimitUntil(limit)
+	if limit == LimitDisablePosting && user.IsSuspended() {
+		msgID, until = "ErrorAccountSuspended", user.LimitUntil(LimitSuspend)
+	}
+
+	if until.IsZero() {
+		return s.tr(ctx, msgID)
+	}
+	return s.tr(ctx, msgID+"Until", map[string]interface{}{"Until": humanize.Time(until)})
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+)
+
+var (
+	// ErrInvalidAccountLimit is returned when placing an unknown limit on a
+	// user's account
+	ErrInvalidAccountLimit = errors.New("error: invalid account limit")
+
+	// ErrPostingDisabled is returned when a user whose posting is disabled
+	// tries to post a twt
+	ErrPostingDisabled = errors.New("error: posting disabled")
+)
+
+// AccountLimit is a limit moderators place on a local user's account short of
+// deleting or resetting it, limits have a reason and an optional expiry
+type AccountLimit string
+
+const (
+	// LimitSilence hides the user's twts from discover
+	LimitSilence AccountLimit = "silence"
+
+	// LimitDisablePosting stops the user posting twts
+	LimitDisablePosting AccountLimit = "disable_posting"
+
+	// LimitSuspend stops the user logging in or using the API and serves the
+	// user's feeds as gone
+	LimitSuspend AccountLimit = "suspend"
+)
+
+// AccountLimits are the limits that can be placed on users' accounts
+var AccountLimits = []AccountLimit{LimitSilence, LimitDisablePosting, LimitSuspend}
+
+// ParseAccountLimit parses an account limit
+func ParseAccountLimit(s string) (AccountLimit, error) {
+	limit := AccountLimit(strings.ToLower(strings.TrimSpace(s)))
+	for _, l := range AccountLimits {
+		if l == limit {
+			return limit, nil
+		}
+	}
+	return "", ErrInvalidAccountLimit
+}
+
+// limitFields returns the user's fields recording when the limit was placed,
+// when it expires and why
+func (u *User) limitFields(limit AccountLimit) (at, until *time.Time, reason *string) {
+	switch limit {
+	case LimitSilence:
+		return &u.SilencedAt, &u.SilencedUntil, &u.SilencedReason
+	case LimitDisablePosting:
+		return &u.PostingDisabledAt, &u.PostingDisabledUntil, &u.PostingDisabledReason
+	case LimitSuspend:
+		return &u.SuspendedAt, &u.SuspendedUntil, &u.SuspendedReason
+	}
+	return nil, nil, nil
+}
+
+// HasLimit returns true if the limit is placed on the user's account and has
+// not expired
+func (u *User) HasLimit(limit AccountLimit) bool {
+	at, until, _ := u.limitFields(limit)
+	if at == nil || at.IsZero() {
+		return false
+	}
+	return until.IsZero() || time.Now().Before(*until)
+}
+
+// Limits returns the limits placed on the user's account
+func (u *User) Limits() (limits []AccountLimit) {
+	for _, limit := range AccountLimits {
+		if u.HasLimit(limit) {
+			limits = append(limits, limit)
+		}
+	}
+	return
+}
+
+// LimitUntil returns when the limit placed on the user's account expires, the
+// zero time if it does not
+func (u *User) LimitUntil(limit AccountLimit) time.Time {
+	if _, until, _ := u.limitFields(limit); until != nil {
+		return *until
+	}
+	return time.Time{}
+}
+
+// LimitReason returns the reason the limit was placed on the user's account
+func (u *User) LimitReason(limit AccountLimit) string {
+	if _, _, reason := u.limitFields(limit); reason != nil {
+		return *reason
+	}
+	return ""
+}
+
+// SetLimit places the limit on the user's account until the given time, or
+// indefinitely if until is the zero time
+func (u *User) SetLimit(limit AccountLimit, reason string, until time.Time) error {
+	at, untilField, reasonField := u.limitFields(limit)
+	if at == nil {
+		return ErrInvalidAccountLimit
+	}
+	*at = time.Now()
+	*untilField = until
+	*reasonField = strings.TrimSpace(reason)
+	return nil
+}
+
+// LiftLimit lifts the limit from the user's account returning false if the
+// limit was not placed on it
+func (u *User) LiftLimit(limit AccountLimit) bool {
+	at, until, reason := u.limitFields(limit)
+	if at == nil || at.IsZero() {
+		return false
+	}
+	*at = time.Time{}
+	*until = time.Time{}
+	*reason = ""
+	return true
+}
+
+// LiftExpiredLimits lifts the limits placed on the user's account that have
+// expired returning the limits lifted
+func (u *User) LiftExpiredLimits() (lifted []AccountLimit) {
+	for _, limit := range AccountLimits {
+		at, _, _ := u.limitFields(limit)
+		if !at.IsZero() && !u.HasLimit(limit) {
+			u.LiftLimit(limit)
+			lifted = append(lifted, limit)
+		}
+	}
+	return
+}
+
+// FeedURLs returns the urls of the user's own feed and the feeds they own
+func (u *User) FeedURLs(baseURL string) []string {
+	urls := []string{URLForUser(baseURL, u.Username)}
+	for _, feed := range u.Feeds {
+		urls = append(urls, URLForUser(baseURL, feed))
+	}
+	return urls
+}
+
+// LimitUser places the limit on the user's account on behalf of the actor, a
+// suspended user's sessions and API tokens are revoked.
+func LimitUser(conf *Config, db Store, sessions session.Store, actor, user *User, limit AccountLimit, reason string, until time.Time) error {
+	if !CanManageUser(conf, actor, user) {
+		return ErrPermissionDenied
+	}
+
+	if err := user.SetLimit(limit, reason, until); err != nil {
+		return err
+	}
+
+	if limit == LimitSuspend {
+		if err := RevokeOtherSessions(sessions, user, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := db.SetUser(user.Username, user); err != nil {
+		return err
+	}
+
+	_, err := ApplyAccountLimits(conf, db)
+	return err
+}
+
+// LiftUserLimit lifts the limit from the user's account on behalf of the actor
+func LiftUserLimit(conf *Config, db Store, actor, user *User, limit AccountLimit) error {
+	if !CanManageUser(conf, actor, user) {
+		return ErrPermissionDenied
+	}
+
+	if !user.LiftLimit(limit) {
+		return nil
+	}
+
+	if err := db.SetUser(user.Username, user); err != nil {
+		return err
+	}
+
+	_, err := ApplyAccountLimits(conf, db)
+	return err
+}
+
+// ApplyAccountLimits lifts the expired limits on users' accounts and updates
+// the feeds the pod hides from discover and serves as gone, returning the
+// limits lifted keyed by username.
+func ApplyAccountLimits(conf *Config, db Store) (map[string][]AccountLimit, error) {
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	var silenced, suspended []string
+	lifted := make(map[string][]AccountLimit)
+
+	for _, user := range users {
+		if limits := user.LiftExpiredLimits(); len(limits) > 0 {
+			if err := db.SetUser(user.Username, user); err != nil {
+				log.WithError(err).Errorf("error lifting expired limits of %s", user.Username)
+				continue
+			}
+			lifted[user.Username] = limits
+		}
+
+		if user.IsSilenced() {
+			silenced = append(silenced, user.FeedURLs(conf.BaseURL)...)
+		}
+		if user.IsSuspended() {
+			suspended = append(suspended, user.FeedURLs(conf.BaseURL)...)
+		}
+	}
+
+	if err := WithSilencedFeeds(silenced)(conf); err != nil {
+		return nil, err
+	}
+	if err := WithSuspendedFeeds(suspended)(conf); err != nil {
+		return nil, err
+	}
+
+	return lifted, nil
+}
@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestAccountLimits(t *testing.T) {
+	user := &User{Username: "alice"}
+	assert.Empty(t, user.Limits())
+
+	require.NoError(t, user.SetLimit(LimitSilence, " spamming discover ", time.Time{}))
+	assert.True(t, user.IsSilenced())
+	assert.False(t, user.IsPostingDisabled())
+	assert.Equal(t, "spamming discover", user.LimitReason(LimitSilence))
+	assert.True(t, user.LimitUntil(LimitSilence).IsZero())
+
+	require.NoError(t, user.SetLimit(LimitSuspend, "", time.Now().Add(time.Hour)))
+	assert.True(t, user.IsSuspended())
+	assert.True(t, user.IsPostingDisabled())
+	assert.Equal(t, []AccountLimit{LimitSilence, LimitSuspend}, user.Limits())
+	assert.Empty(t, user.LiftExpiredLimits())
+
+	user.SuspendedUntil = time.Now().Add(-time.Minute)
+	assert.False(t, user.IsSuspended())
+	assert.False(t, user.IsPostingDisabled())
+	assert.Equal(t, []AccountLimit{LimitSuspend}, user.LiftExpiredLimits())
+	assert.True(t, user.SuspendedAt.IsZero())
+
+	assert.True(t, user.LiftLimit(LimitSilence))
+	assert.False(t, user.LiftLimit(LimitSilence))
+	assert.Empty(t, user.Limits())
+
+	_, err := ParseAccountLimit("ban")
+	assert.Equal(t, ErrInvalidAccountLimit, err)
+	assert.Equal(t, ErrInvalidAccountLimit, user.SetLimit(AccountLimit("ban"), "", time.Time{}))
+
+	limit, err := ParseAccountLimit(" Disable_Posting ")
+	require.NoError(t, err)
+	assert.Equal(t, LimitDisablePosting, limit)
+}
+
+func TestLimitUser(t *testing.T) {
+	api := newTestAPI(t)
+	conf := api.config
+	conf.AdminUser = "admin"
+
+	admin := &User{Username: "admin"}
+	mod := &User{Username: "mod", Role: RoleModerator}
+	alice := &User{Username: "alice", Feeds: []string{"news"}}
+	bob := &User{Username: "bob"}
+	for _, user := range []*User{admin, mod, alice, bob} {
+		require.NoError(t, api.db.SetUser(user.Username, user))
+	}
+
+	assert.Equal(t, ErrPermissionDenied, LimitUser(conf, api.db, api.sessions, mod, admin, LimitSuspend, "", time.Time{}))
+
+	require.NoError(t, LimitUser(conf, api.db, api.sessions, mod, alice, LimitSilence, "spam", time.Time{}))
+	require.NoError(t, LimitUser(conf, api.db, api.sessions, mod, bob, LimitSuspend, "abuse", time.Now().Add(time.Hour)))
+
+	assert.True(t, conf.SilencedFeed(URLForUser(conf.BaseURL, "alice")))
+	assert.True(t, conf.SilencedFeed(URLForUser(conf.BaseURL, "news")))
+	assert.False(t, conf.SuspendedFeed(URLForUser(conf.BaseURL, "alice")))
+	assert.True(t, conf.SilencedFeed(URLForUser(conf.BaseURL, "bob")))
+	assert.True(t, conf.SuspendedFeed(URLForUser(conf.BaseURL, "bob")))
+
+	// Silenced users' twts are hidden from discover
+	hello := types.MakeTwt(types.Twter{Nick: "alice", URI: URLForUser(conf.BaseURL, "alice")}, time.Now(), "Buy now!")
+	assert.False(t, FilterOutFeedsAndBotsFactory(conf)(hello))
+
+	// Suspended and posting disabled users cannot post
+	appendTwt := AppendTwtFactory(conf, api.db)
+	_, err := appendTwt(bob, nil, "Hello World!")
+	assert.Equal(t, ErrPostingDisabled, err)
+
+	require.NoError(t, LimitUser(conf, api.db, api.sessions, admin, alice, LimitDisablePosting, "", time.Time{}))
+	assert.Equal(t, http.StatusForbidden, callEndpoint(t, api.PostEndpoint(), alice, http.MethodPost, types.PostRequest{Text: "Hello World!"}, nil))
+
+	require.NoError(t, LiftUserLimit(conf, api.db, admin, alice, LimitDisablePosting))
+	_, err = appendTwt(alice, nil, "Hello World!")
+	assert.NoError(t, err)
+
+	// Expired limits are lifted by the LiftAccountLimits job
+	bob, err = api.db.GetUser("bob")
+	require.NoError(t, err)
+	bob.SuspendedUntil = time.Now().Add(-time.Minute)
+	require.NoError(t, api.db.SetUser(bob.Username, bob))
+
+	NewLiftAccountLimitsJob(conf, api.cache, api.archive, api.db).Run()
+
+	bob, err = api.db.GetUser("bob")
+	require.NoError(t, err)
+	assert.True(t, bob.SuspendedAt.IsZero())
+	assert.False(t, conf.SuspendedFeed(URLForUser(conf.BaseURL, "bob")))
+	assert.True(t, conf.SilencedFeed(URLForUser(conf.BaseURL, "alice")))
+}
+
+func TestLimitedFeedsConcurrentUpdates(t *testing.T) {
+	conf := NewConfig()
+	uri := "https://example.com/user/alice/twtxt.txt"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, WithSilencedFeeds([]string{uri})(conf))
+			assert.NoError(t, WithSuspendedFeeds([]string{uri})(conf))
+			assert.NoError(t, conf.HideTwt("abcdefg"))
+			assert.NoError(t, conf.MuteFeed(uri))
+		}()
+		go func() {
+			defer wg.Done()
+			conf.SilencedFeed(uri)
+			conf.SuspendedFeed(uri)
+			conf.MutedFeed(uri)
+			conf.HasHiddenTwts()
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, conf.SuspendedFeed(uri))
+	assert.Equal(t, []string{"abcdefg"}, conf.HiddenTwts)
+	assert.Equal(t, []string{uri}, conf.MutedFeeds)
+}
+
+func TestSuspendedUserAPI(t *testing.T) {
+	api := newTestAPI(t)
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	token, err := api.CreateToken(user, httptest.NewRequest(http.MethodPost, "/api/v1/auth", nil), []TokenScope{ScopeRead})
+	require.NoError(t, err)
+
+	do := func() int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/timeline", nil)
+		r.Header.Set("Token", token.Value)
+		api.isAuthorized(ScopeRead, api.PingEndpoint())(w, r, nil)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do())
+
+	user, err = api.db.GetUser("alice")
+	require.NoError(t, err)
+	require.NoError(t, user.SetLimit(LimitSuspend, "", time.Time{}))
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	assert.Equal(t, http.StatusForbidden, do())
+}
+
+func TestManageLimits(t *testing.T) {
+	server := newTestServer(t)
+	server.config.AdminUser = "admin"
+
+	mod := &User{Username: "mod", Role: RoleModerator}
+	support := &User{Username: "helper", Role: RoleSupport}
+	alice := &User{Username: "alice"}
+	for _, user := range []*User{{Username: "admin"}, mod, support, alice} {
+		require.NoError(t, server.db.SetUser(user.Username, user))
+	}
+
+	post := func(handler httprouter.Handle, user *User, form url.Values) string {
+		sess := session.NewSession(server.sc)
+		sess.ID = GenerateRandomToken()
+		sess.Data = session.Map{"username": user.Username}
+
+		r := httptest.NewRequest(http.MethodPost, "/manage/limituser", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+
+		w := httptest.NewRecorder()
+		handler(w, r, nil)
+		return w.Body.String()
+	}
+
+	suspend := url.Values{"username": {"alice"}, "limit": {"suspend"}, "duration": {"24h"}, "reason": {"abuse"}}
+
+	assert.Contains(t, post(server.LimitUserHandler(), support, suspend), "You do not have permission to do this!")
+	assert.Contains(t, post(server.LimitUserHandler(), mod, url.Values{"username": {"alice"}, "limit": {"ban"}}), "Invalid account limit")
+	assert.Contains(t, post(server.LimitUserHandler(), mod, url.Values{"username": {"alice"}, "limit": {"suspend"}, "duration": {"forever"}}), "Invalid account limit")
+	assert.Contains(t, post(server.LimitUserHandler(), mod, suspend), "placed on alice successfully")
+
+	alice, err := server.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.True(t, alice.IsSuspended())
+	assert.Equal(t, "abuse", alice.SuspendedReason)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), alice.SuspendedUntil, time.Minute)
+
+	// The suspended user's feed, its signatures, syndication and config are
+	// gone
+	server.config.Features.Enable(FeatureSignedFeeds)
+	for path, handler := range map[string]httprouter.Handle{
+		"/user/alice/twtxt.txt":     server.TwtxtHandler(),
+		"/user/alice/twtxt.txt.sig": server.TwtxtSignaturesHandler(),
+		"/user/alice/atom.xml":      server.SyndicationHandler(),
+		"/user/alice/config.yaml":   server.UserConfigHandler(),
+	} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, path, nil), httprouter.Params{{Key: "nick", Value: "alice"}})
+		assert.Equal(t, http.StatusGone, w.Code, path)
+	}
+
+	assert.Contains(t, post(server.LiftLimitHandler(), mod, url.Values{"username": {"alice"}, "limit": {"suspend"}}), "of alice lifted successfully")
+
+	alice, err = server.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.False(t, alice.IsSuspended())
+	assert.False(t, server.config.SuspendedFeed(URLForUser(server.config.BaseURL, "alice")))
+
+	entries, err := server.db.GetAllAuditEntries()
+	require.NoError(t, err)
+	placed := entries.Filter(AuditFilter{Action: AuditLimitUser, Target: "alice"})
+	require.Len(t, placed, 1)
+	assert.Equal(t, "mod", placed[0].Actor)
+	assert.True(t, strings.HasPrefix(placed[0].After, "suspend until "))
+	assert.Equal(t, "abuse", placed[0].Note)
+	assert.Len(t, entries.Filter(AuditFilter{Action: AuditLiftLimit, Target: "alice"}), 1)
+}
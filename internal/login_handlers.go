@@ -52,6 +52,14 @@ func (s *Server) LoginHandler() httprouter.Handle {
 			return
 		}
 
+		// Linked accounts must login with the OpenID Connect provider
+		if s.config.PasswordLoginDisabled(user) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPasswordLoginDisabled", map[string]interface{}{"Provider": s.config.OIDCName})
+			s.render("error", w, ctx)
+			return
+		}
+
 		// #239: Throttle failed login attempts and lock user  account.
 		if failures.Get(user.Username) > MaxFailedLogins {
 			ctx.Error = true
@@ -76,6 +84,23 @@ func (s *Server) LoginHandler() httprouter.Handle {
 		// #239: Throttle failed login attempts and lock user  account.
 		failures.Reset(user.Username)
 
+		// Suspended users cannot login, the state of the account is only
+		// revealed once the password has been verified
+		if user.IsSuspended() {
+			ctx.Error = true
+			ctx.Message = s.limitMessage(ctx, user, LimitSuspend)
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Users pending approval cannot login
+		if user.PendingApproval {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorAccountPending")
+			s.render("error", w, ctx)
+			return
+		}
+
 		// Lookup session
 		sess := r.Context().Value(session.SessionKey)
 		if sess == nil {
@@ -83,6 +108,12 @@ func (s *Server) LoginHandler() httprouter.Handle {
 			return
 		}
 
+		// Ask for the user's second factor before authorizing the session
+		if user.HasTOTP() {
+			s.beginTwoFactorLogin(w, r, sess.(*session.Session), user.Username, rememberme, r.FormValue("referer"))
+			return
+		}
+
 		// Authorize session
 		_ = sess.(*session.Session).Set("username", username)
 
@@ -239,6 +270,30 @@ func (s *Server) MagicLinkAuthHandler() httprouter.Handle {
 				return
 			}
 
+			// Suspended users cannot login
+			if user.IsSuspended() {
+				ctx.Error = true
+				ctx.Message = s.limitMessage(ctx, user, LimitSuspend)
+				s.render("error", w, ctx)
+				return
+			}
+
+			// Users pending approval cannot login
+			if user.PendingApproval {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorAccountPending")
+				s.render("error", w, ctx)
+				return
+			}
+
+			// Linked accounts must login with the OpenID Connect provider
+			if s.config.PasswordLoginDisabled(user) {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorPasswordLoginDisabled", map[string]interface{}{"Provider": s.config.OIDCName})
+				s.render("error", w, ctx)
+				return
+			}
+
 			// Lookup session
 			sess := r.Context().Value(session.SessionKey)
 			if sess == nil {
@@ -246,6 +301,12 @@ func (s *Server) MagicLinkAuthHandler() httprouter.Handle {
 				return
 			}
 
+			// Ask for the user's second factor before authorizing the session
+			if user.HasTOTP() {
+				s.beginTwoFactorLogin(w, r, sess.(*session.Session), user.Username, true, "/")
+				return
+			}
+
 			// Authorize session
 			_ = sess.(*session.Session).Set("username", user.Username)
 
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,14 +17,15 @@ import (
 
 // ManagePodHandler ...
 func (s *Server) ManagePodHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	isStaffUser := IsStaffUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !isStaffUser(ctx.User) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
@@ -33,6 +35,15 @@ func (s *Server) ManagePodHandler() httprouter.Handle {
 			return
 		}
 
+		if !hasPermission(ctx.User, PermissionEditSettings) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		before := SnapshotSettings(s.config.Settings())
+
 		name := strings.TrimSpace(r.FormValue("podName"))
 		logo := strings.TrimSpace(r.FormValue("podLogo"))
 		description := strings.TrimSpace(r.FormValue("podDescription"))
@@ -41,8 +52,15 @@ func (s *Server) ManagePodHandler() httprouter.Handle {
 		mediaResolution := SafeParseInt(r.FormValue("mediaResolution"), s.config.MediaResolution)
 		openProfiles := r.FormValue("enableOpenProfiles") == "on"
 		openRegistrations := r.FormValue("enableOpenRegistrations") == "on"
+		inviteQuota := SafeParseInt(r.FormValue("inviteQuota"), s.config.InviteQuota)
+		requireApproval := r.FormValue("requireApproval") == "on"
+		registerCaptcha := r.FormValue("registerCaptcha") == "on"
+		spamHideThreshold := SafeParseFloat(r.FormValue("spamHideThreshold"), s.config.SpamHideThreshold)
+		spamHoldThreshold := SafeParseFloat(r.FormValue("spamHoldThreshold"), s.config.SpamHoldThreshold)
 		whitelistedImages := r.FormValue("whitelistedImages")
 		blacklistedFeeds := r.FormValue("blacklistedFeeds")
+		deniedUsernames := r.FormValue("deniedUsernames")
+		disposableEmailDomains := r.FormValue("disposableEmailDomains")
 		enabledFeatures := r.FormValue("enabledFeatures")
 
 		displayDatesInTimezone := r.FormValue("displayDatesInTimezone")
@@ -57,8 +75,19 @@ func (s *Server) ManagePodHandler() httprouter.Handle {
 
 		whitelistedImages = strings.Trim(strings.ReplaceAll(whitelistedImages, "\r\n", "\n"), "\n")
 		blacklistedFeeds = strings.Trim(strings.ReplaceAll(blacklistedFeeds, "\r\n", "\n"), "\n")
+		deniedUsernames = strings.Trim(strings.ReplaceAll(deniedUsernames, "\r\n", "\n"), "\n")
+		disposableEmailDomains = strings.Trim(strings.ReplaceAll(disposableEmailDomains, "\r\n", "\n"), "\n")
 		enabledFeatures = strings.Trim(strings.ReplaceAll(enabledFeatures, "\r\n", "\n"), "\n")
 
+		// Validate spam thresholds
+		if spamHideThreshold < 0 || spamHideThreshold > 1 ||
+			spamHoldThreshold < 0 || spamHoldThreshold > 1 {
+			ctx.Error = true
+			ctx.Message = "Spam thresholds must be between 0 and 1"
+			s.render("error", w, ctx)
+			return
+		}
+
 		// Update pod name
 		if name != "" {
 			s.config.Name = name
@@ -99,6 +128,18 @@ func (s *Server) ManagePodHandler() httprouter.Handle {
 		s.config.OpenProfiles = openProfiles
 		// Update open registrations
 		s.config.OpenRegistrations = openRegistrations
+		// Update invite quota
+		if inviteQuota >= 0 {
+			s.config.InviteQuota = inviteQuota
+		}
+		// Update require approval
+		s.config.RequireApproval = requireApproval
+		// Update register captcha
+		s.config.RegisterCaptcha = registerCaptcha
+
+		// Update spam thresholds
+		s.config.SpamHideThreshold = spamHideThreshold
+		s.config.SpamHoldThreshold = spamHoldThreshold
 
 		// Update WhitelistedImages
 		if err := WithWhitelistedImages(strings.Split(whitelistedImages, "\n"))(s.config); err != nil {
@@ -116,6 +157,22 @@ func (s *Server) ManagePodHandler() httprouter.Handle {
 			return
 		}
 
+		// Update DeniedUsernames
+		if err := WithDeniedUsernames(strings.Split(deniedUsernames, "\n"))(s.config); err != nil {
+			ctx.Error = true
+			ctx.Message = fmt.Sprintf("Error applying denied usernames: %s", err)
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Update DisposableEmailDomains
+		if err := WithDisposableEmailDomains(strings.Split(disposableEmailDomains, "\n"))(s.config); err != nil {
+			ctx.Error = true
+			ctx.Message = fmt.Sprintf("Error applying disposable email domains: %s", err)
+			s.render("error", w, ctx)
+			return
+		}
+
 		// Update Enabled Optional Features
 
 		features, err := FeaturesFromStrings(strings.Split(enabledFeatures, "\n"))
@@ -132,6 +189,13 @@ func (s *Server) ManagePodHandler() httprouter.Handle {
 			return
 		}
 
+		// Create the signing keys of users and feeds when signing is enabled
+		if s.config.Features.IsEnabled(FeatureSignedFeeds) {
+			s.tasks.DispatchFunc(func() error {
+				return MigrateSigningKeys(s.config, s.db)
+			})
+		}
+
 		// Update Pod Settings (overrideable by Users)
 		s.config.DisplayDatesInTimezone = displayDatesInTimezone
 		s.config.DisplayTimePreference = displayTimePreference
@@ -149,6 +213,8 @@ func (s *Server) ManagePodHandler() httprouter.Handle {
 			return
 		}
 
+		AuditSettings(s.db, ctx.Username, before, SnapshotSettings(s.config.Settings()))
+
 		ctx.Error = false
 		ctx.Message = "Pod updated successfully"
 		s.render("error", w, ctx)
@@ -157,32 +223,59 @@ func (s *Server) ManagePodHandler() httprouter.Handle {
 
 // ManageUsersHandler ...
 func (s *Server) ManageUsersHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !hasPermission(ctx.User, PermissionManageUsers) && !hasPermission(ctx.User, PermissionManageFeeds) && !hasPermission(ctx.User, PermissionLimitUsers) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
 
+		if hasPermission(ctx.User, PermissionManageRoles) || hasPermission(ctx.User, PermissionLimitUsers) || hasPermission(ctx.User, PermissionManageUsers) {
+			users, err := s.db.GetAllUsers()
+			if err != nil {
+				log.WithError(err).Error("error loading users")
+			}
+			for _, user := range users {
+				if role := RoleOf(s.config, user); role != "" && hasPermission(ctx.User, PermissionManageRoles) {
+					ctx.Staff = append(ctx.Staff, StaffMember{Username: user.Username, Role: role})
+				}
+				if len(user.Limits()) > 0 && hasPermission(ctx.User, PermissionLimitUsers) {
+					ctx.LimitedUsers = append(ctx.LimitedUsers, user)
+				}
+				if user.PendingApproval && hasPermission(ctx.User, PermissionManageUsers) {
+					ctx.PendingUsers = append(ctx.PendingUsers, user)
+				}
+			}
+			sort.Sort(ctx.Staff)
+			sort.Slice(ctx.LimitedUsers, func(i, j int) bool {
+				return ctx.LimitedUsers[i].Username < ctx.LimitedUsers[j].Username
+			})
+			sort.Slice(ctx.PendingUsers, func(i, j int) bool {
+				return ctx.PendingUsers[i].CreatedAt.Before(ctx.PendingUsers[j].CreatedAt)
+			})
+		}
+
+		ctx.AccountLimits = AccountLimits
+
 		s.render("manageUsers", w, ctx)
 	}
 }
 
 // AddUserHandler ...
 func (s *Server) AddUserHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !hasPermission(ctx.User, PermissionManageUsers) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
@@ -251,6 +344,12 @@ func (s *Server) AddUserHandler() httprouter.Handle {
 			return
 		}
 
+		if err := CreateSigningKey(s.config, s.db, username); err != nil {
+			log.WithError(err).Errorf("error creating signing key for %s", username)
+		}
+
+		Audit(s.db, ctx.Username, AuditAddUser, username, "", "", "")
+
 		ctx.Error = false
 		ctx.Message = "User successfully created"
 		s.render("error", w, ctx)
@@ -259,14 +358,14 @@ func (s *Server) AddUserHandler() httprouter.Handle {
 
 // DelUserHandler ...
 func (s *Server) DelUserHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !hasPermission(ctx.User, PermissionManageUsers) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
@@ -282,6 +381,14 @@ func (s *Server) DelUserHandler() httprouter.Handle {
 			return
 		}
 
+		// Only admins may manage the accounts of other staff
+		if !CanManageUser(s.config, ctx.User, user) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
 		// Get all user feeds
 		feeds, err := s.db.GetAllFeeds()
 		if err != nil {
@@ -423,9 +530,15 @@ func (s *Server) DelUserHandler() httprouter.Handle {
 			return
 		}
 
+		if err := s.db.DelNotifications(user.Username); err != nil {
+			log.WithError(err).Warnf("error deleting notifications for %s", user.Username)
+		}
+
 		// Delete user's feed from cache
 		s.cache.DeleteFeeds(user.Source())
 
+		Audit(s.db, ctx.Username, AuditDelUser, user.Username, "", "", "")
+
 		ctx.Error = false
 		ctx.Message = "Successfully deleted account"
 		s.render("error", w, ctx)
@@ -434,14 +547,14 @@ func (s *Server) DelUserHandler() httprouter.Handle {
 
 // DelFeedHandler ...
 func (s *Server) DelFeedHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !hasPermission(ctx.User, PermissionManageFeeds) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
@@ -479,6 +592,8 @@ func (s *Server) DelFeedHandler() httprouter.Handle {
 		// Delete feed from cache
 		s.cache.DeleteFeeds(feed.Source())
 
+		Audit(s.db, ctx.Username, AuditDelFeed, feed.Name, "", "", "")
+
 		ctx.Error = false
 		ctx.Message = "Successfully deleted account"
 		s.render("error", w, ctx)
@@ -487,14 +602,14 @@ func (s *Server) DelFeedHandler() httprouter.Handle {
 
 // RstUserHandler ...
 func (s *Server) RstUserHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !hasPermission(ctx.User, PermissionManageUsers) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
@@ -513,6 +628,14 @@ func (s *Server) RstUserHandler() httprouter.Handle {
 			return
 		}
 
+		// Only admins may manage the accounts of other staff
+		if !CanManageUser(s.config, ctx.User, user) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
 		newPassword := GenerateRandomToken()
 
 		hash, err := s.pm.CreatePassword(newPassword)
@@ -525,6 +648,11 @@ func (s *Server) RstUserHandler() httprouter.Handle {
 
 		user.Password = hash
 
+		// Log the user out of all sessions and revoke their tokens
+		if err := RevokeOtherSessions(s.sc, user, ""); err != nil {
+			log.WithError(err).Errorf("error revoking sessions for %s", username)
+		}
+
 		// Save user
 		if err := s.db.SetUser(username, user); err != nil {
 			ctx.Error = true
@@ -534,6 +662,8 @@ func (s *Server) RstUserHandler() httprouter.Handle {
 			return
 		}
 
+		Audit(s.db, ctx.Username, AuditRstUser, username, "", "", "")
+
 		ctx.Error = false
 		ctx.Message = fmt.Sprintf(
 			"Successfully reset password for %s to: %s",
@@ -543,9 +673,134 @@ func (s *Server) RstUserHandler() httprouter.Handle {
 	}
 }
 
+// RstTwoFactorHandler disables two-factor authentication for a user who
+// lost access to their authenticator app and their recovery codes
+func (s *Server) RstTwoFactorHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionManageUsers) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		username := NormalizeUsername(r.FormValue("username"))
+
+		trdata := map[string]interface{}{}
+		trdata["Nick"] = username
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading user object for %s", username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorGetUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Only admins may manage the accounts of other staff
+		if !CanManageUser(s.config, ctx.User, user) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		if !user.HasTOTP() {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorTwoFactorNotEnabled", trdata)
+			s.render("error", w, ctx)
+			return
+		}
+
+		user.DisableTOTP()
+
+		// Save user
+		if err := s.db.SetUser(username, user); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		log.Infof("two-factor authentication reset for %s by %s", username, ctx.Username)
+
+		Audit(s.db, ctx.Username, AuditRstTwoFactor, username, "", "", "")
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgTwoFactorReset", trdata)
+		s.render("error", w, ctx)
+	}
+}
+
+// SetRoleHandler assigns a staff role to a user
+func (s *Server) SetRoleHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionManageRoles) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		username := NormalizeUsername(r.FormValue("username"))
+
+		trdata := map[string]interface{}{}
+		trdata["Nick"] = username
+
+		role, err := ParseRole(r.FormValue("role"))
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidRole")
+			s.render("error", w, ctx)
+			return
+		}
+		trdata["Role"] = role
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading user object for %s", username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorGetUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		before := user.Role
+
+		if err := SetUserRole(s.config, s.db, ctx.User, user, role); err != nil {
+			log.WithError(err).Errorf("error setting role of %s", username)
+			ctx.Error = true
+			if err == ErrPermissionDenied {
+				ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		log.Infof("role of %s set to %q by %s", username, role, ctx.Username)
+
+		Audit(s.db, ctx.Username, AuditSetRole, username, string(before), string(role), "")
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgRoleUpdated", trdata)
+		s.render("error", w, ctx)
+	}
+}
+
 // RefreshCacheHandler ...
 func (s *Server) RefreshCacheHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	var UpdateFeeds Job
 	for _, entry := range s.cron.Entries() {
@@ -561,9 +816,9 @@ func (s *Server) RefreshCacheHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !hasPermission(ctx.User, PermissionRunJobs) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
@@ -574,6 +829,8 @@ func (s *Server) RefreshCacheHandler() httprouter.Handle {
 			return nil
 		})
 
+		Audit(s.db, ctx.Username, AuditRefreshCache, "cache", "", "", "")
+
 		ctx.Error = false
 		ctx.Message = "Successfully deleted cache and started fetch cycle"
 		s.render("error", w, ctx)
@@ -582,14 +839,14 @@ func (s *Server) RefreshCacheHandler() httprouter.Handle {
 
 // ManagePeersHandler ...
 func (s *Server) ManagePeersHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !hasPermission(ctx.User, PermissionManagePeers) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
@@ -602,14 +859,14 @@ func (s *Server) ManagePeersHandler() httprouter.Handle {
 
 // ManageJobsHandler ...
 func (s *Server) ManageJobsHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
+	hasPermission := HasPermissionFactory(s.config)
 
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if !isAdminUser(ctx.User) {
+		if !hasPermission(ctx.User, PermissionRunJobs) {
 			ctx.Error = true
-			ctx.Message = "You are not a Pod Owner!"
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
 			s.render("403", w, ctx)
 			return
 		}
@@ -637,6 +894,8 @@ func (s *Server) ManageJobsHandler() httprouter.Handle {
 				return nil
 			})
 
+			Audit(s.db, ctx.Username, AuditRunJob, job.String(), "", "", "")
+
 			ctx.Error = false
 			ctx.Message = fmt.Sprintf("Job %s successfully queued for execution", name)
 			s.render("error", w, ctx)
@@ -648,3 +907,202 @@ func (s *Server) ManageJobsHandler() httprouter.Handle {
 		s.render("manageJobs", w, ctx)
 	}
 }
+
+// ManageReportsHandler lists the abuse reports in the moderation queue and
+// takes moderators' decisions on them
+func (s *Server) ManageReportsHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionViewReports) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			status := ReportStatus(strings.TrimSpace(r.FormValue("status")))
+			if status == "" {
+				status = ReportOpen
+			} else if status == "all" {
+				status = ""
+			}
+
+			reports, err := s.db.GetAllReports()
+			if err != nil {
+				log.WithError(err).Error("error loading reports")
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorLoadingReports")
+				s.render("error", w, ctx)
+				return
+			}
+
+			ctx.Title = s.tr(ctx, "ManageReportsTitle")
+			ctx.Reports = reports.Filter(status)
+			ctx.ReportStatus = status
+			s.render("manageReports", w, ctx)
+			return
+		}
+
+		id := strings.TrimSpace(r.FormValue("id"))
+		action := ReportAction(strings.TrimSpace(r.FormValue("action")))
+		note := strings.TrimSpace(strings.ReplaceAll(r.FormValue("note"), "\r\n", "\n"))
+
+		report, err := s.db.GetReport(id)
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorReportNotFound")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := ModerateReport(s.config, s.cache, s.db, s.sc, ctx.User, report, action, note); err != nil {
+			log.WithError(err).Errorf("error taking action %s on report %s", action, report.ID)
+			ctx.Error = true
+			switch err {
+			case ErrInvalidReportAction:
+				ctx.Message = s.tr(ctx, "ErrorInvalidReportAction")
+			case ErrReportNotAboutTwt:
+				ctx.Message = s.tr(ctx, "ErrorReportNotAboutTwt")
+			case ErrReportNotAboutUser:
+				ctx.Message = s.tr(ctx, "ErrorReportNotAboutUser")
+			case ErrNoReporterEmail:
+				ctx.Message = s.tr(ctx, "ErrorReportNoReporterEmail")
+			case ErrPermissionDenied:
+				ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			default:
+				ctx.Message = s.tr(ctx, "ErrorReportAction", map[string]interface{}{"Error": err.Error()})
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		before := report.Status
+
+		if err := report.Decide(ctx.User.Username, action, note); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidReportAction")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.db.SetReport(report.ID, report); err != nil {
+			log.WithError(err).Errorf("error saving report %s", report.ID)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorReportAction", map[string]interface{}{"Error": err.Error()})
+			s.render("error", w, ctx)
+			return
+		}
+
+		log.Infof("report %s: %s by %s", report.ID, action, ctx.User.Username)
+
+		AuditReport(s.db, report, before)
+
+		http.Redirect(w, r, "/manage/reports", http.StatusFound)
+	}
+}
+
+// ManageSpamHandler lists the twts of external feeds flagged by the spam
+// filter and takes moderators' reviews of them
+func (s *Server) ManageSpamHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionViewReports) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			ctx.Title = s.tr(ctx, "ManageSpamTitle")
+			ctx.SpamVerdicts = s.cache.Spam.Verdicts("")
+			s.render("manageSpam", w, ctx)
+			return
+		}
+
+		hash := strings.TrimSpace(r.FormValue("hash"))
+		action := strings.TrimSpace(r.FormValue("action"))
+
+		if err := ReviewSpam(s.config, s.cache, s.db, ctx.User, hash, action); err != nil {
+			log.WithError(err).Errorf("error taking action %s on spam %s", action, hash)
+			ctx.Error = true
+			switch err {
+			case ErrSpamVerdictNotFound:
+				ctx.Message = s.tr(ctx, "ErrorSpamVerdictNotFound")
+			case ErrInvalidSpamAction:
+				ctx.Message = s.tr(ctx, "ErrorInvalidSpamAction")
+			default:
+				ctx.Message = s.tr(ctx, "ErrorSpamAction", map[string]interface{}{"Error": err.Error()})
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		log.Infof("spam %s: %s by %s", hash, action, ctx.User.Username)
+
+		http.Redirect(w, r, "/manage/spam", http.StatusFound)
+	}
+}
+
+// ManageAuditHandler lists the audit log of admin and moderation actions
+// filtered by actor, action and target, or exports it as JSON Lines
+func (s *Server) ManageAuditHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionViewAudit) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		filter := AuditFilter{
+			Actor:  strings.TrimSpace(r.FormValue("actor")),
+			Action: AuditAction(strings.TrimSpace(r.FormValue("action"))),
+			Target: strings.TrimSpace(r.FormValue("target")),
+		}
+
+		entries, err := s.db.GetAllAuditEntries()
+		if err != nil {
+			log.WithError(err).Error("error loading audit log")
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorLoadingAuditLog")
+			s.render("error", w, ctx)
+			return
+		}
+		entries = entries.Filter(filter)
+
+		if r.FormValue("format") == "jsonl" {
+			data, err := entries.JSONLines()
+			if err != nil {
+				log.WithError(err).Error("error exporting audit log")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/jsonl")
+			w.Header().Set(
+				"Content-Disposition",
+				fmt.Sprintf("attachment; filename=audit-%s.jsonl", time.Now().Format("20060102")),
+			)
+			_, _ = w.Write(data)
+			return
+		}
+
+		ctx.Title = s.tr(ctx, "ManageAuditTitle")
+		ctx.AuditEntries = entries
+		ctx.AuditFilter = filter
+		ctx.AuditActions = AuditActions
+		s.render("manageAudit", w, ctx)
+	}
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"git.mills.io/yarnsocial/yarn/types"
+	"git.mills.io/yarnsocial/yarn/types/lextwt"
+)
+
+const (
+	maxMetadataFields      = 32
+	maxMetadataValueLength = 512
+
+	// hashVersionMetadataKey is the feed metadata field that declares the hash
+	// version the feed's twts are hashed with (See: types.TwtHashVersion)
+	hashVersionMetadataKey = "hash_version"
+)
+
+var (
+	ErrInvalidMetadata     = errors.New("error: invalid metadata field")
+	ErrReservedMetadataKey = errors.New("error: metadata field is reserved")
+	ErrTooManyMetadata     = errors.New("error: too many metadata fields")
+)
+
+// reservedMetadataKeys are metadata fields the pod generates itself for every
+// feed in its preamble (See: defaultPreambleTemplate) and cannot be overridden.
+var reservedMetadataKeys = []string{
+	"nick", "url", "avatar", "description",
+	"follow", "following", "followers",
+	hashVersionMetadataKey, publicKeyMetadataKey, signaturesMetadataKey,
+}
+
+// IsReservedMetadataKey returns true if the given metadata field is generated
+// by the pod and cannot be set by users.
+func IsReservedMetadataKey(key string) bool {
+	return HasString(reservedMetadataKeys, strings.ToLower(key))
+}
+
+// parseMetadataField parses a single metadata field the same way a feed's
+// preamble is parsed by lextwt and returns the resulting comment.
+func parseMetadataField(key, value string) *lextwt.Comment {
+	line := fmt.Sprintf("# %s = %s", key, value)
+	parser := lextwt.NewParser(lextwt.NewLexer(strings.NewReader(line)))
+	return parser.ParseComment()
+}
+
+// ValidateMetadataField validates a single metadata key/value pair against
+// the Metadata Extension as understood by lextwt as well as any additional
+// constraints on well-known fields such as `link`, `prev` and `refresh`.
+// See: [Metadata Extension](https://dev.twtxt.net/doc/metadataextension.html)
+func ValidateMetadataField(key, value string) error {
+	if IsReservedMetadataKey(key) {
+		return fmt.Errorf("%w: %s", ErrReservedMetadataKey, key)
+	}
+
+	if len(value) > maxMetadataValueLength {
+		return fmt.Errorf("%w: %s value is too long", ErrInvalidMetadata, key)
+	}
+
+	comment := parseMetadataField(key, value)
+	if comment == nil || comment.Key() != key || comment.Value() != value {
+		return fmt.Errorf("%w: %s = %s", ErrInvalidMetadata, key, value)
+	}
+
+	switch key {
+	case "link":
+		links := types.NewLinksFromMetadata(url.Values{key: []string{value}})
+		if len(links) != 1 {
+			return fmt.Errorf("%w: link must be of the form <text> <url>", ErrInvalidMetadata)
+		}
+	case "prev":
+		if len(strings.Fields(value)) != 2 {
+			return fmt.Errorf("%w: prev must be of the form <hash> <url>", ErrInvalidMetadata)
+		}
+	case "refresh":
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("%w: refresh must be a positive number of seconds", ErrInvalidMetadata)
+		}
+	}
+
+	return nil
+}
+
+// ValidateMetadata validates and normalizes a set of user supplied metadata
+// fields. Field names are lowercased, surrounding whitespace is stripped and
+// empty fields are dropped (empty values are not valid metadata).
+func ValidateMetadata(metadata url.Values) (url.Values, error) {
+	normalized := make(url.Values)
+
+	n := 0
+	for key, values := range metadata {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+
+			if err := ValidateMetadataField(key, value); err != nil {
+				return nil, err
+			}
+
+			normalized.Add(key, value)
+			n++
+		}
+	}
+
+	if n > maxMetadataFields {
+		return nil, ErrTooManyMetadata
+	}
+
+	return normalized, nil
+}
+
+// ParseMetadataForm returns the metadata fields submitted by the settings
+// and manage feed forms as pairs of `metadata_key` and `metadata_value`
+// inputs. The request's form must already have been parsed.
+func ParseMetadataForm(r *http.Request) url.Values {
+	metadata := make(url.Values)
+
+	keys := r.Form["metadata_key"]
+	values := r.Form["metadata_value"]
+
+	for i, key := range keys {
+		if i >= len(values) {
+			break
+		}
+		metadata.Add(key, values[i])
+	}
+
+	return metadata
+}
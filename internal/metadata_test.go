@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	testCases := []struct {
+		name     string
+		metadata url.Values
+		err      error
+		expected url.Values
+	}{
+		{
+			name:     "Valid Links",
+			metadata: url.Values{"link": {"My Blog https://example.com", "Code https://git.example.com/foo"}},
+			expected: url.Values{"link": {"My Blog https://example.com", "Code https://git.example.com/foo"}},
+		},
+		{
+			name:     "Normalized Keys and Values",
+			metadata: url.Values{" Refresh ": {" 3600 "}, "x-foo": {"", "bar"}, "": {"ignored"}},
+			expected: url.Values{"refresh": {"3600"}, "x-foo": {"bar"}},
+		},
+		{
+			name:     "Reserved Key",
+			metadata: url.Values{"nick": {"foo"}},
+			err:      ErrReservedMetadataKey,
+		},
+		{
+			name:     "Reserved Follow Key",
+			metadata: url.Values{"Follow": {"foo https://example.com/twtxt.txt"}},
+			err:      ErrReservedMetadataKey,
+		},
+		{
+			name:     "Invalid Link",
+			metadata: url.Values{"link": {"not-a-url"}},
+			err:      ErrInvalidMetadata,
+		},
+		{
+			name:     "Invalid Refresh",
+			metadata: url.Values{"refresh": {"soon"}},
+			err:      ErrInvalidMetadata,
+		},
+		{
+			name:     "Multi-line Value",
+			metadata: url.Values{"foo": {"bar\n2021-01-01T00:00:00Z\tinjected twt"}},
+			err:      ErrInvalidMetadata,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := ValidateMetadata(testCase.metadata)
+			if testCase.err != nil {
+				assert.True(t, errors.Is(err, testCase.err), "expected %s got %s", testCase.err, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, actual)
+		})
+	}
+}
+
+func TestValidateMetadataTooMany(t *testing.T) {
+	metadata := make(url.Values)
+	for i := 0; i <= maxMetadataFields; i++ {
+		metadata.Add("foo", "bar")
+	}
+
+	_, err := ValidateMetadata(metadata)
+	assert.Equal(t, ErrTooManyMetadata, err)
+}
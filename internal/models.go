@@ -37,6 +37,14 @@ type Feed struct {
 
 	Followers map[string]string `default:"{}"`
 
+	// Metadata holds additional user defined fields for the feed's preamble
+	// See: [Metadata Extension](https://dev.twtxt.net/doc/metadataextension.html)
+	Metadata url.Values `default:"{}"`
+
+	// legacySigningKey is the private key used to sign the feed's twts as
+	// stored with the feed by older versions (See: MigrateSigningKeys)
+	legacySigningKey []byte
+
 	remotes map[string]string
 }
 
@@ -61,6 +69,7 @@ type User struct {
 	DisplayImagesPreference string `default:"inline"`
 	DisplayMedia            bool   `default:"true"`
 	OriginalMedia           bool   `default:"false"`
+	ExpandContentWarnings   bool   `default:"false"`
 
 	IsFollowersPubliclyVisible bool `default:"true"`
 	IsFollowingPubliclyVisible bool `default:"true"`
@@ -73,6 +82,75 @@ type User struct {
 	Following map[string]string `default:"{}"`
 	Muted     map[string]string `default:"{}"`
 
+	// Filters are the user's content filters keyed by filter ID
+	Filters map[string]*MuteFilter `default:"{}"`
+
+	// Metadata holds additional user defined fields for the user's preamble
+	// See: [Metadata Extension](https://dev.twtxt.net/doc/metadataextension.html)
+	Metadata url.Values `default:"{}"`
+
+	// legacySigningKey is the private key used to sign the user's twts as
+	// stored with the user by older versions (See: MigrateSigningKeys)
+	legacySigningKey []byte
+
+	// Tokens are the API tokens issued to the user keyed by token ID
+	Tokens map[string]*Token `default:"{}"`
+
+	// LegacyTokensRevoked is true once the user revoked their other sessions
+	// which revokes any tokens older versions issued without an ID
+	LegacyTokensRevoked bool `default:"false"`
+
+	// DigestEmail is the verified address email digests are sent to, it is
+	// only stored encrypted (See: EncryptDigestEmail) and only whilst the
+	// user is subscribed to digests
+	DigestEmail     string `default:""`
+	DigestFrequency string `default:""`
+	DigestToken     string `default:""`
+	DigestSentAt    time.Time
+
+	// TOTPSecret is the secret of the user's authenticator app when the user
+	// has enabled two-factor authentication (See: EnableTOTP)
+	TOTPSecret   string `default:""`
+	TOTPLastStep int64
+
+	// RecoveryCodes are the hashes of the user's unused recovery codes
+	RecoveryCodes []string `default:"[]"`
+
+	// OIDCSubject is the subject of the OpenID Connect identity the user's
+	// account is linked to (See: LinkOIDCIdentity)
+	OIDCSubject string `default:""`
+
+	// Role is the user's staff role (See: RoleOf)
+	Role Role `default:""`
+
+	// SuspendedAt is when the user was suspended by a moderator, suspended
+	// users cannot login and their feeds are gone (See: IsSuspended)
+	SuspendedAt     time.Time
+	SuspendedUntil  time.Time
+	SuspendedReason string `default:""`
+
+	// SilencedAt is when the user was silenced by a moderator, the twts of
+	// silenced users are hidden from discover (See: IsSilenced)
+	SilencedAt     time.Time
+	SilencedUntil  time.Time
+	SilencedReason string `default:""`
+
+	// PostingDisabledAt is when a moderator disabled the user's posting
+	// (See: IsPostingDisabled)
+	PostingDisabledAt     time.Time
+	PostingDisabledUntil  time.Time
+	PostingDisabledReason string `default:""`
+
+	// InvitedBy is the username of the user whose invite the user registered
+	// with (See: ReserveInvite)
+	InvitedBy string `default:""`
+
+	// PendingApproval is true for users who registered whilst the pod
+	// required approval until staff who manage users approve them, pending
+	// users cannot login (See: ApproveUser)
+	PendingApproval bool   `default:"false"`
+	JoinReason      string `default:""`
+
 	muted   map[string]string
 	remotes map[string]string
 	sources map[string]string
@@ -119,6 +197,10 @@ func CreateFeed(conf *Config, db Store, user *User, name string, force bool) err
 		return err
 	}
 
+	if err := CreateSigningKey(conf, db, name); err != nil {
+		return err
+	}
+
 	if user != nil {
 		user.Follow(name, feed.URL)
 	}
@@ -166,6 +248,14 @@ func LoadFeed(data []byte) (feed *Feed, err error) {
 	if feed.Followers == nil {
 		feed.Followers = make(map[string]string)
 	}
+	if feed.Metadata == nil {
+		feed.Metadata = make(url.Values)
+	}
+
+	var legacy struct{ SigningKey []byte }
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		feed.legacySigningKey = legacy.SigningKey
+	}
 
 	feed.remotes = make(map[string]string)
 	for n, u := range feed.Followers {
@@ -209,6 +299,20 @@ func LoadUser(data []byte) (user *User, err error) {
 	if user.Following == nil {
 		user.Following = make(map[string]string)
 	}
+	if user.Metadata == nil {
+		user.Metadata = make(url.Values)
+	}
+
+	var legacy struct{ SigningKey []byte }
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		user.legacySigningKey = legacy.SigningKey
+	}
+
+	for _, f := range user.Filters {
+		if err := f.compile(); err != nil {
+			log.WithError(err).Warnf("error compiling filter %s for %s", f.ID, user.Username)
+		}
+	}
 
 	user.muted = make(map[string]string)
 	for n, u := range user.Muted {
@@ -282,6 +386,9 @@ func (f *Feed) Profile(baseURL string, viewer *User) types.Profile {
 		URI:         f.URL,
 		Avatar:      URLForAvatar(baseURL, f.Name, f.AvatarHash),
 
+		Links:    types.NewLinksFromMetadata(f.Metadata),
+		Metadata: f.Metadata,
+
 		Follows:    follows,
 		FollowedBy: followedBy,
 		Muted:      muted,
@@ -294,9 +401,10 @@ func (f *Feed) Profile(baseURL string, viewer *User) types.Profile {
 
 func (f *Feed) Twter(conf *Config) types.Twter {
 	return types.Twter{
-		Nick:   f.Name,
-		URI:    conf.URLForUser(f.Name),
-		Avatar: conf.URLForAvatar(f.Name, f.AvatarHash),
+		Nick:        f.Name,
+		URI:         conf.URLForUser(f.Name),
+		Avatar:      conf.URLForAvatar(f.Name, f.AvatarHash),
+		HashVersion: conf.TwtHashVersion,
 	}
 }
 
@@ -321,6 +429,22 @@ func (u *User) IsZero() bool {
 	return u.Username == ""
 }
 
+// IsSuspended returns true if the user is suspended
+func (u *User) IsSuspended() bool {
+	return u.HasLimit(LimitSuspend)
+}
+
+// IsSilenced returns true if the user is silenced or suspended
+func (u *User) IsSilenced() bool {
+	return u.HasLimit(LimitSilence) || u.IsSuspended()
+}
+
+// IsPostingDisabled returns true if the user's posting is disabled or the
+// user is suspended
+func (u *User) IsPostingDisabled() bool {
+	return u.HasLimit(LimitDisablePosting) || u.IsSuspended()
+}
+
 func (u *User) OwnsFeed(name string) bool {
 	name = NormalizeFeedName(name)
 	for _, feed := range u.Feeds {
@@ -525,6 +649,9 @@ func (u *User) Profile(baseURL string, viewer *User) types.Profile {
 		URI:         URLForUser(baseURL, u.Username),
 		Avatar:      URLForAvatar(baseURL, u.Username, u.AvatarHash),
 
+		Links:    types.NewLinksFromMetadata(u.Metadata),
+		Metadata: u.Metadata,
+
 		Follows:    viewerFollows,
 		FollowedBy: followedByViewer,
 		Muted:      muted,
@@ -543,17 +670,70 @@ func (u *User) Profile(baseURL string, viewer *User) types.Profile {
 	}
 }
 
+// Settings returns the user's settings as returned to the user without any of
+// the user's secrets
+func (u *User) Settings() types.UserSettings {
+	filters := make(map[string]types.MuteFilter, len(u.Filters))
+	for id, filter := range u.Filters {
+		filters[id] = filter.MuteFilter
+	}
+
+	return types.UserSettings{
+		Username:   u.Username,
+		Tagline:    u.Tagline,
+		URL:        u.URL,
+		CreatedAt:  u.CreatedAt,
+		LastSeenAt: u.LastSeenAt,
+
+		Theme:      u.Theme,
+		Lang:       u.Lang,
+		AvatarHash: u.AvatarHash,
+
+		DisplayDatesInTimezone:  u.DisplayDatesInTimezone,
+		DisplayTimePreference:   u.DisplayTimePreference,
+		OpenLinksInPreference:   u.OpenLinksInPreference,
+		HideRepliesPreference:   u.HideRepliesPreference,
+		DisplayImagesPreference: u.DisplayImagesPreference,
+		DisplayMedia:            u.DisplayMedia,
+		OriginalMedia:           u.OriginalMedia,
+		ExpandContentWarnings:   u.ExpandContentWarnings,
+
+		IsFollowersPubliclyVisible: u.IsFollowersPubliclyVisible,
+		IsFollowingPubliclyVisible: u.IsFollowingPubliclyVisible,
+		IsBookmarksPubliclyVisible: u.IsBookmarksPubliclyVisible,
+
+		Feeds: u.Feeds,
+
+		Bookmarks: u.Bookmarks,
+		Followers: u.Followers,
+		Following: u.Following,
+		Muted:     u.Muted,
+
+		Filters:  filters,
+		Metadata: u.Metadata,
+
+		DigestFrequency: u.DigestFrequency,
+		DigestSentAt:    u.DigestSentAt,
+
+		TwoFactorEnabled: u.HasTOTP(),
+		Role:             string(u.Role),
+	}
+}
+
 func (u *User) Twter(conf *Config) types.Twter {
 	return types.Twter{
-		Nick:   u.Username,
-		URI:    conf.URLForUser(u.Username),
-		Avatar: conf.URLForAvatar(u.Username, u.AvatarHash),
+		Nick:        u.Username,
+		URI:         conf.URLForUser(u.Username),
+		Avatar:      conf.URLForAvatar(u.Username, u.AvatarHash),
+		HashVersion: conf.TwtHashVersion,
 	}
 }
 
+// Filter filters out twts from feeds the user has muted and twts hidden by
+// any of the user's filters
 func (u *User) Filter(twts []types.Twt) (filtered []types.Twt) {
 	// fast-path
-	if len(u.muted) == 0 {
+	if len(u.muted) == 0 && len(u.Filters) == 0 {
 		return twts
 	}
 
@@ -562,11 +742,23 @@ func (u *User) Filter(twts []types.Twt) (filtered []types.Twt) {
 		if u.HasMuted(twt.Twter().URI) {
 			continue
 		}
+		if f := u.MatchMuteFilter(twt); f != nil && f.Action == types.MuteFilterHide {
+			continue
+		}
 		filtered = append(filtered, twt)
 	}
 	return
 }
 
+// CollapsedByWarning returns the content warning the twt is collapsed behind
+// or an empty string if it has none or the user always expands them
+func (u *User) CollapsedByWarning(twt types.Twt) string {
+	if u != nil && u.ExpandContentWarnings {
+		return ""
+	}
+	return twt.ContentWarning()
+}
+
 func (u *User) Reply(twt types.Twt) string {
 	// Initialise the list of tokens with the twt's Subject
 	tokens := []string{twt.Subject().String()}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"git.mills.io/yarnsocial/yarn/types"
 	"github.com/julienschmidt/httprouter"
 	log "github.com/sirupsen/logrus"
 )
@@ -83,3 +85,85 @@ func (s *Server) UnmuteHandler() httprouter.Handle {
 
 	}
 }
+
+// AddMuteFilterHandler adds a content filter for the user
+func (s *Server) AddMuteFilterHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		kind := types.MuteFilterKind(r.FormValue("kind"))
+		action := types.MuteFilterAction(r.FormValue("action"))
+		pattern := r.FormValue("pattern")
+
+		expiresIn := SafeParseInt(r.FormValue("expires"), 0)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		f, err := NewMuteFilter(kind, pattern, action, time.Duration(expiresIn)*time.Second)
+		if err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidMuteFilter")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := user.AddMuteFilter(f); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorTooManyMuteFilters")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			log.WithError(err).Errorf("error adding filter for %s", ctx.Username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		s.cache.DeleteUserViews(user)
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgMuteFilterAdded")
+		s.render("error", w, ctx)
+	}
+}
+
+// RemoveMuteFilterHandler removes one of the user's content filters
+func (s *Server) RemoveMuteFilterHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		id := strings.TrimSpace(r.FormValue("id"))
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		if !user.RemoveMuteFilter(id) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorMuteFilterNotFound")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			log.WithError(err).Errorf("error removing filter for %s", ctx.Username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		s.cache.DeleteUserViews(user)
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgMuteFilterRemoved")
+		s.render("error", w, ctx)
+	}
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"net/http"
+
+	"git.mills.io/yarnsocial/yarn/types"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+	"github.com/vcraescu/go-paginator"
+	"github.com/vcraescu/go-paginator/adapter"
+)
+
+func (s *Server) unreadNotifications(user *User) int {
+	unread, err := s.db.GetUnreadNotifications(user.Username)
+	if err != nil {
+		log.WithError(err).Warnf("error loading notifications for %s", user.Username)
+		return 0
+	}
+	return unread
+}
+
+// lookupNotificationTwts returns the twts the notifications are about keyed
+// by hash, notifications of twts no longer in the cache or archive are
+// rendered without their twt.
+func lookupNotificationTwts(cache *Cache, archive Archiver, notifications []*types.Notification) map[string]types.Twt {
+	twts := make(map[string]types.Twt)
+	for _, n := range notifications {
+		if n.Hash == "" {
+			continue
+		}
+		if twt, ok := cache.Lookup(n.Hash); ok {
+			twts[n.Hash] = twt
+		} else if archive.Has(n.Hash) {
+			if twt, err := archive.Get(n.Hash); err == nil {
+				twts[n.Hash] = twt
+			} else {
+				log.WithError(err).Errorf("error loading twt %s from archive", n.Hash)
+			}
+		}
+	}
+	return twts
+}
+
+// NotificationsHandler ...
+func (s *Server) NotificationsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		n, err := s.db.GetNotifications(ctx.Username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading notifications for %s", ctx.Username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorLoadingNotifications")
+			s.render("error", w, ctx)
+			return
+		}
+
+		var notifications []*types.Notification
+
+		page := SafeParseInt(r.FormValue("p"), 1)
+		pager := paginator.New(adapter.NewSliceAdapter(n.Items), s.config.TwtsPerPage)
+		pager.SetPage(page)
+
+		if err := pager.Results(&notifications); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorLoadingNotifications")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.Title = s.tr(ctx, "PageNotificationsTitle")
+		ctx.Notifications = notifications
+		ctx.NotificationTwts = lookupNotificationTwts(s.cache, s.archive, notifications)
+		ctx.Pager = &pager
+
+		s.render("notifications", w, ctx)
+	}
+}
+
+// MarkNotificationsReadHandler marks the notifications with the submitted
+// `id`s, or all notifications if `all` is submitted, as read
+func (s *Server) MarkNotificationsReadHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		all := r.FormValue("all") != ""
+
+		if _, err := MarkNotificationsRead(s.db, ctx.Username, all, r.Form["id"]...); err != nil {
+			log.WithError(err).Errorf("error marking notifications read for %s", ctx.Username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingNotifications")
+			s.render("error", w, ctx)
+			return
+		}
+
+		http.Redirect(w, r, RedirectRefererURL(r, s.config, "/notifications"), http.StatusFound)
+	}
+}
@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+const (
+	// MaxNotifications is the number of notifications kept per user, older
+	// notifications are discarded as newer ones are added.
+	MaxNotifications = 200
+)
+
+// notificationsMu serializes updates to inboxes by the UpdateFeeds job and
+// the user marking notifications as read
+var notificationsMu sync.Mutex
+
+// Notifications is a user's inbox of notifications, most recent first
+type Notifications struct {
+	Items []*types.Notification
+
+	// Followers are the URIs of the followers the user was notified of so
+	// that only new followers are notified
+	Followers map[string]bool
+
+	// Digested are the IDs of the notifications sent in email digests
+	Digested map[string]bool
+
+	UpdatedAt time.Time
+}
+
+// NewNotifications returns an empty inbox
+func NewNotifications() *Notifications {
+	return &Notifications{Followers: make(map[string]bool)}
+}
+
+// LoadNotifications ...
+func LoadNotifications(data []byte) (*Notifications, error) {
+	n := NewNotifications()
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	if n.Followers == nil {
+		n.Followers = make(map[string]bool)
+	}
+	return n, nil
+}
+
+// Bytes ...
+func (n *Notifications) Bytes() ([]byte, error) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Has returns true if the inbox has a notification with the given id
+func (n *Notifications) Has(id string) bool {
+	for _, item := range n.Items {
+		if item.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds new notifications to the inbox returning the number added.
+// Notifications already in the inbox or older than the oldest notification
+// of a full inbox are ignored.
+func (n *Notifications) Add(items ...*types.Notification) int {
+	var added int
+
+	for _, item := range items {
+		if n.Has(item.ID) {
+			continue
+		}
+		if len(n.Items) >= MaxNotifications && !item.CreatedAt.After(n.Items[len(n.Items)-1].CreatedAt) {
+			continue
+		}
+		n.Items = append(n.Items, item)
+		added++
+	}
+
+	sort.SliceStable(n.Items, func(i, j int) bool {
+		return n.Items[i].CreatedAt.After(n.Items[j].CreatedAt)
+	})
+
+	if len(n.Items) > MaxNotifications {
+		n.Items = n.Items[:MaxNotifications]
+	}
+
+	return added
+}
+
+// Unread returns the number of unread notifications
+func (n *Notifications) Unread() int {
+	var unread int
+	for _, item := range n.Items {
+		if !item.Read {
+			unread++
+		}
+	}
+	return unread
+}
+
+// MarkRead marks the notifications with the given ids as read
+func (n *Notifications) MarkRead(ids ...string) {
+	marked := make(map[string]bool)
+	for _, id := range ids {
+		marked[id] = true
+	}
+
+	for _, item := range n.Items {
+		if marked[item.ID] {
+			item.Read = true
+		}
+	}
+}
+
+// MarkAllRead marks all notifications as read
+func (n *Notifications) MarkAllRead() {
+	for _, item := range n.Items {
+		item.Read = true
+	}
+}
+
+// twtNotification returns a notification of type typ about twt
+func twtNotification(typ types.NotificationType, twt types.Twt) *types.Notification {
+	return &types.Notification{
+		ID:        twt.Hash(),
+		Type:      typ,
+		Nick:      twt.Twter().Nick,
+		URI:       twt.Twter().URI,
+		Hash:      twt.Hash(),
+		CreatedAt: twt.Created(),
+	}
+}
+
+// UpdateNotifications adds notifications of new mentions, replies to the
+// user's conversations, forks of the user's twts and new followers from the
+// cache to the user's inbox. The first update of an inbox marks everything
+// as read so that existing users aren't flooded with notifications.
+func UpdateNotifications(conf *Config, cache *Cache, db Store, user *User) (*Notifications, error) {
+	notificationsMu.Lock()
+	defer notificationsMu.Unlock()
+
+	n, err := db.GetNotifications(user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := n.UpdatedAt.IsZero()
+
+	// A twt may be both a reply and a mention, the more specific type wins
+	notifications := make(map[string]*types.Notification)
+	add := func(typ types.NotificationType, twt types.Twt) {
+		if user.Is(twt.Twter().URI) || len(user.Filter([]types.Twt{twt})) == 0 {
+			return
+		}
+		if _, ok := notifications[twt.Hash()]; ok {
+			return
+		}
+		notifications[twt.Hash()] = twtNotification(typ, twt)
+	}
+
+	// Replies to the user's twts are replies if the twt started a
+	// conversation or forks otherwise
+	for _, twt := range cache.GetByURL(user.URL) {
+		typ := types.NotificationFork
+		if hash := ExtractHashFromSubject(twt.Subject().String()); hash == "" || hash == twt.Hash() {
+			typ = types.NotificationReply
+		}
+
+		for _, reply := range cache.GetByView("subject:(#" + twt.Hash() + ")") {
+			add(typ, reply)
+		}
+	}
+
+	for _, twt := range cache.GetMentions(user, false) {
+		add(types.NotificationMention, twt)
+	}
+
+	var items []*types.Notification
+	for _, item := range notifications {
+		item.Read = seed
+		items = append(items, item)
+	}
+
+	now := time.Now()
+
+	followers := make(map[string]bool)
+	for _, follower := range cache.GetFollowers(user.Profile(conf.BaseURL, user)) {
+		if follower.URI == "" || user.Is(follower.URI) {
+			continue
+		}
+		followers[follower.URI] = true
+
+		if n.Followers[follower.URI] {
+			continue
+		}
+		items = append(items, &types.Notification{
+			ID:        "follower:" + follower.URI,
+			Type:      types.NotificationFollower,
+			Nick:      follower.Nick,
+			URI:       follower.URI,
+			CreatedAt: now,
+			Read:      seed,
+		})
+	}
+	n.Followers = followers
+
+	if added := n.Add(items...); added > 0 {
+		log.Debugf("added %d notifications for %s", added, user.Username)
+	}
+
+	n.UpdatedAt = now
+
+	if err := db.SetNotifications(user.Username, n); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// MarkNotificationsRead marks the user's notifications with the given ids, or
+// all of them if all is true, as read
+func MarkNotificationsRead(db Store, username string, all bool, ids ...string) (*Notifications, error) {
+	notificationsMu.Lock()
+	defer notificationsMu.Unlock()
+
+	n, err := db.GetNotifications(username)
+	if err != nil {
+		return nil, err
+	}
+
+	if all {
+		n.MarkAllRead()
+	} else {
+		n.MarkRead(ids...)
+	}
+
+	if err := db.SetNotifications(username, n); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
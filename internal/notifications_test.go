@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestNotificationsAdd(t *testing.T) {
+	now := time.Now()
+
+	n := NewNotifications()
+	assert.Equal(t, 2, n.Add(
+		&types.Notification{ID: "a", CreatedAt: now.Add(-time.Hour)},
+		&types.Notification{ID: "b", CreatedAt: now},
+	))
+	assert.Equal(t, 0, n.Add(&types.Notification{ID: "a", CreatedAt: now}), "duplicates are ignored")
+
+	require.Len(t, n.Items, 2)
+	assert.Equal(t, "b", n.Items[0].ID, "most recent first")
+	assert.Equal(t, 2, n.Unread())
+
+	n.MarkRead("a", "unknown")
+	assert.Equal(t, 1, n.Unread())
+	assert.True(t, n.Items[1].Read)
+
+	n.MarkAllRead()
+	assert.Equal(t, 0, n.Unread())
+
+	for i := 0; i < MaxNotifications; i++ {
+		n.Add(&types.Notification{ID: fmt.Sprintf("n%d", i), CreatedAt: now.Add(time.Duration(i+1) * time.Second)})
+	}
+	assert.Len(t, n.Items, MaxNotifications)
+	assert.False(t, n.Has("a"), "oldest notifications are discarded")
+	assert.Equal(t, 0, n.Add(&types.Notification{ID: "a", CreatedAt: now.Add(-time.Hour)}), "notifications older than a full inbox are ignored")
+}
+
+func TestUpdateNotifications(t *testing.T) {
+	api := newTestAPI(t)
+
+	user := NewUser()
+	user.Username = "alice"
+	user.URL = URLForUser(api.config.BaseURL, user.Username)
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	alice := types.Twter{Nick: "alice", URI: user.URL}
+	bob := types.Twter{Nick: "bob", URI: "https://example.com/bob.txt"}
+	carol := types.Twter{Nick: "carol", URI: "https://example.com/carol.txt"}
+
+	now := time.Now().Add(-time.Hour)
+	mention := fmt.Sprintf("@<alice %s>", user.URL)
+
+	aliceRoot := types.MakeTwt(alice, now, "Hello World!")
+	carolRoot := types.MakeTwt(carol, now, "Who's there?")
+	aliceReply := types.MakeTwt(alice, now.Add(time.Minute), fmt.Sprintf("(#%s) Me!", carolRoot.Hash()))
+
+	update := func(twts ...types.Twt) *Notifications {
+		byURL := make(map[string]types.Twts)
+		for _, twt := range append(types.Twts{aliceRoot, carolRoot, aliceReply}, twts...) {
+			byURL[twt.Twter().URI] = append(byURL[twt.Twter().URI], twt)
+		}
+		for url, twts := range byURL {
+			api.cache.UpdateFeed(url, "", twts)
+		}
+		api.cache.Refresh()
+
+		n, err := UpdateNotifications(api.config, api.cache, api.db, user)
+		require.NoError(t, err)
+		return n
+	}
+
+	// The first update seeds the inbox with everything marked as read
+	older := types.MakeTwt(bob, now, fmt.Sprintf("Hi %s", mention))
+	n := update(older)
+	require.Len(t, n.Items, 1)
+	assert.True(t, n.Items[0].Read)
+
+	reply := types.MakeTwt(bob, now.Add(2*time.Minute), fmt.Sprintf("(#%s) Hi %s", aliceRoot.Hash(), mention))
+	fork := types.MakeTwt(bob, now.Add(3*time.Minute), fmt.Sprintf("(#%s) Not me!", aliceReply.Hash()))
+	newer := types.MakeTwt(carol, now.Add(4*time.Minute), fmt.Sprintf("Hey %s", mention))
+	other := types.MakeTwt(carol, now.Add(5*time.Minute), fmt.Sprintf("(#%s) Me too!", carolRoot.Hash()))
+
+	api.cache.Followers[user.Username] = types.Followers{
+		{Nick: "carol", URI: carol.URI},
+		{Nick: "alice", URI: user.URL},
+	}
+
+	n = update(older, reply, fork, newer, other)
+	assert.Equal(t, 4, n.Unread())
+
+	unread, err := api.db.GetUnreadNotifications(user.Username)
+	require.NoError(t, err)
+	assert.Equal(t, 4, unread)
+
+	got := make(map[string]types.NotificationType)
+	for _, item := range n.Items {
+		got[item.ID] = item.Type
+	}
+	assert.Equal(t, map[string]types.NotificationType{
+		older.Hash():            "mention",
+		reply.Hash():            "reply",
+		fork.Hash():             "fork",
+		newer.Hash():            "mention",
+		"follower:" + carol.URI: "follower",
+	}, got)
+
+	// Subsequent updates don't notify again
+	n = update(older, reply, fork, newer, other)
+	assert.Equal(t, 4, n.Unread())
+
+	var res types.NotificationsResponse
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.NotificationsEndpoint(), user, http.MethodPost, types.PagedRequest{}, &res))
+	assert.Len(t, res.Notifications, 5)
+	assert.Len(t, res.Twts, 4)
+	assert.Equal(t, 4, res.Unread)
+
+	var read types.NotificationsReadResponse
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.NotificationsReadEndpoint(), user, http.MethodPost, types.NotificationsReadRequest{IDs: []string{reply.Hash()}}, &read))
+	assert.Equal(t, 3, read.Unread)
+
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.NotificationsReadEndpoint(), user, http.MethodPost, types.NotificationsReadRequest{All: true}, &read))
+	assert.Equal(t, 0, read.Unread)
+
+	unread, err = api.db.GetUnreadNotifications(user.Username)
+	require.NoError(t, err)
+	assert.Equal(t, 0, unread)
+}
@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	sync "github.com/sasha-s/go-deadlock"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrOIDCDisabled is returned when the pod is not configured to login
+	// with an OpenID Connect provider
+	ErrOIDCDisabled = errors.New("error: openid connect login is disabled")
+
+	// ErrOIDCNonceMismatch is returned when the ID token's nonce does not
+	// match the nonce of the login that was started
+	ErrOIDCNonceMismatch = errors.New("error: openid connect nonce mismatch")
+
+	// ErrOIDCSubjectLinked is returned when linking an account to an
+	// identity that is already linked to another account
+	ErrOIDCSubjectLinked = errors.New("error: openid connect identity already linked")
+)
+
+// OIDCIdentity is the identity of a user verified by the OpenID Connect
+// provider from the claims of their ID token
+type OIDCIdentity struct {
+	Subject  string
+	Username string
+	Claims   map[string]interface{}
+}
+
+// OIDCProvider logs users in with the pod's OpenID Connect provider using the
+// authorization code flow with PKCE. The provider's configuration is
+// discovered on first use so the pod starts even if the provider is down.
+type OIDCProvider struct {
+	sync.Mutex
+
+	conf *Config
+
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+	oauth2   *oauth2.Config
+}
+
+// NewOIDCProvider ...
+func NewOIDCProvider(conf *Config) *OIDCProvider {
+	return &OIDCProvider{conf: conf}
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oauth2.Config, error) {
+	if !p.conf.OIDCEnabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.provider != nil {
+		return p.oauth2, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.conf.OIDCIssuer)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering openid connect provider %s: %w", p.conf.OIDCIssuer, err)
+	}
+
+	p.provider = provider
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.conf.OIDCClientID})
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.conf.OIDCClientID,
+		ClientSecret: p.conf.OIDCClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  fmt.Sprintf("%s/login/oidc/callback", p.conf.BaseURL),
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	}
+
+	return p.oauth2, nil
+}
+
+// AuthCodeURL returns the provider's URL to send the user to to login
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	config, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(codeVerifier))
+
+	return config.AuthCodeURL(
+		state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", codeChallengeMethodS256),
+	), nil
+}
+
+// Exchange exchanges the authorization code the user was redirected back
+// with for an ID token returning the user's verified identity
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce, codeVerifier string) (*OIDCIdentity, error) {
+	config, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging openid connect authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("error: openid connect token response has no id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying openid connect id_token: %w", err)
+	}
+	if idToken.Nonce != nonce || nonce == "" {
+		return nil, ErrOIDCNonceMismatch
+	}
+
+	claims := make(map[string]interface{})
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("error decoding openid connect claims: %w", err)
+	}
+
+	username, _ := claims[p.conf.OIDCUsernameClaim].(string)
+
+	return &OIDCIdentity{
+		Subject:  idToken.Subject,
+		Username: username,
+		Claims:   claims,
+	}, nil
+}
+
+// HasClaims returns true if the identity has all of the required claims.
+// Claims that are lists (e.g: groups) only need to contain the value.
+func (id *OIDCIdentity) HasClaims(required map[string]string) bool {
+	for name, value := range required {
+		if !claimMatches(id.Claims[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func claimMatches(claim interface{}, value string) bool {
+	switch v := claim.(type) {
+	case string:
+		return v == value
+	case bool:
+		return fmt.Sprintf("%t", v) == strings.ToLower(value)
+	case float64:
+		return fmt.Sprintf("%v", v) == value
+	case []interface{}:
+		for _, item := range v {
+			if claimMatches(item, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetUserByOIDCSubject returns the user linked to the OpenID Connect
+// identity with the given subject
+func GetUserByOIDCSubject(db Store, subject string) (*User, error) {
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if user.OIDCSubject != "" && user.OIDCSubject == subject {
+			return user, nil
+		}
+	}
+
+	return nil, ErrUserNotFound
+}
+
+// LinkOIDCIdentity links the user's account to the OpenID Connect identity
+// so they can login with the pod's provider
+func LinkOIDCIdentity(db Store, user *User, subject string) error {
+	if linked, err := GetUserByOIDCSubject(db, subject); err == nil && linked.Username != user.Username {
+		return ErrOIDCSubjectLinked
+	}
+
+	user.OIDCSubject = subject
+	return db.SetUser(user.Username, user)
+}
@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+)
+
+// beginOIDCLogin sends the user to the OpenID Connect provider to login,
+// or to link their account if link is the username of the logged in user
+func (s *Server) beginOIDCLogin(w http.ResponseWriter, r *http.Request, ctx *Context, sess *session.Session, link string) {
+	state := GenerateRandomToken()
+	nonce := GenerateRandomToken()
+	verifier := GenerateRandomToken() + GenerateRandomToken()
+
+	url, err := s.oidc.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.WithError(err).Error("error starting openid connect login")
+		ctx.Error = true
+		ctx.Message = s.tr(ctx, "ErrorOIDCLogin")
+		s.render("error", w, ctx)
+		return
+	}
+
+	_ = sess.Set("oidc_state", state)
+	_ = sess.Set("oidc_nonce", nonce)
+	_ = sess.Set("oidc_verifier", verifier)
+	if link != "" {
+		_ = sess.Set("oidc_link", link)
+	} else {
+		_ = sess.Del("oidc_link")
+	}
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// endOIDCLogin forgets a pending OpenID Connect login
+func endOIDCLogin(sess *session.Session) {
+	for _, key := range []string{"oidc_state", "oidc_nonce", "oidc_verifier", "oidc_link"} {
+		_ = sess.Del(key)
+	}
+}
+
+// OIDCLoginHandler ...
+func (s *Server) OIDCLoginHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		sess := r.Context().Value(session.SessionKey)
+		if sess == nil {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		s.beginOIDCLogin(w, r, ctx, sess.(*session.Session), "")
+	}
+}
+
+// OIDCLinkHandler ...
+func (s *Server) OIDCLinkHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		sess := r.Context().Value(session.SessionKey)
+		if sess == nil {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		s.beginOIDCLogin(w, r, ctx, sess.(*session.Session), ctx.User.Username)
+	}
+}
+
+// OIDCUnlinkHandler ...
+func (s *Server) OIDCUnlinkHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		// Accounts created by the provider have no password to login with
+		if ctx.User.Password == "" {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorOIDCUnlinkNoPassword")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.User.OIDCSubject = ""
+		if err := s.db.SetUser(ctx.User.Username, ctx.User); err != nil {
+			log.WithError(err).Errorf("error unlinking openid connect identity for %s", ctx.User.Username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgOIDCUnlinked")
+		s.render("error", w, ctx)
+	}
+}
+
+// OIDCCallbackHandler completes a login with the OpenID Connect provider
+// logging the user in to their linked account, linking the logged in user's
+// account or creating a new account if auto-provisioning is enabled
+func (s *Server) OIDCCallbackHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		sess, ok := r.Context().Value(session.SessionKey).(*session.Session)
+		if !ok {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		state, _ := sess.Get("oidc_state")
+		nonce, _ := sess.Get("oidc_nonce")
+		verifier, _ := sess.Get("oidc_verifier")
+		link, _ := sess.Get("oidc_link")
+		endOIDCLogin(sess)
+
+		if state == "" || r.FormValue("state") != state {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorOIDCLogin")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if reason := r.FormValue("error"); reason != "" {
+			log.Warnf("openid connect login failed: %s %s", reason, r.FormValue("error_description"))
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorOIDCLogin")
+			s.render("error", w, ctx)
+			return
+		}
+
+		id, err := s.oidc.Exchange(r.Context(), r.FormValue("code"), nonce, verifier)
+		if err != nil {
+			log.WithError(err).Error("error completing openid connect login")
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorOIDCLogin")
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Link the logged in user's account
+		if link != "" {
+			if !ctx.Authenticated || ctx.User.Username != link {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+
+			if err := LinkOIDCIdentity(s.db, ctx.User, id.Subject); err != nil {
+				log.WithError(err).Warnf("error linking openid connect identity for %s", ctx.User.Username)
+				ctx.Error = true
+				if err == ErrOIDCSubjectLinked {
+					ctx.Message = s.tr(ctx, "ErrorOIDCAlreadyLinked")
+				} else {
+					ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+				}
+				s.render("error", w, ctx)
+				return
+			}
+
+			ctx.Error = false
+			ctx.Message = s.tr(ctx, "MsgOIDCLinked", map[string]interface{}{"Provider": s.config.OIDCName})
+			s.render("error", w, ctx)
+			return
+		}
+
+		var provisioned bool
+		user, err := GetUserByOIDCSubject(s.db, id.Subject)
+		if err == ErrUserNotFound {
+			user, err = s.provisionOIDCUser(id, RemoteIP(r))
+			provisioned = err == nil
+		}
+		if err != nil {
+			log.WithError(err).Warnf("no account for openid connect identity %s", id.Subject)
+			ctx.Error = true
+			switch err {
+			case ErrUserNotFound:
+				ctx.Message = s.tr(ctx, "ErrorOIDCNotLinked")
+			case ErrFeedAlreadyExists:
+				ctx.Message = s.tr(ctx, "ErrorOIDCUsernameTaken", map[string]interface{}{"Username": NormalizeUsername(id.Username)})
+			case ErrDeniedUsername, ErrDisposableEmail, ErrTooManySignups:
+				ctx.Message = s.tr(ctx, signupErrorMessage(err))
+			default:
+				ctx.Message = s.tr(ctx, "ErrorOIDCLogin")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Provisioned accounts wait for approval like registered accounts
+		if provisioned && user.PendingApproval {
+			log.Infof("%s registered with openid connect and is pending approval", user.Username)
+
+			go func() {
+				if err := SendPendingApprovalEmail(s.config, user); err != nil {
+					log.WithError(err).Warnf("error sending pending approval email for %s", user.Username)
+				}
+			}()
+
+			ctx.Error = false
+			ctx.Message = s.tr(ctx, "MsgRegistrationPending")
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Suspended users cannot login
+		if user.IsSuspended() {
+			ctx.Error = true
+			ctx.Message = s.limitMessage(ctx, user, LimitSuspend)
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Users pending approval cannot login
+		if user.PendingApproval {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorAccountPending")
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Ask for the user's second factor before authorizing the session
+		if user.HasTOTP() {
+			s.beginTwoFactorLogin(w, r, sess, user.Username, false, "/")
+			return
+		}
+
+		// Authorize session
+		_ = sess.Set("username", user.Username)
+
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// provisionOIDCUser creates a new account linked to the OpenID Connect
+// identity if auto-provisioning is enabled and the identity has all of the
+// required claims. New accounts have no password and take their username
+// from the configured username claim and are subject to the same checks as
+// people registering from ip (See: CheckSignup) and to the pod's approval of
+// new accounts (See: WithRequireApproval).
+func (s *Server) provisionOIDCUser(id *OIDCIdentity, ip string) (*User, error) {
+	if !s.config.OIDCAutoProvision || !id.HasClaims(s.config.OIDCRequiredClaims) {
+		return nil, ErrUserNotFound
+	}
+
+	username := NormalizeUsername(id.Username)
+	if err := ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	if s.db.HasUser(username) || s.db.HasFeed(username) {
+		return nil, ErrFeedAlreadyExists
+	}
+
+	email, _ := id.Claims["email"].(string)
+	if err := CheckSignup(s.config, s.limits, username, email, ip); err != nil {
+		log.WithError(err).Warnf("signup of %s from %s denied", username, ip)
+		return nil, err
+	}
+
+	user, err := s.createUser(username, "", "", s.config.RequireApproval)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := LinkOIDCIdentity(s.db, user, id.Subject); err != nil {
+		return nil, err
+	}
+
+	// Pending users are welcomed once approved
+	if !user.PendingApproval {
+		s.welcomeUser(user)
+	}
+
+	return user, nil
+}
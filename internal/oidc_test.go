@@ -0,0 +1,302 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+)
+
+// mockOIDCProvider is a minimal OpenID Connect provider that issues ID tokens
+// for whatever claims the test authorizes the user with
+type mockOIDCProvider struct {
+	*httptest.Server
+
+	key   *rsa.PrivateKey
+	codes map[string]mockOIDCCode
+}
+
+type mockOIDCCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	mock := &mockOIDCProvider{key: key, codes: make(map[string]mockOIDCCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                mock.URL,
+			"authorization_endpoint":                mock.URL + "/authorize",
+			"token_endpoint":                        mock.URL + "/token",
+			"jwks_uri":                              mock.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		code, ok := mock.codes[r.FormValue("code")]
+		delete(mock.codes, r.FormValue("code"))
+
+		if !ok || clientID != "yarn" || clientSecret != "secret" || !VerifyCodeChallenge(code.challenge, r.FormValue("code_verifier")) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, code.claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+
+	mock.Server = httptest.NewServer(mux)
+	t.Cleanup(mock.Close)
+
+	return mock
+}
+
+// authorize returns the code the provider redirects back to the pod with
+// once the user logs in as the subject with the given claims
+func (mock *mockOIDCProvider) authorize(t *testing.T, authURL string, subject string, claims jwt.MapClaims) url.Values {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	require.Equal(t, mock.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+
+	q := u.Query()
+	require.Equal(t, "yarn", q.Get("client_id"))
+	require.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	if claims == nil {
+		claims = jwt.MapClaims{}
+	}
+	claims["iss"] = mock.URL
+	claims["sub"] = subject
+	claims["aud"] = "yarn"
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = q.Get("nonce")
+	}
+
+	code := GenerateRandomToken()
+	mock.codes[code] = mockOIDCCode{challenge: q.Get("code_challenge"), claims: claims}
+
+	return url.Values{"code": {code}, "state": {q.Get("state")}}
+}
+
+func newTestServer(t *testing.T) *Server {
+	api := newTestAPI(t)
+
+	translator, err := NewTranslator()
+	require.NoError(t, err)
+
+	tmplman, err := NewTemplateManager(api.config, translator, api.cache, api.archive)
+	require.NoError(t, err)
+
+	return &Server{
+		config:     api.config,
+		cache:      api.cache,
+		archive:    api.archive,
+		db:         api.db,
+		api:        api,
+		tmplman:    tmplman,
+		translator: translator,
+		sc:         api.sessions.(*SessionStore),
+		codes:      NewAuthorizationCodes(),
+		oidc:       NewOIDCProvider(api.config),
+	}
+}
+
+var testMetrics sync.Once
+
+// setupTestMetrics registers the server's metrics the cache records when
+// fetching feeds, metrics are global so they are only registered once
+func setupTestMetrics(t *testing.T) {
+	testMetrics.Do(func() {
+		s := newTestServer(t)
+		s.router = NewRouter()
+		s.setupMetrics()
+	})
+}
+
+func TestOIDCIdentityHasClaims(t *testing.T) {
+	id := &OIDCIdentity{Claims: map[string]interface{}{
+		"groups":         []interface{}{"staff", "yarn"},
+		"email_verified": true,
+		"department":     "engineering",
+	}}
+
+	assert.True(t, id.HasClaims(nil))
+	assert.True(t, id.HasClaims(map[string]string{"groups": "yarn"}))
+	assert.True(t, id.HasClaims(map[string]string{"groups": "staff", "email_verified": "true", "department": "engineering"}))
+	assert.False(t, id.HasClaims(map[string]string{"groups": "admins"}))
+	assert.False(t, id.HasClaims(map[string]string{"email_verified": "false"}))
+	assert.False(t, id.HasClaims(map[string]string{"missing": ""}))
+}
+
+func TestOIDCLogin(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	server := newTestServer(t)
+
+	require.NoError(t, WithOIDCIssuer(mock.URL)(server.config))
+	require.NoError(t, WithOIDCClientID("yarn")(server.config))
+	require.NoError(t, WithOIDCClientSecret("secret")(server.config))
+	require.NoError(t, WithOIDCAutoProvision(true, map[string]string{"groups": "yarn"})(server.config))
+
+	sessions := NewSessionStore(server.db, time.Hour)
+
+	newSession := func(username string) *session.Session {
+		sess := session.NewSession(sessions)
+		sess.ID = GenerateRandomToken()
+		sess.Data = make(session.Map)
+		if username != "" {
+			sess.Data["username"] = username
+		}
+		return sess
+	}
+
+	call := func(handler func(http.ResponseWriter, *http.Request), method, target string, sess *session.Session) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		r = r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	// login starts a login (or links the session's user) and completes it as
+	// the subject with the given claims
+	login := func(sess *session.Session, subject string, claims jwt.MapClaims) *httptest.ResponseRecorder {
+		var w *httptest.ResponseRecorder
+		if username, _ := sess.Get("username"); username != "" {
+			w = call(func(w http.ResponseWriter, r *http.Request) { server.OIDCLinkHandler()(w, r, nil) }, http.MethodPost, "/settings/oidc/link", sess)
+		} else {
+			w = call(func(w http.ResponseWriter, r *http.Request) { server.OIDCLoginHandler()(w, r, nil) }, http.MethodGet, "/login/oidc", sess)
+		}
+		require.Equal(t, http.StatusFound, w.Code)
+
+		params := mock.authorize(t, w.Header().Get("Location"), subject, claims)
+		return call(func(w http.ResponseWriter, r *http.Request) { server.OIDCCallbackHandler()(w, r, nil) }, http.MethodGet, "/login/oidc/callback?"+params.Encode(), sess)
+	}
+
+	// Identities without the required claims are not provisioned
+	sess := newSession("")
+	w := login(sess, "1234", jwt.MapClaims{"preferred_username": "alice"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "No account is linked to this identity")
+	_, ok := sess.Get("username")
+	assert.False(t, ok)
+
+	// Provisioned accounts are subject to the pod's signup checks
+	require.NoError(t, WithDeniedUsernames([]string{"^admin"})(server.config))
+	w = login(sess, "4321", jwt.MapClaims{"preferred_username": "administrator", "groups": []string{"yarn"}})
+	assert.Contains(t, w.Body.String(), "This username is not allowed on this pod")
+	assert.False(t, server.db.HasUser("administrator"))
+
+	// Identities with the required claims are provisioned
+	w = login(sess, "1234", jwt.MapClaims{"preferred_username": "Alice", "groups": []string{"yarn"}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	username, _ := sess.Get("username")
+	assert.Equal(t, "alice", username)
+
+	alice, err := server.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Equal(t, "1234", alice.OIDCSubject)
+	assert.Empty(t, alice.Password)
+
+	// Provisioned accounts wait for approval if the pod requires it
+	server.config.RequireApproval = true
+	pending := newSession("")
+	w = login(pending, "2468", jwt.MapClaims{"preferred_username": "carol", "groups": []string{"yarn"}})
+	assert.Contains(t, w.Body.String(), "Thank you for registering!")
+	_, ok = pending.Get("username")
+	assert.False(t, ok)
+
+	carol, err := server.db.GetUser("carol")
+	require.NoError(t, err)
+	assert.True(t, carol.PendingApproval)
+
+	pending = newSession("")
+	w = login(pending, "2468", nil)
+	assert.Contains(t, w.Body.String(), "You will be able to login once it has been approved")
+	_, ok = pending.Get("username")
+	assert.False(t, ok)
+	server.config.RequireApproval = false
+
+	// Linked identities login to their account
+	sess = newSession("")
+	w = login(sess, "1234", nil)
+	assert.Equal(t, http.StatusFound, w.Code)
+	username, _ = sess.Get("username")
+	assert.Equal(t, "alice", username)
+
+	// ID tokens must carry the nonce of the login
+	sess = newSession("")
+	w = login(sess, "1234", jwt.MapClaims{"nonce": "replayed"})
+	assert.Contains(t, w.Body.String(), "Error logging in with your identity provider")
+	_, ok = sess.Get("username")
+	assert.False(t, ok)
+
+	// Logins must be completed with the state they were started with
+	w = call(func(w http.ResponseWriter, r *http.Request) { server.OIDCCallbackHandler()(w, r, nil) }, http.MethodGet, "/login/oidc/callback?code=invalid&state=invalid", sess)
+	assert.Contains(t, w.Body.String(), "Error logging in with your identity provider")
+
+	// Local users can link their account
+	bob := NewUser()
+	bob.Username = "bob"
+	bob.Password = "hash"
+	require.NoError(t, server.db.SetUser(bob.Username, bob))
+
+	w = login(newSession("bob"), "1234", nil)
+	assert.Contains(t, w.Body.String(), "This identity is already linked to another account")
+
+	w = login(newSession("bob"), "5678", nil)
+	assert.Contains(t, w.Body.String(), "Your account is now linked")
+
+	bob, err = server.db.GetUser("bob")
+	require.NoError(t, err)
+	assert.Equal(t, "5678", bob.OIDCSubject)
+
+	assert.False(t, server.config.PasswordLoginDisabled(bob))
+	require.NoError(t, WithOIDCDisablePasswords(true)(server.config))
+	assert.True(t, server.config.PasswordLoginDisabled(bob))
+	assert.False(t, server.config.PasswordLoginDisabled(NewUser()))
+}
@@ -0,0 +1,552 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.mills.io/yarnsocial/yarn"
+	"git.mills.io/yarnsocial/yarn/types"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// OpenAPIVersion is the version of the OpenAPI specification the API is
+	// described with
+	OpenAPIVersion = "3.0.3"
+
+	apiPrefix = "/api/v1"
+
+	openAPISecurityScheme = "token"
+
+	openAPIOAuth2SecurityScheme = "indieauth"
+)
+
+// apiOperation describes a route of the API, the spec is generated from these
+// and the request/response types they reference. Request and Response are zero
+// values of the types the endpoint parses and writes, a nil Response means the
+// endpoint responds with an empty object.
+type apiOperation struct {
+	Method  string
+	Path    string
+	Summary string
+
+	// Scope is the scope a token requires, empty for public routes
+	Scope TokenScope
+
+	Request  interface{}
+	Response interface{}
+
+	// Query are the names of the query parameters the endpoint reads
+	Query []string
+
+	// Form are the names of the multipart form fields the endpoint reads,
+	// fields ending in `_file` are uploaded files
+	Form []string
+
+	// Statuses are the status codes the endpoint responds with besides 200
+	// and those of every operation and of operations requiring a token
+	Statuses []int
+}
+
+// apiOperations must be kept in sync with API.initRoutes(), this is enforced
+// by TestOpenAPIRoutes.
+var apiOperations = []apiOperation{
+	{Method: http.MethodGet, Path: "/ping", Summary: "Checks the API is reachable"},
+	{Method: http.MethodPost, Path: "/auth", Summary: "Issues a new token", Request: types.AuthRequest{}, Response: types.AuthResponse{}, Statuses: []int{http.StatusUnauthorized, http.StatusForbidden}},
+	{Method: http.MethodPost, Path: "/register", Summary: "Registers a new user", Request: types.RegisterRequest{}, Statuses: []int{http.StatusAccepted, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/config", Summary: "Returns the pod's settings", Response: Settings{}},
+	{Method: http.MethodGet, Path: "/openapi.json", Summary: "Returns this specification"},
+
+	{Method: http.MethodPost, Path: "/post", Summary: "Posts a twt or a thread of twts", Scope: ScopePost, Request: types.PostRequest{}},
+	{Method: http.MethodPost, Path: "/upload", Summary: "Uploads media returning its uri", Scope: ScopePost, Form: []string{"media_file"}, Response: URI{}, Statuses: []int{http.StatusAccepted, http.StatusRequestEntityTooLarge}},
+	{Method: http.MethodPost, Path: "/inject", Summary: "Injects a twt into the cache", Scope: ScopePost, Request: reflect.TypeOf((*types.Twt)(nil)).Elem(), Statuses: []int{http.StatusConflict}},
+	{Method: http.MethodPatch, Path: "/post", Summary: "Edits the last twt of the user or a feed they own", Scope: ScopePost, Request: types.EditTwtRequest{}, Response: types.EditTwtResponse{}, Statuses: []int{http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/post", Summary: "Deletes the last twt of the user or a feed they own", Scope: ScopePost, Request: types.DeleteTwtRequest{}, Statuses: []int{http.StatusNotFound}},
+
+	{Method: http.MethodPost, Path: "/bookmark", Summary: "Toggles a bookmark", Scope: ScopePost, Request: types.BookmarkRequest{}, Response: types.BookmarkResponse{}, Statuses: []int{http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/bookmarks", Summary: "Returns a user's bookmarked twts", Request: types.BookmarksRequest{}, Response: types.PagedResponse{}, Statuses: []int{http.StatusUnauthorized, http.StatusNotFound}},
+
+	{Method: http.MethodGet, Path: "/feeds", Summary: "Returns the user's feeds", Scope: ScopeRead, Response: types.FeedsResponse{}},
+	{Method: http.MethodPost, Path: "/feeds", Summary: "Creates a feed", Scope: ScopePost, Request: types.CreateFeedRequest{}, Response: types.FeedResponse{}},
+	{Method: http.MethodPost, Path: "/feeds/:name", Summary: "Updates one of the user's feeds", Scope: ScopePost, Request: types.UpdateFeedRequest{}, Response: types.FeedResponse{}, Statuses: []int{http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/feeds/:name", Summary: "Deletes one of the user's feeds", Scope: ScopePost, Statuses: []int{http.StatusNotFound}},
+
+	{Method: http.MethodGet, Path: "/settings", Summary: "Returns the user's settings", Scope: ScopeRead, Response: types.UserSettings{}},
+	{
+		Method: http.MethodPost, Path: "/settings", Summary: "Updates the user's settings", Scope: ScopePost,
+		Form: []string{"email", "tagline", "password", "isFollowersPubliclyVisible", "isFollowingPubliclyVisible", "avatar_file"},
+	},
+
+	{Method: http.MethodGet, Path: "/metadata", Summary: "Returns the metadata of the user or one of their feeds", Scope: ScopeRead, Query: []string{"feed"}, Response: types.MetadataResponse{}},
+	{Method: http.MethodPost, Path: "/metadata", Summary: "Updates the metadata of the user or one of their feeds", Scope: ScopePost, Request: types.MetadataRequest{}, Response: types.MetadataResponse{}},
+
+	{Method: http.MethodPost, Path: "/follow", Summary: "Follows a feed", Scope: ScopeFollow, Request: types.FollowRequest{}},
+	{Method: http.MethodPost, Path: "/unfollow", Summary: "Unfollows a feed", Scope: ScopeFollow, Request: types.UnfollowRequest{}},
+	{Method: http.MethodPost, Path: "/import", Summary: "Follows many feeds at once", Scope: ScopeFollow, Request: types.ImportRequest{}, Response: types.ImportResponse{}},
+	{Method: http.MethodPost, Path: "/followers", Summary: "Returns a user's or feed's followers", Request: types.FollowersRequest{}, Response: types.FollowersResponse{}, Statuses: []int{http.StatusUnauthorized, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/following", Summary: "Returns the feeds a user follows", Request: types.FollowingRequest{}, Response: types.FollowingResponse{}, Statuses: []int{http.StatusUnauthorized, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/whoFollows", Summary: "Returns the followers of a whoFollows token", Request: types.WhoFollowsRequest{}, Response: types.WhoFollowsResponse{}, Statuses: []int{http.StatusNotFound}},
+
+	{Method: http.MethodPost, Path: "/mute", Summary: "Mutes a feed", Scope: ScopeFollow, Request: types.MuteRequest{}},
+	{Method: http.MethodPost, Path: "/unmute", Summary: "Unmutes a feed", Scope: ScopeFollow, Request: types.UnmuteRequest{}},
+
+	{Method: http.MethodPost, Path: "/filters", Summary: "Returns the user's content filters", Scope: ScopeRead, Response: types.MuteFiltersResponse{}},
+	{Method: http.MethodPost, Path: "/filters/add", Summary: "Adds a content filter hiding or collapsing twts by word, hashtag, regex or replies to muted feeds", Scope: ScopeFollow, Request: types.AddMuteFilterRequest{}, Response: types.MuteFiltersResponse{}},
+	{Method: http.MethodPost, Path: "/filters/remove", Summary: "Removes a content filter", Scope: ScopeFollow, Request: types.RemoveMuteFilterRequest{}, Statuses: []int{http.StatusNotFound}},
+
+	{Method: http.MethodPost, Path: "/timeline", Summary: "Returns the user's timeline", Scope: ScopeRead, Request: types.PagedRequest{}, Response: types.PagedResponse{}},
+	{Method: http.MethodPost, Path: "/discover", Summary: "Returns the pod's local twts", Request: types.PagedRequest{}, Response: types.PagedResponse{}},
+
+	{Method: http.MethodGet, Path: "/profile", Summary: "Returns the user's profile", Response: types.ProfileResponse{}, Statuses: []int{http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/profile/:username", Summary: "Returns a user's or feed's profile", Response: types.ProfileResponse{}, Statuses: []int{http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/fetch-twts", Summary: "Returns a feed's twts", Request: types.FetchTwtsRequest{}, Response: types.PagedResponse{}, Statuses: []int{http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/conv", Summary: "Returns the twts of a conversation", Request: types.ConversationRequest{}, Response: types.PagedResponse{}, Statuses: []int{http.StatusNotFound}},
+
+	{Method: http.MethodPost, Path: "/external", Summary: "Returns an external feed's profile", Request: types.ExternalProfileRequest{}, Response: types.ProfileResponse{}},
+
+	{Method: http.MethodPost, Path: "/mentions", Summary: "Returns the twts mentioning the user", Scope: ScopeRead, Request: types.PagedRequest{}, Response: types.PagedResponse{}},
+
+	{Method: http.MethodPost, Path: "/notifications", Summary: "Returns the user's notifications and the twts they are about", Scope: ScopeRead, Request: types.PagedRequest{}, Response: types.NotificationsResponse{}},
+	{Method: http.MethodPost, Path: "/notifications/read", Summary: "Marks the user's notifications as read", Scope: ScopePost, Request: types.NotificationsReadRequest{}, Response: types.NotificationsReadResponse{}},
+
+	{Method: http.MethodPost, Path: "/sessions", Summary: "Returns the user's active web sessions and API tokens", Scope: ScopeRead, Response: types.SessionsResponse{}},
+	{Method: http.MethodPost, Path: "/sessions/revoke", Summary: "Revokes one or all other of the user's web sessions and API tokens", Scope: ScopePost, Request: types.RevokeSessionRequest{}, Statuses: []int{http.StatusNotFound}},
+
+	{Method: http.MethodPost, Path: "/support", Summary: "Sends a support request to the pod's operator", Scope: ScopePost, Request: types.SupportRequest{}},
+	{Method: http.MethodPost, Path: "/report", Summary: "Reports a feed or twt to the pod's moderation queue", Scope: ScopePost, Request: types.ReportRequest{}},
+
+	{Method: http.MethodPost, Path: "/admin/users", Summary: "Lists the pod's users with their roles", Scope: ScopeAdmin, Response: types.AdminUsersResponse{}},
+	{Method: http.MethodPost, Path: "/admin/users/role", Summary: "Assigns a staff role to a user", Scope: ScopeAdmin, Request: types.AdminSetRoleRequest{}, Statuses: []int{http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/admin/users/limit", Summary: "Silences, disables posting of or suspends a user, or lifts the limit", Scope: ScopeAdmin, Request: types.AdminLimitUserRequest{}, Statuses: []int{http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/admin/reports", Summary: "Lists the abuse reports in the moderation queue", Scope: ScopeAdmin, Request: types.AdminReportsRequest{}, Response: types.AdminReportsResponse{}},
+	{Method: http.MethodPost, Path: "/admin/reports/decide", Summary: "Takes an action on an abuse report", Scope: ScopeAdmin, Request: types.AdminDecideReportRequest{}, Response: types.Report{}, Statuses: []int{http.StatusNotFound}},
+}
+
+// openAPISchemaOverrides describe types whose JSON encoding differs from their
+// Go definition because they implement json.Marshaler.
+var openAPISchemaOverrides = map[reflect.Type]*OpenAPISchema{
+	reflect.TypeOf((*types.Twt)(nil)).Elem(): {
+		Type: "object",
+		Properties: map[string]*OpenAPISchema{
+			"twter":        {Ref: openAPISchemaRef("Twter")},
+			"text":         {Type: "string"},
+			"created":      {Type: "string", Format: "date-time"},
+			"markdownText": {Type: "string"},
+			"hash":         {Type: "string"},
+			"tags":         {Type: "array", Items: &OpenAPISchema{Type: "string"}, Nullable: true},
+			"subject":      {Type: "string"},
+			"mentions":     {Type: "array", Items: &OpenAPISchema{Type: "string"}, Nullable: true},
+			"links":        {Type: "array", Items: &OpenAPISchema{Type: "string"}, Nullable: true},
+		},
+	},
+	reflect.TypeOf(types.Twter{}): {
+		Type: "object",
+		Properties: map[string]*OpenAPISchema{
+			"nick":         {Type: "string"},
+			"uri":          {Type: "string"},
+			"hashing_uri":  {Type: "string"},
+			"url":          {Type: "string"},
+			"avatar":       {Type: "string"},
+			"tagline":      {Type: "string"},
+			"following":    {Type: "integer"},
+			"followers":    {Type: "integer"},
+			"follow":       {Type: "object", AdditionalProperties: &OpenAPISchema{Ref: openAPISchemaRef("Twter")}, Nullable: true},
+			"hash_version": {Type: "integer"},
+		},
+	},
+	reflect.TypeOf(FeatureFlags{}): {
+		Type:     "array",
+		Items:    &OpenAPISchema{Type: "string"},
+		Nullable: true,
+	},
+}
+
+// OpenAPI is an OpenAPI 3 document describing the API
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIInfo ...
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIServer ...
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIOperation ...
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+// OpenAPIParameter ...
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody ...
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse ...
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType ...
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIComponents ...
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+// OpenAPISecurityScheme ...
+type OpenAPISecurityScheme struct {
+	Type        string             `json:"type"`
+	In          string             `json:"in,omitempty"`
+	Name        string             `json:"name,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Flows       *OpenAPIOAuthFlows `json:"flows,omitempty"`
+}
+
+// OpenAPIOAuthFlows ...
+type OpenAPIOAuthFlows struct {
+	AuthorizationCode *OpenAPIOAuthFlow `json:"authorizationCode,omitempty"`
+}
+
+// OpenAPIOAuthFlow ...
+type OpenAPIOAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl"`
+	TokenURL         string            `json:"tokenUrl"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+// OpenAPISchema is the subset of JSON Schema used to describe the API's types
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+func openAPISchemaRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+// openAPIPath converts a router path such as `/feeds/:name` to an OpenAPI
+// path such as `/feeds/{name}` returning the names of its path parameters
+func openAPIPath(path string) (string, []string) {
+	var params []string
+
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+
+	return strings.Join(parts, "/"), params
+}
+
+// NewOpenAPI generates the OpenAPI document describing the API
+func NewOpenAPI(conf *Config) *OpenAPI {
+	spec := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       fmt.Sprintf("%s API", conf.Name),
+			Description: conf.Description,
+			Version:     yarn.Version,
+		},
+		Servers: []OpenAPIServer{{URL: strings.TrimSuffix(conf.BaseURL, "/") + apiPrefix}},
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: make(map[string]*OpenAPISchema),
+			SecuritySchemes: map[string]*OpenAPISecurityScheme{
+				openAPISecurityScheme: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Token",
+					Description: "A token issued by /auth, scopes are one or more of `read`, `post`, `follow` and `admin`",
+				},
+				openAPIOAuth2SecurityScheme: {
+					Type:        "oauth2",
+					Description: "A token issued to a third-party application by the pod's IndieAuth authorization server sent as a Bearer token, PKCE with S256 is required",
+					Flows: &OpenAPIOAuthFlows{
+						AuthorizationCode: &OpenAPIOAuthFlow{
+							AuthorizationURL: strings.TrimSuffix(conf.BaseURL, "/") + "/indieauth/auth",
+							TokenURL:         strings.TrimSuffix(conf.BaseURL, "/") + "/indieauth/token",
+							Scopes: map[string]string{
+								string(ScopeRead):   "Read timelines, mentions and the user's settings",
+								string(ScopePost):   "Post twts, upload media, mark notifications read and update the user's settings and profile",
+								string(ScopeFollow): "Follow, unfollow, mute and unmute feeds",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for t := range openAPISchemaOverrides {
+		spec.schemaForType(t)
+	}
+
+	for _, op := range apiOperations {
+		path, params := openAPIPath(op.Path)
+
+		operation := &OpenAPIOperation{
+			OperationID: strings.ToLower(op.Method) + strings.NewReplacer("/", "_", ":", "", "-", "_").Replace(op.Path),
+			Summary:     op.Summary,
+			Responses: map[string]*OpenAPIResponse{
+				"200": {
+					Description: "OK",
+					Content: map[string]*OpenAPIMediaType{
+						"application/json": {Schema: spec.schemaFor(op.Response)},
+					},
+				},
+				"400": {Description: "Bad Request"},
+				"429": {Description: "Too Many Requests"},
+				"500": {Description: "Internal Server Error"},
+			},
+		}
+
+		for _, param := range params {
+			operation.Parameters = append(operation.Parameters, &OpenAPIParameter{
+				Name: param, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"},
+			})
+		}
+		for _, param := range op.Query {
+			operation.Parameters = append(operation.Parameters, &OpenAPIParameter{
+				Name: param, In: "query", Schema: &OpenAPISchema{Type: "string"},
+			})
+		}
+
+		if op.Request != nil {
+			operation.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content: map[string]*OpenAPIMediaType{
+					"application/json": {Schema: spec.schemaFor(op.Request)},
+				},
+			}
+		} else if op.Form != nil {
+			form := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+			for _, field := range op.Form {
+				if strings.HasSuffix(field, "_file") {
+					form.Properties[field] = &OpenAPISchema{Type: "string", Format: "binary"}
+				} else {
+					form.Properties[field] = &OpenAPISchema{Type: "string"}
+				}
+			}
+			operation.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content: map[string]*OpenAPIMediaType{
+					"multipart/form-data": {Schema: form},
+				},
+			}
+		}
+
+		if op.Scope != "" {
+			operation.Security = []map[string][]string{
+				{openAPISecurityScheme: {string(op.Scope)}},
+			}
+			if op.Scope != ScopeAdmin {
+				operation.Security = append(operation.Security, map[string][]string{
+					openAPIOAuth2SecurityScheme: {string(op.Scope)},
+				})
+			}
+			operation.Responses["401"] = &OpenAPIResponse{Description: "Invalid Token"}
+			operation.Responses["403"] = &OpenAPIResponse{Description: "Insufficient Scope"}
+		}
+
+		for _, code := range op.Statuses {
+			if _, ok := operation.Responses[strconv.Itoa(code)]; ok {
+				continue
+			}
+			response := &OpenAPIResponse{Description: http.StatusText(code)}
+			// Other successful responses have the same body as 200
+			if code < http.StatusMultipleChoices {
+				response.Content = operation.Responses["200"].Content
+			}
+			operation.Responses[strconv.Itoa(code)] = response
+		}
+
+		if spec.Paths[path] == nil {
+			spec.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		spec.Paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	return spec
+}
+
+// schemaFor returns the schema of the type of v, or of v itself if it is a
+// reflect.Type such as that of an interface, named types are added to the
+// document's components and referenced
+func (spec *OpenAPI) schemaFor(v interface{}) *OpenAPISchema {
+	if v == nil {
+		return &OpenAPISchema{Type: "object"}
+	}
+	if t, ok := v.(reflect.Type); ok {
+		return spec.schemaForType(t)
+	}
+	return spec.schemaForType(reflect.TypeOf(v))
+}
+
+func (spec *OpenAPI) schemaForType(t reflect.Type) *OpenAPISchema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	if override, ok := openAPISchemaOverrides[t]; ok {
+		if t.Name() == "" || override.Type != "object" {
+			return override
+		}
+		spec.Components.Schemas[t.Name()] = override
+		return &OpenAPISchema{Ref: openAPISchemaRef(t.Name()), Nullable: nullable || t.Kind() == reflect.Interface}
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(url.Values{}):
+		return &OpenAPISchema{
+			Type:                 "object",
+			AdditionalProperties: &OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "string"}},
+			Nullable:             true,
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &OpenAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &OpenAPISchema{Type: "array", Items: spec.schemaForType(t.Elem()), Nullable: true}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: spec.schemaForType(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		ref := &OpenAPISchema{Ref: openAPISchemaRef(t.Name()), Nullable: nullable}
+		if _, ok := spec.Components.Schemas[t.Name()]; ok {
+			return ref
+		}
+
+		schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+		// Reserve the name before recursing into the fields of the type
+		spec.Components.Schemas[t.Name()] = schema
+		spec.addProperties(schema, t)
+
+		return ref
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+func (spec *OpenAPI) addProperties(schema *OpenAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			spec.addProperties(schema, field.Type)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+
+		schema.Properties[name] = spec.schemaForType(field.Type)
+	}
+}
+
+// Bytes ...
+func (spec *OpenAPI) Bytes() ([]byte, error) {
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// Operations returns the method and path of every operation in the document
+// sorted by path
+func (spec *OpenAPI) Operations() []Route {
+	var routes []Route
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: path})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// OpenAPIEndpoint serves the OpenAPI document describing the API
+func (a *API) OpenAPIEndpoint() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		data, err := NewOpenAPI(a.config).Bytes()
+		if err != nil {
+			log.WithError(err).Error("error serializing openapi spec")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
@@ -0,0 +1,488 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/passwords"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+// newTestAPIRouter returns a test API with its routes registered on a router
+func newTestAPIRouter(t *testing.T) (*API, *Router) {
+	api := newTestAPI(t)
+	api.router = NewRouter()
+
+	// Groups copy the router's trees so the root router must have a route
+	// before the API's group is created, as the Server's does
+	api.router.GET("/", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+
+	api.initRoutes()
+
+	return api, api.router
+}
+
+// validateOpenAPISchema validates a decoded JSON value against a schema of
+// the spec returning the paths of any values that do not match
+func validateOpenAPISchema(spec *OpenAPI, schema *OpenAPISchema, v interface{}, path string) []string {
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, openAPISchemaRef(""))
+		ref, ok := spec.Components.Schemas[name]
+		if !ok {
+			return []string{fmt.Sprintf("%s: unknown schema %s", path, name)}
+		}
+		if v == nil && schema.Nullable {
+			return nil
+		}
+		return validateOpenAPISchema(spec, ref, v, path)
+	}
+
+	if v == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: unexpected null", path)}
+	}
+
+	var errs []string
+
+	switch schema.Type {
+	case "":
+	case "string":
+		if _, ok := v.(string); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected string got %T", path, v))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected boolean got %T", path, v))
+		}
+	case "integer", "number":
+		if _, ok := v.(float64); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected %s got %T", path, schema.Type, v))
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array got %T", path, v)}
+		}
+		for i, item := range items {
+			errs = append(errs, validateOpenAPISchema(spec, schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object got %T", path, v)}
+		}
+		for key, value := range obj {
+			switch {
+			case schema.Properties[key] != nil:
+				errs = append(errs, validateOpenAPISchema(spec, schema.Properties[key], value, path+"."+key)...)
+			case schema.AdditionalProperties != nil:
+				errs = append(errs, validateOpenAPISchema(spec, schema.AdditionalProperties, value, path+"."+key)...)
+			case schema.Properties != nil:
+				errs = append(errs, fmt.Sprintf("%s: undocumented property %s", path, key))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("%s: unknown type %s", path, schema.Type))
+	}
+
+	return errs
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	_, router := newTestAPIRouter(t)
+
+	var routes []Route
+	for _, route := range router.Routes() {
+		if !strings.HasPrefix(route.Path, apiPrefix+"/") {
+			continue
+		}
+		path, _ := openAPIPath(strings.TrimPrefix(route.Path, apiPrefix))
+		routes = append(routes, Route{Method: route.Method, Path: path})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+
+	assert.Equal(t, routes, NewOpenAPI(testConfig).Operations(), "routes registered in API.initRoutes() and apiOperations differ")
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	spec := NewOpenAPI(testConfig)
+
+	data, err := spec.Bytes()
+	require.NoError(t, err)
+
+	// Every $ref must resolve to a schema of the document
+	var doc interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	var refs func(v interface{})
+	refs = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				_, found := spec.Components.Schemas[strings.TrimPrefix(ref, openAPISchemaRef(""))]
+				assert.True(t, found, "unresolved $ref %s", ref)
+			}
+			for _, value := range v {
+				refs(value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				refs(value)
+			}
+		}
+	}
+	refs(doc)
+
+	// Request and response types must round-trip through their schemas
+	twt := types.MakeTwt(testLocalTwter, time.Now(), "Hello @<alice https://example.com/alice> #yarn")
+	values := []interface{}{
+		types.PagedResponse{Twts: types.Twts{twt}, Poll: &types.Poll{Hash: "abc", Options: []types.PollOption{{Text: "Yes", Votes: 1}}}},
+		types.ProfileResponse{Twter: testLocalTwter, Profile: types.OldProfile{Links: types.Links{{Title: "Home", URL: "https://example.com"}}}},
+		types.FollowersResponse{Followers: types.Followers{{Nick: "alice", URI: "https://example.com/alice"}}},
+	}
+	for _, op := range apiOperations {
+		// Interfaces are described by reflect.Type and have no zero value
+		if _, ok := op.Request.(reflect.Type); op.Request != nil && !ok {
+			values = append(values, op.Request)
+		}
+		if op.Response != nil {
+			values = append(values, op.Response)
+		}
+	}
+
+	for _, value := range values {
+		data, err := json.Marshal(value)
+		require.NoError(t, err)
+
+		var v interface{}
+		require.NoError(t, json.Unmarshal(data, &v))
+
+		assert.Empty(t, validateOpenAPISchema(spec, spec.schemaFor(value), v, fmt.Sprintf("%T", value)))
+	}
+}
+
+// openAPIFixture is a valid request to an operation and the state it requires
+type openAPIFixture struct {
+	Body interface{}
+	Form map[string]string
+
+	// Setup prepares the state the request requires
+	Setup func(t *testing.T, api *API, user *User)
+
+	// Token sends the token with the request to a public operation
+	Token bool
+
+	// Status is the status code the request responds with, defaults to 200
+	Status int
+}
+
+func TestOpenAPIOperations(t *testing.T) {
+	const password = "secret"
+
+	// An external feed to follow, fetch and inject twts of
+	feed := fmt.Sprintf("# nick = bob\n%s\tHello from bob\n", time.Now().Add(-time.Hour).Format(time.RFC3339))
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feed))
+	}))
+	defer external.Close()
+
+	bob := types.Twter{Nick: "bob", URI: external.URL + "/twtxt.txt"}
+	tf, err := types.ParseFile(strings.NewReader(feed), &bob)
+	require.NoError(t, err)
+	bobTwt := tf.Twts()[0]
+
+	var (
+		twt    types.Twt
+		filter *MuteFilter
+		report *Report
+	)
+
+	fixtures := map[string]openAPIFixture{
+		"POST /auth":     {Body: types.AuthRequest{Username: "alice", Password: password}},
+		"POST /register": {Body: types.RegisterRequest{Username: "carol", Password: password, Email: "carol@example.com"}},
+
+		"POST /post": {Body: types.PostRequest{PostAs: me, Text: "Hello World!"}},
+		"POST /upload": {
+			Form: map[string]string{"media_file": "avatar.png"},
+			// Media is processed in the background
+			Status: http.StatusAccepted,
+		},
+		"POST /inject": {Body: bobTwt},
+
+		"POST /bookmarks": {Body: types.BookmarksRequest{Nick: "alice"}},
+
+		"POST /feeds":        {Body: types.CreateFeedRequest{Name: "sports"}},
+		"POST /feeds/{name}": {Body: types.UpdateFeedRequest{Description: "The weather"}},
+
+		"POST /settings": {Form: map[string]string{"email": "alice@example.com", "tagline": "Hello"}},
+		"POST /metadata": {Body: types.MetadataRequest{Feed: "alice", Metadata: url.Values{"title": {"Hello"}}}},
+
+		"POST /follow":    {Body: types.FollowRequest{Nick: bob.Nick, URL: bob.URI}},
+		"POST /unfollow":  {Body: types.UnfollowRequest{Nick: "alice"}},
+		"POST /import":    {Body: types.ImportRequest{Follows: map[string]string{bob.Nick: bob.URI}}},
+		"POST /followers": {Body: types.FollowersRequest{Nick: "alice"}},
+		"POST /following": {Body: types.FollowingRequest{Nick: "alice"}},
+		"POST /whoFollows": {Setup: func(t *testing.T, api *API, user *User) {
+			tokenCache.SetString("whofollows", user.URL)
+		}, Body: types.WhoFollowsRequest{Token: "whofollows"}},
+
+		"POST /mute": {Body: types.MuteRequest{Nick: bob.Nick, URL: bob.URI}},
+		"POST /unmute": {Setup: func(t *testing.T, api *API, user *User) {
+			user.Mute(bob.Nick, bob.URI)
+			require.NoError(t, api.db.SetUser(user.Username, user))
+		}, Body: types.UnmuteRequest{Nick: bob.Nick}},
+
+		"POST /filters/add": {Body: types.AddMuteFilterRequest{Kind: types.MuteFilterWord, Pattern: "spam", Action: types.MuteFilterHide}},
+		"POST /filters/remove": {Setup: func(t *testing.T, api *API, user *User) {
+			var err error
+			filter, err = NewMuteFilter(types.MuteFilterWord, "spam", types.MuteFilterHide, 0)
+			require.NoError(t, err)
+			require.NoError(t, user.AddMuteFilter(filter))
+			require.NoError(t, api.db.SetUser(user.Username, user))
+		}},
+
+		"GET /profile":     {Token: true},
+		"POST /fetch-twts": {Body: types.FetchTwtsRequest{Nick: "alice"}},
+		"POST /external":   {Body: types.ExternalProfileRequest{Nick: bob.Nick, URL: bob.URI}},
+
+		"POST /notifications/read": {Body: types.NotificationsReadRequest{All: true}},
+		"POST /sessions/revoke":    {Body: types.RevokeSessionRequest{Others: true}},
+
+		"POST /support": {
+			Body: types.SupportRequest{Name: "Alice", Email: "alice@example.com", Subject: "Hello", Message: "Hello World!"},
+			// No SMTP server is configured
+			Status: http.StatusInternalServerError,
+		},
+		"POST /report": {Body: types.ReportRequest{Nick: bob.Nick, URL: bob.URI, Name: "Alice", Email: "alice@example.com", Category: "spam", Message: "Spam"}},
+
+		"POST /admin/users/role":  {Body: types.AdminSetRoleRequest{Username: "bob", Role: string(RoleModerator)}},
+		"POST /admin/users/limit": {Body: types.AdminLimitUserRequest{Username: "bob", Limit: string(LimitSilence)}},
+		"POST /admin/reports/decide": {Setup: func(t *testing.T, api *API, user *User) {
+			report = NewReport(bob.Nick, bob.URI, "", "spam", "Spam")
+			require.NoError(t, api.db.SetReport(report.ID, report))
+		}},
+	}
+
+	// Fixtures whose bodies depend on the state created by their Setup
+	body := func(key string) interface{} {
+		switch key {
+		case "PATCH /post":
+			return types.EditTwtRequest{Hash: twt.Hash(), Text: "Hello Edited World!"}
+		case "DELETE /post":
+			return types.DeleteTwtRequest{Hash: twt.Hash()}
+		case "POST /bookmark":
+			return types.BookmarkRequest{Hash: twt.Hash()}
+		case "POST /conv":
+			return types.ConversationRequest{Hash: twt.Hash()}
+		case "POST /filters/remove":
+			return types.RemoveMuteFilterRequest{ID: filter.ID}
+		case "POST /admin/reports/decide":
+			return types.AdminDecideReportRequest{ID: report.ID, Action: string(ReportDismiss)}
+		}
+		return fixtures[key].Body
+	}
+
+	// Calibrating scrypt is slow so share the hasher between operations
+	pm := passwords.NewScryptPasswords(nil)
+
+	setupTestMetrics(t)
+
+	setup := func(t *testing.T) (*API, *Router, *User, *Token) {
+		api, router := newTestAPIRouter(t)
+		api.pm = pm
+		api.config.AdminUser = "alice"
+		api.config.OpenRegistrations = true
+		api.config.MaxUploadSize = DefaultMaxUploadSize
+		api.config.MaxCacheTTL = DefaultMaxCacheTTL
+		api.config.MaxCacheFetchers = DefaultMaxCacheFetchers
+		api.config.MaxFetchLimit = DefaultMaxFetchLimit
+
+		hash, err := api.pm.CreatePassword(password)
+		require.NoError(t, err)
+
+		user := NewUser()
+		user.Username = "alice"
+		user.Password = hash
+		user.URL = "http://127.0.0.1:8000/user/alice/twtxt.txt"
+		user.Following = map[string]string{"alice": user.URL}
+
+		other := NewUser()
+		other.Username = "bob"
+		other.URL = "http://127.0.0.1:8000/user/bob/twtxt.txt"
+		require.NoError(t, api.db.SetUser(other.Username, other))
+
+		token, err := api.CreateToken(user, httptest.NewRequest(http.MethodPost, "/api/v1/auth", nil), []TokenScope{ScopeAdmin})
+		require.NoError(t, err)
+		require.NoError(t, CreateFeed(api.config, api.db, user, "weather", false))
+		require.NoError(t, api.db.SetUser(user.Username, user))
+
+		twt, err = AppendTwtFactory(api.config, api.db)(user, nil, "Hello World!")
+		require.NoError(t, err)
+		api.cache.UpdateFeed(user.URL, "", types.Twts{twt})
+		api.cache.Refresh()
+
+		return api, router, user, token
+	}
+
+	request := func(method, path string, body interface{}, form map[string]string) *http.Request {
+		path = strings.NewReplacer("{name}", "weather", "{username}", "alice").Replace(path)
+
+		var buf bytes.Buffer
+		contentType := "application/json"
+		switch {
+		case form != nil:
+			mw := multipart.NewWriter(&buf)
+			for field, value := range form {
+				if strings.HasSuffix(field, "_file") {
+					header := make(textproto.MIMEHeader)
+					header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, field, value))
+					header.Set("Content-Type", "image/png")
+					fw, err := mw.CreatePart(header)
+					require.NoError(t, err)
+					require.NoError(t, png.Encode(fw, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+				} else {
+					require.NoError(t, mw.WriteField(field, value))
+				}
+			}
+			require.NoError(t, mw.Close())
+			contentType = mw.FormDataContentType()
+		case body != nil:
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		case method != http.MethodGet:
+			buf.WriteString(`{}`)
+		}
+
+		r := httptest.NewRequest(method, apiPrefix+path, &buf)
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+
+	// assertDocumented asserts the response's status is documented and that
+	// the body of a successful response matches the documented schema
+	assertDocumented := func(t *testing.T, spec *OpenAPI, op *OpenAPIOperation, w *httptest.ResponseRecorder) {
+		response, ok := op.Responses[strconv.Itoa(w.Code)]
+		if !assert.True(t, ok, "undocumented response %d: %s", w.Code, w.Body.String()) {
+			return
+		}
+		if response.Content == nil {
+			return
+		}
+
+		var v interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v))
+		assert.Empty(t, validateOpenAPISchema(spec, response.Content["application/json"].Schema, v, "response"))
+	}
+
+	spec := NewOpenAPI(testConfig)
+
+	for _, route := range spec.Operations() {
+		route := route
+		key := route.Method + " " + route.Path
+		op := spec.Paths[route.Path][strings.ToLower(route.Method)]
+
+		t.Run(key, func(t *testing.T) {
+			api, router, user, token := setup(t)
+
+			fixture := fixtures[key]
+			if fixture.Setup != nil {
+				fixture.Setup(t, api, user)
+			}
+			status := fixture.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			handle, _, _ := router.Lookup(route.Method, apiPrefix+strings.NewReplacer("{name}", "weather", "{username}", "alice").Replace(route.Path))
+			require.NotNil(t, handle, "no route registered for operation")
+
+			// Operations requiring a token must reject requests without one
+			if op.Security != nil {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, request(route.Method, route.Path, body(key), fixture.Form))
+				assert.Equal(t, http.StatusUnauthorized, w.Code, "operation requires a token but its route is public")
+				assertDocumented(t, spec, op, w)
+			}
+
+			// With a malformed body
+			if op.RequestBody != nil && fixture.Form == nil {
+				r := request(route.Method, route.Path, nil, nil)
+				r.Body = ioutil.NopCloser(strings.NewReader(`{`))
+				r.Header.Set("Token", token.Value)
+
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+				assert.Equal(t, http.StatusBadRequest, w.Code, "malformed request body was accepted")
+				assertDocumented(t, spec, op, w)
+			}
+
+			// The fixture must match the documented request
+			if b := body(key); b != nil && op.RequestBody != nil {
+				data, err := json.Marshal(b)
+				require.NoError(t, err)
+
+				var v interface{}
+				require.NoError(t, json.Unmarshal(data, &v))
+				assert.Empty(t, validateOpenAPISchema(spec, op.RequestBody.Content["application/json"].Schema, v, "request"))
+			}
+
+			// With a valid request, public operations are called anonymously
+			r := request(route.Method, route.Path, body(key), fixture.Form)
+			if op.Security != nil || fixture.Token {
+				r.Header.Set("Token", token.Value)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			assert.Equal(t, status, w.Code, "unexpected response: %s", w.Body.String())
+			assertDocumented(t, spec, op, w)
+		})
+	}
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	_, router := newTestAPIRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, apiPrefix+"/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	wk := httptest.NewRecorder()
+	router.ServeHTTP(wk, httptest.NewRequest(http.MethodGet, "/.well-known/openapi.json", nil))
+	require.Equal(t, http.StatusOK, wk.Code)
+	assert.Equal(t, w.Body.String(), wk.Body.String())
+
+	var spec OpenAPI
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, OpenAPIVersion, spec.OpenAPI)
+	assert.Contains(t, spec.Paths, "/feeds/{name}")
+	assert.Equal(t, []map[string][]string{
+		{openAPISecurityScheme: {string(ScopeRead)}},
+		{openAPIOAuth2SecurityScheme: {string(ScopeRead)}},
+	}, spec.Paths["/timeline"]["post"].Security)
+}
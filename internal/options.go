@@ -2,10 +2,16 @@ package internal
 
 import (
 	_ "embed"
+	"fmt"
+	"net"
 	"net/url"
 	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
+
+	"git.mills.io/yarnsocial/yarn/types"
 )
 
 const (
@@ -54,6 +60,22 @@ const (
 	// DefaultOpenRegistrations is the default for open user registrations
 	DefaultOpenRegistrations = false
 
+	// DefaultInviteQuota is the default number of invites each user can
+	// create, zero only lets staff who manage invites create invites
+	DefaultInviteQuota = 0
+
+	// DefaultInviteExpiry is how long invites created by users (not staff)
+	// are valid for
+	DefaultInviteExpiry = 7 * 24 * time.Hour
+
+	// DefaultRequireApproval is the default for whether new accounts are
+	// pending until approved by staff who manage users
+	DefaultRequireApproval = false
+
+	// DefaultRegisterCaptcha is the default for whether people must solve a
+	// captcha to register
+	DefaultRegisterCaptcha = false
+
 	// DefaultDisableGzip is the default for disabling Gzip compression
 	DefaultDisableGzip = false
 
@@ -78,6 +100,10 @@ const (
 	// DefaultMaxTwtLength is the default maximum length of posts permitted
 	DefaultMaxTwtLength = 288
 
+	// DefaultMaxThreadLength is the default maximum number of twts of a
+	// thread posted at once
+	DefaultMaxThreadLength = 10
+
 	// DefaultMaxCacheTTL is the default maximum cache ttl of twts in memory
 	DefaultMaxCacheTTL = time.Hour * 24 * 10 // 10 days 28 days 28 days 28 days
 
@@ -89,6 +115,9 @@ const (
 	// of twts in memory
 	DefaultMaxCacheItems = DefaultTwtsPerPage * 3 // We get bored after paging thorughh > 3 pages :D
 
+	// DefaultTwtHashVersion is the default hash version for local feeds
+	DefaultTwtHashVersion = int(types.DefaultTwtHashVersion)
+
 	// DefaultOpenProfiles is the default for whether or not to have open user profiles
 	DefaultOpenProfiles = false
 
@@ -124,9 +153,22 @@ const (
 	// DefaultAPISessionTime is the server's default session time for API tokens
 	DefaultAPISessionTime = 240 * time.Hour // 10 days
 
+	// DefaultAPILegacyTokens is the default for whether to accept the
+	// deprecated tokens older versions issued without an ID, they are rejected
+	// by default as they never expire
+	DefaultAPILegacyTokens = false
+
 	// DefaultAPISigningKey is the default API JWT signing key for tokens
 	DefaultAPISigningKey = InvalidConfigValue
 
+	// DefaultOIDCName is the default name of the OpenID Connect provider
+	// shown on the login button
+	DefaultOIDCName = "Single Sign-On"
+
+	// DefaultOIDCUsernameClaim is the default claim new accounts take their
+	// username from when logging in with an OpenID Connect provider
+	DefaultOIDCUsernameClaim = "preferred_username"
+
 	// MinimumCacheFetchInterval is the smallest allowable cache fetch interval for
 	// production pods, an attempt to configure a pod with a smaller value than this
 	// results in a configuration validation error.
@@ -175,6 +217,26 @@ var (
 	// blacklisted and prohibuted from being fetched by the global feed cache
 	DefaultBlacklistedFeeds = []string{}
 
+	// DefaultDeniedUsernames is the default list of username patterns people
+	// cannot register with
+	DefaultDeniedUsernames = []string{}
+
+	// DefaultDisposableEmailDomains is the default list of disposable email
+	// domains people cannot register with
+	DefaultDisposableEmailDomains = []string{
+		"10minutemail.com",
+		"discard.email",
+		"dispostable.com",
+		"getnada.com",
+		"guerrillamail.com",
+		"mailinator.com",
+		"maildrop.cc",
+		"sharklasers.com",
+		"temp-mail.org",
+		"trashmail.com",
+		"yopmail.com",
+	}
+
 	// DefaultMaxCacheFetchers is the default maximun number of fetchers used
 	// by the global feed cache during update cycles. This controls how quickly
 	// feeds are updated in each feed cache cycle. The default is the number of
@@ -200,6 +262,30 @@ var (
 
 	// OriginalMedia is the default for whether to link or display original media or not
 	OriginalMedia bool
+
+	// DefaultRateLimits are the default request budgets of rate limited routes
+	// as `<requests>/<period>`, the `api` budget applies to all API requests
+	// in addition to the API route's own budget. A budget of `0/1m` disables
+	// rate limiting of its routes.
+	DefaultRateLimits = map[string]string{
+		"api":      "120/1m",
+		"auth":     "5/1m",
+		"login":    "5/1m",
+		"register": "3/1h",
+		"signup":   "3/24h",
+		"post":     "30/1m",
+		"upload":   "10/1m",
+		"support":  "3/1h",
+	}
+
+	// DefaultTrustedProxies is the default list of addresses (or networks) of
+	// reverse proxies whose X-Forwarded-For headers are trusted, by default a
+	// reverse proxy on the same host
+	DefaultTrustedProxies = []string{"127.0.0.0/8", "::1"}
+
+	// DefaultOldMagicLinkSecrets is the default list of previous magiclink
+	// secrets digest addresses are still decrypted with
+	DefaultOldMagicLinkSecrets = []string{}
 )
 
 func NewConfig() *Config {
@@ -220,10 +306,17 @@ func NewConfig() *Config {
 		TwtPrompts:              DefaultTwtPrompts,
 		TwtsPerPage:             DefaultTwtsPerPage,
 		MaxTwtLength:            DefaultMaxTwtLength,
+		MaxThreadLength:         DefaultMaxThreadLength,
 		AvatarResolution:        DefaultAvatarResolution,
 		MediaResolution:         DefaultMediaResolution,
 		OpenProfiles:            DefaultOpenProfiles,
 		OpenRegistrations:       DefaultOpenRegistrations,
+		InviteQuota:             DefaultInviteQuota,
+		RequireApproval:         DefaultRequireApproval,
+		RegisterCaptcha:         DefaultRegisterCaptcha,
+		SpamHideThreshold:       DefaultSpamHideThreshold,
+		SpamHoldThreshold:       DefaultSpamHoldThreshold,
+		DisposableEmailDomains:  DefaultDisposableEmailDomains,
 		DisableGzip:             DefaultDisableGzip,
 		DisableLogger:           DefaultDisableLogger,
 		DisableFfmpeg:           DefaultDisableFfmpeg,
@@ -234,12 +327,73 @@ func NewConfig() *Config {
 		OpenLinksInPreference:   DefaultOpenLinksInPreference,
 		DisplayImagesPreference: DefaultDisplayImagesPreference,
 		SessionExpiry:           DefaultSessionExpiry,
+		APISessionTime:          DefaultAPISessionTime,
+		APILegacyTokens:         DefaultAPILegacyTokens,
+		APISigningKey:           DefaultAPISigningKey,
 		MagicLinkSecret:         DefaultMagicLinkSecret,
 		SMTPHost:                DefaultSMTPHost,
 		SMTPPort:                DefaultSMTPPort,
 		SMTPUser:                DefaultSMTPUser,
 		SMTPPass:                DefaultSMTPPass,
+		TwtHashVersion:          types.DefaultTwtHashVersion,
+		RateLimits:              mustParseRateLimits(DefaultRateLimits),
+		TrustedProxies:          DefaultTrustedProxies,
+		trustedProxies:          mustParseTrustedProxies(DefaultTrustedProxies),
+		OIDCName:                DefaultOIDCName,
+		OIDCUsernameClaim:       DefaultOIDCUsernameClaim,
+	}
+}
+
+func mustParseRateLimits(limits map[string]string) map[string]RateLimit {
+	parsed := make(map[string]RateLimit)
+	for name, limit := range limits {
+		rl, err := ParseRateLimit(limit)
+		if err != nil {
+			panic(fmt.Sprintf("invalid rate limit %s=%s", name, limit))
+		}
+		parsed[name] = rl
 	}
+	return parsed
+}
+
+func mustParseTrustedProxies(trustedProxies []string) []*net.IPNet {
+	_, networks, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
+// parseTrustedProxies parses a list of addresses or networks (CIDRs) of
+// reverse proxies, addresses are normalized to single address networks
+func parseTrustedProxies(trustedProxies []string) ([]string, []*net.IPNet, error) {
+	var (
+		normalized []string
+		networks   []*net.IPNet
+	)
+	for _, trustedProxy := range trustedProxies {
+		if trustedProxy = strings.TrimSpace(trustedProxy); trustedProxy == "" {
+			continue
+		}
+		if !strings.Contains(trustedProxy, "/") {
+			ip := net.ParseIP(trustedProxy)
+			if ip == nil {
+				return nil, nil, fmt.Errorf("error parsing trusted proxy %s", trustedProxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trustedProxy = fmt.Sprintf("%s/%d", ip, bits)
+		}
+		_, network, err := net.ParseCIDR(trustedProxy)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing trusted proxy %s: %w", trustedProxy, err)
+		}
+		normalized = append(normalized, trustedProxy)
+		networks = append(networks, network)
+	}
+	return normalized, networks, nil
 }
 
 // Option is a function that takes a config struct and modifies it
@@ -346,6 +500,49 @@ func WithOpenRegistrations(openRegistrations bool) Option {
 	}
 }
 
+// WithRequireApproval sets whether new accounts are pending until approved by
+// staff who manage users
+func WithRequireApproval(requireApproval bool) Option {
+	return func(cfg *Config) error {
+		cfg.RequireApproval = requireApproval
+		return nil
+	}
+}
+
+// WithRegisterCaptcha sets whether people must solve a captcha to register
+func WithRegisterCaptcha(registerCaptcha bool) Option {
+	return func(cfg *Config) error {
+		cfg.RegisterCaptcha = registerCaptcha
+		return nil
+	}
+}
+
+// WithInviteQuota sets the number of invites each user can create
+func WithInviteQuota(inviteQuota int) Option {
+	return func(cfg *Config) error {
+		cfg.InviteQuota = inviteQuota
+		return nil
+	}
+}
+
+// WithSpamHideThreshold sets the spam score at or above which twts of
+// external feeds are hidden from discover (0 to disable)
+func WithSpamHideThreshold(threshold float64) Option {
+	return func(cfg *Config) error {
+		cfg.SpamHideThreshold = threshold
+		return nil
+	}
+}
+
+// WithSpamHoldThreshold sets the spam score at or above which twts of
+// external feeds are held for review (0 to disable)
+func WithSpamHoldThreshold(threshold float64) Option {
+	return func(cfg *Config) error {
+		cfg.SpamHoldThreshold = threshold
+		return nil
+	}
+}
+
 // WithDisableGzip sets the disable Gzip flag
 func WithDisableGzip(disableGzip bool) Option {
 	return func(cfg *Config) error {
@@ -402,6 +599,15 @@ func WithMaxTwtLength(maxTwtLength int) Option {
 	}
 }
 
+// WithMaxThreadLength sets the maximum number of twts of a thread posted at
+// once
+func WithMaxThreadLength(maxThreadLength int) Option {
+	return func(cfg *Config) error {
+		cfg.MaxThreadLength = maxThreadLength
+		return nil
+	}
+}
+
 // WithMaxCacheTTL sets the maximum cache ttl of twts in memory
 func WithMaxCacheTTL(maxCacheTTL time.Duration) Option {
 	return func(cfg *Config) error {
@@ -435,6 +641,18 @@ func WithMaxCacheItems(maxCacheItems int) Option {
 	}
 }
 
+// WithTwtHashVersion sets the hash version local feeds hash their twts with
+func WithTwtHashVersion(version int) Option {
+	return func(cfg *Config) error {
+		hv, err := types.ParseTwtHashVersion(strconv.Itoa(version))
+		if err != nil {
+			return err
+		}
+		cfg.TwtHashVersion = hv
+		return nil
+	}
+}
+
 // WithOpenProfiles sets whether or not to have open user profiles
 func WithOpenProfiles(openProfiles bool) Option {
 	return func(cfg *Config) error {
@@ -483,6 +701,20 @@ func WithMagicLinkSecret(secret string) Option {
 	}
 }
 
+// WithOldMagicLinkSecrets sets the previous MagicLinkSecrets digest addresses
+// encrypted before the secret was rotated are decrypted with
+func WithOldMagicLinkSecrets(secrets []string) Option {
+	return func(cfg *Config) error {
+		cfg.OldMagicLinkSecrets = nil
+		for _, secret := range secrets {
+			if secret = strings.TrimSpace(secret); secret != "" {
+				cfg.OldMagicLinkSecrets = append(cfg.OldMagicLinkSecrets, secret)
+			}
+		}
+		return nil
+	}
+}
+
 // WithSMTPHost sets the SMTPHost to use for sending email
 func WithSMTPHost(host string) Option {
 	return func(cfg *Config) error {
@@ -547,6 +779,112 @@ func WithAPISigningKey(key string) Option {
 	}
 }
 
+// WithAPILegacyTokens sets whether to accept the deprecated tokens older
+// versions issued without an ID, such tokens never expire and cannot be
+// revoked individually so they are only granted DefaultTokenScopes and are
+// revoked when the user revokes their other sessions. Support for them will be
+// removed in a future version.
+func WithAPILegacyTokens(legacyTokens bool) Option {
+	return func(cfg *Config) error {
+		cfg.APILegacyTokens = legacyTokens
+		return nil
+	}
+}
+
+// WithRateLimits overrides the request budgets of rate limited routes
+func WithRateLimits(limits map[string]string) Option {
+	return func(cfg *Config) error {
+		if cfg.RateLimits == nil {
+			cfg.RateLimits = make(map[string]RateLimit)
+		}
+		for name, limit := range limits {
+			rl, err := ParseRateLimit(limit)
+			if err != nil {
+				return fmt.Errorf("error parsing rate limit %s=%s: %w", name, limit, err)
+			}
+			cfg.RateLimits[name] = rl
+		}
+		return nil
+	}
+}
+
+// WithTrustedProxies sets the list of addresses or networks (CIDRs) of
+// reverse proxies whose X-Forwarded-For headers are trusted
+func WithTrustedProxies(trustedProxies []string) Option {
+	return func(cfg *Config) error {
+		normalized, networks, err := parseTrustedProxies(trustedProxies)
+		if err != nil {
+			return err
+		}
+		cfg.TrustedProxies = normalized
+		cfg.trustedProxies = networks
+		return nil
+	}
+}
+
+// WithOIDCIssuer sets the issuer URL of the OpenID Connect provider users
+// can login with, an empty issuer disables OpenID Connect login
+func WithOIDCIssuer(issuer string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCIssuer = strings.TrimSuffix(issuer, "/")
+		return nil
+	}
+}
+
+// WithOIDCClientID sets the client ID the pod is registered with at the
+// OpenID Connect provider
+func WithOIDCClientID(clientID string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCClientID = clientID
+		return nil
+	}
+}
+
+// WithOIDCClientSecret sets the client secret the pod is registered with at
+// the OpenID Connect provider
+func WithOIDCClientSecret(clientSecret string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCClientSecret = clientSecret
+		return nil
+	}
+}
+
+// WithOIDCName sets the name of the OpenID Connect provider shown on the
+// login button
+func WithOIDCName(name string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCName = name
+		return nil
+	}
+}
+
+// WithOIDCUsernameClaim sets the claim new accounts take their username from
+func WithOIDCUsernameClaim(claim string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCUsernameClaim = claim
+		return nil
+	}
+}
+
+// WithOIDCAutoProvision enables creating accounts for users logging in with
+// the OpenID Connect provider who have all of the required claims
+func WithOIDCAutoProvision(autoProvision bool, requiredClaims map[string]string) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCAutoProvision = autoProvision
+		cfg.OIDCRequiredClaims = requiredClaims
+		return nil
+	}
+}
+
+// WithOIDCDisablePasswords disables password login for accounts linked to
+// the OpenID Connect provider
+func WithOIDCDisablePasswords(disablePasswords bool) Option {
+	return func(cfg *Config) error {
+		cfg.OIDCDisablePasswords = disablePasswords
+		return nil
+	}
+}
+
 // WithWhitelistedImages sets the list of image domains whitelisted
 // and permitted for external iamges to display inline
 func WithWhitelistedImages(whitelistedImages []string) Option {
@@ -566,11 +904,108 @@ func WithWhitelistedImages(whitelistedImages []string) Option {
 	}
 }
 
+// WithHiddenTwts sets the hashes of twts hidden pod-wide by moderators
+func WithHiddenTwts(hiddenTwts []string) Option {
+	return func(cfg *Config) error {
+		cfg.mu.Lock()
+		defer cfg.mu.Unlock()
+		cfg.setHiddenTwts(hiddenTwts)
+		return nil
+	}
+}
+
+// WithMutedFeeds sets the uris of feeds muted pod-wide by moderators
+func WithMutedFeeds(mutedFeeds []string) Option {
+	return func(cfg *Config) error {
+		cfg.mu.Lock()
+		defer cfg.mu.Unlock()
+		cfg.setMutedFeeds(mutedFeeds)
+		return nil
+	}
+}
+
+// WithSilencedFeeds sets the urls of silenced users' feeds
+func WithSilencedFeeds(silencedFeeds []string) Option {
+	return func(cfg *Config) error {
+		silenced := make(map[string]bool)
+		for _, uri := range silencedFeeds {
+			if uri = NormalizeURL(uri); uri != "" {
+				silenced[uri] = true
+			}
+		}
+		cfg.mu.Lock()
+		cfg.silencedFeeds = silenced
+		cfg.mu.Unlock()
+		return nil
+	}
+}
+
+// WithSuspendedFeeds sets the urls of suspended users' feeds
+func WithSuspendedFeeds(suspendedFeeds []string) Option {
+	return func(cfg *Config) error {
+		suspended := make(map[string]bool)
+		for _, uri := range suspendedFeeds {
+			if uri = NormalizeURL(uri); uri != "" {
+				suspended[uri] = true
+			}
+		}
+		cfg.mu.Lock()
+		cfg.suspendedFeeds = suspended
+		cfg.mu.Unlock()
+		return nil
+	}
+}
+
+// WithBlocklist sets the entries of the pod's blocklist
+func WithBlocklist(blocklist []*BlockEntry) Option {
+	return func(cfg *Config) error {
+		return cfg.updateBlocklist(func([]*BlockEntry) ([]*BlockEntry, error) {
+			return blocklist, nil
+		})
+	}
+}
+
+// WithDeniedUsernames sets the list of username patterns (regexes) people
+// cannot register with
+func WithDeniedUsernames(deniedUsernames []string) Option {
+	return func(cfg *Config) error {
+		cfg.DeniedUsernames = deniedUsernames
+		cfg.deniedUsernames = nil
+		for _, deniedUsername := range deniedUsernames {
+			if deniedUsername == "" {
+				continue
+			}
+			re, err := regexp.Compile(deniedUsername)
+			if err != nil {
+				return err
+			}
+			cfg.deniedUsernames = append(cfg.deniedUsernames, re)
+		}
+		return nil
+	}
+}
+
+// WithDisposableEmailDomains sets the list of disposable email domains people
+// cannot register with
+func WithDisposableEmailDomains(disposableEmailDomains []string) Option {
+	return func(cfg *Config) error {
+		var domains []string
+		for _, domain := range disposableEmailDomains {
+			if domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."); domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+		cfg.DisposableEmailDomains = domains
+		return nil
+	}
+}
+
 // WithBlacklistedFeeds sets the list of feed uris blacklisted
 // and prohibited from being fetched by the global feed cache
 func WithBlacklistedFeeds(blacklistedFeeds []string) Option {
 	return func(cfg *Config) error {
 		cfg.BlacklistedFeeds = blacklistedFeeds
+		cfg.blacklistedFeeds = nil
 		for _, blacklistedFeed := range blacklistedFeeds {
 			if blacklistedFeed == "" {
 				continue
@@ -236,6 +236,11 @@ func (s *Server) NewPasswordHandler() httprouter.Handle {
 
 				user.Password = hash
 
+				// Log the user out of all sessions and revoke their tokens
+				if err := RevokeOtherSessions(s.sc, user, ""); err != nil {
+					log.WithError(err).Errorf("error revoking sessions for %s", username)
+				}
+
 				// Save user
 				if err := s.db.SetUser(username, user); err != nil {
 					ctx.Error = true
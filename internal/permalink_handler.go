@@ -61,7 +61,7 @@ func (s *Server) PermalinkHandler() httprouter.Handle {
 			}
 		}
 
-		if twt == nil || twt.IsZero() {
+		if twt == nil || twt.IsZero() || s.config.HiddenTwt(twt) {
 			if accept.PreferredContentTypeLike(r.Header, "text/html") == "text/html" {
 				ctx.Error = true
 				ctx.Message = s.tr(ctx, "ErrorNoMatchingTwt")
@@ -82,6 +82,9 @@ func (s *Server) PermalinkHandler() httprouter.Handle {
 
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("Last-Modified", twt.Created().Format(http.TimeFormat))
+			if signature := s.cache.GetSignature(twt); signature != "" {
+				w.Header().Set(TwtSignatureHeader, signature)
+			}
 			_, _ = w.Write(data)
 			return
 		}
@@ -44,6 +44,13 @@ func (s *Server) PostHandler() httprouter.Handle {
 			}
 		}
 
+		if ctx.User.IsPostingDisabled() {
+			ctx.Error = true
+			ctx.Message = s.limitMessage(ctx, ctx.User, LimitDisablePosting)
+			s.render("error", w, ctx)
+			return
+		}
+
 		hash := r.FormValue("hash")
 		lastTwt, _, err := GetLastTwt(s.config, ctx.User)
 		if err != nil {
@@ -53,7 +60,34 @@ func (s *Server) PostHandler() httprouter.Handle {
 			return
 		}
 
-		if hash != "" && lastTwt.Hash() == hash {
+		editing := hash != "" && lastTwt.Hash() == hash
+
+		// A thread is posted as multiple `text` fields
+		texts := CleanThread(r.Form["text"])
+
+		if len(texts) == 0 {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorNoPostContent")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if len(texts) > s.config.MaxThreadLength {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorThreadTooLong", map[string]interface{}{"Max": s.config.MaxThreadLength})
+			s.render("error", w, ctx)
+			return
+		}
+
+		// Edits replace the last twt with a single twt
+		if editing && len(texts) > 1 {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorEditThread")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if editing {
 			if err := DeleteLastTwt(s.config, ctx.User); err != nil {
 				ctx.Error = true
 				ctx.Message = s.tr(ctx, "ErrorDeleteLastTwt")
@@ -63,21 +97,18 @@ func (s *Server) PostHandler() httprouter.Handle {
 			s.cache.DeleteFeeds(ctx.User.Source())
 		}
 
-		text := CleanTwt(r.FormValue("text"))
-
-		if text == "" {
-			ctx.Error = true
-			ctx.Message = s.tr(ctx, "ErrorNoPostContent")
-			s.render("error", w, ctx)
-			return
-		}
-
 		reply := strings.TrimSpace(r.FormValue("reply"))
 		if reply != "" {
 			re := regexp.MustCompile(`^(@<.*>[, ]*)*(\(.*?\))(.*)`)
-			match := re.FindStringSubmatch(text)
+			match := re.FindStringSubmatch(texts[0])
 			if match == nil {
-				text = fmt.Sprintf("(%s) %s", reply, text)
+				texts[0] = fmt.Sprintf("(%s) %s", reply, texts[0])
+			}
+		}
+
+		if warning := r.FormValue("content_warning"); warning != "" {
+			for i, text := range texts {
+				texts[i] = WithContentWarning(text, warning)
 			}
 		}
 
@@ -92,7 +123,7 @@ func (s *Server) PostHandler() httprouter.Handle {
 
 		var (
 			//sources types.Feeds
-			twt     types.Twt = types.NilTwt
+			twts    types.Twts
 			feedURL string
 		)
 
@@ -101,10 +132,12 @@ func (s *Server) PostHandler() httprouter.Handle {
 			//sources = user.Source()
 			feedURL = s.config.URLForUser(user.Username)
 
-			if hash != "" && lastTwt.Hash() == hash {
-				twt, err = appendTwt(user, nil, text, lastTwt.Created())
+			if editing {
+				var twt types.Twt
+				twt, err = appendTwt(user, nil, texts[0], lastTwt.Created())
+				twts = types.Twts{twt}
 			} else {
-				twt, err = appendTwt(user, nil, text)
+				twts, err = AppendThread(appendTwt, user, nil, texts)
 			}
 		default:
 			if user.OwnsFeed(postAs) {
@@ -119,10 +152,12 @@ func (s *Server) PostHandler() httprouter.Handle {
 				//		sources = feed.Source()
 				feedURL = s.config.URLForUser(postAs)
 
-				if hash != "" && lastTwt.Hash() == hash {
-					twt, err = appendTwt(user, feed, text, lastTwt.Created)
+				if editing {
+					var twt types.Twt
+					twt, err = appendTwt(user, feed, texts[0], lastTwt.Created())
+					twts = types.Twts{twt}
 				} else {
-					twt, err = appendTwt(user, feed, text)
+					twts, err = AppendThread(appendTwt, user, feed, texts)
 				}
 			} else {
 				err = ErrFeedImposter
@@ -140,7 +175,9 @@ func (s *Server) PostHandler() httprouter.Handle {
 		// Update user's own timeline with their own new post.
 		// XXX: This is too slow and expensive :/
 		// s.cache.FetchFeeds(s.config, s.archive, sources, nil)
-		s.cache.InjectFeed(feedURL, twt)
+		for _, twt := range twts {
+			s.cache.InjectFeed(feedURL, twt)
+		}
 
 		// Force User Views to be recalculated
 		s.cache.DeleteUserViews(ctx.User)
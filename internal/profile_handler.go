@@ -68,6 +68,9 @@ func (s *Server) ProfileHandler() httprouter.Handle {
 			Href: fmt.Sprintf("%s/webmention", UserURL(profile.URI)),
 			Rel:  "webmention",
 		})
+		if profile.Type == "User" {
+			ctx.Links = append(ctx.Links, s.indieAuthLinks()...)
+		}
 
 		ctx.Alternatives = append(ctx.Alternatives, Alternatives{
 			Alternative{
@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+)
+
+var (
+	// ErrInvalidRateLimit is returned when parsing a malformed rate limit
+	ErrInvalidRateLimit = errors.New("error: invalid rate limit")
+)
+
+// RateLimit is a budget of requests per period, e.g: `5/1m` permits bursts of
+// up to 5 requests refilled at a rate of 5 requests per minute. A budget with
+// zero requests is unlimited.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseRateLimit parses a rate limit of the form `<requests>/<period>`
+func ParseRateLimit(s string) (RateLimit, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, ErrInvalidRateLimit
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 0 {
+		return RateLimit{}, ErrInvalidRateLimit
+	}
+
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return RateLimit{}, ErrInvalidRateLimit
+	}
+
+	return RateLimit{Requests: requests, Period: period}, nil
+}
+
+// IsZero returns true if the rate limit is unlimited
+func (l RateLimit) IsZero() bool { return l.Requests == 0 }
+
+func (l RateLimit) String() string { return fmt.Sprintf("%d/%s", l.Requests, l.Period) }
+
+// interval returns how long it takes to refill a single request
+func (l RateLimit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// RateLimitResult is the outcome of taking a request from a budget
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Reset is how long until the budget is fully refilled
+	Reset time.Duration
+
+	// RetryAfter is how long until the next request is allowed
+	RetryAfter time.Duration
+}
+
+type rateLimitBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a token bucket rate limiter keeping a bucket per key
+type RateLimiter struct {
+	sync.Mutex
+
+	limit   RateLimit
+	buckets map[string]*rateLimitBucket
+	pruned  time.Time
+
+	// now is overridden by tests
+	now func() time.Time
+}
+
+// NewRateLimiter returns a RateLimiter enforcing limit for each key
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		buckets: make(map[string]*rateLimitBucket),
+		now:     time.Now,
+	}
+}
+
+func (rl *RateLimiter) refill(bucket *rateLimitBucket, now time.Time) {
+	elapsed := now.Sub(bucket.last)
+	bucket.tokens = math.Min(
+		float64(rl.limit.Requests),
+		bucket.tokens+float64(elapsed)/float64(rl.limit.interval()),
+	)
+	bucket.last = now
+}
+
+// prune removes buckets that have been fully refilled as they are equivalent
+// to a new bucket, this is done at most once per period.
+func (rl *RateLimiter) prune(now time.Time) {
+	if now.Sub(rl.pruned) < rl.limit.Period {
+		return
+	}
+	for key, bucket := range rl.buckets {
+		rl.refill(bucket, now)
+		if bucket.tokens >= float64(rl.limit.Requests) {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.pruned = now
+}
+
+// Allow takes a request from the budget of key
+func (rl *RateLimiter) Allow(key string) RateLimitResult {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := rl.now()
+	rl.prune(now)
+
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &rateLimitBucket{tokens: float64(rl.limit.Requests), last: now}
+		rl.buckets[key] = bucket
+	}
+	rl.refill(bucket, now)
+
+	res := RateLimitResult{Limit: rl.limit.Requests}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - bucket.tokens) * float64(rl.limit.interval()))
+	}
+
+	res.Remaining = int(bucket.tokens)
+	res.Reset = time.Duration((float64(rl.limit.Requests) - bucket.tokens) * float64(rl.limit.interval()))
+
+	return res
+}
+
+// Len returns the number of keys being tracked
+func (rl *RateLimiter) Len() int {
+	rl.Lock()
+	defer rl.Unlock()
+	return len(rl.buckets)
+}
+
+// RateLimitKeyFunc returns the key a request is rate limited by
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimiters holds a RateLimiter per named budget (e.g: `login`, `post`)
+// shared by the web routes and the API so both draw from the same budget.
+type RateLimiters struct {
+	limiters map[string]*RateLimiter
+}
+
+// NewRateLimiters returns RateLimiters for the budgets configured for the pod
+func NewRateLimiters(conf *Config) *RateLimiters {
+	limiters := make(map[string]*RateLimiter)
+	for name, limit := range conf.RateLimits {
+		if !limit.IsZero() {
+			limiters[name] = NewRateLimiter(limit)
+		}
+	}
+	return &RateLimiters{limiters: limiters}
+}
+
+// Get returns the RateLimiter for the named budget or nil if it is unlimited
+func (rls *RateLimiters) Get(name string) *RateLimiter {
+	if rls == nil {
+		return nil
+	}
+	return rls.limiters[name]
+}
+
+// Limit returns a Middleware enforcing the named budget for each key returned
+// by key, it can be used with Router.Use(), Router.Group() or to wrap a single
+// httprouter.Handle. Unlimited budgets return the handle unchanged.
+func (rls *RateLimiters) Limit(name string, key RateLimitKeyFunc) Middleware {
+	limiter := rls.Get(name)
+
+	return func(next httprouter.Handle) httprouter.Handle {
+		if limiter == nil {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			k := key(r)
+			res := limiter.Allow(k)
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(res.Reset.Seconds()))))
+
+			if !res.Allowed {
+				if cv := metrics.CounterVec("ratelimit", "limited"); cv != nil {
+					cv.WithLabelValues(name).Inc()
+				}
+				log.Warnf("rate limit %s exceeded by %s", name, k)
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+
+			if cv := metrics.CounterVec("ratelimit", "allowed"); cv != nil {
+				cv.WithLabelValues(name).Inc()
+			}
+
+			next(w, r, p)
+		}
+	}
+}
+
+// RemoteIP returns the IP address of the client that made the request
+// (See: ForwardedFor)
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ForwardedFor replaces the remote address of requests made by trusted
+// reverse proxies with the nearest untrusted address of their X-Forwarded-For
+// header, the header of any other client is ignored as it is easily spoofed
+func ForwardedFor(conf *Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if conf.IsTrustedProxy(RemoteIP(r)) {
+				var addrs []string
+				for _, xff := range r.Header.Values("X-Forwarded-For") {
+					addrs = append(addrs, strings.Split(xff, ",")...)
+				}
+				for i := len(addrs) - 1; i >= 0; i-- {
+					ip := net.ParseIP(strings.TrimSpace(addrs[i]))
+					if ip == nil {
+						break
+					}
+					r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+					if !conf.IsTrustedProxy(ip.String()) {
+						break
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitByIP keys requests by the client's IP address
+func RateLimitByIP(r *http.Request) string {
+	return "ip:" + RemoteIP(r)
+}
+
+// RateLimitByUser keys requests by the logged in user's session falling back
+// to the client's IP address for anonymous requests
+func RateLimitByUser(r *http.Request) string {
+	if sess, ok := r.Context().Value(session.SessionKey).(*session.Session); ok && sess != nil {
+		if username, ok := sess.Get("username"); ok && username != "" {
+			return "user:" + username
+		}
+	}
+	return RateLimitByIP(r)
+}
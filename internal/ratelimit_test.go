@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected RateLimit
+		err      error
+	}{
+		{"5/1m", RateLimit{Requests: 5, Period: time.Minute}, nil},
+		{" 100/1h ", RateLimit{Requests: 100, Period: time.Hour}, nil},
+		{"0/1m", RateLimit{Requests: 0, Period: time.Minute}, nil},
+		{"5", RateLimit{}, ErrInvalidRateLimit},
+		{"-1/1m", RateLimit{}, ErrInvalidRateLimit},
+		{"5/0s", RateLimit{}, ErrInvalidRateLimit},
+		{"five/1m", RateLimit{}, ErrInvalidRateLimit},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.in, func(t *testing.T) {
+			actual, err := ParseRateLimit(testCase.in)
+			assert.Equal(t, testCase.err, err)
+			assert.Equal(t, testCase.expected, actual)
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+
+	limiter := NewRateLimiter(RateLimit{Requests: 3, Period: 3 * time.Second})
+	limiter.now = func() time.Time { return now }
+
+	for i := 2; i >= 0; i-- {
+		res := limiter.Allow("alice")
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+	}
+
+	res := limiter.Allow("alice")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.Reset)
+
+	// Other keys have their own budget
+	assert.True(t, limiter.Allow("bob").Allowed)
+
+	now = now.Add(time.Second)
+	assert.True(t, limiter.Allow("alice").Allowed)
+	assert.False(t, limiter.Allow("alice").Allowed)
+
+	// Refilled buckets are pruned
+	now = now.Add(time.Minute)
+	assert.True(t, limiter.Allow("alice").Allowed)
+	assert.Equal(t, 1, limiter.Len())
+}
+
+func TestRateLimitersLimit(t *testing.T) {
+	conf := NewConfig()
+	require.NoError(t, WithRateLimits(map[string]string{"login": "2/1m", "post": "0/1m"})(conf))
+
+	limits := NewRateLimiters(conf)
+	assert.Nil(t, limits.Get("post"))
+	assert.Nil(t, (*RateLimiters)(nil).Get("login"))
+
+	handle := limits.Limit("login", RateLimitByIP)(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+
+	call := func(addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		handle(w, r, nil)
+		return w
+	}
+
+	w := call("10.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, call("10.0.0.1:4321").Code)
+
+	w = call("10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, call("10.0.0.2:1234").Code)
+}
+
+func TestRemoteIP(t *testing.T) {
+	// A reverse proxy on the same host is trusted by default
+	assert.True(t, NewConfig().IsTrustedProxy("127.0.0.1"))
+	assert.True(t, NewConfig().IsTrustedProxy("::1"))
+	assert.False(t, NewConfig().IsTrustedProxy("10.0.0.1"))
+
+	conf := NewConfig()
+	require.NoError(t, WithTrustedProxies([]string{"10.0.0.1", "172.16.0.0/12"})(conf))
+	assert.Equal(t, []string{"10.0.0.1/32", "172.16.0.0/12"}, conf.TrustedProxies)
+	assert.Error(t, WithTrustedProxies([]string{"garbage"})(NewConfig()))
+
+	remoteIP := func(remoteAddr, xff string) string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		if xff != "" {
+			r.Header.Set("X-Forwarded-For", xff)
+		}
+
+		var ip string
+		ForwardedFor(conf)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip = RemoteIP(r)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		return ip
+	}
+
+	assert.Equal(t, "10.0.0.1", remoteIP("10.0.0.1:1234", ""))
+	assert.Equal(t, "192.168.1.1", remoteIP("10.0.0.1:1234", "1.2.3.4, 192.168.1.1"))
+	assert.Equal(t, "1.2.3.4", remoteIP("10.0.0.1:1234", "1.2.3.4, 172.16.0.1"))
+	assert.Equal(t, "10.0.0.1", remoteIP("10.0.0.1:1234", "garbage"))
+
+	// Untrusted clients cannot spoof their address
+	assert.Equal(t, "10.0.0.2", remoteIP("10.0.0.2:1234", "1.2.3.4"))
+}
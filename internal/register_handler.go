@@ -15,26 +15,28 @@ import (
 
 // RegisterHandler ...
 func (s *Server) RegisterHandler() httprouter.Handle {
-	isAdminUser := IsAdminUserFactory(s.config)
-
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		ctx := NewContext(s, r)
 
-		if r.Method == "GET" {
-			if s.config.OpenRegistrations {
-				s.render("register", w, ctx)
-			} else {
-				message := s.config.RegisterMessage
-
-				if message == "" {
-					message = s.tr(ctx, "ErrorRegisterDisabled")
-				}
+		// An invite lets people register when open registrations are disabled
+		invite := strings.TrimSpace(r.FormValue("invite"))
 
-				ctx.Error = true
-				ctx.Message = message
-				s.render("error", w, ctx)
+		if !s.config.OpenRegistrations && !ValidInvite(s.db, invite) {
+			ctx.Error = true
+			if invite != "" {
+				ctx.Message = s.tr(ctx, "ErrorInvalidInvite")
+			} else if s.config.RegisterMessage != "" {
+				ctx.Message = s.config.RegisterMessage
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorRegisterDisabled")
 			}
+			s.render("error", w, ctx)
+			return
+		}
 
+		if r.Method == "GET" {
+			ctx.InviteCode = invite
+			s.render("register", w, ctx)
 			return
 		}
 
@@ -42,6 +44,14 @@ func (s *Server) RegisterHandler() httprouter.Handle {
 		password := r.FormValue("password")
 		// XXX: We DO NOT store this! (EVER)
 		email := strings.TrimSpace(r.FormValue("email"))
+		reason := strings.TrimSpace(r.FormValue("reason"))
+
+		if s.config.RegisterCaptcha && !verifyCaptcha(r) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorCaptchaMismatch")
+			s.render("error", w, ctx)
+			return
+		}
 
 		if err := ValidateUsername(username); err != nil {
 			ctx.Error = true
@@ -60,24 +70,21 @@ func (s *Server) RegisterHandler() httprouter.Handle {
 			return
 		}
 
-		p := filepath.Join(s.config.Data, feedsDir)
-		if err := os.MkdirAll(p, 0755); err != nil {
-			log.WithError(err).Error("error creating feeds directory")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		// People registering with an invite are vouched for by its creator
+		pending := s.config.RequireApproval && !ValidInvite(s.db, invite)
 
-		fn := filepath.Join(p, username)
-		if _, err := os.Stat(fn); err == nil {
+		if pending && reason == "" {
 			ctx.Error = true
-			ctx.Message = s.tr(ctx, "ErrorUsernameExists")
+			ctx.Message = s.tr(ctx, "ErrorJoinReasonRequired")
 			s.render("error", w, ctx)
 			return
 		}
 
-		if err := ioutil.WriteFile(fn, []byte{}, 0644); err != nil {
-			log.WithError(err).Error("error creating new user feed")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := CheckSignup(s.config, s.limits, username, email, RemoteIP(r)); err != nil {
+			log.WithError(err).Warnf("signup of %s from %s denied", username, RemoteIP(r))
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, signupErrorMessage(err))
+			s.render("error", w, ctx)
 			return
 		}
 
@@ -90,48 +97,157 @@ func (s *Server) RegisterHandler() httprouter.Handle {
 
 		recoveryHash := fmt.Sprintf("email:%s", FastHashString(email))
 
-		user := NewUser()
-		user.Username = username
-		user.Password = hash
-		user.Recovery = recoveryHash
-		user.URL = URLForUser(s.config.BaseURL, username)
-		user.CreatedAt = time.Now()
-
-		// Default Feeds
-		user.Follow(newsSpecialUser, s.config.URLForUser(newsSpecialUser)+"/twtxt.txt")
-		user.Follow(supportSpecialUser, s.config.URLForUser(supportSpecialUser)+"/twtxt.txt")
-		user.Follow(helpSpecialUser, s.config.URLForUser(helpSpecialUser)+"/twtxt.txt")
+		var reserved *Invite
+		if invite != "" {
+			reserved, err = ReserveInvite(s.db, invite, username)
+			if err != nil {
+				log.WithError(err).Warnf("error reserving invite for %s", username)
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorInvalidInvite")
+				s.render("error", w, ctx)
+				return
+			}
+		}
 
-		if err := s.db.SetUser(username, user); err != nil {
-			log.WithError(err).Error("error saving user object for new user")
+		user, err := s.createUser(username, hash, recoveryHash, pending)
+		if err != nil && reserved != nil {
+			if err := ReleaseInvite(s.db, reserved.Code, username); err != nil {
+				log.WithError(err).Warnf("error releasing invite reserved for %s", username)
+			}
+		}
+		if err == ErrFeedAlreadyExists {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUsernameExists")
+			s.render("error", w, ctx)
+			return
+		}
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Onboarding: Welcome new User and notify Poderator
-
-		// TODO: Make this async?
-		if s.config.Features.IsEnabled(FeatureInternalEvents) {
-			if admin, err := s.db.GetUser(s.config.AdminUser); err == nil && !isAdminUser(user) {
-				if twtxt, err := s.db.GetFeed(twtxtBot); err == nil {
-					// TODO: Make this configurable?
-					welcomeUserText := fmt.Sprintf(
-						"👋 Hey @<%s %s/twtxt.txt>, welcome to %s, a [Yarn.social](https://yarn.social) Pod! To get started you may want to check out the pod's [Discover](/discover) feed. To follow a new feed or user check out [Feeds](/feeds) and [Follow](/follow). Once again, welcome! 🤗",
-						user.Username, s.config.URLForUser(user.Username),
-						s.config.Name,
-					)
-					newUserText := fmt.Sprintf(
-						"👋 Hey @<%s %s/twtxt.txt>, a new user (@<%s %s/twtxt.txt>) has joined your pod %s! 🥳",
-						admin.Username, s.config.URLForUser(admin.Username),
-						user.Username, s.config.URLForUser(user.Username),
-						s.config.Name,
-					)
-					s.cache.AddEvent(user, twtxt, welcomeUserText)
-					s.cache.AddEvent(admin, twtxt, newUserText)
-				}
+		if reserved != nil || reason != "" {
+			if reserved != nil {
+				user.InvitedBy = reserved.CreatedBy
+			}
+			user.JoinReason = reason
+			if err := s.db.SetUser(user.Username, user); err != nil {
+				log.WithError(err).Warnf("error saving invite or reason for joining of %s", username)
 			}
 		}
 
+		if user.PendingApproval {
+			log.Infof("%s registered and is pending approval", username)
+
+			go func() {
+				if err := SendPendingApprovalEmail(s.config, user); err != nil {
+					log.WithError(err).Warnf("error sending pending approval email for %s", username)
+				}
+			}()
+
+			ctx.Error = false
+			ctx.Message = s.tr(ctx, "MsgRegistrationPending")
+			s.render("error", w, ctx)
+			return
+		}
+
+		s.welcomeUser(user)
+
 		http.Redirect(w, r, "/login", http.StatusFound)
 	}
 }
+
+// createUser creates a new user and their feed following the pod's default
+// feeds, hash is the user's password hash and recovery the hash of their
+// email address used to recover their account. Pending users cannot login
+// until approved (See: ApproveUser).
+func (s *Server) createUser(username, hash, recovery string, pending bool) (*User, error) {
+	p := filepath.Join(s.config.Data, feedsDir)
+	if err := os.MkdirAll(p, 0755); err != nil {
+		log.WithError(err).Error("error creating feeds directory")
+		return nil, err
+	}
+
+	fn := filepath.Join(p, username)
+	if _, err := os.Stat(fn); err == nil {
+		return nil, ErrFeedAlreadyExists
+	}
+
+	if err := ioutil.WriteFile(fn, []byte{}, 0644); err != nil {
+		log.WithError(err).Error("error creating new user feed")
+		return nil, err
+	}
+
+	user := NewUser()
+	user.Username = username
+	user.Password = hash
+	user.Recovery = recovery
+	user.URL = URLForUser(s.config.BaseURL, username)
+	user.CreatedAt = time.Now()
+	user.PendingApproval = pending
+
+	// Default Feeds
+	user.Follow(newsSpecialUser, s.config.URLForUser(newsSpecialUser)+"/twtxt.txt")
+	user.Follow(supportSpecialUser, s.config.URLForUser(supportSpecialUser)+"/twtxt.txt")
+	user.Follow(helpSpecialUser, s.config.URLForUser(helpSpecialUser)+"/twtxt.txt")
+
+	if err := s.db.SetUser(username, user); err != nil {
+		log.WithError(err).Error("error saving user object for new user")
+		return nil, err
+	}
+
+	if err := CreateSigningKey(s.config, s.db, username); err != nil {
+		log.WithError(err).Errorf("error creating signing key for %s", username)
+	}
+
+	return user, nil
+}
+
+// signupErrorMessage returns the message id of the error of a registration
+// denied by CheckSignup
+func signupErrorMessage(err error) string {
+	switch err {
+	case ErrDeniedUsername:
+		return "ErrorDeniedUsername"
+	case ErrDisposableEmail:
+		return "ErrorDisposableEmail"
+	case ErrTooManySignups:
+		return "ErrorTooManySignups"
+	}
+	return "ErrorRegisterDenied"
+}
+
+// welcomeUser welcomes a new user and notifies the Poderator
+func (s *Server) welcomeUser(user *User) {
+	// TODO: Make this async?
+	if !s.config.Features.IsEnabled(FeatureInternalEvents) {
+		return
+	}
+
+	isAdminUser := IsAdminUserFactory(s.config)
+
+	admin, err := s.db.GetUser(s.config.AdminUser)
+	if err != nil || isAdminUser(user) {
+		return
+	}
+
+	twtxt, err := s.db.GetFeed(twtxtBot)
+	if err != nil {
+		return
+	}
+
+	// TODO: Make this configurable?
+	welcomeUserText := fmt.Sprintf(
+		"👋 Hey @<%s %s/twtxt.txt>, welcome to %s, a [Yarn.social](https://yarn.social) Pod! To get started you may want to check out the pod's [Discover](/discover) feed. To follow a new feed or user check out [Feeds](/feeds) and [Follow](/follow). Once again, welcome! 🤗",
+		user.Username, s.config.URLForUser(user.Username),
+		s.config.Name,
+	)
+	newUserText := fmt.Sprintf(
+		"👋 Hey @<%s %s/twtxt.txt>, a new user (@<%s %s/twtxt.txt>) has joined your pod %s! 🥳",
+		admin.Username, s.config.URLForUser(admin.Username),
+		user.Username, s.config.URLForUser(user.Username),
+		s.config.Name,
+	)
+	s.cache.AddEvent(user, twtxt, welcomeUserText)
+	s.cache.AddEvent(admin, twtxt, newUserText)
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrDeniedUsername is returned when registering with a username that
+	// matches one of the pod's denied usernames
+	ErrDeniedUsername = errors.New("error: username denied")
+
+	// ErrDisposableEmail is returned when registering with an email address
+	// of a disposable email provider
+	ErrDisposableEmail = errors.New("error: disposable email address")
+
+	// ErrTooManySignups is returned when a client has registered more accounts
+	// than the pod's `signup` budget allows
+	ErrTooManySignups = errors.New("error: too many signups")
+
+	// ErrUserNotPending is returned when approving or rejecting a user who is
+	// not pending approval
+	ErrUserNotPending = errors.New("error: user not pending approval")
+)
+
+// CheckSignup runs the pod's automatic checks on an account registered by the
+// client at ip with the username and email address (if any). The client's
+// `signup` budget is only taken once the username and email address pass.
+func CheckSignup(conf *Config, limits *RateLimiters, username, email, ip string) error {
+	if conf.DeniedUsername(username) {
+		return ErrDeniedUsername
+	}
+
+	if email != "" && conf.DisposableEmail(email) {
+		return ErrDisposableEmail
+	}
+
+	if limiter := limits.Get("signup"); limiter != nil {
+		if res := limiter.Allow("ip:" + ip); !res.Allowed {
+			return ErrTooManySignups
+		}
+	}
+
+	return nil
+}
+
+// ApproveUser approves a user pending approval so they can login
+func ApproveUser(db Store, user *User) error {
+	if !user.PendingApproval {
+		return ErrUserNotPending
+	}
+
+	user.PendingApproval = false
+	return db.SetUser(user.Username, user)
+}
+
+// RejectUser rejects a user pending approval deleting their account and
+// (empty) feed, pending users cannot login so have nothing else to delete
+func RejectUser(conf *Config, db Store, user *User) error {
+	if !user.PendingApproval {
+		return ErrUserNotPending
+	}
+
+	fn := filepath.Join(conf.Data, feedsDir, user.Username)
+	if FileExists(fn) {
+		if err := os.Remove(fn); err != nil {
+			return err
+		}
+	}
+
+	if err := db.DelUser(user.Username); err != nil {
+		return err
+	}
+
+	return db.DelNotifications(user.Username)
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+// ApproveUserHandler approves a user pending approval so they can login
+func (s *Server) ApproveUserHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionManageUsers) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		username := NormalizeUsername(r.FormValue("username"))
+
+		trdata := map[string]interface{}{}
+		trdata["Nick"] = username
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading user object for %s", username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorGetUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := ApproveUser(s.db, user); err != nil {
+			log.WithError(err).Errorf("error approving %s", username)
+			ctx.Error = true
+			if err == ErrUserNotPending {
+				ctx.Message = s.tr(ctx, "ErrorUserNotPending", trdata)
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		log.Infof("%s approved by %s", username, ctx.Username)
+
+		Audit(s.db, ctx.Username, AuditApproveUser, username, "", "", "")
+
+		s.welcomeUser(user)
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgUserApproved", trdata)
+		s.render("error", w, ctx)
+	}
+}
+
+// RejectUserHandler rejects a user pending approval deleting their account
+func (s *Server) RejectUserHandler() httprouter.Handle {
+	hasPermission := HasPermissionFactory(s.config)
+
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		if !hasPermission(ctx.User, PermissionManageUsers) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorPermissionDenied")
+			s.render("403", w, ctx)
+			return
+		}
+
+		username := NormalizeUsername(r.FormValue("username"))
+
+		trdata := map[string]interface{}{}
+		trdata["Nick"] = username
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			log.WithError(err).Errorf("error loading user object for %s", username)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorGetUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := RejectUser(s.config, s.db, user); err != nil {
+			log.WithError(err).Errorf("error rejecting %s", username)
+			ctx.Error = true
+			if err == ErrUserNotPending {
+				ctx.Message = s.tr(ctx, "ErrorUserNotPending", trdata)
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		log.Infof("%s rejected by %s", username, ctx.Username)
+
+		Audit(s.db, ctx.Username, AuditRejectUser, username, "", "", user.JoinReason)
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgUserRejected", trdata)
+		s.render("error", w, ctx)
+	}
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/passwords"
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestCheckSignup(t *testing.T) {
+	conf := NewConfig()
+	require.NoError(t, WithDeniedUsernames([]string{"^spam", "admin"})(conf))
+	require.NoError(t, WithDisposableEmailDomains([]string{" Mailinator.com ", ""})(conf))
+	conf.RateLimits = map[string]RateLimit{"signup": {Requests: 1, Period: 24 * time.Hour}}
+	limits := NewRateLimiters(conf)
+
+	assert.True(t, conf.DeniedUsername("spammer"))
+	assert.True(t, conf.DeniedUsername("podadmin"))
+	assert.False(t, conf.DeniedUsername("alice"))
+
+	assert.True(t, conf.DisposableEmail("bob@mailinator.com"))
+	assert.True(t, conf.DisposableEmail("bob@eu.MAILINATOR.com"))
+	assert.False(t, conf.DisposableEmail("bob@notmailinator.com"))
+	assert.False(t, conf.DisposableEmail("mailinator.com"))
+
+	assert.Equal(t, ErrDeniedUsername, CheckSignup(conf, limits, "spammer", "", "192.0.2.1"))
+	assert.Equal(t, ErrDisposableEmail, CheckSignup(conf, limits, "bob", "bob@mailinator.com", "192.0.2.1"))
+
+	// Denied signups do not take from the client's budget
+	assert.NoError(t, CheckSignup(conf, limits, "alice", "alice@example.com", "192.0.2.1"))
+	assert.Equal(t, ErrTooManySignups, CheckSignup(conf, limits, "bob", "", "192.0.2.1"))
+	assert.NoError(t, CheckSignup(conf, limits, "bob", "", "192.0.2.2"))
+
+	// The signup budget is unlimited when not configured
+	assert.NoError(t, CheckSignup(NewConfig(), nil, "alice", "", "192.0.2.1"))
+}
+
+func TestRegisterPendingApproval(t *testing.T) {
+	api := newTestAPI(t)
+	api.pm = passwords.NewScryptPasswords(nil)
+	api.config.OpenRegistrations = true
+	api.config.RequireApproval = true
+	api.config.SMTPHost = "127.0.0.1"
+	api.config.SMTPPort = 1
+
+	register := func(username, reason, invite string) int {
+		req := types.RegisterRequest{Username: username, Password: "secret", Reason: reason, Invite: invite}
+		return callEndpoint(t, api.RegisterEndpoint(), nil, http.MethodPost, req, nil)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, register("alice", "", ""))
+	assert.Equal(t, http.StatusAccepted, register("alice", "I like yarns", ""))
+
+	alice, err := api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.True(t, alice.PendingApproval)
+	assert.Equal(t, "I like yarns", alice.JoinReason)
+
+	// The state of the account is only revealed once the password is verified
+	auth := types.AuthRequest{Username: "alice", Password: "wrong"}
+	assert.Equal(t, http.StatusUnauthorized, callEndpoint(t, api.AuthEndpoint(), nil, http.MethodPost, auth, nil))
+
+	auth = types.AuthRequest{Username: "alice", Password: "secret"}
+	assert.Equal(t, http.StatusForbidden, callEndpoint(t, api.AuthEndpoint(), nil, http.MethodPost, auth, nil))
+
+	// People registering with an invite do not need approval
+	invite := NewInvite("admin", 1, time.Hour)
+	require.NoError(t, api.db.SetInvite(invite.Code, invite))
+	assert.Equal(t, http.StatusOK, register("bob", "", invite.Code))
+
+	bob, err := api.db.GetUser("bob")
+	require.NoError(t, err)
+	assert.False(t, bob.PendingApproval)
+
+	// Captchas cannot be solved with the API so people must be invited
+	api.config.RegisterCaptcha = true
+	assert.Equal(t, http.StatusForbidden, register("carol", "I like yarns", ""))
+	assert.False(t, api.db.HasUser("carol"))
+
+	invite = NewInvite("admin", 1, time.Hour)
+	require.NoError(t, api.db.SetInvite(invite.Code, invite))
+	assert.Equal(t, http.StatusOK, register("carol", "", invite.Code))
+}
+
+func TestRegistrationHandlers(t *testing.T) {
+	server := newTestServer(t)
+	server.pm = passwords.NewScryptPasswords(nil)
+	server.config.AdminUser = "admin"
+	server.config.OpenRegistrations = true
+	server.config.RequireApproval = true
+	server.config.RegisterCaptcha = true
+	server.config.SMTPHost = "127.0.0.1"
+	server.config.SMTPPort = 1
+	require.NoError(t, server.db.SetUser("admin", &User{Username: "admin"}))
+	require.NoError(t, server.db.SetUser("mod", &User{Username: "mod", Role: RoleModerator}))
+
+	post := func(handler httprouter.Handle, data session.Map, form url.Values) string {
+		sess := session.NewSession(server.sc)
+		sess.ID = GenerateRandomToken()
+		sess.Data = data
+
+		r := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+
+		w := httptest.NewRecorder()
+		handler(w, r, nil)
+		return w.Body.String()
+	}
+
+	register := func(username, captcha string) string {
+		form := url.Values{"username": {username}, "password": {"secret"}, "reason": {"I like yarns"}, "captchaInput": {captcha}}
+		return post(server.RegisterHandler(), session.Map{"captchaText": "42"}, form)
+	}
+
+	assert.Contains(t, register("alice", "41"), "Unable to match captcha text")
+	assert.Contains(t, register("alice", "42"), "Your account is pending approval")
+	assert.Contains(t, register("bob", "42"), "Your account is pending approval")
+
+	admin := session.Map{"username": "admin"}
+	mod := session.Map{"username": "mod"}
+
+	assert.Contains(t, post(server.ApproveUserHandler(), mod, url.Values{"username": {"alice"}}), "You do not have permission to do this!")
+	assert.Contains(t, post(server.ApproveUserHandler(), admin, url.Values{"username": {"alice"}}), "User alice approved successfully")
+	assert.Contains(t, post(server.ApproveUserHandler(), admin, url.Values{"username": {"alice"}}), "User alice is not pending approval")
+	assert.Contains(t, post(server.RejectUserHandler(), admin, url.Values{"username": {"bob"}}), "User bob rejected and deleted successfully")
+
+	alice, err := server.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.False(t, alice.PendingApproval)
+	assert.False(t, server.db.HasUser("bob"))
+
+	entries, err := server.db.GetAllAuditEntries()
+	require.NoError(t, err)
+	assert.Len(t, entries.Filter(AuditFilter{Action: AuditApproveUser, Target: "alice"}), 1)
+	rejected := entries.Filter(AuditFilter{Action: AuditRejectUser, Target: "bob"})
+	require.Len(t, rejected, 1)
+	assert.Equal(t, "I like yarns", rejected[0].Note)
+}
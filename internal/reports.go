@@ -0,0 +1,366 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+var (
+	// ErrReportNotFound is returned when looking up a report that does not
+	// exist
+	ErrReportNotFound = errors.New("error: report not found")
+
+	// ErrInvalidReportAction is returned when deciding a report with an
+	// unknown action
+	ErrInvalidReportAction = errors.New("error: invalid report action")
+
+	// ErrReportNotAboutTwt is returned when hiding the twt of a report about
+	// a feed
+	ErrReportNotAboutTwt = errors.New("error: report is not about a twt")
+
+	// ErrReportNotAboutUser is returned when suspending the user of a report
+	// about an external feed
+	ErrReportNotAboutUser = errors.New("error: report is not about a local user")
+
+	// ErrNoReporterEmail is returned when replying to a report without the
+	// reporter's email address or without a message
+	ErrNoReporterEmail = errors.New("error: no reporter email or message")
+)
+
+// ReportStatus is the status of an abuse report in the moderation queue
+type ReportStatus string
+
+const (
+	// ReportOpen is a report awaiting a decision
+	ReportOpen ReportStatus = "open"
+
+	// ReportActioned is a report that was acted upon
+	ReportActioned ReportStatus = "actioned"
+
+	// ReportDismissed is a report that was dismissed without action
+	ReportDismissed ReportStatus = "dismissed"
+)
+
+// ReportAction is a decision taken on an abuse report
+type ReportAction string
+
+const (
+	// ReportHideTwt hides the reported twt pod-wide
+	ReportHideTwt ReportAction = "hide_twt"
+
+	// ReportMuteFeed hides the reported feed from the pod's timelines
+	ReportMuteFeed ReportAction = "mute_feed"
+
+	// ReportBlacklistFeed stops the pod fetching the reported feed at all
+	ReportBlacklistFeed ReportAction = "blacklist_feed"
+
+	// ReportSuspendUser suspends the reported local user
+	ReportSuspendUser ReportAction = "suspend_user"
+
+	// ReportReply emails the reporter, it does not change the report's status
+	ReportReply ReportAction = "reply"
+
+	// ReportDismiss dismisses the report without action
+	ReportDismiss ReportAction = "dismiss"
+
+	// ReportReopen puts the report back in the queue
+	ReportReopen ReportAction = "reopen"
+)
+
+// ReportDecision is an entry in a report's audit trail recording who took
+// which action on the report and why
+type ReportDecision struct {
+	Actor     string
+	Action    ReportAction
+	Note      string
+	CreatedAt time.Time
+}
+
+// Report is an abuse report about a feed or one of its twts
+type Report struct {
+	ID     string
+	Status ReportStatus
+
+	// Reporter is the username of the reporter if they were logged in
+	Reporter     string
+	ReporterName string
+
+	// ReporterEmail is only stored encrypted (See: EncryptReporterEmail) so
+	// that moderators can reply to the reporter
+	ReporterEmail string
+
+	// Nick and URL are the reported feed, Hash is the reported twt (if any)
+	Nick string
+	URL  string
+	Hash string
+
+	Category string
+	Message  string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Decisions []*ReportDecision
+}
+
+// NewReport returns a new open report about the feed or one of its twts if
+// hash is non-empty
+func NewReport(nick, url, hash, category, message string) *Report {
+	now := time.Now()
+
+	return &Report{
+		ID:        GenerateRandomToken(),
+		Status:    ReportOpen,
+		Nick:      nick,
+		URL:       url,
+		Hash:      strings.TrimPrefix(hash, "#"),
+		Category:  category,
+		Message:   message,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// LoadReport ...
+func LoadReport(data []byte) (report *Report, err error) {
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Bytes ...
+func (r *Report) Bytes() ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// IsOpen returns true if the report is awaiting a decision
+func (r *Report) IsOpen() bool {
+	return r.Status == ReportOpen
+}
+
+// Decide records the actor's decision in the report's audit trail and
+// updates the report's status accordingly
+func (r *Report) Decide(actor string, action ReportAction, note string) error {
+	switch action {
+	case ReportHideTwt, ReportMuteFeed, ReportBlacklistFeed, ReportSuspendUser:
+		r.Status = ReportActioned
+	case ReportDismiss:
+		r.Status = ReportDismissed
+	case ReportReopen:
+		r.Status = ReportOpen
+	case ReportReply:
+	default:
+		return ErrInvalidReportAction
+	}
+
+	now := time.Now()
+
+	r.Decisions = append(r.Decisions, &ReportDecision{
+		Actor:     actor,
+		Action:    action,
+		Note:      note,
+		CreatedAt: now,
+	})
+	r.UpdatedAt = now
+
+	return nil
+}
+
+// Reports is a list of reports sorted by most recently created
+type Reports []*Report
+
+func (reports Reports) Len() int { return len(reports) }
+func (reports Reports) Less(i, j int) bool {
+	return reports[i].CreatedAt.After(reports[j].CreatedAt)
+}
+func (reports Reports) Swap(i, j int) { reports[i], reports[j] = reports[j], reports[i] }
+
+// Filter returns the reports with the given status or all reports if status
+// is empty
+func (reports Reports) Filter(status ReportStatus) (filtered Reports) {
+	for _, report := range reports {
+		if status == "" || report.Status == status {
+			filtered = append(filtered, report)
+		}
+	}
+	sort.Sort(filtered)
+	return
+}
+
+// FileReport adds the report to the moderation queue and notifies the pod
+// operator by email, the reporter's address is only stored encrypted
+func FileReport(conf *Config, db Store, report *Report, email string) error {
+	if email != "" {
+		encrypted, err := EncryptReporterEmail(conf, email)
+		if err != nil {
+			return err
+		}
+		report.ReporterEmail = encrypted
+	}
+
+	if err := db.SetReport(report.ID, report); err != nil {
+		return err
+	}
+
+	if err := SendReportAbuseEmail(
+		conf, report.Nick, report.URL, report.Hash,
+		report.ReporterName, email, report.Category, report.Message,
+	); err != nil {
+		log.WithError(err).Warnf("error notifying pod operator of report %s", report.ID)
+	}
+
+	return nil
+}
+
+// ModerateReport takes the actor's action on the reported twt, feed or user,
+// the decision must then be recorded in the report's audit trail
+func ModerateReport(conf *Config, cache *Cache, db Store, sessions session.Store, actor *User, report *Report, action ReportAction, note string) error {
+	saveSettings := func() error {
+		return conf.Settings().Save(filepath.Join(conf.Data, "settings.yaml"))
+	}
+
+	switch action {
+	case ReportHideTwt:
+		if report.Hash == "" {
+			return ErrReportNotAboutTwt
+		}
+		if err := conf.HideTwt(report.Hash); err != nil {
+			return err
+		}
+		if err := saveSettings(); err != nil {
+			return err
+		}
+		cache.Refresh()
+		return nil
+	case ReportMuteFeed:
+		if err := conf.MuteFeed(report.URL); err != nil {
+			return err
+		}
+		if err := saveSettings(); err != nil {
+			return err
+		}
+		cache.Refresh()
+		return nil
+	case ReportBlacklistFeed:
+		if err := conf.BlacklistFeed(report.URL); err != nil {
+			return err
+		}
+		if err := saveSettings(); err != nil {
+			return err
+		}
+		// Deleting the feed from the cache also refreshes the cache's views
+		cache.DeleteFeeds(types.Feeds{types.Feed{Nick: report.Nick, URL: report.URL}: true})
+		return nil
+	case ReportSuspendUser:
+		if !conf.IsLocalURL(report.URL) {
+			return ErrReportNotAboutUser
+		}
+		user, err := db.GetUser(NormalizeUsername(report.Nick))
+		if err != nil {
+			return ErrReportNotAboutUser
+		}
+		if user.IsSuspended() {
+			return nil
+		}
+		if err := LimitUser(conf, db, sessions, actor, user, LimitSuspend, note, time.Time{}); err != nil {
+			return err
+		}
+		cache.Refresh()
+		return nil
+	case ReportReply:
+		if report.ReporterEmail == "" || note == "" {
+			return ErrNoReporterEmail
+		}
+		email, err := DecryptReporterEmail(conf, report.ReporterEmail)
+		if err != nil {
+			return err
+		}
+		return SendReportReplyEmail(conf, report, email, note)
+	case ReportDismiss, ReportReopen:
+		return nil
+	default:
+		return ErrInvalidReportAction
+	}
+}
+
+// AuditReport appends the report's latest decision to the audit log, before
+// is the report's status before the decision
+func AuditReport(db Store, report *Report, before ReportStatus) {
+	if len(report.Decisions) == 0 {
+		return
+	}
+
+	decision := report.Decisions[len(report.Decisions)-1]
+
+	note := string(decision.Action)
+	if decision.Note != "" {
+		note = fmt.Sprintf("%s: %s", decision.Action, decision.Note)
+	}
+
+	Audit(db, decision.Actor, AuditModerateReport, report.ID, string(before), string(report.Status), note)
+}
+
+// EncryptReporterEmail encrypts the reporter's address for storage with
+// their report
+func EncryptReporterEmail(conf *Config, email string) (string, error) {
+	return encryptEmail(conf, "reporter-email", email)
+}
+
+// DecryptReporterEmail decrypts an address encrypted by EncryptReporterEmail
+func DecryptReporterEmail(conf *Config, encrypted string) (string, error) {
+	email, _, err := decryptEmail(conf, "reporter-email", encrypted)
+	return email, err
+}
+
+// AsReport returns the report as it is exposed by the API, the reporter's
+// email address is never exposed
+func (r *Report) AsReport() types.Report {
+	decisions := make([]types.ReportDecision, len(r.Decisions))
+	for i, d := range r.Decisions {
+		decisions[i] = types.ReportDecision{
+			Actor:     d.Actor,
+			Action:    string(d.Action),
+			Note:      d.Note,
+			CreatedAt: d.CreatedAt,
+		}
+	}
+
+	return types.Report{
+		ID:           r.ID,
+		Status:       string(r.Status),
+		Reporter:     r.Reporter,
+		ReporterName: r.ReporterName,
+		Nick:         r.Nick,
+		URL:          r.URL,
+		Hash:         r.Hash,
+		Category:     r.Category,
+		Message:      r.Message,
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+		Decisions:    decisions,
+	}
+}
+
+// AsReports ...
+func (reports Reports) AsReports() []types.Report {
+	res := make([]types.Report, len(reports))
+	for i, report := range reports {
+		res[i] = report.AsReport()
+	}
+	return res
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestReportDecide(t *testing.T) {
+	report := NewReport("bob", "https://example.com/bob.txt", "#abcdefg", "spam", "Buy now!")
+	assert.Equal(t, "abcdefg", report.Hash)
+	assert.True(t, report.IsOpen())
+
+	assert.Equal(t, ErrInvalidReportAction, report.Decide("admin", "delete", ""))
+	assert.Empty(t, report.Decisions)
+
+	require.NoError(t, report.Decide("admin", ReportReply, "Thanks, we're looking into it"))
+	assert.Equal(t, ReportOpen, report.Status)
+
+	require.NoError(t, report.Decide("admin", ReportHideTwt, "spam"))
+	assert.Equal(t, ReportActioned, report.Status)
+
+	require.NoError(t, report.Decide("admin", ReportReopen, ""))
+	assert.Equal(t, ReportOpen, report.Status)
+
+	require.NoError(t, report.Decide("admin", ReportDismiss, "not spam after all"))
+	assert.Equal(t, ReportDismissed, report.Status)
+
+	require.Len(t, report.Decisions, 4)
+	assert.Equal(t, ReportReply, report.Decisions[0].Action)
+	assert.Equal(t, "not spam after all", report.Decisions[3].Note)
+}
+
+func TestFileReport(t *testing.T) {
+	api := newTestAPI(t)
+	api.config.MagicLinkSecret = "secret"
+
+	// Reports are kept even if the pod operator cannot be emailed
+	api.config.SMTPHost = "127.0.0.1"
+	api.config.SMTPPort = 1
+
+	older := NewReport("bob", "https://example.com/bob.txt", "", "spam", "Spammer")
+	older.CreatedAt = older.CreatedAt.Add(-time.Hour)
+	require.NoError(t, older.Decide("admin", ReportDismiss, ""))
+	require.NoError(t, api.db.SetReport(older.ID, older))
+
+	report := NewReport("carol", "https://example.com/carol.txt", "abcdefg", "harassment", "Mean")
+	report.ReporterName = "Alice"
+	require.NoError(t, FileReport(api.config, api.db, report, "alice@example.com"))
+	assert.NotContains(t, report.ReporterEmail, "alice")
+
+	email, err := DecryptReporterEmail(api.config, report.ReporterEmail)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", email)
+
+	_, err = DecryptDigestEmail(api.config, report.ReporterEmail)
+	assert.Error(t, err, "reporter emails are not encrypted with the digest key")
+
+	reports, err := api.db.GetAllReports()
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, report.ID, reports[0].ID)
+	assert.Equal(t, "Alice", reports[0].ReporterName)
+
+	assert.Len(t, reports.Filter(""), 2)
+	require.Len(t, reports.Filter(ReportOpen), 1)
+	assert.Equal(t, report.ID, reports.Filter(ReportOpen)[0].ID)
+	assert.Len(t, reports.Filter(ReportDismissed), 1)
+
+	_, err = api.db.GetReport("invalid")
+	assert.Equal(t, ErrReportNotFound, err)
+}
+
+func TestManageReports(t *testing.T) {
+	server := newTestServer(t)
+	server.config.AdminUser = "admin"
+
+	admin := NewUser()
+	admin.Username = "admin"
+	require.NoError(t, server.db.SetUser(admin.Username, admin))
+
+	bob := NewUser()
+	bob.Username = "bob"
+	bob.URL = URLForUser(server.config.BaseURL, bob.Username)
+	bob.AddToken(&Token{ID: "token", CreatedAt: time.Now()})
+	require.NoError(t, server.db.SetUser(bob.Username, bob))
+
+	sessions := server.sc
+	bobSession := session.NewSession(sessions)
+	bobSession.ID = GenerateRandomToken()
+	bobSession.Data = session.Map{"username": bob.Username}
+	require.NoError(t, sessions.SetSession(bobSession.ID, bobSession))
+
+	twter := types.Twter{Nick: bob.Username, URI: bob.URL}
+	spam := types.MakeTwt(twter, time.Now(), "Buy now!")
+	hello := types.MakeTwt(twter, time.Now().Add(-time.Minute), "Hello World!")
+	server.cache.UpdateFeed(bob.URL, "", types.Twts{spam, hello})
+
+	report := NewReport(bob.Username, bob.URL, spam.Hash(), "spam", "Spammer")
+	require.NoError(t, server.db.SetReport(report.ID, report))
+
+	decide := func(user *User, id string, action ReportAction, note string) *httptest.ResponseRecorder {
+		sess := session.NewSession(sessions)
+		sess.ID = GenerateRandomToken()
+		sess.Data = session.Map{"username": user.Username}
+
+		form := url.Values{"id": {id}, "action": {string(action)}, "note": {note}}
+		r := httptest.NewRequest(http.MethodPost, "/manage/reports", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+
+		w := httptest.NewRecorder()
+		server.ManageReportsHandler()(w, r, nil)
+		return w
+	}
+
+	// Only staff with the view_reports permission can moderate reports
+	w := decide(bob, report.ID, ReportDismiss, "")
+	assert.Contains(t, w.Body.String(), "You do not have permission to do this!")
+
+	w = decide(admin, "invalid", ReportDismiss, "")
+	assert.Contains(t, w.Body.String(), "Report not found!")
+
+	// Replying requires the reporter's email address
+	w = decide(admin, report.ID, ReportReply, "Thanks")
+	assert.Contains(t, w.Body.String(), "no email address to reply to")
+
+	// Hidden twts are hidden pod-wide
+	w = decide(admin, report.ID, ReportHideTwt, "spam")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, server.config.HiddenTwt(spam))
+	assert.Equal(t, []string{hello.Hash()}, hashesOf(server.cache.GetByURL(bob.URL)))
+	assert.Equal(t, []string{hello.Hash()}, hashesOf(server.cache.GetByView(localViewKey)))
+
+	settings, err := LoadSettings(server.config.Data + "/settings.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{spam.Hash()}, settings.HiddenTwts)
+
+	// Suspended users are logged out everywhere
+	w = decide(admin, report.ID, ReportSuspendUser, "repeated spam")
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	bob, err = server.db.GetUser(bob.Username)
+	require.NoError(t, err)
+	assert.True(t, bob.IsSuspended())
+	assert.Equal(t, "repeated spam", bob.SuspendedReason)
+	assert.Empty(t, bob.Tokens)
+	assert.False(t, sessions.HasSession(bobSession.ID))
+
+	// Every decision is recorded in the report's audit trail
+	report, err = server.db.GetReport(report.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ReportActioned, report.Status)
+	require.Len(t, report.Decisions, 2)
+	assert.Equal(t, "admin", report.Decisions[0].Actor)
+	assert.Equal(t, ReportHideTwt, report.Decisions[0].Action)
+	assert.Equal(t, ReportSuspendUser, report.Decisions[1].Action)
+
+	// External feeds have no local user to suspend
+	external := NewReport("carol", "https://example.com/carol.txt", "", "spam", "Spammer")
+	require.NoError(t, server.db.SetReport(external.ID, external))
+
+	w = decide(admin, external.ID, ReportSuspendUser, "")
+	assert.Contains(t, w.Body.String(), "not about a user of this pod")
+
+	w = decide(admin, external.ID, ReportMuteFeed, "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, server.config.MutedFeed(external.URL))
+
+	w = decide(admin, external.ID, ReportBlacklistFeed, "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, server.config.BlacklistedFeed(external.URL))
+	assert.False(t, server.config.BlacklistedFeed("https://example.com/carol.txt.bak"))
+
+	// Every decision is also recorded in the audit log
+	entries, err := server.db.GetAllAuditEntries()
+	require.NoError(t, err)
+	entries = entries.Filter(AuditFilter{Action: AuditModerateReport})
+	require.Len(t, entries, 4)
+	assert.Equal(t, external.ID, entries[0].Target)
+	assert.Equal(t, "blacklist_feed", entries[0].Note)
+	assert.Equal(t, report.ID, entries[3].Target)
+	assert.Equal(t, "open", entries[3].Before)
+	assert.Equal(t, "actioned", entries[3].After)
+	assert.Equal(t, "hide_twt: spam", entries[3].Note)
+}
+
+func hashesOf(twts types.Twts) (hashes []string) {
+	for _, twt := range twts {
+		hashes = append(hashes, twt.Hash())
+	}
+	return
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalidRole is returned when assigning an unknown role
+	ErrInvalidRole = errors.New("error: invalid role")
+
+	// ErrPermissionDenied is returned when a user lacks the permission for
+	// an action
+	ErrPermissionDenied = errors.New("error: permission denied")
+)
+
+// Role is a staff role assigned to a user by the pod's administrators that
+// grants the user a set of permissions, users without a role are not staff
+type Role string
+
+const (
+	// RoleAdmin has every permission, the configured pod owner (AdminUser)
+	// is always an admin
+	RoleAdmin Role = "admin"
+
+	// RoleModerator reviews abuse reports and manages feeds
+	RoleModerator Role = "moderator"
+
+	// RoleSupport helps users with their accounts
+	RoleSupport Role = "support"
+)
+
+// Permission is a capability in managing the pod
+type Permission string
+
+const (
+	// PermissionManageUsers allows adding, deleting and resetting users
+	PermissionManageUsers Permission = "manage_users"
+
+	// PermissionManageFeeds allows deleting feeds
+	PermissionManageFeeds Permission = "manage_feeds"
+
+	// PermissionManagePeers allows viewing the pod's peers
+	PermissionManagePeers Permission = "manage_peers"
+
+	// PermissionRunJobs allows running background jobs and refreshing the cache
+	PermissionRunJobs Permission = "run_jobs"
+
+	// PermissionViewReports allows reviewing and deciding abuse reports
+	PermissionViewReports Permission = "view_reports"
+
+	// PermissionEditSettings allows editing the pod's settings
+	PermissionEditSettings Permission = "edit_settings"
+
+	// PermissionManageRoles allows assigning roles to users
+	PermissionManageRoles Permission = "manage_roles"
+
+	// PermissionViewAudit allows viewing and exporting the audit log
+	PermissionViewAudit Permission = "view_audit"
+
+	// PermissionLimitUsers allows silencing, disabling posting of and
+	// suspending users
+	PermissionLimitUsers Permission = "limit_users"
+
+	// PermissionManageInvites allows creating invites without a quota and
+	// viewing and revoking everyone's invites
+	PermissionManageInvites Permission = "manage_invites"
+)
+
+// Roles are the roles that can be assigned to users
+var Roles = []Role{RoleAdmin, RoleModerator, RoleSupport}
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionManageUsers,
+		PermissionManageFeeds,
+		PermissionManagePeers,
+		PermissionRunJobs,
+		PermissionViewReports,
+		PermissionEditSettings,
+		PermissionManageRoles,
+		PermissionViewAudit,
+		PermissionLimitUsers,
+		PermissionManageInvites,
+	},
+	RoleModerator: {
+		PermissionManageFeeds,
+		PermissionViewReports,
+		PermissionLimitUsers,
+	},
+	RoleSupport: {
+		PermissionManageUsers,
+	},
+}
+
+// StaffMember is a user with a role
+type StaffMember struct {
+	Username string
+	Role     Role
+}
+
+// StaffMembers is a list of staff sorted by username
+type StaffMembers []StaffMember
+
+func (staff StaffMembers) Len() int           { return len(staff) }
+func (staff StaffMembers) Less(i, j int) bool { return staff[i].Username < staff[j].Username }
+func (staff StaffMembers) Swap(i, j int)      { staff[i], staff[j] = staff[j], staff[i] }
+
+// ParseRole parses a role, the empty role (or "none") removes a user's role
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	if role == "" || role == "none" {
+		return "", nil
+	}
+	if _, ok := rolePermissions[role]; !ok {
+		return "", ErrInvalidRole
+	}
+	return role, nil
+}
+
+// Permissions returns the permissions granted by the role
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// Can returns true if the role grants the permission
+func (r Role) Can(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleOf returns the user's role, the configured pod owner is always an admin
+func RoleOf(conf *Config, user *User) Role {
+	if user == nil || user.Username == "" {
+		return ""
+	}
+	if NormalizeUsername(conf.AdminUser) == NormalizeUsername(user.Username) {
+		return RoleAdmin
+	}
+	if _, ok := rolePermissions[user.Role]; !ok {
+		return ""
+	}
+	return user.Role
+}
+
+// HasPermissionFactory returns a function that returns true if the user
+// provided has the permission by way of their role, false otherwise.
+func HasPermissionFactory(conf *Config) func(user *User, perm Permission) bool {
+	return func(user *User, perm Permission) bool {
+		return RoleOf(conf, user).Can(perm)
+	}
+}
+
+// IsStaffUserFactory returns a function that returns true if the user
+// provided has any role, false otherwise.
+func IsStaffUserFactory(conf *Config) func(user *User) bool {
+	return func(user *User) bool {
+		return RoleOf(conf, user) != ""
+	}
+}
+
+// CanManageUser returns true if the actor may manage the user's account,
+// only admins may manage the accounts of other staff
+func CanManageUser(conf *Config, actor, user *User) bool {
+	return RoleOf(conf, user) == "" || RoleOf(conf, actor) == RoleAdmin
+}
+
+// SetUserRole assigns the role to the user on behalf of the actor, the
+// configured pod owner's role cannot be changed
+func SetUserRole(conf *Config, db Store, actor, user *User, role Role) error {
+	if !RoleOf(conf, actor).Can(PermissionManageRoles) {
+		return ErrPermissionDenied
+	}
+	if NormalizeUsername(conf.AdminUser) == NormalizeUsername(user.Username) {
+		return ErrPermissionDenied
+	}
+	if role != "" {
+		if _, ok := rolePermissions[role]; !ok {
+			return ErrInvalidRole
+		}
+	}
+
+	user.Role = role
+	return db.SetUser(user.Username, user)
+}
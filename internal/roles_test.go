@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestRolePermissions(t *testing.T) {
+	conf := NewConfig()
+	conf.AdminUser = "owner"
+
+	hasPermission := HasPermissionFactory(conf)
+	isStaffUser := IsStaffUserFactory(conf)
+
+	owner := &User{Username: "owner"}
+	moderator := &User{Username: "mod", Role: RoleModerator}
+	support := &User{Username: "helper", Role: RoleSupport}
+	bogus := &User{Username: "bogus", Role: Role("root")}
+	alice := &User{Username: "alice"}
+
+	assert.Equal(t, RoleAdmin, RoleOf(conf, owner))
+	assert.Equal(t, Role(""), RoleOf(conf, bogus))
+	assert.Equal(t, Role(""), RoleOf(conf, nil))
+
+	testCases := []struct {
+		user  *User
+		perm  Permission
+		allow bool
+	}{
+		{owner, PermissionManageRoles, true},
+		{owner, PermissionEditSettings, true},
+		{moderator, PermissionViewReports, true},
+		{moderator, PermissionManageFeeds, true},
+		{moderator, PermissionManageUsers, false},
+		{moderator, PermissionEditSettings, false},
+		{support, PermissionManageUsers, true},
+		{support, PermissionViewReports, false},
+		{support, PermissionRunJobs, false},
+		{bogus, PermissionManageUsers, false},
+		{alice, PermissionViewReports, false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.allow, hasPermission(testCase.user, testCase.perm), "%s %s", testCase.user.Username, testCase.perm)
+	}
+
+	assert.True(t, isStaffUser(support))
+	assert.False(t, isStaffUser(alice))
+	assert.False(t, isStaffUser(bogus))
+
+	// Only admins may manage the accounts of other staff
+	assert.True(t, CanManageUser(conf, support, alice))
+	assert.False(t, CanManageUser(conf, support, moderator))
+	assert.True(t, CanManageUser(conf, owner, moderator))
+
+	role, err := ParseRole(" Moderator ")
+	require.NoError(t, err)
+	assert.Equal(t, RoleModerator, role)
+
+	role, err = ParseRole("none")
+	require.NoError(t, err)
+	assert.Equal(t, Role(""), role)
+
+	_, err = ParseRole("root")
+	assert.Equal(t, ErrInvalidRole, err)
+}
+
+func TestSetUserRole(t *testing.T) {
+	api := newTestAPI(t)
+	api.config.AdminUser = "owner"
+
+	owner := &User{Username: "owner"}
+	moderator := &User{Username: "mod", Role: RoleModerator}
+	alice := &User{Username: "alice"}
+	require.NoError(t, api.db.SetUser(alice.Username, alice))
+
+	assert.Equal(t, ErrPermissionDenied, SetUserRole(api.config, api.db, moderator, alice, RoleModerator))
+	assert.Equal(t, ErrPermissionDenied, SetUserRole(api.config, api.db, owner, owner, ""))
+	assert.Equal(t, ErrInvalidRole, SetUserRole(api.config, api.db, owner, alice, Role("root")))
+
+	require.NoError(t, SetUserRole(api.config, api.db, owner, alice, RoleSupport))
+
+	alice, err := api.db.GetUser(alice.Username)
+	require.NoError(t, err)
+	assert.Equal(t, RoleSupport, alice.Role)
+}
+
+func TestManageRoleHandlers(t *testing.T) {
+	server := newTestServer(t)
+	server.config.AdminUser = "owner"
+
+	users := map[string]*User{}
+	for username, role := range map[string]Role{"owner": "", "mod": RoleModerator, "helper": RoleSupport, "alice": ""} {
+		user := NewUser()
+		user.Username = username
+		user.Role = role
+		require.NoError(t, server.db.SetUser(user.Username, user))
+		users[username] = user
+	}
+
+	post := func(handler func() httprouter.Handle, user *User, form url.Values) string {
+		sess := session.NewSession(server.sc)
+		sess.ID = GenerateRandomToken()
+		sess.Data = session.Map{"username": user.Username}
+
+		r := httptest.NewRequest(http.MethodPost, "/manage", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+
+		w := httptest.NewRecorder()
+		handler()(w, r, nil)
+		return w.Body.String()
+	}
+
+	// Moderators cannot assign roles
+	body := post(server.SetRoleHandler, users["mod"], url.Values{"username": {"alice"}, "role": {"moderator"}})
+	assert.Contains(t, body, "You do not have permission to do this!")
+
+	body = post(server.SetRoleHandler, users["owner"], url.Values{"username": {"alice"}, "role": {"root"}})
+	assert.Contains(t, body, "Invalid role")
+
+	post(server.SetRoleHandler, users["owner"], url.Values{"username": {"alice"}, "role": {"moderator"}})
+	alice, err := server.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Equal(t, RoleModerator, alice.Role)
+
+	// Moderators cannot delete users and support cannot delete other staff
+	body = post(server.DelUserHandler, users["mod"], url.Values{"username": {"helper"}})
+	assert.Contains(t, body, "You do not have permission to do this!")
+
+	body = post(server.DelUserHandler, users["helper"], url.Values{"username": {"alice"}})
+	assert.Contains(t, body, "You do not have permission to do this!")
+	assert.True(t, server.db.HasUser("alice"))
+
+	// Support cannot edit the pod's settings
+	body = post(server.ManagePodHandler, users["helper"], url.Values{"podName": {"hacked"}})
+	assert.Contains(t, body, "You do not have permission to do this!")
+	assert.NotEqual(t, "hacked", server.config.Name)
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	api := newTestAPI(t)
+	api.config.AdminUser = "owner"
+
+	owner := &User{Username: "owner"}
+	moderator := &User{Username: "mod", Role: RoleModerator}
+	alice := &User{Username: "alice"}
+	for _, user := range []*User{owner, moderator, alice} {
+		require.NoError(t, api.db.SetUser(user.Username, user))
+	}
+
+	report := NewReport("carol", "https://example.com/carol.txt", "", "spam", "Spammer")
+	require.NoError(t, api.db.SetReport(report.ID, report))
+
+	usersEndpoint := api.hasPermission(PermissionManageUsers, api.AdminUsersEndpoint())
+	roleEndpoint := api.hasPermission(PermissionManageRoles, api.AdminSetRoleEndpoint())
+	reportsEndpoint := api.hasPermission(PermissionViewReports, api.AdminReportsEndpoint())
+	decideEndpoint := api.hasPermission(PermissionViewReports, api.AdminDecideReportEndpoint())
+
+	assert.Equal(t, http.StatusForbidden, callEndpoint(t, usersEndpoint, moderator, http.MethodPost, struct{}{}, nil))
+
+	var users types.AdminUsersResponse
+	require.Equal(t, http.StatusOK, callEndpoint(t, usersEndpoint, owner, http.MethodPost, struct{}{}, &users))
+	require.Len(t, users.Users, 3)
+	assert.Equal(t, "alice", users.Users[0].Username)
+	assert.Equal(t, "moderator", users.Users[1].Role)
+	assert.Equal(t, "admin", users.Users[2].Role)
+
+	assert.Equal(t, http.StatusForbidden, callEndpoint(t, roleEndpoint, moderator, http.MethodPost, types.AdminSetRoleRequest{Username: "alice", Role: "admin"}, nil))
+	assert.Equal(t, http.StatusBadRequest, callEndpoint(t, roleEndpoint, owner, http.MethodPost, types.AdminSetRoleRequest{Username: "alice", Role: "root"}, nil))
+	assert.Equal(t, http.StatusNotFound, callEndpoint(t, roleEndpoint, owner, http.MethodPost, types.AdminSetRoleRequest{Username: "nobody", Role: "support"}, nil))
+	assert.Equal(t, http.StatusOK, callEndpoint(t, roleEndpoint, owner, http.MethodPost, types.AdminSetRoleRequest{Username: "alice", Role: "support"}, nil))
+
+	alice, err := api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Equal(t, RoleSupport, alice.Role)
+
+	assert.Equal(t, http.StatusForbidden, callEndpoint(t, reportsEndpoint, alice, http.MethodPost, types.AdminReportsRequest{}, nil))
+
+	var reports types.AdminReportsResponse
+	require.Equal(t, http.StatusOK, callEndpoint(t, reportsEndpoint, moderator, http.MethodPost, types.AdminReportsRequest{Status: "open"}, &reports))
+	require.Len(t, reports.Reports, 1)
+	assert.Equal(t, report.ID, reports.Reports[0].ID)
+
+	assert.Equal(t, http.StatusBadRequest, callEndpoint(t, decideEndpoint, moderator, http.MethodPost, types.AdminDecideReportRequest{ID: report.ID, Action: "suspend_user"}, nil))
+	assert.Equal(t, http.StatusNotFound, callEndpoint(t, decideEndpoint, moderator, http.MethodPost, types.AdminDecideReportRequest{ID: "invalid", Action: "dismiss"}, nil))
+
+	var decided types.Report
+	require.Equal(t, http.StatusOK, callEndpoint(t, decideEndpoint, moderator, http.MethodPost, types.AdminDecideReportRequest{ID: report.ID, Action: "dismiss", Note: "not spam"}, &decided))
+	assert.Equal(t, "dismissed", decided.Status)
+	require.Len(t, decided.Decisions, 1)
+	assert.Equal(t, "mod", decided.Decisions[0].Actor)
+	assert.Equal(t, "not spam", decided.Decisions[0].Note)
+}
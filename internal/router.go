@@ -10,12 +10,21 @@ import (
 // Middleware ...
 type Middleware func(httprouter.Handle) httprouter.Handle
 
+// Route is a method and path registered with a Router
+type Route struct {
+	Method string
+	Path   string
+}
+
 // Router ...
 type Router struct {
 	httprouter.Router
 
 	path        string
 	middlewares []Middleware
+
+	// routes is shared with all groups of the Router
+	routes *[]Route
 }
 
 // NewRouter ...
@@ -27,6 +36,7 @@ func NewRouter() *Router {
 			HandleMethodNotAllowed: false,
 			HandleOPTIONS:          true,
 		},
+		routes: &[]Route{},
 	}
 }
 
@@ -48,6 +58,7 @@ func (r *Router) Group(path string, m ...Middleware) *Router {
 		Router:      r.Router,
 		middlewares: append(m, r.middlewares...),
 		path:        r.joinPath(path),
+		routes:      r.routes,
 	}
 }
 
@@ -63,6 +74,18 @@ func (r *Router) Handle(method, path string, handle httprouter.Handle) {
 		handle = v(handle)
 	}
 	r.Router.Handle(method, r.joinPath(path), handle)
+
+	if r.routes != nil {
+		*r.routes = append(*r.routes, Route{Method: method, Path: r.joinPath(path)})
+	}
+}
+
+// Routes returns the routes registered with the Router and all its groups
+func (r *Router) Routes() []Route {
+	if r.routes == nil {
+		return nil
+	}
+	return *r.routes
 }
 
 // GET is a shortcut for Router.Handle("GET", path, handle)
@@ -20,6 +20,7 @@ import (
 	"github.com/andyleap/microformats"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/gabstv/merger"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
 	"github.com/robfig/cron"
 	log "github.com/sirupsen/logrus"
@@ -80,6 +81,15 @@ type Server struct {
 	// API
 	api *API
 
+	// IndieAuth Authorization Codes
+	codes *AuthorizationCodes
+
+	// OpenID Connect Provider
+	oidc *OIDCProvider
+
+	// Rate Limiters
+	limits *RateLimiters
+
 	// Passwords
 	pm passwords.Passwords
 
@@ -87,6 +97,12 @@ type Server struct {
 	translator *Translator
 }
 
+// limit rate limits a handler by the named budget keyed by the logged in user
+// or the client's IP address for anonymous requests
+func (s *Server) limit(name string, handler httprouter.Handle) httprouter.Handle {
+	return s.limits.Limit(name, RateLimitByUser)(handler)
+}
+
 func (s *Server) render(name string, w http.ResponseWriter, ctx *Context) {
 	//
 	// Update timeline view(s) UpdatedAt timestamps
@@ -108,6 +124,7 @@ func (s *Server) render(name string, w http.ResponseWriter, ctx *Context) {
 		if ctx.LastMentionedAt.IsZero() {
 			ctx.LastMentionedAt = s.lastMentionedAt(ctx.User)
 		}
+		ctx.UnreadNotifications = s.unreadNotifications(ctx.User)
 	}
 
 	buf, err := s.tmplman.Exec(name, ctx)
@@ -324,6 +341,30 @@ func (s *Server) setupMetrics() {
 		"Count of old Media (PNG) served",
 	)
 
+	// spam filter
+	metrics.NewCounterVec(
+		"spam", "matched",
+		"Number of twts of external feeds scored as spam by each spam rule",
+		[]string{"rule"},
+	)
+	metrics.NewCounterVec(
+		"spam", "flagged",
+		"Number of twts of external feeds hidden or held by the spam filter",
+		[]string{"action"},
+	)
+
+	// rate limits
+	metrics.NewCounterVec(
+		"ratelimit", "allowed",
+		"Number of requests allowed by rate limits",
+		[]string{"limit"},
+	)
+	metrics.NewCounterVec(
+		"ratelimit", "limited",
+		"Number of requests rejected by rate limits",
+		[]string{"limit"},
+	)
+
 	s.AddRoute("GET", "/metrics", metrics.Handler())
 }
 
@@ -520,8 +561,13 @@ func (s *Server) initRoutes() {
 	s.router.GET("/robots.txt", httproutermiddleware.Handler("robots", s.RobotsHandler(), mdlw))
 	s.router.HEAD("/robots.txt", httproutermiddleware.Handler("robots", s.RobotsHandler(), mdlw))
 
+	s.router.GET("/blocklist.json", httproutermiddleware.Handler("blocklist", s.BlocklistHandler(), mdlw))
+	s.router.HEAD("/blocklist.json", httproutermiddleware.Handler("blocklist", s.BlocklistHandler(), mdlw))
+
 	s.router.GET("/discover", httproutermiddleware.Handler("discover", s.am.MustAuth(s.DiscoverHandler()), mdlw))
 	s.router.GET("/mentions", httproutermiddleware.Handler("mentions", s.am.MustAuth(s.MentionsHandler()), mdlw))
+	s.router.GET("/notifications", httproutermiddleware.Handler("notifications", s.am.MustAuth(s.NotificationsHandler()), mdlw))
+	s.router.POST("/notifications/read", httproutermiddleware.Handler("notifications_read", s.am.MustAuth(s.MarkNotificationsReadHandler()), mdlw))
 	s.router.GET("/search", httproutermiddleware.Handler("search", s.SearchHandler(), mdlw))
 
 	s.router.HEAD("/twt/:hash", httproutermiddleware.Handler("twt", s.PermalinkHandler(), mdlw))
@@ -536,7 +582,7 @@ func (s *Server) initRoutes() {
 	s.router.GET("/feeds", httproutermiddleware.Handler("feeds", s.am.MustAuth(s.FeedsHandler()), mdlw))
 	s.router.POST("/feed", httproutermiddleware.Handler("feeds", s.am.MustAuth(s.FeedHandler()), mdlw))
 
-	s.router.POST("/post", httproutermiddleware.Handler("post", s.am.MustAuth(s.PostHandler()), mdlw))
+	s.router.POST("/post", httproutermiddleware.Handler("post", s.am.MustAuth(s.limit("post", s.PostHandler())), mdlw))
 	s.router.PATCH("/post", httproutermiddleware.Handler("post", s.am.MustAuth(s.PostHandler()), mdlw))
 	s.router.DELETE("/post", httproutermiddleware.Handler("post", s.am.MustAuth(s.PostHandler()), mdlw))
 
@@ -553,6 +599,7 @@ func (s *Server) initRoutes() {
 	s.router.HEAD("/user/:nick/avatar", httproutermiddleware.Handler("avatar", s.AvatarHandler(), mdlw))
 	s.router.HEAD("/user/:nick/twtxt.txt", httproutermiddleware.Handler("twtxt", s.TwtxtHandler(), mdlw))
 	s.router.GET("/user/:nick/twtxt.txt", httproutermiddleware.Handler("twtxt", s.TwtxtHandler(), mdlw))
+	s.router.GET("/user/:nick/twtxt.txt.sig", httproutermiddleware.Handler("twtxt_signatures", s.TwtxtSignaturesHandler(), mdlw))
 	s.router.GET("/user/:nick/followers", httproutermiddleware.Handler("followers", s.FollowersHandler(), mdlw))
 	s.router.GET("/user/:nick/following", httproutermiddleware.Handler("following", s.FollowingHandler(), mdlw))
 	s.router.GET("/user/:nick/bookmarks", httproutermiddleware.Handler("bookmarks", s.BookmarksHandler(), mdlw))
@@ -575,6 +622,7 @@ func (s *Server) initRoutes() {
 	s.router.HEAD("/~:nick/avatar", httproutermiddleware.Handler("avatar", s.AvatarHandler(), mdlw))
 	s.router.HEAD("/~:nick/twtxt.txt", httproutermiddleware.Handler("twtxt", s.TwtxtHandler(), mdlw))
 	s.router.GET("/~:nick/twtxt.txt", httproutermiddleware.Handler("twtxt", s.TwtxtHandler(), mdlw))
+	s.router.GET("/~:nick/twtxt.txt.sig", httproutermiddleware.Handler("twtxt_signatures", s.TwtxtSignaturesHandler(), mdlw))
 	s.router.GET("/~:nick/followers", httproutermiddleware.Handler("followers", s.FollowersHandler(), mdlw))
 	s.router.GET("/~:nick/following", httproutermiddleware.Handler("following", s.FollowingHandler(), mdlw))
 	s.router.GET("/~:nick/bookmarks", httproutermiddleware.Handler("bookmarks", s.BookmarksHandler(), mdlw))
@@ -604,28 +652,45 @@ func (s *Server) initRoutes() {
 	s.router.POST("/feed/:name/delete", httproutermiddleware.Handler("feed_delete", s.am.MustAuth(s.DeleteFeedHandler()), mdlw))
 
 	s.router.GET("/login", httproutermiddleware.Handler("login", s.am.HasAuth(s.LoginHandler()), mdlw))
-	s.router.POST("/login", httproutermiddleware.Handler("login", s.LoginHandler(), mdlw))
+	s.router.POST("/login", httproutermiddleware.Handler("login", s.limit("login", s.LoginHandler()), mdlw))
 
 	s.router.GET("/login/email", httproutermiddleware.Handler("login_email", s.am.HasAuth(s.LoginEmailHandler()), mdlw))
-	s.router.POST("/login/email", httproutermiddleware.Handler("login_email", s.LoginEmailHandler(), mdlw))
+	s.router.POST("/login/email", httproutermiddleware.Handler("login_email", s.limit("login", s.LoginEmailHandler()), mdlw))
+	s.router.GET("/login/2fa", httproutermiddleware.Handler("login_2fa", s.am.HasAuth(s.TwoFactorLoginHandler()), mdlw))
+	s.router.POST("/login/2fa", httproutermiddleware.Handler("login_2fa", s.limit("login", s.TwoFactorLoginHandler()), mdlw))
+
+	// OpenID Connect Login
+	if s.config.OIDCEnabled() {
+		s.router.GET("/login/oidc", httproutermiddleware.Handler("login_oidc", s.am.HasAuth(s.limit("login", s.OIDCLoginHandler())), mdlw))
+		s.router.GET("/login/oidc/callback", httproutermiddleware.Handler("login_oidc_callback", s.limit("login", s.OIDCCallbackHandler()), mdlw))
+		s.router.POST("/settings/oidc/link", httproutermiddleware.Handler("settings_oidc_link", s.am.MustAuth(s.OIDCLinkHandler()), mdlw))
+		s.router.POST("/settings/oidc/unlink", httproutermiddleware.Handler("settings_oidc_unlink", s.am.MustAuth(s.OIDCUnlinkHandler()), mdlw))
+	}
+
+	// IndieAuth / OAuth2 Authorization Server
+	s.router.GET("/.well-known/oauth-authorization-server", httproutermiddleware.Handler("indieauth_metadata", s.IndieAuthMetadataHandler(), mdlw))
+	s.router.GET("/indieauth/auth", httproutermiddleware.Handler("indieauth_auth", s.AuthorizationHandler(), mdlw))
+	s.router.POST("/indieauth/auth", httproutermiddleware.Handler("indieauth_auth", s.limit("login", s.ProfileCodeHandler()), mdlw))
+	s.router.POST("/indieauth/consent", httproutermiddleware.Handler("indieauth_consent", s.am.MustAuth(s.ConsentHandler()), mdlw))
+	s.router.POST("/indieauth/token", httproutermiddleware.Handler("indieauth_token", s.limit("login", s.TokenHandler()), mdlw))
 	s.router.GET("/magiclinkauth", httproutermiddleware.Handler("magiclinkauth", s.MagicLinkAuthHandler(), mdlw))
 
 	s.router.GET("/logout", httproutermiddleware.Handler("logout", s.LogoutHandler(), mdlw))
 	s.router.POST("/logout", httproutermiddleware.Handler("logout", s.LogoutHandler(), mdlw))
 
 	s.router.GET("/register", httproutermiddleware.Handler("register", s.am.HasAuth(s.RegisterHandler()), mdlw))
-	s.router.POST("/register", httproutermiddleware.Handler("register", s.RegisterHandler(), mdlw))
+	s.router.POST("/register", httproutermiddleware.Handler("register", s.limit("register", s.RegisterHandler()), mdlw))
 
 	// Reset Password
 	s.router.GET("/resetPassword", httproutermiddleware.Handler("resetPassword", s.ResetPasswordHandler(), mdlw))
-	s.router.POST("/resetPassword", httproutermiddleware.Handler("resetPassword", s.ResetPasswordHandler(), mdlw))
+	s.router.POST("/resetPassword", httproutermiddleware.Handler("resetPassword", s.limit("login", s.ResetPasswordHandler()), mdlw))
 	s.router.GET("/newPassword", httproutermiddleware.Handler("resetPassword", s.ResetPasswordMagicLinkHandler(), mdlw))
 	s.router.POST("/newPassword", httproutermiddleware.Handler("newPassword", s.NewPasswordHandler(), mdlw))
 
 	// Media Handling
 	s.router.GET("/media/:name", httproutermiddleware.Handler("media", s.MediaHandler(), mdlw))
 	s.router.HEAD("/media/:name", httproutermiddleware.Handler("media", s.MediaHandler(), mdlw))
-	s.router.POST("/upload", httproutermiddleware.Handler("upload", s.am.MustAuth(s.UploadMediaHandler()), mdlw))
+	s.router.POST("/upload", httproutermiddleware.Handler("upload", s.am.MustAuth(s.limit("upload", s.UploadMediaHandler())), mdlw))
 
 	// Task State
 	s.router.GET("/task/:uuid", httproutermiddleware.Handler("task", s.TaskHandler(), mdlw))
@@ -649,6 +714,20 @@ func (s *Server) initRoutes() {
 
 	s.router.GET("/settings", httproutermiddleware.Handler("settings", s.am.MustAuth(s.SettingsHandler()), mdlw))
 	s.router.POST("/settings", httproutermiddleware.Handler("settings", s.am.MustAuth(s.SettingsHandler()), mdlw))
+	s.router.POST("/settings/sessions/revoke", httproutermiddleware.Handler("settings_sessions_revoke", s.am.MustAuth(s.RevokeSessionHandler()), mdlw))
+	s.router.POST("/settings/filters/add", httproutermiddleware.Handler("settings_filters_add", s.am.MustAuth(s.AddMuteFilterHandler()), mdlw))
+	s.router.POST("/settings/filters/remove", httproutermiddleware.Handler("settings_filters_remove", s.am.MustAuth(s.RemoveMuteFilterHandler()), mdlw))
+	s.router.GET("/settings/2fa", httproutermiddleware.Handler("settings_2fa", s.am.MustAuth(s.TwoFactorSettingsHandler()), mdlw))
+	s.router.POST("/settings/2fa", httproutermiddleware.Handler("settings_2fa", s.am.MustAuth(s.limit("login", s.TwoFactorSettingsHandler())), mdlw))
+	s.router.POST("/settings/2fa/disable", httproutermiddleware.Handler("settings_2fa_disable", s.am.MustAuth(s.limit("login", s.DisableTwoFactorHandler())), mdlw))
+	s.router.POST("/settings/2fa/recovery", httproutermiddleware.Handler("settings_2fa_recovery", s.am.MustAuth(s.limit("login", s.RecoveryCodesHandler())), mdlw))
+	s.router.POST("/settings/digest", httproutermiddleware.Handler("settings_digest", s.am.MustAuth(s.limit("support", s.DigestSettingsHandler())), mdlw))
+	s.router.POST("/settings/invites", httproutermiddleware.Handler("settings_invites", s.am.MustAuth(s.CreateInviteHandler()), mdlw))
+	s.router.POST("/settings/invites/revoke", httproutermiddleware.Handler("settings_invites_revoke", s.am.MustAuth(s.RevokeInviteHandler()), mdlw))
+
+	s.router.GET("/digest/verify", httproutermiddleware.Handler("digest_verify", s.VerifyDigestHandler(), mdlw))
+	s.router.GET("/digest/unsubscribe", httproutermiddleware.Handler("digest_unsubscribe", s.UnsubscribeDigestHandler(), mdlw))
+	s.router.POST("/digest/unsubscribe", httproutermiddleware.Handler("digest_unsubscribe", s.UnsubscribeDigestHandler(), mdlw))
 
 	s.router.GET("/info", httproutermiddleware.Handler("info", s.PodInfoHandler(), mdlw))
 	s.router.GET("/config", httproutermiddleware.Handler("config", s.am.MustAuth(s.PodConfigHandler()), mdlw))
@@ -659,22 +738,40 @@ func (s *Server) initRoutes() {
 	s.router.POST("/manage/pod", httproutermiddleware.Handler("manage_pod", s.am.MustAuth(s.ManagePodHandler()), mdlw))
 	s.router.GET("/manage/refreshcache", httproutermiddleware.Handler("manage_refreshcache", s.am.MustAuth(s.RefreshCacheHandler()), mdlw))
 
+	s.router.GET("/manage/reports", httproutermiddleware.Handler("manage_reports", s.am.MustAuth(s.ManageReportsHandler()), mdlw))
+	s.router.POST("/manage/reports", httproutermiddleware.Handler("manage_reports", s.am.MustAuth(s.ManageReportsHandler()), mdlw))
+	s.router.GET("/manage/spam", httproutermiddleware.Handler("manage_spam", s.am.MustAuth(s.ManageSpamHandler()), mdlw))
+	s.router.POST("/manage/spam", httproutermiddleware.Handler("manage_spam", s.am.MustAuth(s.ManageSpamHandler()), mdlw))
+	s.router.GET("/manage/audit", httproutermiddleware.Handler("manage_audit", s.am.MustAuth(s.ManageAuditHandler()), mdlw))
+	s.router.GET("/manage/invites", httproutermiddleware.Handler("manage_invites", s.am.MustAuth(s.ManageInvitesHandler()), mdlw))
+	s.router.GET("/manage/blocklist", httproutermiddleware.Handler("manage_blocklist", s.am.MustAuth(s.ManageBlocklistHandler()), mdlw))
+	s.router.POST("/manage/blocklist", httproutermiddleware.Handler("manage_blocklist", s.am.MustAuth(s.ManageBlocklistHandler()), mdlw))
+	s.router.POST("/manage/blocklist/remove", httproutermiddleware.Handler("manage_blocklist_remove", s.am.MustAuth(s.DelBlockEntryHandler()), mdlw))
+	s.router.POST("/manage/blocklist/import", httproutermiddleware.Handler("manage_blocklist_import", s.am.MustAuth(s.ImportBlocklistHandler()), mdlw))
+	s.router.POST("/manage/blocklist/subscriptions", httproutermiddleware.Handler("manage_blocklist_subscriptions", s.am.MustAuth(s.BlocklistSubscriptionsHandler()), mdlw))
+
 	s.router.GET("/manage/users", httproutermiddleware.Handler("manager_users", s.am.MustAuth(s.ManageUsersHandler()), mdlw))
 	s.router.POST("/manage/adduser", httproutermiddleware.Handler("adduser", s.am.MustAuth(s.AddUserHandler()), mdlw))
 	s.router.POST("/manage/delfeed", httproutermiddleware.Handler("delfeed", s.am.MustAuth(s.DelFeedHandler()), mdlw))
 	s.router.POST("/manage/deluser", httproutermiddleware.Handler("deluser", s.am.MustAuth(s.DelUserHandler()), mdlw))
 	s.router.POST("/manage/rstuser", httproutermiddleware.Handler("rstuser", s.am.MustAuth(s.RstUserHandler()), mdlw))
+	s.router.POST("/manage/rst2fa", httproutermiddleware.Handler("rst2fa", s.am.MustAuth(s.RstTwoFactorHandler()), mdlw))
+	s.router.POST("/manage/setrole", httproutermiddleware.Handler("setrole", s.am.MustAuth(s.SetRoleHandler()), mdlw))
+	s.router.POST("/manage/limituser", httproutermiddleware.Handler("limituser", s.am.MustAuth(s.LimitUserHandler()), mdlw))
+	s.router.POST("/manage/liftlimit", httproutermiddleware.Handler("liftlimit", s.am.MustAuth(s.LiftLimitHandler()), mdlw))
+	s.router.POST("/manage/approveuser", httproutermiddleware.Handler("approveuser", s.am.MustAuth(s.ApproveUserHandler()), mdlw))
+	s.router.POST("/manage/rejectuser", httproutermiddleware.Handler("rejectuser", s.am.MustAuth(s.RejectUserHandler()), mdlw))
 
 	s.router.POST("/delete", httproutermiddleware.Handler("delete", s.am.MustAuth(s.DeleteHandler()), mdlw))
 
 	// Support / Report Abuse handlers
 
 	s.router.GET("/support", httproutermiddleware.Handler("support", s.SupportHandler(), mdlw))
-	s.router.POST("/support", httproutermiddleware.Handler("support", s.SupportHandler(), mdlw))
+	s.router.POST("/support", httproutermiddleware.Handler("support", s.limit("support", s.SupportHandler()), mdlw))
 	s.router.GET("/_captcha", httproutermiddleware.Handler("captcha", s.CaptchaHandler(), mdlw))
 
 	s.router.GET("/report", httproutermiddleware.Handler("report", s.ReportHandler(), mdlw))
-	s.router.POST("/report", httproutermiddleware.Handler("report", s.ReportHandler(), mdlw))
+	s.router.POST("/report", httproutermiddleware.Handler("report", s.limit("support", s.ReportHandler()), mdlw))
 }
 
 // NewServer ...
@@ -757,28 +854,36 @@ func NewServer(bind string, options ...Option) (*Server, error) {
 		sc,
 	)
 
-	api := NewAPI(router, config, cache, archive, db, pm, tasks)
+	limits := NewRateLimiters(config)
+
+	api := NewAPI(router, config, cache, archive, db, pm, tasks, limits, sc)
 
 	var handler http.Handler
 
 	csrfHandler := nosurf.New(router)
 	csrfHandler.ExemptGlob("/api/v1/*")
+	// One-click unsubscribe from digests is POSTed by mail clients
+	csrfHandler.ExemptPath("/digest/unsubscribe")
+	// IndieAuth clients redeem authorization codes server to server
+	csrfHandler.ExemptPath("/indieauth/auth")
+	csrfHandler.ExemptPath("/indieauth/token")
 
 	// Useful for Safari / Mobile Safari when behind Cloudflare to streaming
 	// videos _actually_ works :O
 	if config.DisableGzip {
-		handler = sm.Handler(csrfHandler)
+		handler = sm.Handler(TrackSessions(sc)(csrfHandler))
 	} else {
-		handler = gziphandler.GzipHandler(sm.Handler(csrfHandler))
+		handler = gziphandler.GzipHandler(sm.Handler(TrackSessions(sc)(csrfHandler)))
 	}
 
 	if !config.DisableLogger {
 		handler = logger.New(logger.Options{
-			Prefix:               "yarnd",
-			RemoteAddressHeaders: []string{"X-Forwarded-For"},
+			Prefix: "yarnd",
 		}).Handler(handler)
 	}
 
+	handler = ForwardedFor(config)(handler)
+
 	server := &Server{
 		bind:    bind,
 		config:  config,
@@ -790,6 +895,15 @@ func NewServer(bind string, options ...Option) (*Server, error) {
 		// API
 		api: api,
 
+		// IndieAuth Authorization Codes
+		codes: NewAuthorizationCodes(),
+
+		// OpenID Connect Provider
+		oidc: NewOIDCProvider(config),
+
+		// Rate Limiters
+		limits: limits,
+
 		// Feed Cache
 		cache: cache,
 
@@ -819,6 +933,13 @@ func NewServer(bind string, options ...Option) (*Server, error) {
 		translator: translator,
 	}
 
+	// Hide the feeds of silenced users and serve those of suspended users as
+	// gone before serving any requests
+	if _, err := ApplyAccountLimits(server.config, server.db); err != nil {
+		log.WithError(err).Error("error applying account limits")
+		return nil, err
+	}
+
 	if err := server.setupJobs(); err != nil {
 		log.WithError(err).Error("error setting up background jobs")
 		return nil, err
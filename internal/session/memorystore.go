@@ -65,3 +65,15 @@ func (s *MemoryStore) GetAllSessions() ([]*Session, error) {
 	}
 	return sessions, nil
 }
+
+// GetUserSessions ...
+func (s *MemoryStore) GetUserSessions(username string) ([]*Session, error) {
+	var sessions []*Session
+	for _, item := range s.entries.Items() {
+		sess := item.Object.(*Session)
+		if u, ok := sess.Get("username"); ok && u == username {
+			sessions = append(sessions, sess)
+		}
+	}
+	return sessions, nil
+}
@@ -16,6 +16,12 @@ type Session struct {
 	Data      Map       `json:"data"`
 	CreatedAt time.Time `json:"created"`
 	ExpiresAt time.Time `json:"expires"`
+
+	// UserAgent and RemoteAddr are the user agent and approximate address
+	// of the client the session was last seen from
+	UserAgent  string    `json:"user_agent,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	LastSeenAt time.Time `json:"last_seen,omitempty"`
 }
 
 func NewSession(store Store) *Session {
@@ -58,6 +64,21 @@ func (sess *Session) Del(key string) error {
 	return sess.store.SyncSession(sess)
 }
 
+// Touch records the session as seen from a client returning true if the
+// session changed and needs to be synced, the time the session was last seen
+// is only updated every LastSeenInterval to avoid a write on every request.
+func (sess *Session) Touch(userAgent, remoteAddr string, now time.Time) bool {
+	if sess.UserAgent == userAgent && sess.RemoteAddr == remoteAddr && now.Sub(sess.LastSeenAt) < LastSeenInterval {
+		return false
+	}
+
+	sess.UserAgent = userAgent
+	sess.RemoteAddr = remoteAddr
+	sess.LastSeenAt = now
+
+	return true
+}
+
 func (sess *Session) Bytes() ([]byte, error) {
 	data, err := json.Marshal(sess)
 	if err != nil {
@@ -0,0 +1,20 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTouch(t *testing.T) {
+	sess := NewSession(NewMemoryStore(-1))
+	now := time.Now()
+
+	assert.True(t, sess.Touch("Firefox", "203.0.113.0/24", now))
+	assert.False(t, sess.Touch("Firefox", "203.0.113.0/24", now.Add(time.Second)))
+	assert.Equal(t, now, sess.LastSeenAt)
+
+	assert.True(t, sess.Touch("Firefox", "198.51.100.0/24", now.Add(time.Second)), "a new address is recorded")
+	assert.True(t, sess.Touch("Firefox", "198.51.100.0/24", now.Add(LastSeenInterval+time.Second)))
+}
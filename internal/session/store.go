@@ -10,6 +10,9 @@ import (
 // will automatically delete saved session data after this time.
 const DefaultSessionDuration = time.Hour
 
+// LastSeenInterval is how often the time a session was last seen is updated
+const LastSeenInterval = time.Minute
+
 var (
 	ErrSessionNotFound = errors.New("session not found or expired")
 	ErrSessionExpired  = errors.New("session expired")
@@ -29,4 +32,7 @@ type Store interface {
 	SyncSession(sess *Session) error
 
 	GetAllSessions() ([]*Session, error)
+
+	// GetUserSessions returns the sessions of the user logged in as username
+	GetUserSessions(username string) ([]*Session, error)
 }
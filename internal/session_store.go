@@ -88,3 +88,30 @@ func (s *SessionStore) GetAllSessions() ([]*session.Session, error) {
 	}
 	return append(sessions, persistedSessions...), nil
 }
+
+// GetUserSessions returns the sessions of the user logged in as username
+func (s *SessionStore) GetUserSessions(username string) ([]*session.Session, error) {
+	seen := make(map[string]bool)
+
+	var sessions []*session.Session
+	for _, item := range s.cached.Items() {
+		sess := item.Object.(*session.Session)
+		if u, ok := sess.Get("username"); ok && u == username {
+			seen[sess.ID] = true
+			sessions = append(sessions, sess)
+		}
+	}
+
+	persistedSessions, err := s.store.GetUserSessions(username)
+	if err != nil {
+		log.WithError(err).Errorf("error getting persisted sessions for %s", username)
+		return sessions, err
+	}
+	for _, sess := range persistedSessions {
+		if !seen[sess.ID] {
+			sessions = append(sessions, sess)
+		}
+	}
+
+	return sessions, nil
+}
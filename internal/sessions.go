@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+// ApproximateIP returns the network of an address (a /24 for IPv4 and a /48
+// for IPv6) so users can recognise where their sessions are used from
+// without the exact address being stored
+func ApproximateIP(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return ""
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return (&net.IPNet{IP: ip4.Mask(mask), Mask: mask}).String()
+	}
+
+	mask := net.CIDRMask(48, 128)
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
+
+// SessionHandle returns the ID a session is shown to users as, session IDs
+// themselves are never shown as they authenticate the session
+func SessionHandle(sess *session.Session) string {
+	return FastHashString(sess.ID)
+}
+
+// currentSessionHandle returns the ID of the session making the request
+func currentSessionHandle(r *http.Request) string {
+	if sess, ok := r.Context().Value(session.SessionKey).(*session.Session); ok {
+		return SessionHandle(sess)
+	}
+	return ""
+}
+
+// TrackSessions records the user agent and approximate address of clients
+// using a logged in session and when the session was last seen
+func TrackSessions(store session.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sess, ok := r.Context().Value(session.SessionKey).(*session.Session); ok && sess.Has("username") {
+				if sess.Touch(r.UserAgent(), ApproximateIP(RemoteIP(r)), time.Now()) {
+					if err := store.SyncSession(sess); err != nil {
+						log.WithError(err).Warnf("error syncing session %s", sess.ID)
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetUserSessions returns the user's active web sessions and API tokens most
+// recently seen first, current is the ID of the session (See: SessionHandle)
+// or token making the request
+func GetUserSessions(store session.Store, user *User, current string) ([]types.Session, error) {
+	sessions, err := store.GetUserSessions(user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []types.Session
+	for _, sess := range sessions {
+		if sess.Expired() {
+			continue
+		}
+
+		id := SessionHandle(sess)
+
+		lastSeenAt := sess.LastSeenAt
+		if lastSeenAt.IsZero() {
+			lastSeenAt = sess.CreatedAt
+		}
+
+		res = append(res, types.Session{
+			ID:         id,
+			Type:       types.SessionWeb,
+			Current:    id == current,
+			UserAgent:  sess.UserAgent,
+			RemoteAddr: sess.RemoteAddr,
+			CreatedAt:  sess.CreatedAt,
+			LastSeenAt: lastSeenAt,
+			ExpiresAt:  sess.ExpiresAt,
+		})
+	}
+
+	for _, token := range user.GetTokens() {
+		var scopes []string
+		for _, scope := range token.Scopes {
+			scopes = append(scopes, string(scope))
+		}
+
+		lastSeenAt := token.LastUsedAt
+		if lastSeenAt.IsZero() {
+			lastSeenAt = token.CreatedAt
+		}
+
+		res = append(res, types.Session{
+			ID:         token.ID,
+			Type:       types.SessionToken,
+			Current:    token.ID == current,
+			UserAgent:  token.UserAgent,
+			RemoteAddr: token.RemoteAddr,
+			ClientID:   token.ClientID,
+			Scopes:     scopes,
+			CreatedAt:  token.CreatedAt,
+			LastSeenAt: lastSeenAt,
+			ExpiresAt:  token.ExpiresAt,
+		})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].LastSeenAt.After(res[j].LastSeenAt)
+	})
+
+	return res, nil
+}
+
+// RevokeSession revokes the user's web session or API token with the given
+// ID returning false if the user has no such session, the user must be saved
+// afterwards as revoking a token changes the user
+func RevokeSession(store session.Store, user *User, id string) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+
+	if user.RevokeToken(id) {
+		return true, nil
+	}
+
+	sessions, err := store.GetUserSessions(user.Username)
+	if err != nil {
+		return false, err
+	}
+
+	for _, sess := range sessions {
+		if SessionHandle(sess) == id {
+			return true, store.DelSession(sess.ID)
+		}
+	}
+
+	return false, nil
+}
+
+// RevokeUserSessions logs the user out of all web sessions except the
+// current one (if any)
+func RevokeUserSessions(store session.Store, username, current string) error {
+	sessions, err := store.GetUserSessions(username)
+	if err != nil {
+		return err
+	}
+
+	for _, sess := range sessions {
+		if current != "" && SessionHandle(sess) == current {
+			continue
+		}
+		if err := store.DelSession(sess.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions revokes all of the user's web sessions and API tokens
+// except the current one, e.g: after the user's password changed. The user
+// must be saved afterwards.
+func RevokeOtherSessions(store session.Store, user *User, current string) error {
+	for id := range user.Tokens {
+		if id != current {
+			user.RevokeToken(id)
+		}
+	}
+	user.LegacyTokensRevoked = true
+
+	return RevokeUserSessions(store, user.Username, current)
+}
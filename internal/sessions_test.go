@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/passwords"
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestApproximateIP(t *testing.T) {
+	testCases := []struct {
+		addr     string
+		expected string
+	}{
+		{"203.0.113.42", "203.0.113.0/24"},
+		{"::ffff:203.0.113.42", "203.0.113.0/24"},
+		{"2001:db8:1234:5678::1", "2001:db8:1234::/48"},
+		{"", ""},
+		{"invalid", ""},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, ApproximateIP(testCase.addr), testCase.addr)
+	}
+}
+
+func newTestSession(t *testing.T, store session.Store, username string, persist bool) *session.Session {
+	sess := session.NewSession(store)
+	sess.ID = GenerateRandomToken()
+	sess.Data = session.Map{"username": username}
+	if persist {
+		sess.Data["persist"] = "1"
+	}
+	sess.CreatedAt = time.Now()
+	sess.ExpiresAt = time.Now().Add(time.Hour)
+	require.NoError(t, store.SetSession(sess.ID, sess))
+	return sess
+}
+
+func TestBitcaskStoreUserSessions(t *testing.T) {
+	api := newTestAPI(t)
+
+	alice1 := newTestSession(t, api.db, "alice", true)
+	alice2 := newTestSession(t, api.db, "alice", true)
+	newTestSession(t, api.db, "bob", true)
+
+	sessions, err := api.db.GetUserSessions("alice")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	require.NoError(t, api.db.DelSession(alice1.ID))
+
+	sessions, err = api.db.GetUserSessions("alice")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, alice2.ID, sessions[0].ID)
+
+	sessions, err = api.db.GetUserSessions("ali")
+	require.NoError(t, err)
+	assert.Empty(t, sessions, "usernames are not matched by prefix")
+}
+
+func TestSessionsEndpoint(t *testing.T) {
+	api := newTestAPI(t)
+	sessions := api.sessions
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	r, err := http.NewRequest(http.MethodPost, "/api/v1/auth", nil)
+	require.NoError(t, err)
+	r.Header.Set("User-Agent", "yarnc")
+	r.RemoteAddr = "203.0.113.42:1234"
+
+	token, err := api.CreateToken(user, r, []TokenScope{ScopeRead})
+	require.NoError(t, err)
+
+	cached := newTestSession(t, sessions, "alice", false)
+	persisted := newTestSession(t, sessions, "alice", true)
+	require.True(t, persisted.Touch("Firefox", "198.51.100.0/24", time.Now()))
+	require.NoError(t, sessions.SyncSession(persisted))
+	other := newTestSession(t, sessions, "bob", true)
+
+	var res types.SessionsResponse
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.SessionsEndpoint(), user, http.MethodPost, nil, &res))
+	require.Len(t, res.Sessions, 3)
+
+	byID := make(map[string]types.Session)
+	for _, s := range res.Sessions {
+		byID[s.ID] = s
+	}
+
+	assert.Equal(t, types.SessionToken, byID[token.ID].Type)
+	assert.Equal(t, "yarnc", byID[token.ID].UserAgent)
+	assert.Equal(t, "203.0.113.0/24", byID[token.ID].RemoteAddr)
+	assert.Equal(t, []string{"read"}, byID[token.ID].Scopes)
+
+	assert.Equal(t, types.SessionWeb, byID[SessionHandle(persisted)].Type)
+	assert.Equal(t, "Firefox", byID[SessionHandle(persisted)].UserAgent)
+	assert.Contains(t, byID, SessionHandle(cached))
+	assert.NotContains(t, byID, persisted.ID, "session IDs are never disclosed")
+
+	assert.Equal(t, http.StatusNotFound, callEndpoint(t, api.RevokeSessionEndpoint(), user, http.MethodPost, types.RevokeSessionRequest{ID: SessionHandle(other)}, nil))
+	assert.True(t, sessions.HasSession(other.ID), "other users' sessions cannot be revoked")
+
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.RevokeSessionEndpoint(), user, http.MethodPost, types.RevokeSessionRequest{ID: SessionHandle(cached)}, nil))
+	assert.False(t, sessions.HasSession(cached.ID))
+
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.RevokeSessionEndpoint(), user, http.MethodPost, types.RevokeSessionRequest{Others: true}, nil))
+	assert.False(t, sessions.HasSession(persisted.ID))
+	assert.True(t, sessions.HasSession(other.ID))
+
+	user, err = api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Empty(t, user.Tokens)
+}
+
+func TestPasswordChangeRevokesSessions(t *testing.T) {
+	api := newTestAPI(t)
+	api.pm = passwords.NewScryptPasswords(nil)
+	api.config.MaxUploadSize = DefaultMaxUploadSize
+	sessions := api.sessions
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth", nil)
+	current, err := api.CreateToken(user, r, []TokenScope{ScopePost})
+	require.NoError(t, err)
+	other, err := api.CreateToken(user, r, []TokenScope{ScopeRead})
+	require.NoError(t, err)
+	sess := newTestSession(t, sessions, "alice", true)
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	require.NoError(t, form.WriteField("password", "secret"))
+	require.NoError(t, form.Close())
+	r = httptest.NewRequest(http.MethodPost, "/api/v1/settings", body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	ctx := context.WithValue(r.Context(), UserContextKey, user)
+	ctx = context.WithValue(ctx, TokenContextKey, current)
+
+	w := httptest.NewRecorder()
+	api.SettingsEndpoint()(w, r.WithContext(ctx), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Changing the password revokes all other sessions and tokens
+	assert.False(t, sessions.HasSession(sess.ID))
+
+	user, err = api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Contains(t, user.Tokens, current.ID)
+	assert.NotContains(t, user.Tokens, other.ID)
+}
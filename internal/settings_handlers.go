@@ -28,6 +28,23 @@ func (s *Server) SettingsHandler() httprouter.Handle {
 
 			ctx.Profile = profile
 
+			sessions, err := GetUserSessions(s.sc, ctx.User, currentSessionHandle(r))
+			if err != nil {
+				log.WithError(err).Warnf("error loading sessions for %s", ctx.Username)
+			}
+			ctx.Sessions = sessions
+
+			if invites, err := s.db.GetAllInvites(); err != nil {
+				log.WithError(err).Warnf("error loading invites for %s", ctx.Username)
+			} else {
+				ctx.Invites = invites.CreatedBy(ctx.Username)
+			}
+			if invitesLeft, err := InviteQuota(s.config, s.db, ctx.User); err != nil {
+				log.WithError(err).Warnf("error loading invite quota for %s", ctx.Username)
+			} else {
+				ctx.InvitesLeft = invitesLeft
+			}
+
 			ctx.Title = s.tr(ctx, "PageSettingsTitle")
 			ctx.Bookmarklet = url.QueryEscape(fmt.Sprintf(bookmarkletTemplate, s.config.BaseURL))
 			s.render("settings", w, ctx)
@@ -52,6 +69,7 @@ func (s *Server) SettingsHandler() httprouter.Handle {
 		displayImagesPreference := r.FormValue("displayImagesPreference")
 		displayMedia := r.FormValue("displayMedia") == "on"
 		originalMedia := r.FormValue("originalMedia") == "on"
+		expandContentWarnings := r.FormValue("expandContentWarnings") == "on"
 
 		isFollowersPubliclyVisible := r.FormValue("isFollowersPubliclyVisible") == "on"
 		isFollowingPubliclyVisible := r.FormValue("isFollowingPubliclyVisible") == "on"
@@ -78,6 +96,11 @@ func (s *Server) SettingsHandler() httprouter.Handle {
 			}
 
 			user.Password = hash
+
+			// Log the user out of all other sessions and revoke their tokens
+			if err := RevokeOtherSessions(s.sc, user, currentSessionHandle(r)); err != nil {
+				log.WithError(err).Errorf("error revoking sessions for %s", user.Username)
+			}
 		}
 
 		if avatarFile != nil {
@@ -118,6 +141,7 @@ func (s *Server) SettingsHandler() httprouter.Handle {
 		user.DisplayImagesPreference = displayImagesPreference
 		user.DisplayMedia = displayMedia
 		user.OriginalMedia = originalMedia
+		user.ExpandContentWarnings = expandContentWarnings
 
 		if hideRepliesPreference != user.HideRepliesPreference {
 			// Force User Views to be recalculated
@@ -129,6 +153,20 @@ func (s *Server) SettingsHandler() httprouter.Handle {
 		user.IsFollowingPubliclyVisible = isFollowingPubliclyVisible
 		user.IsBookmarksPubliclyVisible = isBookmarksPubliclyVisible
 
+		if _, ok := r.Form["metadata_key"]; ok {
+			metadata, err := ValidateMetadata(ParseMetadataForm(r))
+			if err != nil {
+				ctx.Error = true
+				trdata := map[string]interface{}{
+					"Error": err.Error(),
+				}
+				ctx.Message = s.tr(ctx, "ErrorInvalidMetadata", trdata)
+				s.render("error", w, ctx)
+				return
+			}
+			user.Metadata = metadata
+		}
+
 		if err := s.db.SetUser(ctx.Username, user); err != nil {
 			ctx.Error = true
 			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
@@ -141,3 +179,59 @@ func (s *Server) SettingsHandler() httprouter.Handle {
 		s.render("error", w, ctx)
 	}
 }
+
+// RevokeSessionHandler revokes one of the user's web sessions or API tokens
+// or all of them except the current session
+func (s *Server) RevokeSessionHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		id := strings.TrimSpace(r.FormValue("id"))
+		others := r.FormValue("others") == "true"
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		if others {
+			if err := RevokeOtherSessions(s.sc, user, currentSessionHandle(r)); err != nil {
+				log.WithError(err).Errorf("error revoking sessions for %s", user.Username)
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+				s.render("error", w, ctx)
+				return
+			}
+		} else {
+			ok, err := RevokeSession(s.sc, user, id)
+			if err != nil {
+				log.WithError(err).Errorf("error revoking session for %s", user.Username)
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+				s.render("error", w, ctx)
+				return
+			}
+			if !ok {
+				ctx.Error = true
+				ctx.Message = s.tr(ctx, "ErrorSessionNotFound")
+				s.render("error", w, ctx)
+				return
+			}
+		}
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.Error = false
+		if others {
+			ctx.Message = s.tr(ctx, "MsgRevokeOtherSessionsSuccess")
+		} else {
+			ctx.Message = s.tr(ctx, "MsgRevokeSessionSuccess")
+		}
+		s.render("error", w, ctx)
+	}
+}
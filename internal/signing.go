@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+const (
+	// TwtSignatureHeader is the HTTP header used by pods to pass along the
+	// detached signature of a twt when gossiping twts between peers.
+	TwtSignatureHeader = "Twt-Signature"
+
+	// publicKeyMetadataKey is the feed metadata field the author's public
+	// signing key is published under.
+	publicKeyMetadataKey = "public_key"
+
+	// signaturesMetadataKey is the feed metadata field pointing to the
+	// feed's detached signatures file.
+	signaturesMetadataKey = "signatures"
+
+	signatureAlgorithm = "ed25519"
+)
+
+var (
+	ErrNoPublicKey      = errors.New("error: no public key for feed")
+	ErrNoSignature      = errors.New("error: no signature for twt")
+	ErrInvalidPublicKey = errors.New("error: invalid public key")
+	ErrInvalidSignature = errors.New("error: invalid signature")
+	ErrNoSigningKey     = errors.New("error: no signing key for feed")
+)
+
+// Signatures maps twt hashes to their detached signatures
+type Signatures map[string]string
+
+// GenerateSigningKey generates a new private key used to sign a feed's twts
+func GenerateSigningKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodePublicKey encodes a public key as published in a feed's metadata
+// as `# public_key = ed25519:<base64>`
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return fmt.Sprintf("%s:%s", signatureAlgorithm, base64.RawStdEncoding.EncodeToString(key))
+}
+
+// DecodePublicKey decodes a public key published in a feed's metadata
+func DecodePublicKey(s string) (ed25519.PublicKey, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 2)
+	if len(parts) != 2 || parts[0] != signatureAlgorithm {
+		return nil, ErrInvalidPublicKey
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil || len(data) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+
+	return ed25519.PublicKey(data), nil
+}
+
+// TwtSigningPayload returns the message that is signed for a twt, this is the
+// same payload the twt's hash is derived from.
+func TwtSigningPayload(twt types.Twt) []byte {
+	twter := twt.Twter()
+	hashingURI := twter.HashingURI
+	if hashingURI == "" {
+		hashingURI = twter.URI
+	}
+
+	var text string
+	if lt, ok := twt.(interface{ LiteralText() string }); ok {
+		text = lt.LiteralText()
+	} else {
+		var obj struct{ Text string }
+		data, _ := json.Marshal(twt)
+		_ = json.Unmarshal(data, &obj)
+		text = obj.Text
+	}
+
+	return []byte(fmt.Sprintf(
+		"%s\n%s\n%s",
+		hashingURI,
+		twt.Created().Format(time.RFC3339),
+		text,
+	))
+}
+
+// SignTwt returns the detached signature of a twt
+func SignTwt(key ed25519.PrivateKey, twt types.Twt) string {
+	sig := ed25519.Sign(key, TwtSigningPayload(twt))
+	return base64.RawStdEncoding.EncodeToString(sig)
+}
+
+// VerifyTwtSignature verifies the detached signature of a twt against the
+// author's public key.
+func VerifyTwtSignature(key ed25519.PublicKey, twt types.Twt, signature string) error {
+	if signature == "" {
+		return ErrNoSignature
+	}
+
+	sig, err := base64.RawStdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrInvalidSignature
+	}
+
+	if !ed25519.Verify(key, TwtSigningPayload(twt), sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// PublicKeyForTwter returns the public key the feed published in its metadata
+func PublicKeyForTwter(twter *types.Twter) (ed25519.PublicKey, error) {
+	if twter == nil || twter.Metadata == nil {
+		return nil, ErrNoPublicKey
+	}
+
+	value := twter.Metadata.Get(publicKeyMetadataKey)
+	if value == "" {
+		return nil, ErrNoPublicKey
+	}
+
+	return DecodePublicKey(value)
+}
+
+// WriteSignatures writes a detached signatures file for a feed, one twt per
+// line as `<hash> <signature>`
+func WriteSignatures(w io.Writer, key ed25519.PrivateKey, twts types.Twts) error {
+	for _, twt := range twts {
+		if _, err := fmt.Fprintf(w, "%s %s\n", twt.Hash(), SignTwt(key, twt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseSignatures parses a detached signatures file as written by
+// WriteSignatures ignoring comments, blank and malformed lines.
+func ParseSignatures(r io.Reader) (Signatures, error) {
+	signatures := make(Signatures)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		signatures[fields[0]] = fields[1]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return signatures, nil
+}
+
+// GetSigningKey returns the signing key of a local user or feed, keys are
+// only created when signed feeds are enabled (See: CreateSigningKey)
+func GetSigningKey(db Store, nick string) (ed25519.PrivateKey, error) {
+	data, err := db.GetSigningKey(nick)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != ed25519.PrivateKeySize {
+		return nil, ErrNoSigningKey
+	}
+	return ed25519.PrivateKey(data), nil
+}
+
+// CreateSigningKey generates and stores a signing key for a local user or
+// feed that doesn't have one yet if signed feeds are enabled
+func CreateSigningKey(conf *Config, db Store, nick string) error {
+	if !conf.Features.IsEnabled(FeatureSignedFeeds) {
+		return nil
+	}
+
+	if _, err := GetSigningKey(db, nick); err == nil {
+		return nil
+	}
+
+	key, err := GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+	return db.SetSigningKey(nick, key)
+}
+
+// MigrateSigningKeys moves the signing keys older versions stored with users
+// and feeds to the store and creates the keys of users and feeds without one
+// if signed feeds are enabled
+func MigrateSigningKeys(conf *Config, db Store) error {
+	migrate := func(nick string, legacy []byte) (bool, error) {
+		if len(legacy) == 0 {
+			return false, CreateSigningKey(conf, db, nick)
+		}
+		if _, err := GetSigningKey(db, nick); err != nil {
+			if err := db.SetSigningKey(nick, legacy); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		migrated, err := migrate(user.Username, user.legacySigningKey)
+		if err != nil {
+			return err
+		}
+		if migrated {
+			if err := db.SetUser(user.Username, user); err != nil {
+				return err
+			}
+		}
+	}
+
+	feeds, err := db.GetAllFeeds()
+	if err != nil {
+		return err
+	}
+	for _, feed := range feeds {
+		migrated, err := migrate(feed.Name, feed.legacySigningKey)
+		if err != nil {
+			return err
+		}
+		if migrated {
+			if err := db.SetFeed(feed.Name, feed); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// FetchSignatures fetches the detached signatures file a feed advertises in
+// its metadata.
+func FetchSignatures(conf *Config, twter *types.Twter) (Signatures, error) {
+	uri := twter.Metadata.Get(signaturesMetadataKey)
+	if uri == "" {
+		return nil, ErrNoSignature
+	}
+
+	res, err := Request(conf, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-success HTTP %s response for %s", res.Status, uri)
+	}
+
+	limitedReader := &io.LimitedReader{R: res.Body, N: conf.MaxFetchLimit}
+	return ParseSignatures(limitedReader)
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"git.mills.io/yarnsocial/yarn/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifyTwt(t *testing.T) {
+	key, err := GenerateSigningKey()
+	require.NoError(t, err)
+
+	pub := key.Public().(ed25519.PublicKey)
+
+	decoded, err := DecodePublicKey(EncodePublicKey(pub))
+	require.NoError(t, err)
+	assert.Equal(t, pub, decoded)
+
+	ts := time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+	twt := types.MakeTwt(testLocalTwter, ts, "Hello World!")
+	sig := SignTwt(key, twt)
+
+	assert.NoError(t, VerifyTwtSignature(pub, twt, sig))
+	assert.Equal(t, ErrNoSignature, VerifyTwtSignature(pub, twt, ""))
+	assert.Equal(t, ErrInvalidSignature, VerifyTwtSignature(pub, twt, "foo"))
+
+	forged := types.MakeTwt(testLocalTwter, ts, "Hello Forged World!")
+	assert.Equal(t, ErrInvalidSignature, VerifyTwtSignature(pub, forged, sig))
+
+	other, err := GenerateSigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, ErrInvalidSignature, VerifyTwtSignature(pub, twt, SignTwt(other, twt)))
+}
+
+func TestWriteAndParseSignatures(t *testing.T) {
+	key, err := GenerateSigningKey()
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteSignatures(buf, key, testLocalTwts))
+
+	signatures, err := ParseSignatures(buf)
+	require.NoError(t, err)
+	assert.Len(t, signatures, len(UniqTwts(testLocalTwts)))
+
+	for _, twt := range testLocalTwts {
+		assert.NoError(t, VerifyTwtSignature(key.Public().(ed25519.PublicKey), twt, signatures[twt.Hash()]))
+	}
+}
+
+func TestCacheVerifyTwt(t *testing.T) {
+	key, err := GenerateSigningKey()
+	require.NoError(t, err)
+
+	cache := NewCache(testConfig)
+	twt := types.MakeTwt(testLocalTwter, time.Now(), "Hello World!")
+
+	cache.SetTwter(testLocalFeed, &types.Twter{Nick: testLocalNick, URI: testLocalFeed})
+	assert.Equal(t, ErrNoPublicKey, cache.VerifyTwt(twt, SignTwt(key, twt)))
+
+	cache.SetTwter(testLocalFeed, &types.Twter{
+		Nick: testLocalNick,
+		URI:  testLocalFeed,
+		Metadata: url.Values{
+			publicKeyMetadataKey: []string{EncodePublicKey(key.Public().(ed25519.PublicKey))},
+		},
+	})
+	assert.False(t, cache.IsVerified(twt))
+	assert.NoError(t, cache.VerifyTwt(twt, SignTwt(key, twt)))
+
+	cache.SetSignature(twt, SignTwt(key, twt))
+	assert.True(t, cache.IsVerified(twt))
+}
+
+func TestMigrateSigningKeys(t *testing.T) {
+	api := newTestAPI(t)
+	db := api.db
+
+	key, err := GenerateSigningKey()
+	require.NoError(t, err)
+
+	// Older versions stored the signing key with the user
+	data := `{"Username":"alice","SigningKey":"` + base64.StdEncoding.EncodeToString(key) + `"}`
+	require.NoError(t, db.(*BitcaskStore).db.Put([]byte(usersKeyPrefix+"/alice"), []byte(data)))
+	require.NoError(t, db.SetUser("bob", &User{Username: "bob"}))
+
+	_, err = GetSigningKey(db, "alice")
+	assert.Equal(t, ErrNoSigningKey, err)
+
+	// Keys are only created when signing is enabled
+	require.NoError(t, MigrateSigningKeys(api.config, db))
+	migrated, err := GetSigningKey(db, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, key, migrated)
+	_, err = GetSigningKey(db, "bob")
+	assert.Equal(t, ErrNoSigningKey, err)
+
+	buf, err := db.(*BitcaskStore).db.Get([]byte(usersKeyPrefix + "/alice"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(buf), "SigningKey")
+
+	api.config.Features.Enable(FeatureSignedFeeds)
+	require.NoError(t, MigrateSigningKeys(api.config, db))
+	_, err = GetSigningKey(db, "bob")
+	assert.NoError(t, err)
+
+	require.NoError(t, db.DelUser("bob"))
+	_, err = GetSigningKey(db, "bob")
+	assert.Equal(t, ErrNoSigningKey, err)
+}
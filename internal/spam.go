@@ -0,0 +1,539 @@
+package internal
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+const (
+	// DefaultSpamHideThreshold is the default spam score at or above which
+	// twts of external feeds are hidden from discover
+	DefaultSpamHideThreshold = 0.5
+
+	// DefaultSpamHoldThreshold is the default spam score at or above which
+	// twts of external feeds are held for review by moderators
+	DefaultSpamHoldThreshold = 1.0
+
+	// minDuplicateTextLength is the length of the shortest twt text the
+	// duplicate text rule considers, shorter twts (greetings, reactions) are
+	// commonly duplicated by people
+	minDuplicateTextLength = 20
+)
+
+var (
+	// ErrSpamVerdictNotFound is returned when reviewing a twt that is not
+	// flagged as spam
+	ErrSpamVerdictNotFound = errors.New("error: spam verdict not found")
+
+	// ErrInvalidSpamAction is returned when reviewing a flagged twt with an
+	// unknown action
+	ErrInvalidSpamAction = errors.New("error: invalid spam review action")
+)
+
+// SpamAction is what is done with twts whose spam score crosses one of the
+// pod's spam thresholds
+type SpamAction string
+
+const (
+	// SpamHide hides the twt from discover
+	SpamHide SpamAction = "hide"
+
+	// SpamHold holds the twt for review hiding it from all views until a
+	// moderator releases it
+	SpamHold SpamAction = "hold"
+)
+
+// SpamRule is a heuristic scoring twts ingested from external feeds, scores
+// range from 0 (not spam) to 1 (spam) and the scores of all rules are summed
+// into the twt's spam score. Rules are called concurrently for many feeds.
+type SpamRule interface {
+	// Name is the name of the rule used in metrics and verdicts
+	Name() string
+
+	// Score scores the twt given all twts fetched from its feed
+	Score(twt types.Twt, feed types.Twts) float64
+}
+
+// SpamRulePruner is implemented by rules that keep state about the twts they
+// scored so it can be pruned along with the twts
+type SpamRulePruner interface {
+	Prune(olderThan time.Time)
+}
+
+// DefaultSpamRules returns the builtin spam rules
+func DefaultSpamRules() []SpamRule {
+	return []SpamRule{
+		&LinkDensityRule{MinLinks: 2},
+		NewDuplicateTextRule(3, 24*time.Hour),
+		&MentionFloodRule{MaxMentions: 3, FloodMentions: 10},
+		&NewFeedBurstRule{MaxAge: 7 * 24 * time.Hour, Window: time.Hour, BurstTwts: 10},
+	}
+}
+
+func clampScore(score float64) float64 {
+	return math.Max(0, math.Min(1, score))
+}
+
+// LinkDensityRule scores twts by the proportion of their words that are
+// links, twts with fewer than MinLinks links are not scored so sharing a link
+// is not penalised
+type LinkDensityRule struct {
+	MinLinks int
+}
+
+// Name ...
+func (r *LinkDensityRule) Name() string { return "link_density" }
+
+// Score ...
+func (r *LinkDensityRule) Score(twt types.Twt, feed types.Twts) float64 {
+	links := len(twt.Links())
+	if links < r.MinLinks {
+		return 0
+	}
+
+	words := len(strings.Fields(twt.FormatText(types.TextFmt, nil)))
+	if words < links {
+		words = links
+	}
+
+	return clampScore(float64(links) / float64(words))
+}
+
+// DuplicateTextRule scores twts by the number of distinct feeds posting the
+// same text within Window of each other, the score reaches 1 when MinFeeds
+// feeds post the same text
+type DuplicateTextRule struct {
+	mu sync.Mutex
+
+	MinFeeds int
+	Window   time.Duration
+
+	// seen is keyed by the fingerprint of the text of twts and holds when
+	// each feed posted the text
+	seen map[string]map[string]time.Time
+}
+
+// NewDuplicateTextRule ...
+func NewDuplicateTextRule(minFeeds int, window time.Duration) *DuplicateTextRule {
+	return &DuplicateTextRule{
+		MinFeeds: minFeeds,
+		Window:   window,
+		seen:     make(map[string]map[string]time.Time),
+	}
+}
+
+// Name ...
+func (r *DuplicateTextRule) Name() string { return "duplicate_text" }
+
+// Score ...
+func (r *DuplicateTextRule) Score(twt types.Twt, feed types.Twts) float64 {
+	text := strings.Join(strings.Fields(strings.ToLower(twt.FormatText(types.TextFmt, nil))), " ")
+	if len(text) < minDuplicateTextLength {
+		return 0
+	}
+	key := FastHashString(text)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	feeds, ok := r.seen[key]
+	if !ok {
+		feeds = make(map[string]time.Time)
+		r.seen[key] = feeds
+	}
+	feeds[twt.Twter().URI] = twt.Created()
+
+	n := 0
+	for _, created := range feeds {
+		if d := created.Sub(twt.Created()); d > -r.Window && d < r.Window {
+			n++
+		}
+	}
+
+	if n < 2 || r.MinFeeds < 2 {
+		return 0
+	}
+	return clampScore(float64(n-1) / float64(r.MinFeeds-1))
+}
+
+// Prune ...
+func (r *DuplicateTextRule) Prune(olderThan time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, feeds := range r.seen {
+		for uri, created := range feeds {
+			if created.Before(olderThan) {
+				delete(feeds, uri)
+			}
+		}
+		if len(feeds) == 0 {
+			delete(r.seen, key)
+		}
+	}
+}
+
+// MentionFloodRule scores twts mentioning more than MaxMentions feeds, the
+// score reaches 1 at FloodMentions mentions
+type MentionFloodRule struct {
+	MaxMentions   int
+	FloodMentions int
+}
+
+// Name ...
+func (r *MentionFloodRule) Name() string { return "mention_flood" }
+
+// Score ...
+func (r *MentionFloodRule) Score(twt types.Twt, feed types.Twts) float64 {
+	mentions := make(map[string]bool)
+	for _, mention := range twt.Mentions() {
+		mentions[mention.Twter().URI] = true
+	}
+
+	n := len(mentions)
+	if n <= r.MaxMentions || r.FloodMentions <= r.MaxMentions {
+		return 0
+	}
+	return clampScore(float64(n-r.MaxMentions) / float64(r.FloodMentions-r.MaxMentions))
+}
+
+// NewFeedBurstRule scores twts of feeds whose first twt is younger than
+// MaxAge by the number of twts posted within Window of the twt, the score
+// reaches 1 at BurstTwts twts and is 0 up to half as many
+type NewFeedBurstRule struct {
+	MaxAge    time.Duration
+	Window    time.Duration
+	BurstTwts int
+}
+
+// Name ...
+func (r *NewFeedBurstRule) Name() string { return "new_feed_burst" }
+
+// Score ...
+func (r *NewFeedBurstRule) Score(twt types.Twt, feed types.Twts) float64 {
+	if len(feed) == 0 || r.BurstTwts < 2 {
+		return 0
+	}
+
+	first := feed[0].Created()
+	for _, t := range feed[1:] {
+		if t.Created().Before(first) {
+			first = t.Created()
+		}
+	}
+	if time.Since(first) > r.MaxAge {
+		return 0
+	}
+
+	n := 0
+	for _, t := range feed {
+		if d := t.Created().Sub(twt.Created()); d > -r.Window && d < r.Window {
+			n++
+		}
+	}
+
+	half := r.BurstTwts / 2
+	return clampScore(float64(n-half) / float64(r.BurstTwts-half))
+}
+
+// SpamVerdict records the spam score of a twt that crossed one of the pod's
+// spam thresholds and what was done with it
+type SpamVerdict struct {
+	Hash string
+	Nick string
+	URI  string
+	Text string
+
+	Score     float64
+	Scores    map[string]float64
+	Action    SpamAction
+	CreatedAt time.Time
+
+	// Released is set when a moderator reviewed the twt and found it is not
+	// spam, released twts are shown in all views
+	Released bool
+}
+
+// Rules returns the names of the rules that scored the twt, highest first
+func (v *SpamVerdict) Rules() []string {
+	var rules []string
+	for name, score := range v.Scores {
+		if score > 0 {
+			rules = append(rules, name)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if v.Scores[rules[i]] != v.Scores[rules[j]] {
+			return v.Scores[rules[i]] > v.Scores[rules[j]]
+		}
+		return rules[i] < rules[j]
+	})
+	return rules
+}
+
+// SpamVerdicts ...
+type SpamVerdicts []*SpamVerdict
+
+func (vs SpamVerdicts) Len() int           { return len(vs) }
+func (vs SpamVerdicts) Less(i, j int) bool { return vs[i].CreatedAt.After(vs[j].CreatedAt) }
+func (vs SpamVerdicts) Swap(i, j int)      { vs[i], vs[j] = vs[j], vs[i] }
+
+// SpamFilter is the pipeline of spam rules scoring twts ingested from
+// external feeds against the pod's spam thresholds (See: Cache.FetchFeeds)
+type SpamFilter struct {
+	mu sync.RWMutex
+
+	conf  *Config
+	rules []SpamRule
+
+	// scored holds when the twts scored so far were created so each twt is
+	// scored once and can be pruned once it expires from the cache
+	scored   map[string]time.Time
+	verdicts map[string]*SpamVerdict
+}
+
+// NewSpamFilter returns a spam filter scoring twts with the given rules
+func NewSpamFilter(conf *Config, rules ...SpamRule) *SpamFilter {
+	return &SpamFilter{
+		conf:     conf,
+		rules:    rules,
+		scored:   make(map[string]time.Time),
+		verdicts: make(map[string]*SpamVerdict),
+	}
+}
+
+// AddRule adds a rule to the pipeline, twts already scored are not rescored
+func (f *SpamFilter) AddRule(rule SpamRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = append(f.rules, rule)
+}
+
+// Rules returns the names of the rules in the pipeline
+func (f *SpamFilter) Rules() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	names := make([]string, len(f.rules))
+	for i, rule := range f.rules {
+		names[i] = rule.Name()
+	}
+	return names
+}
+
+// Score scores the twt with every rule given all twts fetched from its feed
+// and returns the verdict the pod's thresholds decide, the verdict's action
+// is empty if the twt is not spam
+func (f *SpamFilter) Score(twt types.Twt, feed types.Twts) *SpamVerdict {
+	f.mu.RLock()
+	rules := f.rules
+	f.mu.RUnlock()
+
+	verdict := &SpamVerdict{
+		Hash:      twt.Hash(),
+		Nick:      twt.Twter().Nick,
+		URI:       twt.Twter().URI,
+		Text:      twt.FormatText(types.TextFmt, nil),
+		Scores:    make(map[string]float64),
+		CreatedAt: time.Now(),
+	}
+
+	for _, rule := range rules {
+		score := clampScore(rule.Score(twt, feed))
+		if score <= 0 {
+			continue
+		}
+		verdict.Scores[rule.Name()] = score
+		verdict.Score += score
+
+		if cv := metrics.CounterVec("spam", "matched"); cv != nil {
+			cv.WithLabelValues(rule.Name()).Inc()
+		}
+	}
+	verdict.Score = clampScore(verdict.Score)
+
+	hide, hold := f.conf.SpamHideThreshold, f.conf.SpamHoldThreshold
+	switch {
+	case hold > 0 && verdict.Score >= hold:
+		verdict.Action = SpamHold
+	case hide > 0 && verdict.Score >= hide:
+		verdict.Action = SpamHide
+	}
+
+	return verdict
+}
+
+// Check scores the twts of the feed not scored before, feed is all twts
+// fetched from the feed so twts older than the cache's ttl are not scored
+func (f *SpamFilter) Check(feed types.Twts) {
+	if !f.Enabled() {
+		return
+	}
+
+	since := time.Now().Add(-f.conf.MaxCacheTTL)
+
+	for _, twt := range feed {
+		if twt.Created().Before(since) {
+			continue
+		}
+
+		hash := twt.Hash()
+
+		f.mu.Lock()
+		_, scored := f.scored[hash]
+		_, flagged := f.verdicts[hash]
+		f.scored[hash] = twt.Created()
+		f.mu.Unlock()
+
+		if scored || flagged {
+			continue
+		}
+
+		verdict := f.Score(twt, feed)
+		if verdict.Action == "" {
+			continue
+		}
+
+		log.Infof("spam filter: %s twt %s from %s (score %.2f)", verdict.Action, hash, verdict.URI, verdict.Score)
+
+		if cv := metrics.CounterVec("spam", "flagged"); cv != nil {
+			cv.WithLabelValues(string(verdict.Action)).Inc()
+		}
+
+		f.mu.Lock()
+		f.verdicts[hash] = verdict
+		f.mu.Unlock()
+	}
+}
+
+// Enabled returns true if any of the pod's spam thresholds is set
+func (f *SpamFilter) Enabled() bool {
+	return f.conf.SpamHideThreshold > 0 || f.conf.SpamHoldThreshold > 0
+}
+
+// Prune forgets twts created before olderThan (and their verdicts)
+func (f *SpamFilter) Prune(olderThan time.Time) {
+	f.mu.Lock()
+	for hash, created := range f.scored {
+		if created.Before(olderThan) {
+			delete(f.scored, hash)
+			delete(f.verdicts, hash)
+		}
+	}
+	for hash, verdict := range f.verdicts {
+		if verdict.CreatedAt.Before(olderThan) {
+			delete(f.verdicts, hash)
+		}
+	}
+	rules := f.rules
+	f.mu.Unlock()
+
+	for _, rule := range rules {
+		if pruner, ok := rule.(SpamRulePruner); ok {
+			pruner.Prune(olderThan)
+		}
+	}
+}
+
+// Verdict returns the unreleased verdict of the twt (if flagged)
+func (f *SpamFilter) Verdict(hash string) (*SpamVerdict, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	verdict, ok := f.verdicts[hash]
+	if !ok || verdict.Released {
+		return nil, false
+	}
+	return verdict, true
+}
+
+// Verdicts returns the unreleased verdicts of flagged twts with the given
+// action (or all if empty), newest first
+func (f *SpamFilter) Verdicts(action SpamAction) (verdicts SpamVerdicts) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, verdict := range f.verdicts {
+		if verdict.Released || (action != "" && verdict.Action != action) {
+			continue
+		}
+		verdicts = append(verdicts, verdict)
+	}
+	sort.Sort(verdicts)
+	return
+}
+
+// Release releases the flagged twt so it is shown in all views
+func (f *SpamFilter) Release(hash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	verdict, ok := f.verdicts[hash]
+	if !ok || verdict.Released {
+		return ErrSpamVerdictNotFound
+	}
+	verdict.Released = true
+	return nil
+}
+
+// Forget removes the verdict of the twt, used once a moderator hid the twt
+// pod-wide
+func (f *SpamFilter) Forget(hash string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.verdicts, hash)
+}
+
+// FilterOutSpamFactory filters out twts flagged as spam, twts held for
+// review are filtered out by either action and hidden twts only by SpamHide
+func FilterOutSpamFactory(spam *SpamFilter, action SpamAction) FilterFunc {
+	return func(twt types.Twt) bool {
+		verdict, ok := spam.Verdict(twt.Hash())
+		if !ok {
+			return true
+		}
+		return action == SpamHold && verdict.Action != SpamHold
+	}
+}
+
+// ReviewSpam takes the actor's action on a twt flagged as spam, releasing it
+// or hiding it pod-wide, and records it in the audit log
+func ReviewSpam(conf *Config, cache *Cache, db Store, actor *User, hash, action string) error {
+	verdict, ok := cache.Spam.Verdict(hash)
+	if !ok {
+		return ErrSpamVerdictNotFound
+	}
+
+	switch action {
+	case "release":
+		if err := cache.Spam.Release(hash); err != nil {
+			return err
+		}
+		Audit(db, actor.Username, AuditReleaseSpam, hash, string(verdict.Action), "", verdict.URI)
+	case "hide":
+		if err := conf.HideTwt(hash); err != nil {
+			return err
+		}
+		if err := conf.Settings().Save(filepath.Join(conf.Data, "settings.yaml")); err != nil {
+			return err
+		}
+		cache.Spam.Forget(hash)
+		Audit(db, actor.Username, AuditHideSpam, hash, string(verdict.Action), "", verdict.URI)
+	default:
+		return ErrInvalidSpamAction
+	}
+
+	cache.Refresh()
+	return nil
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestSpamRules(t *testing.T) {
+	now := time.Now()
+	spammer := types.Twter{Nick: "spammer", URI: "https://spam.example/twtxt.txt"}
+
+	links := &LinkDensityRule{MinLinks: 2}
+	assert.Zero(t, links.Score(types.MakeTwt(spammer, now, "Have a look at https://example.com/"), nil))
+	assert.Equal(t, 1.0, links.Score(types.MakeTwt(spammer, now, "https://a.example/ https://b.example/"), nil))
+	assert.InDelta(t, 0.2, links.Score(types.MakeTwt(spammer, now, "two links https://a.example/ and https://b.example/ in ten words of text"), nil), 0.01)
+
+	var mentions []string
+	for i := 0; i < 10; i++ {
+		mentions = append(mentions, fmt.Sprintf("@<user%d https://pod%d.example/twtxt.txt>", i, i))
+	}
+	flood := &MentionFloodRule{MaxMentions: 3, FloodMentions: 10}
+	assert.Zero(t, flood.Score(types.MakeTwt(spammer, now, strings.Join(mentions[:3], " ")+" hi"), nil))
+	assert.InDelta(t, 3.0/7, flood.Score(types.MakeTwt(spammer, now, strings.Join(mentions[:6], " ")+" hi"), nil), 0.01)
+	assert.Equal(t, 1.0, flood.Score(types.MakeTwt(spammer, now, strings.Join(mentions, " ")+" hi"), nil))
+
+	dupes := NewDuplicateTextRule(3, 24*time.Hour)
+	text := "Buy cheap watches now at our store!"
+	for i, expected := range []float64{0, 0.5, 1} {
+		twter := types.Twter{Nick: "spammer", URI: fmt.Sprintf("https://spam%d.example/twtxt.txt", i)}
+		assert.Equal(t, expected, dupes.Score(types.MakeTwt(twter, now, text), nil))
+	}
+	assert.Zero(t, dupes.Score(types.MakeTwt(spammer, now.Add(-48*time.Hour), text), nil))
+	assert.Zero(t, NewDuplicateTextRule(3, time.Hour).Score(types.MakeTwt(spammer, now, "gm"), nil))
+
+	dupes.Prune(now.Add(time.Minute))
+	assert.Empty(t, dupes.seen)
+
+	burst := &NewFeedBurstRule{MaxAge: 7 * 24 * time.Hour, Window: time.Hour, BurstTwts: 10}
+	var feed types.Twts
+	for i := 0; i < 10; i++ {
+		feed = append(feed, types.MakeTwt(spammer, now.Add(-time.Duration(i)*time.Minute), fmt.Sprintf("Spam #%d", i)))
+	}
+	assert.Equal(t, 1.0, burst.Score(feed[0], feed))
+	assert.Zero(t, burst.Score(feed[0], feed[:5]))
+
+	// Established feeds are not scored
+	old := append(feed, types.MakeTwt(spammer, now.Add(-30*24*time.Hour), "Hello World!"))
+	assert.Zero(t, burst.Score(feed[0], old))
+}
+
+func TestSpamFilter(t *testing.T) {
+	conf := NewConfig()
+	conf.MaxCacheTTL = 24 * time.Hour
+	conf.SpamHideThreshold = 0.5
+	conf.SpamHoldThreshold = 1
+
+	spam := NewSpamFilter(conf, &LinkDensityRule{MinLinks: 2}, &MentionFloodRule{MaxMentions: 1, FloodMentions: 3})
+	assert.Equal(t, []string{"link_density", "mention_flood"}, spam.Rules())
+
+	spammer := types.Twter{Nick: "spammer", URI: "https://spam.example/twtxt.txt"}
+	now := time.Now()
+
+	ham := types.MakeTwt(spammer, now, "Hello World!")
+	hidden := types.MakeTwt(spammer, now, "@<a https://a.example/twtxt.txt> @<b https://b.example/twtxt.txt> hi")
+	held := types.MakeTwt(spammer, now, "https://a.example/ https://b.example/")
+	expired := types.MakeTwt(spammer, now.Add(-48*time.Hour), "https://a.example/ https://b.example/")
+
+	verdict := spam.Score(held, nil)
+	assert.Equal(t, SpamHold, verdict.Action)
+	assert.Equal(t, []string{"link_density"}, verdict.Rules())
+
+	spam.Check(types.Twts{ham, hidden, held, expired})
+
+	verdicts := spam.Verdicts("")
+	require.Len(t, verdicts, 2)
+	assert.Len(t, spam.Verdicts(SpamHold), 1)
+	assert.Equal(t, held.Hash(), spam.Verdicts(SpamHold)[0].Hash)
+	assert.Equal(t, hidden.Hash(), spam.Verdicts(SpamHide)[0].Hash)
+	_, ok := spam.Verdict(expired.Hash())
+	assert.False(t, ok)
+
+	filterOutHidden := FilterOutSpamFactory(spam, SpamHide)
+	filterOutHeld := FilterOutSpamFactory(spam, SpamHold)
+	assert.True(t, filterOutHidden(ham))
+	assert.False(t, filterOutHidden(hidden))
+	assert.False(t, filterOutHidden(held))
+	assert.True(t, filterOutHeld(hidden))
+	assert.False(t, filterOutHeld(held))
+
+	// Released twts are not flagged again
+	require.NoError(t, spam.Release(held.Hash()))
+	assert.Equal(t, ErrSpamVerdictNotFound, spam.Release(held.Hash()))
+	spam.Check(types.Twts{held})
+	assert.True(t, filterOutHeld(held))
+	assert.Len(t, spam.Verdicts(""), 1)
+
+	// Rules can be added to the pipeline
+	spam.AddRule(NewDuplicateTextRule(2, time.Hour))
+	assert.Len(t, spam.Rules(), 3)
+
+	spam.Prune(now.Add(time.Minute))
+	assert.Empty(t, spam.Verdicts(""))
+
+	// The spam filter is disabled without thresholds
+	conf.SpamHideThreshold = 0
+	conf.SpamHoldThreshold = 0
+	spam.Check(types.Twts{hidden})
+	assert.Empty(t, spam.Verdicts(""))
+}
+
+func TestCacheSpam(t *testing.T) {
+	api := newTestAPI(t)
+	conf := api.config
+	conf.MaxCacheTTL = DefaultMaxCacheTTL
+	cache := api.cache
+
+	spammer := types.Twter{Nick: "spammer", URI: "https://spam.example/twtxt.txt"}
+	now := time.Now()
+
+	ham := types.MakeTwt(spammer, now.Add(-time.Hour), "Hello World!")
+	hidden := types.MakeTwt(spammer, now.Add(-time.Minute), "Look https://a.example/ https://b.example/ https://c.example/ now")
+	held := types.MakeTwt(spammer, now, "https://a.example/ https://b.example/")
+	twts := types.Twts{held, hidden, ham}
+
+	cache.Spam.Check(twts)
+	cache.UpdateFeed(spammer.URI, "", twts)
+	cache.Refresh()
+
+	assert.Equal(t, []string{ham.Hash()}, hashesOf(cache.GetByView(discoverViewKey)))
+	assert.Equal(t, []string{hidden.Hash(), ham.Hash()}, hashesOf(cache.GetByURL(spammer.URI)))
+	_, ok := cache.Lookup(held.Hash())
+	assert.False(t, ok)
+
+	// Verdicts are persisted with the cache
+	require.NoError(t, cache.Store(conf))
+	loaded, err := LoadCache(conf)
+	require.NoError(t, err)
+	assert.Len(t, loaded.Spam.Verdicts(""), 2)
+
+	// Moderators release held twts or hide them pod-wide
+	moderator := &User{Username: "mod", Role: RoleModerator}
+	assert.Equal(t, ErrInvalidSpamAction, ReviewSpam(conf, cache, api.db, moderator, held.Hash(), "ignore"))
+	require.NoError(t, ReviewSpam(conf, cache, api.db, moderator, held.Hash(), "release"))
+	require.NoError(t, ReviewSpam(conf, cache, api.db, moderator, hidden.Hash(), "hide"))
+	assert.Equal(t, ErrSpamVerdictNotFound, ReviewSpam(conf, cache, api.db, moderator, hidden.Hash(), "release"))
+
+	assert.Equal(t, []string{held.Hash(), ham.Hash()}, hashesOf(cache.GetByView(discoverViewKey)))
+	assert.True(t, conf.HiddenTwt(hidden))
+
+	settings, err := LoadSettings(filepath.Join(conf.Data, "settings.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{hidden.Hash()}, settings.HiddenTwts)
+
+	entries, err := api.db.GetAllAuditEntries()
+	require.NoError(t, err)
+	assert.Len(t, entries.Filter(AuditFilter{Action: AuditReleaseSpam, Target: held.Hash()}), 1)
+	assert.Len(t, entries.Filter(AuditFilter{Action: AuditHideSpam, Target: hidden.Hash()}), 1)
+}
+
+func TestManageSpamHandler(t *testing.T) {
+	server := newTestServer(t)
+	server.config.AdminUser = "admin"
+	server.config.MaxCacheTTL = DefaultMaxCacheTTL
+	require.NoError(t, server.db.SetUser("admin", &User{Username: "admin"}))
+	require.NoError(t, server.db.SetUser("alice", &User{Username: "alice"}))
+
+	spammer := types.Twter{Nick: "spammer", URI: "https://spam.example/twtxt.txt"}
+	held := types.MakeTwt(spammer, time.Now(), "https://a.example/ https://b.example/")
+	server.cache.Spam.Check(types.Twts{held})
+
+	request := func(method, username string, form url.Values) *httptest.ResponseRecorder {
+		sess := session.NewSession(server.sc)
+		sess.ID = GenerateRandomToken()
+		sess.Data = session.Map{"username": username}
+
+		r := httptest.NewRequest(method, "/manage/spam", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = r.WithContext(context.WithValue(r.Context(), session.SessionKey, sess))
+
+		w := httptest.NewRecorder()
+		server.ManageSpamHandler()(w, r, nil)
+		return w
+	}
+
+	assert.Contains(t, request(http.MethodGet, "alice", nil).Body.String(), "You do not have permission to do this!")
+	page := request(http.MethodGet, "admin", nil).Body.String()
+	assert.Contains(t, page, held.Hash())
+	assert.Contains(t, page, "Held for review")
+
+	release := url.Values{"hash": {held.Hash()}, "action": {"release"}}
+	assert.Equal(t, http.StatusFound, request(http.MethodPost, "admin", release).Code)
+	assert.Contains(t, request(http.MethodPost, "admin", release).Body.String(), "Twt not flagged as spam!")
+}
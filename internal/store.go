@@ -45,6 +45,28 @@ type Store interface {
 	SyncSession(sess *session.Session) error
 	LenSessions() int64
 	GetAllSessions() ([]*session.Session, error)
+	GetUserSessions(username string) ([]*session.Session, error)
+
+	GetNotifications(username string) (*Notifications, error)
+	SetNotifications(username string, notifications *Notifications) error
+	DelNotifications(username string) error
+	GetUnreadNotifications(username string) (int, error)
+
+	GetReport(id string) (*Report, error)
+	SetReport(id string, report *Report) error
+	GetAllReports() (Reports, error)
+
+	AddAuditEntry(entry *AuditEntry) error
+	GetAllAuditEntries() (AuditEntries, error)
+
+	GetInvite(code string) (*Invite, error)
+	SetInvite(code string, invite *Invite) error
+	DelInvite(code string) error
+	GetAllInvites() (Invites, error)
+
+	GetSigningKey(nick string) ([]byte, error)
+	SetSigningKey(nick string, key []byte) error
+	DelSigningKey(nick string) error
 }
 
 type StoreFactory func() (Store, error)
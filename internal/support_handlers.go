@@ -38,6 +38,24 @@ func (s *Server) CaptchaHandler() httprouter.Handle {
 	}
 }
 
+// verifyCaptcha returns true if the captcha input of the form matches the text
+// of the captcha last shown to the session (See: CaptchaHandler), the captcha
+// can only be used once
+func verifyCaptcha(r *http.Request) bool {
+	sess, ok := r.Context().Value(session.SessionKey).(*session.Session)
+	if !ok || sess == nil {
+		return false
+	}
+
+	captchaText, ok := sess.Get("captchaText")
+	if !ok || captchaText == "" {
+		return false
+	}
+	_ = sess.Del("captchaText")
+
+	return strings.TrimSpace(r.FormValue("captchaInput")) == captchaText
+}
+
 // SupportHandler ...
 func (s *Server) SupportHandler() httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
@@ -115,10 +133,13 @@ func (s *Server) ReportHandler() httprouter.Handle {
 			return
 		}
 
+		hash := strings.TrimSpace(r.FormValue("hash"))
+
 		if r.Method == "GET" {
 			ctx.Title = "Report abuse"
 			ctx.ReportNick = nick
 			ctx.ReportURL = url
+			ctx.ReportHash = hash
 			s.render("report", w, ctx)
 			return
 		}
@@ -155,8 +176,14 @@ func (s *Server) ReportHandler() httprouter.Handle {
 			return
 		}
 
-		if err := SendReportAbuseEmail(s.config, nick, url, name, email, category, message); err != nil {
-			log.WithError(err).Errorf("unable to send report email for %s", email)
+		report := NewReport(nick, url, hash, category, message)
+		report.ReporterName = name
+		if ctx.Authenticated {
+			report.Reporter = ctx.User.Username
+		}
+
+		if err := FileReport(s.config, s.db, report, email); err != nil {
+			log.WithError(err).Errorf("unable to file report for %s", email)
 			ctx.Error = true
 			ctx.Message = "Error sending report! Please try again."
 			s.render("error", w, ctx)
@@ -92,7 +92,11 @@ func NewTemplateManager(conf *Config, translator *Translator, cache *Cache, arch
 	funcMap["getConvLength"] = GetConvLength(conf, cache, archive)
 	funcMap["getForkLength"] = GetForkLength(conf, cache, archive)
 	funcMap["isAdminUser"] = IsAdminUserFactory(conf)
+	funcMap["hasPermission"] = HasPermissionFactory(conf)
 	funcMap["isSpecialFeed"] = IsSpecialFeed
+	funcMap["isReservedMetadataKey"] = IsReservedMetadataKey
+	funcMap["isVerifiedTwt"] = cache.IsVerified
+	funcMap["getPoll"] = cache.GetPoll
 	funcMap["isFeatureEnabled"] = func(name string) bool {
 		return IsFeatureEnabled(conf.Features, name)
 	}
@@ -551,6 +551,22 @@ u("#imgBtn").on("click", function(e) {
   insertText(u("textarea#text"), "![](https://)");
 });
 
+u("#pollBtn").on("click", function(e) {
+  e.preventDefault();
+  insertText(u("textarea#text"), "\n[ ] \n[ ] ");
+});
+
+u("#threadBtn").on("click", function(e) {
+  e.preventDefault();
+  var textarea = document.createElement("textarea");
+  textarea.name = "text";
+  textarea.rows = 3;
+  textarea.maxLength = u("textarea#text").attr("maxlength");
+  textarea.placeholder = u("#thread").data("placeholder");
+  u("#thread").append(textarea);
+  textarea.focus();
+});
+
 u("#usrBtn").on("click", function(e) {
   e.preventDefault();
   if (!$mentionedList.classList.contains("show")) {
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+)
+
+// TokenScope limits what an API token is allowed to do
+type TokenScope string
+
+const (
+	// ScopeRead allows reading timelines, mentions and the user's settings
+	ScopeRead TokenScope = "read"
+
+	// ScopePost allows posting twts, uploading media, marking notifications
+	// read and updating the user's settings and profile
+	ScopePost TokenScope = "post"
+
+	// ScopeFollow allows following, unfollowing, muting and unmuting feeds
+	ScopeFollow TokenScope = "follow"
+
+	// ScopeAdmin allows managing the pod and implies all other scopes, it
+	// can only be granted to the pod's staff whose role further limits what
+	// they can manage (See: Role)
+	ScopeAdmin TokenScope = "admin"
+)
+
+var (
+	// DefaultTokenScopes are the scopes granted to tokens when a client does
+	// not request any specific scopes
+	DefaultTokenScopes = []TokenScope{ScopeRead, ScopePost, ScopeFollow}
+
+	// ErrInvalidTokenScope is returned when requesting an unknown scope
+	ErrInvalidTokenScope = errors.New("error: invalid token scope")
+
+	// ErrTokenRevoked is returned for tokens that were revoked or never issued
+	ErrTokenRevoked = errors.New("error: token revoked")
+)
+
+// Token is an API token issued to a user, the token's value is never stored
+// only its ID which is carried as the `jti` claim of the JWT.
+type Token struct {
+	ID         string
+	Signature  string `json:"-"`
+	Value      string `json:"-"`
+	UserAgent  string
+	RemoteAddr string
+	ClientID   string
+	Scopes     []TokenScope
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+}
+
+// IsExpired returns true if the token has expired
+func (t *Token) IsExpired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+// HasScope returns true if the token was granted the scope
+func (t *Token) HasScope(scope TokenScope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTokenScopes parses the scopes requested for a new token returning
+// DefaultTokenScopes if none are requested
+func ParseTokenScopes(scopes []string) ([]TokenScope, error) {
+	if len(scopes) == 0 {
+		return DefaultTokenScopes, nil
+	}
+
+	seen := make(map[TokenScope]bool)
+
+	var parsed []TokenScope
+	for _, s := range scopes {
+		scope := TokenScope(strings.ToLower(strings.TrimSpace(s)))
+		switch scope {
+		case ScopeRead, ScopePost, ScopeFollow, ScopeAdmin:
+		default:
+			return nil, ErrInvalidTokenScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			parsed = append(parsed, scope)
+		}
+	}
+
+	return parsed, nil
+}
+
+// Tokens is a list of tokens sorted by most recently created
+type Tokens []*Token
+
+func (tokens Tokens) Len() int           { return len(tokens) }
+func (tokens Tokens) Less(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) }
+func (tokens Tokens) Swap(i, j int)      { tokens[i], tokens[j] = tokens[j], tokens[i] }
+
+// AddToken stores a newly issued token for the user pruning expired tokens
+func (u *User) AddToken(token *Token) {
+	if u.Tokens == nil {
+		u.Tokens = make(map[string]*Token)
+	}
+
+	for id, t := range u.Tokens {
+		if t.IsExpired() {
+			delete(u.Tokens, id)
+		}
+	}
+
+	u.Tokens[token.ID] = token
+}
+
+// GetToken returns the user's unexpired token with the given ID
+func (u *User) GetToken(id string) (*Token, error) {
+	token, ok := u.Tokens[id]
+	if !ok || id == "" {
+		return nil, ErrTokenRevoked
+	}
+	if token.IsExpired() {
+		return nil, ErrInvalidToken
+	}
+	return token, nil
+}
+
+// RevokeToken revokes the user's token with the given ID
+func (u *User) RevokeToken(id string) bool {
+	if _, ok := u.Tokens[id]; !ok {
+		return false
+	}
+	delete(u.Tokens, id)
+	return true
+}
+
+// GetTokens returns the user's unexpired tokens most recent first
+func (u *User) GetTokens() Tokens {
+	var tokens Tokens
+	for _, token := range u.Tokens {
+		if !token.IsExpired() {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Sort(tokens)
+	return tokens
+}
+
+// userLocks serializes recording when users and their tokens were last seen
+var userLocks sync.Map
+
+func lockUser(username string) func() {
+	v, _ := userLocks.LoadOrStore(username, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// TouchUser records that the user was seen, and the user's token (if any)
+// used, from remoteAddr. Like web sessions the user is only written when the
+// day the user was last seen changes or the token was last used from another
+// address or more than session.LastSeenInterval ago. The user is reloaded
+// before it is updated so that changes made whilst the request was handled,
+// e.g: revoked tokens, are never undone by a stale copy of the user.
+func TouchUser(db Store, user *User, token *Token, remoteAddr string, now time.Time) error {
+	lastSeenAt := now.Round(24 * time.Hour)
+
+	touched := !user.LastSeenAt.Equal(lastSeenAt)
+	if token != nil && token.ID != "" {
+		touched = touched || token.RemoteAddr != remoteAddr || now.Sub(token.LastUsedAt) >= session.LastSeenInterval
+	}
+	if !touched {
+		return nil
+	}
+
+	unlock := lockUser(user.Username)
+	defer unlock()
+
+	user, err := db.GetUser(user.Username)
+	if err != nil {
+		return err
+	}
+
+	user.LastSeenAt = lastSeenAt
+	if token != nil {
+		if t, ok := user.Tokens[token.ID]; ok {
+			t.LastUsedAt = now
+			t.RemoteAddr = remoteAddr
+		}
+	}
+
+	return db.SetUser(user.Username, user)
+}
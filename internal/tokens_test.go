@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTokenScopes(t *testing.T) {
+	scopes, err := ParseTokenScopes(nil)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTokenScopes, scopes)
+
+	scopes, err = ParseTokenScopes([]string{"Read", " post ", "read"})
+	require.NoError(t, err)
+	assert.Equal(t, []TokenScope{ScopeRead, ScopePost}, scopes)
+
+	_, err = ParseTokenScopes([]string{"root"})
+	assert.Equal(t, ErrInvalidTokenScope, err)
+}
+
+func TestTokenScopesAndExpiry(t *testing.T) {
+	token := &Token{ID: "foo", Scopes: []TokenScope{ScopeRead}, ExpiresAt: time.Now().Add(time.Hour)}
+	assert.True(t, token.HasScope(ScopeRead))
+	assert.False(t, token.HasScope(ScopePost))
+	assert.False(t, token.IsExpired())
+
+	admin := &Token{ID: "bar", Scopes: []TokenScope{ScopeAdmin}}
+	assert.True(t, admin.HasScope(ScopeFollow))
+
+	expired := &Token{ID: "baz", ExpiresAt: time.Now().Add(-time.Hour)}
+	assert.True(t, expired.IsExpired())
+
+	user := NewUser()
+	user.AddToken(expired)
+	user.AddToken(token)
+
+	_, err := user.GetToken("baz")
+	assert.Error(t, err)
+	assert.Len(t, user.GetTokens(), 1)
+
+	got, err := user.GetToken("foo")
+	require.NoError(t, err)
+	assert.Equal(t, token, got)
+
+	assert.True(t, user.RevokeToken("foo"))
+	assert.False(t, user.RevokeToken("foo"))
+	_, err = user.GetToken("foo")
+	assert.Equal(t, ErrTokenRevoked, err)
+}
+
+func TestTouchUser(t *testing.T) {
+	api := newTestAPI(t)
+	db := api.db
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, db.SetUser(user.Username, user))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth", nil)
+	foo, err := api.CreateToken(user, r, nil)
+	require.NoError(t, err)
+	bar, err := api.CreateToken(user, r, nil)
+	require.NoError(t, err)
+
+	// A copy of the user loaded before a token was revoked doesn't undo the
+	// revocation
+	stale, err := db.GetUser("alice")
+	require.NoError(t, err)
+
+	user, err = db.GetUser("alice")
+	require.NoError(t, err)
+	user.RevokeToken(bar.ID)
+	require.NoError(t, db.SetUser(user.Username, user))
+
+	now := time.Now()
+	require.NoError(t, TouchUser(db, stale, stale.Tokens[foo.ID], "192.0.2.0/24", now))
+
+	user, err = db.GetUser("alice")
+	require.NoError(t, err)
+	_, err = user.GetToken(bar.ID)
+	assert.Equal(t, ErrTokenRevoked, err)
+
+	token, err := user.GetToken(foo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.0/24", token.RemoteAddr)
+	assert.True(t, token.LastUsedAt.Equal(now))
+	assert.True(t, user.LastSeenAt.Equal(now.Round(24*time.Hour)))
+}
+
+func TestAPIIsAuthorized(t *testing.T) {
+	api := newTestAPI(t)
+	db := api.db
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, db.SetUser(user.Username, user))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth", nil)
+	token, err := api.CreateToken(user, r, []TokenScope{ScopeRead})
+	require.NoError(t, err)
+
+	ok := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	do := func(scope TokenScope) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/timeline", nil)
+		r.Header.Set("Token", token.Value)
+		api.isAuthorized(scope, ok)(w, r, nil)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(ScopeRead))
+	assert.Equal(t, http.StatusForbidden, do(ScopePost))
+
+	user, err = db.GetUser("alice")
+	require.NoError(t, err)
+	stored, err := user.GetToken(token.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.LastUsedAt.IsZero())
+	assert.Empty(t, stored.Value)
+
+	user.RevokeToken(token.ID)
+	require.NoError(t, db.SetUser(user.Username, user))
+	assert.Equal(t, http.StatusUnauthorized, do(ScopeRead))
+}
+
+func TestAPILegacyTokens(t *testing.T) {
+	api := newTestAPI(t)
+	api.config.APILegacyTokens = true
+	db := api.db
+
+	user := NewUser()
+	user.Username = "alice"
+	require.NoError(t, db.SetUser(user.Username, user))
+
+	// Older versions issued tokens without an ID, expiry or scopes
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"username": "alice"}).SignedString([]byte(api.config.APISigningKey))
+	require.NoError(t, err)
+
+	ok := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	do := func(scope TokenScope) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/timeline", nil)
+		r.Header.Set("Token", legacy)
+		api.isAuthorized(scope, ok)(w, r, nil)
+		return w
+	}
+
+	w := do(ScopeRead)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, http.StatusForbidden, do(ScopeAdmin).Code)
+
+	// Revoking the user's other sessions revokes legacy tokens
+	user, err = db.GetUser("alice")
+	require.NoError(t, err)
+	require.NoError(t, RevokeOtherSessions(api.sessions, user, ""))
+	require.NoError(t, db.SetUser(user.Username, user))
+	assert.Equal(t, http.StatusUnauthorized, do(ScopeRead).Code)
+
+	// Pods can stop accepting legacy tokens
+	user.LegacyTokensRevoked = false
+	require.NoError(t, db.SetUser(user.Username, user))
+	assert.Equal(t, http.StatusOK, do(ScopeRead).Code)
+	api.config.APILegacyTokens = false
+	assert.Equal(t, http.StatusUnauthorized, do(ScopeRead).Code)
+}
@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// totpPeriod is how long each TOTP code is valid for
+	totpPeriod = 30
+
+	// totpSkew is the number of periods either side of the current period
+	// codes are accepted for to allow for clock drift
+	totpSkew = 1
+
+	// RecoveryCodesCount is the number of recovery codes issued to users
+	// when they enable two-factor authentication
+	RecoveryCodesCount = 10
+
+	// recoveryCodeSize is the number of random bytes of recovery codes, 80
+	// bits so that their (fast) hashes cannot be brute-forced offline
+	recoveryCodeSize = 10
+)
+
+var (
+	// ErrInvalidTOTPCode is returned when enabling two-factor authentication
+	// with a code that does not match the new secret
+	ErrInvalidTOTPCode = errors.New("error: invalid two-factor code")
+
+	// ErrTOTPAlreadyEnabled is returned when enabling two-factor
+	// authentication for a user who already has it enabled
+	ErrTOTPAlreadyEnabled = errors.New("error: two-factor authentication already enabled")
+
+	totpValidateOpts = totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+
+	recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateTOTPKey generates a new TOTP secret for the user to enrol in their
+// authenticator app
+func GenerateTOTPKey(conf *Config, username string) (*otp.Key, error) {
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      conf.Name,
+		AccountName: username,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+}
+
+// TOTPQRCode returns a QR code of the key as a data URI to be scanned by
+// authenticator apps
+func TOTPQRCode(key *otp.Key) (template.URL, error) {
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return "", err
+	}
+
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// generateRecoveryCode returns a new random recovery code, e.g:
+// abcd-efgh-ijkl-mnop
+func generateRecoveryCode() string {
+	b := make([]byte, recoveryCodeSize)
+	_, _ = rand.Read(b)
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+	return fmt.Sprintf("%s-%s-%s-%s", code[:4], code[4:8], code[8:12], code[12:])
+}
+
+// normalizeRecoveryCode normalizes a recovery code as entered by users
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// hashRecoveryCode hashes a recovery code for storage
+func hashRecoveryCode(code string) string {
+	return FastHashString(normalizeRecoveryCode(code))
+}
+
+// HasTOTP returns true if the user has enabled two-factor authentication
+func (u *User) HasTOTP() bool {
+	return u.TOTPSecret != ""
+}
+
+// EnableTOTP enables two-factor authentication for the user with the secret
+// they enrolled, code must be a current code generated from the secret. The
+// user's new recovery codes are returned, only their hashes are stored.
+func (u *User) EnableTOTP(secret, code string) ([]string, error) {
+	if u.HasTOTP() {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	step, ok := validateTOTP(secret, code, 0, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	u.TOTPSecret = secret
+	u.TOTPLastStep = step
+
+	return u.GenerateRecoveryCodes(), nil
+}
+
+// DisableTOTP disables two-factor authentication for the user and discards
+// their recovery codes
+func (u *User) DisableTOTP() {
+	u.TOTPSecret = ""
+	u.TOTPLastStep = 0
+	u.RecoveryCodes = nil
+}
+
+// GenerateRecoveryCodes replaces the user's recovery codes with new ones
+func (u *User) GenerateRecoveryCodes() []string {
+	codes := make([]string, RecoveryCodesCount)
+	hashes := make([]string, RecoveryCodesCount)
+	for i := range codes {
+		codes[i] = generateRecoveryCode()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	u.RecoveryCodes = hashes
+	return codes
+}
+
+// CheckSecondFactor returns true if code is a current TOTP code or one of
+// the user's recovery codes. Codes can only be used once so the user must be
+// saved after a successful check.
+func (u *User) CheckSecondFactor(code string) bool {
+	if !u.HasTOTP() || code == "" {
+		return false
+	}
+
+	if step, ok := validateTOTP(u.TOTPSecret, code, u.TOTPLastStep, time.Now()); ok {
+		u.TOTPLastStep = step
+		return true
+	}
+
+	hash := hashRecoveryCode(code)
+	for i, h := range u.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i], u.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateTOTP returns the time step code is valid for, steps at or before
+// lastStep are rejected so that codes cannot be replayed
+func validateTOTP(secret, code string, lastStep int64, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != otp.DigitsSix.Length() {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totpValidateOpts)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
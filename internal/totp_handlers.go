@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"git.mills.io/yarnsocial/yarn/internal/session"
+)
+
+const (
+	// totpLoginTimeout is how long users have to enter their two-factor code
+	// after entering their password
+	totpLoginTimeout = 5 * time.Minute
+)
+
+// beginTwoFactorLogin records the user as having passed the first factor in
+// the session and asks the user for their two-factor code, the session is
+// only authorized once the code is verified (See: TwoFactorLoginHandler)
+func (s *Server) beginTwoFactorLogin(w http.ResponseWriter, r *http.Request, sess *session.Session, username string, persist bool, referer string) {
+	expiresAt := time.Now().Add(totpLoginTimeout).Unix()
+
+	_ = sess.Set("totp_username", username)
+	_ = sess.Set("totp_expires", strconv.FormatInt(expiresAt, 10))
+	_ = sess.Set("totp_referer", referer)
+	if persist {
+		_ = sess.Set("totp_persist", "1")
+	} else {
+		_ = sess.Del("totp_persist")
+	}
+
+	http.Redirect(w, r, "/login/2fa", http.StatusFound)
+}
+
+// endTwoFactorLogin forgets a pending two-factor login
+func endTwoFactorLogin(sess *session.Session) {
+	for _, key := range []string{"totp_username", "totp_expires", "totp_referer", "totp_persist"} {
+		_ = sess.Del(key)
+	}
+}
+
+// pendingTwoFactorLogin returns the user awaiting two-factor authentication
+// in the session if they haven't timed out
+func pendingTwoFactorLogin(sess *session.Session) (string, bool) {
+	username, ok := sess.Get("totp_username")
+	if !ok || username == "" {
+		return "", false
+	}
+
+	value, _ := sess.Get("totp_expires")
+	expiresAt, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		endTwoFactorLogin(sess)
+		return "", false
+	}
+
+	return username, true
+}
+
+// TwoFactorLoginHandler completes the login of users with two-factor
+// authentication enabled by verifying a code from their authenticator app or
+// one of their recovery codes
+func (s *Server) TwoFactorLoginHandler() httprouter.Handle {
+	failures := NewTTLCache(5 * time.Minute)
+
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		sess, ok := r.Context().Value(session.SessionKey).(*session.Session)
+		if !ok {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		username, ok := pendingTwoFactorLogin(sess)
+		if !ok {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			ctx.Title = s.tr(ctx, "LoginTwoFactorTitle")
+			s.render("loginTwoFactor", w, ctx)
+			return
+		}
+
+		user, err := s.db.GetUser(username)
+		if err != nil {
+			endTwoFactorLogin(sess)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidUsername")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if failures.Get(user.Username) > MaxFailedLogins {
+			endTwoFactorLogin(sess)
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorMaxFailedLogins")
+			s.render("error", w, ctx)
+			return
+		}
+
+		if !user.CheckSecondFactor(r.FormValue("code")) {
+			failed := failures.Inc(user.Username)
+			time.Sleep(time.Duration(IntPow(2, failed)) * time.Second)
+
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidTwoFactorCode")
+			s.render("error", w, ctx)
+			return
+		}
+
+		failures.Reset(user.Username)
+
+		// Persist the used code so it cannot be used again
+		if err := s.db.SetUser(user.Username, user); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		_, persist := sess.Get("totp_persist")
+		referer, _ := sess.Get("totp_referer")
+		endTwoFactorLogin(sess)
+
+		// Authorize session
+		_ = sess.Set("username", user.Username)
+
+		// Persist session?
+		if persist {
+			_ = sess.Set("persist", "1")
+		}
+
+		http.Redirect(w, r, referer, http.StatusFound)
+	}
+}
+
+// TwoFactorSettingsHandler enrols the user in two-factor authentication, a
+// GET shows a new secret to add to their authenticator app and a POST with a
+// code generated from that secret enables two-factor authentication
+func (s *Server) TwoFactorSettingsHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		ctx.Title = s.tr(ctx, "PageTwoFactorTitle")
+
+		if r.Method == http.MethodGet {
+			if !user.HasTOTP() {
+				key, err := GenerateTOTPKey(s.config, user.Username)
+				if err != nil {
+					log.WithError(err).Error("error generating totp key")
+					ctx.Error = true
+					ctx.Message = s.tr(ctx, "ErrorEnablingTwoFactor")
+					s.render("error", w, ctx)
+					return
+				}
+
+				qrcode, err := TOTPQRCode(key)
+				if err != nil {
+					log.WithError(err).Error("error generating totp qr code")
+					ctx.Error = true
+					ctx.Message = s.tr(ctx, "ErrorEnablingTwoFactor")
+					s.render("error", w, ctx)
+					return
+				}
+
+				ctx.TOTPSecret = key.Secret()
+				ctx.TOTPQRCode = qrcode
+			}
+
+			s.render("twoFactor", w, ctx)
+			return
+		}
+
+		secret := strings.TrimSpace(r.FormValue("secret"))
+		code := r.FormValue("code")
+
+		codes, err := user.EnableTOTP(secret, code)
+		if err != nil {
+			ctx.Error = true
+			if err == ErrInvalidTOTPCode {
+				ctx.Message = s.tr(ctx, "ErrorInvalidTwoFactorCode")
+			} else {
+				ctx.Message = s.tr(ctx, "ErrorEnablingTwoFactor")
+			}
+			s.render("error", w, ctx)
+			return
+		}
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.RecoveryCodes = codes
+		s.render("twoFactor", w, ctx)
+	}
+}
+
+// DisableTwoFactorHandler disables two-factor authentication for the user,
+// a current code or a recovery code is required
+func (s *Server) DisableTwoFactorHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		if !user.CheckSecondFactor(r.FormValue("code")) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidTwoFactorCode")
+			s.render("error", w, ctx)
+			return
+		}
+
+		user.DisableTOTP()
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.Error = false
+		ctx.Message = s.tr(ctx, "MsgTwoFactorDisabled")
+		s.render("error", w, ctx)
+	}
+}
+
+// RecoveryCodesHandler issues the user new recovery codes replacing their
+// old ones, a current code or a recovery code is required
+func (s *Server) RecoveryCodesHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := NewContext(s, r)
+
+		user := ctx.User
+		if user == nil {
+			log.Fatalf("user not found in context")
+		}
+
+		if !user.CheckSecondFactor(r.FormValue("code")) {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorInvalidTwoFactorCode")
+			s.render("error", w, ctx)
+			return
+		}
+
+		codes := user.GenerateRecoveryCodes()
+
+		if err := s.db.SetUser(ctx.Username, user); err != nil {
+			ctx.Error = true
+			ctx.Message = s.tr(ctx, "ErrorUpdatingUser")
+			s.render("error", w, ctx)
+			return
+		}
+
+		ctx.Title = s.tr(ctx, "PageTwoFactorTitle")
+		ctx.RecoveryCodes = codes
+		s.render("twoFactor", w, ctx)
+	}
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"git.mills.io/yarnsocial/yarn/internal/passwords"
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	key, err := GenerateTOTPKey(&Config{Name: "Yarn"}, "alice")
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := totp.GenerateCodeCustom(key.Secret(), now, totpValidateOpts)
+	require.NoError(t, err)
+
+	step, ok := validateTOTP(key.Secret(), code, 0, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/totpPeriod, step)
+
+	_, ok = validateTOTP(key.Secret(), code, 0, now.Add(totpPeriod*time.Second))
+	assert.True(t, ok, "codes are accepted for one period either side")
+
+	_, ok = validateTOTP(key.Secret(), code, 0, now.Add(2*totpPeriod*time.Second))
+	assert.False(t, ok, "expired codes are rejected")
+
+	_, ok = validateTOTP(key.Secret(), code, step, now)
+	assert.False(t, ok, "codes cannot be replayed")
+
+	_, ok = validateTOTP(key.Secret(), "", 0, now)
+	assert.False(t, ok)
+}
+
+func TestTwoFactorAuthentication(t *testing.T) {
+	key, err := GenerateTOTPKey(&Config{Name: "Yarn"}, "alice")
+	require.NoError(t, err)
+
+	user := NewUser()
+	assert.False(t, user.HasTOTP())
+	assert.False(t, user.CheckSecondFactor("123456"))
+
+	_, err = user.EnableTOTP(key.Secret(), "000000")
+	assert.Equal(t, ErrInvalidTOTPCode, err)
+	assert.False(t, user.HasTOTP())
+
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	require.NoError(t, err)
+
+	codes, err := user.EnableTOTP(key.Secret(), code)
+	require.NoError(t, err)
+	assert.True(t, user.HasTOTP())
+	require.Len(t, codes, RecoveryCodesCount)
+	require.Len(t, user.RecoveryCodes, RecoveryCodesCount)
+	assert.NotContains(t, user.RecoveryCodes, codes[0], "recovery codes are stored hashed")
+	assert.Len(t, normalizeRecoveryCode(codes[0]), 16, "recovery codes have 80 bits")
+
+	_, err = user.EnableTOTP(key.Secret(), code)
+	assert.Equal(t, ErrTOTPAlreadyEnabled, err)
+
+	assert.False(t, user.CheckSecondFactor(code), "the code used to enable cannot be used again")
+
+	assert.True(t, user.CheckSecondFactor(" "+codes[0]+" "))
+	assert.False(t, user.CheckSecondFactor(codes[0]), "recovery codes can only be used once")
+	assert.Len(t, user.RecoveryCodes, RecoveryCodesCount-1)
+
+	newCodes := user.GenerateRecoveryCodes()
+	assert.False(t, user.CheckSecondFactor(codes[1]), "old recovery codes are discarded")
+	assert.True(t, user.CheckSecondFactor(newCodes[1]))
+
+	user.DisableTOTP()
+	assert.False(t, user.HasTOTP())
+	assert.Empty(t, user.RecoveryCodes)
+}
+
+func TestAuthEndpointTwoFactor(t *testing.T) {
+	api := newTestAPI(t)
+	api.pm = passwords.NewScryptPasswords(nil)
+
+	key, err := GenerateTOTPKey(api.config, "alice")
+	require.NoError(t, err)
+
+	hash, err := api.pm.CreatePassword("password")
+	require.NoError(t, err)
+
+	user := NewUser()
+	user.Username = "alice"
+	user.Password = hash
+	codes := user.GenerateRecoveryCodes()
+	user.TOTPSecret = key.Secret()
+	require.NoError(t, api.db.SetUser(user.Username, user))
+
+	var res types.AuthResponse
+	assert.Equal(t, http.StatusUnauthorized, callEndpoint(t, api.AuthEndpoint(), nil, http.MethodPost, types.AuthRequest{Username: "alice", Password: "password"}, &res))
+
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.AuthEndpoint(), nil, http.MethodPost, types.AuthRequest{Username: "alice", Password: "password", OTP: code}, &res))
+	assert.NotEmpty(t, res.Token)
+
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.AuthEndpoint(), nil, http.MethodPost, types.AuthRequest{Username: "alice", Password: "password", OTP: codes[0]}, &res))
+
+	user, err = api.db.GetUser("alice")
+	require.NoError(t, err)
+	assert.Len(t, user.RecoveryCodes, RecoveryCodesCount-1, "used codes are persisted")
+	assert.NotZero(t, user.TOTPLastStep)
+}
+
+func TestSettingsEndpointRedactsSecrets(t *testing.T) {
+	api := newTestAPI(t)
+
+	user := NewUser()
+	user.Username = "alice"
+	user.Password = "hash"
+	user.TOTPSecret = "secret"
+	user.DigestEmail = "encrypted"
+	user.DigestToken = "token"
+	codes := user.GenerateRecoveryCodes()
+	require.NoError(t, api.db.SetSigningKey("alice", []byte("key")))
+
+	var res User
+	assert.Equal(t, http.StatusOK, callEndpoint(t, api.SettingsEndpoint(), user, http.MethodGet, nil, &res))
+	assert.Equal(t, "alice", res.Username)
+	assert.Empty(t, res.Password)
+	assert.Empty(t, res.TOTPSecret)
+	assert.Empty(t, res.RecoveryCodes)
+	assert.Empty(t, res.DigestEmail)
+	assert.Empty(t, res.DigestToken)
+	assert.Empty(t, res.legacySigningKey)
+
+	assert.Equal(t, "secret", user.TOTPSecret, "the user is left untouched")
+	assert.True(t, user.CheckSecondFactor(codes[0]))
+}
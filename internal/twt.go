@@ -21,15 +21,24 @@ const (
 )
 
 func DeleteLastTwt(conf *Config, user *User) error {
+	return deleteLastTwt(conf, user.Username, user.Twter(conf))
+}
+
+// DeleteLastFeedTwt deletes the last twt of a feed
+func DeleteLastFeedTwt(conf *Config, feed *Feed) error {
+	return deleteLastTwt(conf, feed.Name, feed.Twter(conf))
+}
+
+func deleteLastTwt(conf *Config, name string, twter types.Twter) error {
 	p := filepath.Join(conf.Data, feedsDir)
 	if err := os.MkdirAll(p, 0755); err != nil {
 		log.WithError(err).Error("error creating feeds directory")
 		return err
 	}
 
-	fn := filepath.Join(p, user.Username)
+	fn := filepath.Join(p, name)
 
-	_, n, err := GetLastTwt(conf, user)
+	_, n, err := getLastTwt(conf, name, twter)
 	if err != nil {
 		return err
 	}
@@ -69,6 +78,10 @@ func AppendTwtFactory(conf *Config, db Store) AppendTwtFunc {
 			return types.NilTwt, err
 		}
 
+		if user.IsPostingDisabled() {
+			return types.NilTwt, ErrPostingDisabled
+		}
+
 		if feed != nil && !canPostAsFeed(user, feed) {
 			log.Warnf("unauthorized attempt to post to feed %s from user %s", feed, user)
 			return types.NilTwt, fmt.Errorf("unauthorized attempt to post to feed %s from user %s", feed, user)
@@ -118,6 +131,41 @@ func AppendTwtFactory(conf *Config, db Store) AppendTwtFunc {
 	}
 }
 
+// AppendThread appends a thread of twts to a user's feed (or a feed the user
+// owns) where every twt after the first replies to the first twt's subject.
+// Twts are timestamped a second apart from now on so the thread is ordered as
+// written after the feed's previous twts. Callers limit the length of threads
+// (See: WithMaxThreadLength).
+func AppendThread(appendTwt AppendTwtFunc, user *User, feed *Feed, texts []string) (types.Twts, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("cowardly refusing to twt an empty thread")
+	}
+
+	now := time.Now()
+
+	first, err := appendTwt(user, feed, texts[0], now)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := ExtractHashFromSubject(first.Subject().String())
+	if hash == "" {
+		hash = first.Hash()
+	}
+
+	twts := types.Twts{first}
+	for i, text := range texts[1:] {
+		created := now.Add(time.Duration(i+1) * time.Second)
+		twt, err := appendTwt(user, feed, fmt.Sprintf("(#%s) %s", hash, text), created)
+		if err != nil {
+			return twts, err
+		}
+		twts = append(twts, twt)
+	}
+
+	return twts, nil
+}
+
 func FeedExists(conf *Config, username string) bool {
 	fn := filepath.Join(conf.Data, feedsDir, NormalizeUsername(username))
 	if _, err := os.Stat(fn); err != nil {
@@ -130,6 +178,15 @@ func FeedExists(conf *Config, username string) bool {
 }
 
 func GetLastTwt(conf *Config, user *User) (twt types.Twt, offset int, err error) {
+	return getLastTwt(conf, user.Username, user.Twter(conf))
+}
+
+// GetLastFeedTwt returns the last twt of a feed
+func GetLastFeedTwt(conf *Config, feed *Feed) (twt types.Twt, offset int, err error) {
+	return getLastTwt(conf, feed.Name, feed.Twter(conf))
+}
+
+func getLastTwt(conf *Config, name string, twter types.Twter) (twt types.Twt, offset int, err error) {
 	twt = types.NilTwt
 
 	p := filepath.Join(conf.Data, feedsDir)
@@ -138,7 +195,7 @@ func GetLastTwt(conf *Config, user *User) (twt types.Twt, offset int, err error)
 		return
 	}
 
-	fn := filepath.Join(p, user.Username)
+	fn := filepath.Join(p, name)
 	if !FileExists(fn) {
 		return
 	}
@@ -149,7 +206,6 @@ func GetLastTwt(conf *Config, user *User) (twt types.Twt, offset int, err error)
 		return
 	}
 
-	twter := user.Twter(conf)
 	twt, err = types.ParseLine(string(data), &twter)
 
 	return
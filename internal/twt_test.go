@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"regexp"
 	"testing"
+	"time"
 
+	"git.mills.io/yarnsocial/yarn/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestExpandTag(t *testing.T) {
@@ -103,3 +106,30 @@ func ExpandTag(conf *Config, text string) string {
 		return fmt.Sprintf("%s#<%s %s>", prefix, tag, URLForTag(conf.BaseURL, tag))
 	})
 }
+
+func TestAppendThread(t *testing.T) {
+	var posted []string
+	appendTwt := func(user *User, feed *Feed, text string, args ...interface{}) (types.Twt, error) {
+		posted = append(posted, text)
+		return types.MakeTwt(testLocalTwter, args[0].(time.Time), text), nil
+	}
+
+	before := time.Now().Truncate(time.Second)
+	twts, err := AppendThread(appendTwt, &User{Username: testLocalNick}, nil, []string{"Hello", "World", "!"})
+	require.NoError(t, err)
+	require.Len(t, twts, 3)
+	assert.False(t, twts[0].Created().Before(before), "threads are not backdated")
+
+	subject := fmt.Sprintf("(#%s)", twts[0].Hash())
+	assert.Equal(t, []string{"Hello", subject + " World", subject + " !"}, posted)
+
+	for i, twt := range twts {
+		assert.Equal(t, subject, twt.Subject().String())
+		if i > 0 {
+			assert.True(t, twt.Created().After(twts[i-1].Created()))
+		}
+	}
+
+	_, err = AppendThread(appendTwt, &User{Username: testLocalNick}, nil, nil)
+	assert.Error(t, err)
+}
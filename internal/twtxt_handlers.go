@@ -1,11 +1,15 @@
 package internal
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"fmt"
 	std_ioutil "io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"git.mills.io/yarnsocial/yarn"
@@ -33,6 +37,14 @@ const defaultPreambleTemplate = `# Twtxt is an open, distributed microblogging p
 # followers   = {{ if .Profile.ShowFollowers }}{{ .Profile.NFollowers }}{{ end }}
 # following   = {{ if .Profile.ShowFollowing }}{{ .Profile.NFollowing }}{{ end }}
 #
+{{- range $key, $values := .Profile.Metadata }}
+{{- range $value := $values }}
+# {{ $key }} = {{ $value }}
+{{- end }}
+{{- end }}
+{{- if .Profile.Metadata }}
+#
+{{- end }}
 {{- if .Profile.ShowFollowing }}
 {{ range $f := .Profile.Following -}}
 # follow = {{ $f.Nick }} {{ $f.URI }}
@@ -52,6 +64,12 @@ func (s *Server) TwtxtHandler() httprouter.Handle {
 			return
 		}
 
+		// The feeds of suspended users are gone whilst they are suspended
+		if s.config.SuspendedFeed(URLForUser(s.config.BaseURL, nick)) {
+			http.Error(w, "Feed Gone", http.StatusGone)
+			return
+		}
+
 		fn, err := securejoin.SecureJoin(filepath.Join(s.config.Data, "feeds"), nick)
 		if err != nil {
 			http.Error(w, "Bad Request", http.StatusBadRequest)
@@ -83,6 +101,27 @@ func (s *Server) TwtxtHandler() httprouter.Handle {
 			log.WithError(err).Warnf("unable to load user or feed profile for %s", nick)
 		}
 
+		// Metadata generated by the pod in addition to the user defined fields
+		metadata := make(url.Values)
+		for k, v := range ctx.Profile.Metadata {
+			metadata[k] = v
+		}
+
+		if s.config.TwtHashVersion != types.DefaultTwtHashVersion {
+			metadata.Set(hashVersionMetadataKey, strconv.Itoa(int(s.config.TwtHashVersion)))
+		}
+
+		if s.config.Features.IsEnabled(FeatureSignedFeeds) && ctx.Profile.Nick != "" {
+			if key, err := GetSigningKey(s.db, nick); err == nil {
+				metadata.Set(publicKeyMetadataKey, EncodePublicKey(key.Public().(ed25519.PublicKey)))
+				metadata.Set(signaturesMetadataKey, fmt.Sprintf("%s.sig", URLForUser(s.config.BaseURL, nick)))
+			} else {
+				log.WithError(err).Warnf("error loading signing key for %s", nick)
+			}
+		}
+
+		ctx.Profile.Metadata = metadata
+
 		s.tasks.DispatchFunc(func() error {
 			return s.cache.DetectClientFromRequest(r, ctx.Profile)
 		})
@@ -134,9 +173,89 @@ func (s *Server) TwtxtHandler() httprouter.Handle {
 
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.Header().Set("Link", fmt.Sprintf(`<%s/user/%s/webmention>; rel="webmention"`, s.config.BaseURL, nick))
+		if s.db.HasUser(nick) {
+			for _, link := range s.indieAuthLinks() {
+				w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="%s"`, link.Href, link.Rel))
+			}
+		}
 		w.Header().Set("Powered-By", fmt.Sprintf("yarnd/%s (Pod: %s Support: %s)", yarn.FullVersion(), s.config.Name, URLForPage(s.config.BaseURL, "support")))
 
 		mrs := ioutil.NewMultiReadSeeker(strings.NewReader(preamble), pr)
 		http.ServeContent(w, r, "", fileInfo.ModTime(), mrs)
 	}
 }
+
+// TwtxtSignaturesHandler serves the detached signatures of a local feed's twts
+func (s *Server) TwtxtSignaturesHandler() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if !s.config.Features.IsEnabled(FeatureSignedFeeds) {
+			http.Error(w, "Signatures Not Found", http.StatusNotFound)
+			return
+		}
+
+		nick := NormalizeUsername(p.ByName("nick"))
+		if nick == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		// The feeds of suspended users are gone whilst they are suspended
+		if s.config.SuspendedFeed(URLForUser(s.config.BaseURL, nick)) {
+			http.Error(w, "Feed Gone", http.StatusGone)
+			return
+		}
+
+		fn, err := securejoin.SecureJoin(filepath.Join(s.config.Data, "feeds"), nick)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		f, err := os.Open(fn)
+		if err != nil {
+			if os.IsNotExist(err) {
+				http.Error(w, "Feed Not Found", http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			log.WithError(err).Error("error calling Stat() on feed")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		key, err := GetSigningKey(s.db, nick)
+		if err != nil {
+			log.WithError(err).Warnf("error loading signing key for %s", nick)
+			http.Error(w, "Signatures Not Found", http.StatusNotFound)
+			return
+		}
+
+		// Twts are hashed (and signed) against the `url` we publish in the
+		// feed's preamble (See: defaultPreambleTemplate)
+		uri := URLForUser(s.config.BaseURL, nick)
+		twter := types.Twter{Nick: nick, URI: uri, HashingURI: uri, HashVersion: s.config.TwtHashVersion}
+		tf, err := types.ParseFile(f, &twter)
+		if err != nil {
+			log.WithError(err).Error("error parsing feed")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		buf := &bytes.Buffer{}
+		if err := WriteSignatures(buf, key, tf.Twts()); err != nil {
+			log.WithError(err).Error("error writing signatures")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeContent(w, r, "", stat.ModTime(), bytes.NewReader(buf.Bytes()))
+	}
+}
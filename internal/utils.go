@@ -117,6 +117,7 @@ var (
 	singleUserUARegex = regexp.MustCompile(`(.+) \(\+(https?://\S+/\S+); @(\S+)\)`)
 	multiUserUARegex  = regexp.MustCompile(`(.+) \(~(https?://\S+\/\S+); contact=(https?://\S+)\)`)
 	yarndUserUARegex  = regexp.MustCompile(`(.+) \(Pod: (\S+) Support: (https?://\S+)\)`)
+	twtPrefixRegex    = regexp.MustCompile(`^((@<[^>]*>|@\S+)[, ]*)*(\(.*?\) *)?`)
 
 	ErrInvalidFeedName  = errors.New("error: invalid feed name")
 	ErrBadRequest       = errors.New("error: request failed with non-200 response")
@@ -994,8 +995,21 @@ func NormalizeFeedName(name string) string {
 	return name
 }
 
-func IsTwtAuthentic(conf *Config, twt types.Twt) bool {
+// IsTwtAuthentic returns true if the twt was really published by its author.
+// If the author publishes a public key the twt's signature is verified offline
+// otherwise (or if no signature is available) the author's feed is fetched to
+// check that it contains the twt.
+func IsTwtAuthentic(conf *Config, cache *Cache, twt types.Twt, signature string) bool {
 	hash := twt.Hash()
+
+	switch err := cache.VerifyTwt(twt, signature); err {
+	case nil:
+		return true
+	case ErrInvalidSignature:
+		log.Warnf("invalid signature for twt %s", hash)
+		return false
+	}
+
 	twter := twt.Twter()
 	tf, err := ValidateFeed(conf, twter.Nick, twter.URI)
 	if err != nil {
@@ -1600,6 +1614,15 @@ func SafeParseInt(s string, d int) int {
 	return n
 }
 
+// SafeParseFloat ...
+func SafeParseFloat(s string, d float64) float64 {
+	n, e := strconv.ParseFloat(s, 64)
+	if e != nil {
+		return d
+	}
+	return n
+}
+
 // ValidateUsername validates the username before allowing it to be created.
 // This ensures usernames match a defined pattern and that some usernames
 // that are reserved are never used by users.
@@ -1660,6 +1683,7 @@ func UnparseTwtFactory(conf *Config) func(text string) string {
 }
 
 // FilterTwts filters out Twts from users/feeds that a User has chosen to mute
+// and Twts hidden by the User's filters
 func FilterTwts(user *User, twts types.Twts) (filtered types.Twts) {
 	if user == nil {
 		return twts
@@ -1676,6 +1700,39 @@ func CleanTwt(text string) string {
 	return text
 }
 
+// CleanThread cleans each twt of a thread dropping empty twts
+func CleanThread(texts []string) []string {
+	var thread []string
+	for _, text := range texts {
+		if text = CleanTwt(text); text != "" {
+			thread = append(thread, text)
+		}
+	}
+	return thread
+}
+
+// WithContentWarning flags the twt's text with a content warning written as
+// `[CW: warning]` after any mentions and subject the text starts with. Text
+// already flagged with a content warning is returned unchanged.
+func WithContentWarning(text, warning string) string {
+	warning = strings.NewReplacer("[", "(", "]", ")").Replace(warning)
+	warning = strings.Join(strings.Fields(warning), " ")
+	if warning == "" {
+		return text
+	}
+
+	prefix := twtPrefixRegex.FindString(text)
+	rest := strings.TrimLeft(text[len(prefix):], " ")
+	if len(rest) >= len("[CW:") && strings.EqualFold(rest[:len("[CW:")], "[CW:") {
+		return text
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, " ") {
+		prefix += " "
+	}
+	return fmt.Sprintf("%s[CW: %s] %s", prefix, warning, rest)
+}
+
 // RenderAudio ...
 func RenderAudio(conf *Config, uri, title, renderAs string, full bool) string {
 	// XXX: `renderAs` is ignored for Audio right now
@@ -1875,6 +1932,10 @@ type URLProcessor struct {
 	conf *Config
 	user *User
 
+	// hideMedia links media instead of displaying it such as for twts
+	// collapsed behind a content warning
+	hideMedia bool
+
 	Images []string
 }
 
@@ -1895,6 +1956,9 @@ func (p *URLProcessor) RenderNodeHook(w io.Writer, node ast.Node, entering bool)
 	if p.user != nil {
 		display = p.user.DisplayMedia
 	}
+	if p.hideMedia {
+		display = false
+	}
 
 	full := p.conf.OriginalMedia
 	if p.user != nil {
@@ -1993,7 +2057,10 @@ func FormatTwtFactory(conf *Config, cache *Cache, archive Archiver) func(twt typ
 			htmlFlags = htmlFlags | html.HrefTargetBlank
 		}
 
-		up := &URLProcessor{conf: conf, user: user}
+		// Media in twts collapsed behind a content warning is hidden
+		// regardless of the user's display preferences
+		warning := twt.ContentWarning()
+		up := &URLProcessor{conf: conf, user: user, hideMedia: user.CollapsedByWarning(twt) != ""}
 
 		opts := html.RendererOptions{
 			Flags:          htmlFlags,
@@ -2018,6 +2085,11 @@ func FormatTwtFactory(conf *Config, cache *Cache, archive Archiver) func(twt typ
 			markdownInput = strings.ReplaceAll(markdownInput, subject, "")
 			markdownInput = strings.TrimSpace(markdownInput)
 		}
+		if warning != "" {
+			// The warning itself is rendered by the template
+			markdownInput = strings.Replace(markdownInput, lextwt.NewContentWarning(warning).String(), "", 1)
+			markdownInput = strings.TrimSpace(markdownInput)
+		}
 
 		md := []byte(markdownInput)
 		maybeUnsafeHTML := markdown.ToHTML(md, mdParser, renderer)
@@ -2061,7 +2133,7 @@ func GetRootTwtFactory(conf *Config, cache *Cache, archive Archiver) func(twt ty
 			return types.NilTwt
 		}
 
-		if u.HasMuted(rootTwt.Twter().URI) {
+		if len(FilterTwts(u, types.Twts{rootTwt})) == 0 {
 			return types.NilTwt
 		}
 
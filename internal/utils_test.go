@@ -246,6 +246,61 @@ func TestFormatTwtFactory(t *testing.T) {
 	assert.Equal(actual, expected)
 }
 
+func TestFormatTwtContentWarning(t *testing.T) {
+	cfg := NewConfig()
+	cfg.baseURL = &url.URL{Host: "example.com"}
+	factory := FormatTwtFactory(cfg, NewCache(cfg), &NullArchiver{})
+	twter := types.Twter{
+		Nick: "test",
+		URI:  "https://example.com/twtxt.txt",
+	}
+	twt := lextwt.NewTwt(twter,
+		lextwt.NewDateTime(parseTime("2021-01-24T02:19:54Z"), "2021-01-24T02:19:54Z"),
+		lextwt.NewContentWarning("food"),
+		lextwt.NewText(" Dinner "),
+		lextwt.NewMedia("cake", "https://example.com/cake.png", ""),
+	)
+
+	// Media is hidden behind the warning regardless of display preferences
+	user := NewUser()
+	user.DisplayImagesPreference = "gallery"
+	assert.Equal(t, "food", user.CollapsedByWarning(twt))
+
+	html := string(factory(twt, user))
+	assert.NotContains(t, html, "CW:")
+	assert.NotContains(t, html, "<img")
+	assert.NotContains(t, html, "image-gallery")
+	assert.Contains(t, html, `<i class="external-image">`)
+
+	user.ExpandContentWarnings = true
+	assert.Empty(t, user.CollapsedByWarning(twt))
+	assert.Contains(t, string(factory(twt, user)), `<img loading="lazy" src="//example.com/cake.png"`)
+}
+
+func TestWithContentWarning(t *testing.T) {
+	testCases := []struct {
+		text     string
+		warning  string
+		expected string
+	}{
+		{"Hello World", "", "Hello World"},
+		{"Hello World", " spoilers ", "[CW: spoilers] Hello World"},
+		{"(#abcdefg) Hello", "food", "(#abcdefg) [CW: food] Hello"},
+		{"@<bob https://example.com/bob.txt> (#abcdefg) Hello", "food", "@<bob https://example.com/bob.txt> (#abcdefg) [CW: food] Hello"},
+		{"@bob Hello", "a [nested]\nwarning", "@bob [CW: a (nested) warning] Hello"},
+		{"(#abcdefg) [cw: food] Hello", "politics", "(#abcdefg) [cw: food] Hello"},
+	}
+
+	for _, testCase := range testCases {
+		actual := WithContentWarning(testCase.text, testCase.warning)
+		assert.Equal(t, testCase.expected, actual)
+		if testCase.warning != "" {
+			twt := types.MakeTwt(types.Twter{Nick: "test"}, time.Now(), actual)
+			assert.NotEmpty(t, twt.ContentWarning(), actual)
+		}
+	}
+}
+
 func parseTime(s string) time.Time {
 	if dt, err := time.Parse(time.RFC3339, s); err == nil {
 		return dt
@@ -46,7 +46,7 @@ func (s *Server) WhoFollowsHandler() httprouter.Handle {
 		}
 		tokenCache.Del(token)
 
-		users, err := s.db.GetAllUsers()
+		nick, followers, err := WhoFollows(s.config, s.db, uri, ctx.User)
 		if err != nil {
 			log.WithError(err).Error("unable to get all users from database")
 			if ctype == "html" {
@@ -59,33 +59,6 @@ func (s *Server) WhoFollowsHandler() httprouter.Handle {
 			return
 		}
 
-		var (
-			nick      string
-			followers types.Followers
-		)
-
-		for _, user := range users {
-			userURL := URLForUser(s.config.BaseURL, user.Username)
-
-			if !user.IsFollowingPubliclyVisible && !ctx.User.Is(userURL) {
-				continue
-			}
-
-			if user.Follows(uri) {
-				followers = append(followers, &types.Follower{
-					Nick:       user.Username,
-					URI:        userURL,
-					LastSeenAt: time.Now(),
-				})
-				if nick == "" {
-					nick = user.sources[uri]
-				}
-			}
-		}
-		if nick == "" {
-			nick = "unknown"
-		}
-
 		ctx.Profile = types.Profile{
 			Type: "External",
 
@@ -117,3 +90,42 @@ func (s *Server) WhoFollowsHandler() httprouter.Handle {
 		s.render("followers", w, ctx)
 	}
 }
+
+// WhoFollows returns the local users following the feed uri and the nick the
+// feed is followed as. Users who hide who they follow are only included if
+// they are the viewer.
+func WhoFollows(conf *Config, db Store, uri string, viewer *User) (string, types.Followers, error) {
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		nick      string
+		followers types.Followers
+	)
+
+	for _, user := range users {
+		userURL := URLForUser(conf.BaseURL, user.Username)
+
+		if !user.IsFollowingPubliclyVisible && (viewer == nil || !viewer.Is(userURL)) {
+			continue
+		}
+
+		if user.Follows(uri) {
+			followers = append(followers, &types.Follower{
+				Nick:       user.Username,
+				URI:        userURL,
+				LastSeenAt: time.Now(),
+			})
+			if nick == "" {
+				nick = user.sources[uri]
+			}
+		}
+	}
+	if nick == "" {
+		nick = "unknown"
+	}
+
+	return nick, followers, nil
+}
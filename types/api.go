@@ -4,12 +4,22 @@ import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"net/url"
+	"time"
 )
 
 // AuthRequest ...
 type AuthRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// OTP is the user's current two-factor code or one of their recovery
+	// codes, required for users who enabled two-factor authentication
+	OTP string `json:"otp,omitempty"`
+
+	// Scopes are the scopes requested for the token, one or more of
+	// `read`, `post`, `follow` and `admin`. Defaults to all but `admin`.
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 // NewAuthRequest ...
@@ -41,6 +51,14 @@ type RegisterRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Email    string `json:"email"`
+
+	// Invite is the code of an invite to register with when open
+	// registrations are disabled or the pod requires a captcha to register
+	Invite string `json:"invite,omitempty"`
+
+	// Reason is why the user wants to join the pod, it is required when the
+	// pod requires new accounts to be approved
+	Reason string `json:"reason,omitempty"`
 }
 
 // NewRegisterRequest ...
@@ -57,6 +75,15 @@ func NewRegisterRequest(r io.Reader) (req RegisterRequest, err error) {
 type PostRequest struct {
 	PostAs string `json:"post_as"`
 	Text   string `json:"text"`
+
+	// Thread is an optional list of texts posted after Text, each replying
+	// to the first twt's subject. Pods limit the number of twts of a thread
+	// (Text included).
+	Thread []string `json:"thread,omitempty"`
+
+	// ContentWarning optionally flags the twts posted with a content
+	// warning written as `[CW: warning]`.
+	ContentWarning string `json:"content_warning,omitempty"`
 }
 
 // NewPostRequest ...
@@ -95,6 +122,13 @@ type PagerResponse struct {
 type PagedResponse struct {
 	Twts  Twts `json:"twts"`
 	Pager PagerResponse
+
+	// Poll is the tally of a conversation's root twt if it is a poll
+	Poll *Poll `json:"poll,omitempty"`
+
+	// Collapsed are the filters that collapse twts of the page keyed by the
+	// twt's hash, clients should show these twts behind a warning
+	Collapsed map[string]MuteFilter `json:"collapsed,omitempty"`
 }
 
 // Bytes ...
@@ -261,6 +295,7 @@ func NewSupportRequest(r io.Reader) (req SupportRequest, err error) {
 type ReportRequest struct {
 	Nick string `json:"nick"`
 	URL  string `json:"url"`
+	Hash string `json:"hash,omitempty"`
 
 	Name     string `json:"name"`
 	Email    string `json:"email"`
@@ -277,3 +312,620 @@ func NewReportRequest(r io.Reader) (req ReportRequest, err error) {
 	err = json.Unmarshal(body, &req)
 	return
 }
+
+// MetadataRequest ...
+type MetadataRequest struct {
+	Feed     string     `json:"feed"`
+	Metadata url.Values `json:"metadata"`
+}
+
+// NewMetadataRequest ...
+func NewMetadataRequest(r io.Reader) (req MetadataRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// UserSettings is a user's settings as returned to the user, fields are named
+// as in the user object older versions returned for compatibility but secrets
+// such as the user's password, tokens and two-factor secrets are never included
+type UserSettings struct {
+	Username   string
+	Tagline    string
+	URL        string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+
+	Theme      string
+	Lang       string
+	AvatarHash string
+
+	DisplayDatesInTimezone  string
+	DisplayTimePreference   string
+	OpenLinksInPreference   string
+	HideRepliesPreference   bool
+	DisplayImagesPreference string
+	DisplayMedia            bool
+	OriginalMedia           bool
+	ExpandContentWarnings   bool
+
+	IsFollowersPubliclyVisible bool
+	IsFollowingPubliclyVisible bool
+	IsBookmarksPubliclyVisible bool
+
+	Feeds []string
+
+	Bookmarks map[string]string
+	Followers map[string]string
+	Following map[string]string
+	Muted     map[string]string
+
+	Filters  map[string]MuteFilter
+	Metadata url.Values
+
+	DigestFrequency string
+	DigestSentAt    time.Time
+
+	TwoFactorEnabled bool
+	Role             string
+}
+
+// Bytes ...
+func (res UserSettings) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// MetadataResponse ...
+type MetadataResponse struct {
+	Feed     string     `json:"feed"`
+	Metadata url.Values `json:"metadata"`
+}
+
+// Bytes ...
+func (res MetadataResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// BookmarkRequest ...
+type BookmarkRequest struct {
+	Hash string `json:"hash"`
+}
+
+// NewBookmarkRequest ...
+func NewBookmarkRequest(r io.Reader) (req BookmarkRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// BookmarkResponse ...
+type BookmarkResponse struct {
+	Hash       string `json:"hash"`
+	Bookmarked bool   `json:"bookmarked"`
+}
+
+// Bytes ...
+func (res BookmarkResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// BookmarksRequest ...
+type BookmarksRequest struct {
+	Nick string `json:"nick"`
+	Page int    `json:"page"`
+}
+
+// NewBookmarksRequest ...
+func NewBookmarksRequest(r io.Reader) (req BookmarksRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// FeedsResponse ...
+type FeedsResponse struct {
+	Feeds []Profile `json:"feeds"`
+}
+
+// Bytes ...
+func (res FeedsResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// CreateFeedRequest ...
+type CreateFeedRequest struct {
+	Name string `json:"name"`
+}
+
+// NewCreateFeedRequest ...
+func NewCreateFeedRequest(r io.Reader) (req CreateFeedRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// UpdateFeedRequest ...
+type UpdateFeedRequest struct {
+	Description string `json:"description"`
+}
+
+// NewUpdateFeedRequest ...
+func NewUpdateFeedRequest(r io.Reader) (req UpdateFeedRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// FeedResponse ...
+type FeedResponse struct {
+	Feed Profile `json:"feed"`
+}
+
+// Bytes ...
+func (res FeedResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// FollowersRequest ...
+type FollowersRequest struct {
+	Nick string `json:"nick"`
+}
+
+// NewFollowersRequest ...
+func NewFollowersRequest(r io.Reader) (req FollowersRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// FollowersResponse ...
+type FollowersResponse struct {
+	Followers Followers `json:"followers"`
+}
+
+// Bytes ...
+func (res FollowersResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// FollowingRequest ...
+type FollowingRequest struct {
+	Nick string `json:"nick"`
+}
+
+// NewFollowingRequest ...
+func NewFollowingRequest(r io.Reader) (req FollowingRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// FollowingResponse ...
+type FollowingResponse struct {
+	Following map[string]string `json:"following"`
+}
+
+// Bytes ...
+func (res FollowingResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// EditTwtRequest ...
+type EditTwtRequest struct {
+	PostAs string `json:"post_as,omitempty"`
+	Hash   string `json:"hash"`
+	Text   string `json:"text"`
+}
+
+// NewEditTwtRequest ...
+func NewEditTwtRequest(r io.Reader) (req EditTwtRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// DeleteTwtRequest ...
+type DeleteTwtRequest struct {
+	PostAs string `json:"post_as,omitempty"`
+	Hash   string `json:"hash"`
+}
+
+// NewDeleteTwtRequest ...
+func NewDeleteTwtRequest(r io.Reader) (req DeleteTwtRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// EditTwtResponse ...
+type EditTwtResponse struct {
+	Hash string `json:"hash"`
+}
+
+// Bytes ...
+func (res EditTwtResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// ImportRequest ...
+type ImportRequest struct {
+	// Follows maps the nicks of the feeds to follow to their urls
+	Follows map[string]string `json:"follows"`
+}
+
+// NewImportRequest ...
+func NewImportRequest(r io.Reader) (req ImportRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// ImportResponse ...
+type ImportResponse struct {
+	Imported int `json:"imported"`
+}
+
+// Bytes ...
+func (res ImportResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// WhoFollowsRequest ...
+type WhoFollowsRequest struct {
+	Token string `json:"token"`
+}
+
+// NewWhoFollowsRequest ...
+func NewWhoFollowsRequest(r io.Reader) (req WhoFollowsRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// WhoFollowsResponse ...
+type WhoFollowsResponse struct {
+	Nick      string            `json:"nick"`
+	URI       string            `json:"uri"`
+	Followers map[string]string `json:"followers"`
+}
+
+// Bytes ...
+func (res WhoFollowsResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// NotificationsResponse ...
+type NotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+
+	// Twts are the twts the notifications are about
+	Twts   Twts          `json:"twts"`
+	Unread int           `json:"unread"`
+	Pager  PagerResponse `json:"pager"`
+}
+
+// Bytes ...
+func (res NotificationsResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// NotificationsReadRequest ...
+type NotificationsReadRequest struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+// NewNotificationsReadRequest ...
+func NewNotificationsReadRequest(r io.Reader) (req NotificationsReadRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// NotificationsReadResponse ...
+type NotificationsReadResponse struct {
+	Unread int `json:"unread"`
+}
+
+// Bytes ...
+func (res NotificationsReadResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// SessionsResponse ...
+type SessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+// Bytes ...
+func (res SessionsResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// RevokeSessionRequest revokes the session or token with the given ID or all
+// sessions and tokens but the one making the request if Others is true
+type RevokeSessionRequest struct {
+	ID     string `json:"id,omitempty"`
+	Others bool   `json:"others,omitempty"`
+}
+
+// NewRevokeSessionRequest ...
+func NewRevokeSessionRequest(r io.Reader) (req RevokeSessionRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// MuteFiltersResponse ...
+type MuteFiltersResponse struct {
+	Filters []MuteFilter `json:"filters"`
+}
+
+// Bytes ...
+func (res MuteFiltersResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// AddMuteFilterRequest adds a content filter that expires after ExpiresIn
+// seconds or never if ExpiresIn is zero
+type AddMuteFilterRequest struct {
+	Kind      MuteFilterKind   `json:"kind"`
+	Pattern   string           `json:"pattern,omitempty"`
+	Action    MuteFilterAction `json:"action"`
+	ExpiresIn int64            `json:"expires_in,omitempty"`
+}
+
+// NewAddMuteFilterRequest ...
+func NewAddMuteFilterRequest(r io.Reader) (req AddMuteFilterRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// RemoveMuteFilterRequest ...
+type RemoveMuteFilterRequest struct {
+	ID string `json:"id"`
+}
+
+// NewRemoveMuteFilterRequest ...
+func NewRemoveMuteFilterRequest(r io.Reader) (req RemoveMuteFilterRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// AdminUser is a user of the pod as seen by the pod's staff
+type AdminUser struct {
+	Username        string    `json:"username"`
+	Role            string    `json:"role,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	LastSeenAt      time.Time `json:"last_seen_at"`
+	Silenced        bool      `json:"silenced"`
+	PostingDisabled bool      `json:"posting_disabled"`
+	Suspended       bool      `json:"suspended"`
+}
+
+// AdminUsersResponse ...
+type AdminUsersResponse struct {
+	Users []AdminUser `json:"users"`
+}
+
+// Bytes ...
+func (res AdminUsersResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// AdminSetRoleRequest assigns a staff role to a user, an empty role removes
+// the user's role
+type AdminSetRoleRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// NewAdminSetRoleRequest ...
+func NewAdminSetRoleRequest(r io.Reader) (req AdminSetRoleRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// AdminLimitUserRequest places a limit (silence, disable_posting or suspend)
+// on a user until the given time, or indefinitely if Until is the zero time,
+// or lifts it if Lift is true
+type AdminLimitUserRequest struct {
+	Username string    `json:"username"`
+	Limit    string    `json:"limit"`
+	Reason   string    `json:"reason,omitempty"`
+	Until    time.Time `json:"until,omitempty"`
+	Lift     bool      `json:"lift,omitempty"`
+}
+
+// NewAdminLimitUserRequest ...
+func NewAdminLimitUserRequest(r io.Reader) (req AdminLimitUserRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// ReportDecision is an entry in an abuse report's audit trail
+type ReportDecision struct {
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Report is an abuse report about a feed or one of its twts (if Hash is set)
+type Report struct {
+	ID           string           `json:"id"`
+	Status       string           `json:"status"`
+	Reporter     string           `json:"reporter,omitempty"`
+	ReporterName string           `json:"reporter_name,omitempty"`
+	Nick         string           `json:"nick"`
+	URL          string           `json:"url"`
+	Hash         string           `json:"hash,omitempty"`
+	Category     string           `json:"category"`
+	Message      string           `json:"message"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	Decisions    []ReportDecision `json:"decisions"`
+}
+
+// AdminReportsRequest lists the reports with the given status or all
+// reports if Status is empty
+type AdminReportsRequest struct {
+	Status string `json:"status,omitempty"`
+}
+
+// NewAdminReportsRequest ...
+func NewAdminReportsRequest(r io.Reader) (req AdminReportsRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
+
+// AdminReportsResponse ...
+type AdminReportsResponse struct {
+	Reports []Report `json:"reports"`
+}
+
+// Bytes ...
+func (res AdminReportsResponse) Bytes() ([]byte, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// AdminDecideReportRequest takes an action on a report, Note is recorded in
+// the report's audit trail and is the reply sent to the reporter
+type AdminDecideReportRequest struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	Note   string `json:"note,omitempty"`
+}
+
+// NewAdminDecideReportRequest ...
+func NewAdminDecideReportRequest(r io.Reader) (req AdminDecideReportRequest, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &req)
+	return
+}
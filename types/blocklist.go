@@ -0,0 +1,76 @@
+package types
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// BlocklistVersion is the version of the format pods share blocklists in
+const BlocklistVersion = 1
+
+// BlockKind is what a BlocklistEntry matches feed uris by
+type BlockKind string
+
+const (
+	// BlockDomain matches feeds hosted on the domain or any of its subdomains
+	BlockDomain BlockKind = "domain"
+
+	// BlockPrefix matches feed uris starting with the prefix
+	BlockPrefix BlockKind = "prefix"
+
+	// BlockRegex matches feed uris matching the regular expression
+	BlockRegex BlockKind = "regex"
+)
+
+// BlockAction is what a pod does with feeds matched by a BlocklistEntry
+type BlockAction string
+
+const (
+	// BlockRejectFetch stops the pod fetching matching feeds at all
+	BlockRejectFetch BlockAction = "reject_fetch"
+
+	// BlockHideFromDiscover hides twts of matching feeds from discover
+	BlockHideFromDiscover BlockAction = "hide_from_discover"
+
+	// BlockStripFromConversations removes twts of matching feeds from
+	// conversations
+	BlockStripFromConversations BlockAction = "strip_from_conversations"
+)
+
+// BlocklistEntry is an entry of a shared blocklist
+type BlocklistEntry struct {
+	Kind    BlockKind     `json:"kind"`
+	Pattern string        `json:"pattern"`
+	Actions []BlockAction `json:"actions"`
+	Reason  string        `json:"reason,omitempty"`
+}
+
+// Blocklist is the format pods share their blocklists in, Source is the base
+// url of the pod the blocklist was exported from
+type Blocklist struct {
+	Version   int              `json:"version"`
+	Source    string           `json:"source"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Entries   []BlocklistEntry `json:"entries"`
+}
+
+// DecodeBlocklist ...
+func DecodeBlocklist(r io.Reader) (blocklist Blocklist, err error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &blocklist)
+	return
+}
+
+// Bytes ...
+func (blocklist Blocklist) Bytes() ([]byte, error) {
+	body, err := json.Marshal(blocklist)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"time"
+)
+
+// MuteFilterKind is what a MuteFilter matches twts by
+type MuteFilterKind string
+
+const (
+	// MuteFilterWord matches twts containing the word (case-insensitive)
+	MuteFilterWord MuteFilterKind = "word"
+
+	// MuteFilterHashtag matches twts tagged with the hashtag
+	MuteFilterHashtag MuteFilterKind = "hashtag"
+
+	// MuteFilterRegex matches twts whose text matches the regular expression
+	MuteFilterRegex MuteFilterKind = "regex"
+
+	// MuteFilterReplies matches twts replying to or mentioning muted feeds
+	MuteFilterReplies MuteFilterKind = "replies"
+)
+
+// MuteFilterAction is what happens to twts matched by a MuteFilter
+type MuteFilterAction string
+
+const (
+	// MuteFilterHide hides matching twts entirely
+	MuteFilterHide MuteFilterAction = "hide"
+
+	// MuteFilterCollapse collapses matching twts behind a warning
+	MuteFilterCollapse MuteFilterAction = "collapse"
+)
+
+// MuteFilter is a user defined content filter, a zero ExpiresAt never expires
+type MuteFilter struct {
+	ID        string           `json:"id"`
+	Kind      MuteFilterKind   `json:"kind"`
+	Pattern   string           `json:"pattern,omitempty"`
+	Action    MuteFilterAction `json:"action"`
+	CreatedAt time.Time        `json:"created_at"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
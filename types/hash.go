@@ -0,0 +1,138 @@
+package types
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// TwtHashVersion identifies the algorithm used to derive a twt's hash
+type TwtHashVersion int
+
+const (
+	// TwtHashV1 is the original twt hash, a blake2b-256 sum of
+	// `uri\nRFC3339\ntext` base32 encoded and truncated to 7 characters.
+	TwtHashV1 TwtHashVersion = 1
+
+	// TwtHashV2 is a blake2b-256 sum of `uri\nRFC3339\ntext` with the
+	// timestamp normalized to UTC, base32 encoded and truncated to 12
+	// characters.
+	TwtHashV2 TwtHashVersion = 2
+
+	// DefaultTwtHashVersion is the hash version used by feeds that do not
+	// declare one with the `hash_version` metadata field.
+	DefaultTwtHashVersion = TwtHashV1
+)
+
+var ErrUnknownTwtHashVersion = errors.New("error: unknown twt hash version")
+
+// TwtHasher derives a twt's hash from the twt's hashing uri, timestamp and
+// literal text.
+type TwtHasher interface {
+	Version() TwtHashVersion
+	Hash(uri string, created time.Time, text string) string
+}
+
+var (
+	twtHashersMu sync.RWMutex
+	twtHashers   = make(map[TwtHashVersion]TwtHasher)
+)
+
+// RegisterTwtHasher registers a TwtHasher for its version replacing any
+// previously registered TwtHasher for the same version.
+func RegisterTwtHasher(hasher TwtHasher) {
+	twtHashersMu.Lock()
+	defer twtHashersMu.Unlock()
+
+	twtHashers[hasher.Version()] = hasher
+}
+
+// GetTwtHasher returns the TwtHasher for the given version falling back to
+// the TwtHasher for DefaultTwtHashVersion for unknown versions.
+func GetTwtHasher(version TwtHashVersion) TwtHasher {
+	twtHashersMu.RLock()
+	defer twtHashersMu.RUnlock()
+
+	if hasher, ok := twtHashers[version]; ok {
+		return hasher
+	}
+	return twtHashers[DefaultTwtHashVersion]
+}
+
+// TwtHashVersions returns all registered hash versions in ascending order
+func TwtHashVersions() []TwtHashVersion {
+	twtHashersMu.RLock()
+	defer twtHashersMu.RUnlock()
+
+	var versions []TwtHashVersion
+	for version := range twtHashers {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	return versions
+}
+
+// ParseTwtHashVersion parses a hash version as declared by a feed's
+// `hash_version` metadata field (e.g: `2` or `v2`)
+func ParseTwtHashVersion(s string) (TwtHashVersion, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "v"))
+	if err != nil {
+		return 0, ErrUnknownTwtHashVersion
+	}
+
+	twtHashersMu.RLock()
+	defer twtHashersMu.RUnlock()
+
+	if _, ok := twtHashers[TwtHashVersion(n)]; !ok {
+		return 0, ErrUnknownTwtHashVersion
+	}
+
+	return TwtHashVersion(n), nil
+}
+
+// Blake2bTwtHasher hashes twts with blake2b-256, encodes the sum as
+// lowercase base32 and keeps the last Length characters.
+type Blake2bTwtHasher struct {
+	V      TwtHashVersion
+	Length int
+
+	// UTC normalizes the twt's timestamp to UTC before hashing
+	UTC bool
+}
+
+// Version ...
+func (h Blake2bTwtHasher) Version() TwtHashVersion { return h.V }
+
+// Hash ...
+func (h Blake2bTwtHasher) Hash(uri string, created time.Time, text string) string {
+	if h.UTC {
+		created = created.UTC()
+	}
+
+	payload := fmt.Sprintf(
+		"%s\n%s\n%s",
+		uri,
+		created.Format(time.RFC3339),
+		text,
+	)
+	sum := blake2b.Sum256([]byte(payload))
+
+	// Base32 is URL-safe, unlike Base64, and shorter than hex.
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	hash := strings.ToLower(encoding.EncodeToString(sum[:]))
+
+	return hash[len(hash)-h.Length:]
+}
+
+func init() {
+	RegisterTwtHasher(Blake2bTwtHasher{V: TwtHashV1, Length: TwtHashLength})
+	RegisterTwtHasher(Blake2bTwtHasher{V: TwtHashV2, Length: 12, UTC: true})
+}
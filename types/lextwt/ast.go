@@ -1,17 +1,16 @@
 package lextwt
 
 import (
-	"encoding/base32"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"net/url"
 	"strings"
 	"time"
 
 	"git.mills.io/yarnsocial/yarn/types"
-	"golang.org/x/crypto/blake2b"
 )
 
 func init() {
@@ -473,6 +472,46 @@ func (n *Subject) String() string {
 	return fmt.Sprintf("%c", n)
 }
 
+// ContentWarning flags a twt as sensitive, it is written as `[CW: text]`
+// at the start of the twt after any mentions and subject.
+type ContentWarning struct {
+	lit  string
+	text string
+}
+
+var _ Elem = (*ContentWarning)(nil)
+var _ fmt.Formatter = (*ContentWarning)(nil)
+
+func NewContentWarning(text string) *ContentWarning {
+	return &ContentWarning{lit: "[CW: " + text + "]", text: text}
+}
+func (n *ContentWarning) Clone() Elem {
+	if n == nil {
+		return nil
+	}
+	return &ContentWarning{n.lit, n.text}
+}
+func (n *ContentWarning) IsNil() bool     { return n == nil }
+func (n *ContentWarning) Literal() string { return n.lit }
+func (n *ContentWarning) Text() string    { return n.text }
+func (n *ContentWarning) Format(state fmt.State, r rune) {
+	switch r {
+	case 'l':
+		_, _ = state.Write([]byte(n.lit))
+	case 't':
+		_, _ = state.Write([]byte("CW: " + n.text))
+	case 'h':
+		_, _ = fmt.Fprintf(state, `<span class="content-warning">CW: %s</span>`, html.EscapeString(n.text))
+	default:
+		_, _ = state.Write([]byte(n.String()))
+	}
+}
+
+// String returns the content warning in its canonical form
+func (n *ContentWarning) String() string {
+	return "[CW: " + n.text + "]"
+}
+
 type Text struct {
 	lit string
 }
@@ -646,7 +685,9 @@ type Twt struct {
 	tags       []*Tag
 	links      []*Link
 	hash       string
+	hashes     []string
 	subject    *Subject
+	warning    *ContentWarning
 	twter      *types.Twter
 	pos        int
 	hasSubject bool
@@ -726,6 +767,10 @@ func (twt *Twt) append(elem Elem) {
 		}
 	}
 
+	if warning, ok := elem.(*ContentWarning); ok && twt.warning == nil {
+		twt.warning = warning
+	}
+
 	if tag, ok := elem.(*Tag); ok {
 		twt.tags = append(twt.tags, tag)
 	}
@@ -818,6 +863,7 @@ func (twt *Twt) GobDecode(data []byte) error {
 		twt.tags = t.tags
 		twt.links = t.links
 		twt.subject = t.subject
+		twt.warning = t.warning
 		twt.twter = t.twter
 	}
 
@@ -1015,25 +1061,35 @@ func (twt *Twt) Hash() string {
 		return twt.hash
 	}
 
+	twt.hash = twt.hashWith(types.GetTwtHasher(twt.Twter().HashVersion))
+
+	return twt.hash
+}
+func (twt *Twt) Hashes() []string {
+	if twt.hashes != nil {
+		return twt.hashes
+	}
+
+	hash := twt.Hash()
+
+	hashes := []string{hash}
+	for _, version := range types.TwtHashVersions() {
+		if h := twt.hashWith(types.GetTwtHasher(version)); h != hash {
+			hashes = append(hashes, h)
+		}
+	}
+
+	twt.hashes = hashes
+
+	return twt.hashes
+}
+func (twt *Twt) hashWith(hasher types.TwtHasher) string {
 	hashingURI := twt.Twter().HashingURI
 	if hashingURI == "" {
 		hashingURI = twt.Twter().URI
 	}
 
-	payload := fmt.Sprintf(
-		"%s\n%s\n%s",
-		hashingURI,
-		twt.Created().Format(time.RFC3339),
-		twt.LiteralText(),
-	)
-	sum := blake2b.Sum256([]byte(payload))
-
-	// Base32 is URL-safe, unlike Base64, and shorter than hex.
-	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
-	hash := strings.ToLower(encoding.EncodeToString(sum[:]))
-	twt.hash = hash[len(hash)-types.TwtHashLength:]
-
-	return twt.hash
+	return hasher.Hash(hashingURI, twt.Created(), twt.LiteralText())
 }
 func (twt *Twt) Subject() types.Subject {
 	if twt.subject == nil {
@@ -1041,3 +1097,67 @@ func (twt *Twt) Subject() types.Subject {
 	}
 	return twt.subject
 }
+
+const (
+	pollOptionMarker = "[ ]"
+	pollVoteMarker   = "[x]"
+)
+
+// lines returns the literal text of each line of the twt excluding its
+// subject and content warning
+func (twt *Twt) lines() []string {
+	var b strings.Builder
+	for _, elem := range twt.msg {
+		switch elem.(type) {
+		case *Subject, *ContentWarning:
+			continue
+		}
+		b.WriteString(elem.Literal())
+	}
+	return strings.Split(b.String(), LineSeparator.Literal())
+}
+
+// markedLine returns the text of a line starting with marker
+func markedLine(line, marker string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if len(line) <= len(marker) || !strings.EqualFold(line[:len(marker)], marker) {
+		return "", false
+	}
+	text := strings.TrimSpace(line[len(marker):])
+	return text, text != ""
+}
+
+// PollOptions returns the options of a poll written one per line as
+// `[ ] option`, a twt with fewer than two options is not a poll.
+func (twt *Twt) PollOptions() []string {
+	var options []string
+	for _, line := range twt.lines() {
+		if option, ok := markedLine(line, pollOptionMarker); ok {
+			options = append(options, option)
+		}
+	}
+
+	if len(options) < 2 {
+		return nil
+	}
+	return options
+}
+
+// Vote returns the poll option a reply votes for written as `[x] option`
+func (twt *Twt) Vote() string {
+	for _, line := range twt.lines() {
+		if option, ok := markedLine(line, pollVoteMarker); ok {
+			return option
+		}
+	}
+	return ""
+}
+
+// ContentWarning returns the text of the content warning the twt is flagged
+// with written as `[CW: text]`, or an empty string if it is not flagged
+func (twt *Twt) ContentWarning() string {
+	if twt.warning == nil {
+		return ""
+	}
+	return twt.warning.text
+}
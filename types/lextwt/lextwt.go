@@ -87,6 +87,12 @@ func ParseFile(r io.Reader, twter *types.Twter) (types.TwtFile, error) {
 		}
 	}
 
+	if v, ok := f.Info().GetN("hash_version", 0); ok {
+		if hv, err := types.ParseTwtHashVersion(v.Value()); err == nil {
+			f.twter.HashVersion = hv
+		}
+	}
+
 	if v, ok := f.Info().GetN("avatar", 0); ok {
 		if u, err := url.Parse(v.Value()); err == nil {
 			if u.Scheme == "" {
@@ -1095,6 +1095,145 @@ func parseTime(s string) time.Time {
 	return time.Time{}
 }
 
+func TestParseFileHashVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	feed := `# nick = example
+# url = https://example.com/twtxt.txt
+%s
+2020-11-13T16:13:22+01:00	Hello World!
+`
+
+	v1Twter := types.Twter{Nick: "example", URI: "https://example.com/twtxt.txt"}
+	v1, err := lextwt.ParseFile(strings.NewReader(fmt.Sprintf(feed, "")), &v1Twter)
+	assert.NoError(err)
+
+	v2Twter := types.Twter{Nick: "example", URI: "https://example.com/twtxt.txt"}
+	v2, err := lextwt.ParseFile(strings.NewReader(fmt.Sprintf(feed, "# hash_version = 2")), &v2Twter)
+	assert.NoError(err)
+
+	assert.Equal(types.TwtHashVersion(0), v1.Twter().HashVersion)
+	assert.Equal(types.TwtHashV2, v2.Twter().HashVersion)
+
+	v1Twt, v2Twt := v1.Twts()[0], v2.Twts()[0]
+	assert.Len(v1Twt.Hash(), types.TwtHashLength)
+	assert.Len(v2Twt.Hash(), 12)
+
+	// Both twts resolve under each other's hashes
+	assert.Equal([]string{v1Twt.Hash(), v2Twt.Hash()}, v1Twt.Hashes())
+	assert.Equal([]string{v2Twt.Hash(), v1Twt.Hash()}, v2Twt.Hashes())
+
+	// Hashes are only computed once
+	hashes := v2Twt.Hashes()
+	assert.Equal(&hashes[0], &v2Twt.Hashes()[0])
+}
+
+func TestPollOptions(t *testing.T) {
+	twter := types.Twter{Nick: "example", URI: "https://example.com/twtxt.txt"}
+
+	testCases := []struct {
+		text    string
+		options []string
+		vote    string
+	}{
+		{
+			text:    "Best colour?\u2028[ ] Red\u2028[ ] Blue",
+			options: []string{"Red", "Blue"},
+		},
+		{
+			text:    "(#abcdefg) Best colour?\u2028[ ]  Red \u2028[ ]\u2028[ ] Blue",
+			options: []string{"Red", "Blue"},
+		},
+		{
+			text: "Not a poll\u2028[ ] Red",
+		},
+		{
+			text: "Not a poll [ ] Red [ ] Blue",
+		},
+		{
+			text: "(#abcdefg) [x] Red",
+			vote: "Red",
+		},
+		{
+			text: "(#abcdefg) I pick\u2028[X] Blue",
+			vote: "Blue",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.text, func(t *testing.T) {
+			twt := types.MakeTwt(twter, time.Now(), testCase.text)
+			assert.Equal(t, testCase.options, twt.PollOptions())
+			assert.Equal(t, testCase.vote, twt.Vote())
+		})
+	}
+}
+
+func TestContentWarning(t *testing.T) {
+	twter := types.Twter{Nick: "example", URI: "https://example.com/twtxt.txt"}
+
+	testCases := []struct {
+		text    string
+		warning string
+		txt     string
+	}{
+		{
+			text:    "[CW: spoilers] The butler did it",
+			warning: "spoilers",
+			txt:     "CW: spoilers The butler did it",
+		},
+		{
+			text:    "(#abcdefg) [cw:  food ] Look at this ![cake](https://example.com/cake.png)",
+			warning: "food",
+			txt:     "(#abcdefg) CW: food Look at this ![cake](https://example.com/cake.png)",
+		},
+		{
+			text:    "@<bob https://example.com/bob.txt> [CW: politics] Hello",
+			warning: "politics",
+			txt:     "@<bob https://example.com/bob.txt> CW: politics Hello",
+		},
+		{
+			text:    "[CW: food (meat)] Steak",
+			warning: "food (meat)",
+			txt:     "CW: food (meat) Steak",
+		},
+		{
+			text: "Not a warning [CW: late]",
+		},
+		{
+			text: "[CW:] empty",
+		},
+		{
+			text: "[CW: unclosed",
+		},
+		{
+			text: "[CW](https://example.com) is a link",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.text, func(t *testing.T) {
+			twt := types.MakeTwt(twter, time.Now(), testCase.text)
+			assert.Equal(t, testCase.warning, twt.ContentWarning())
+			assert.Equal(t, testCase.text, twt.(*lextwt.Twt).LiteralText())
+
+			if testCase.warning != "" {
+				assert.Equal(t, testCase.txt, fmt.Sprintf("%t", twt))
+			}
+		})
+	}
+
+	twt := lextwt.NewTwt(
+		twter,
+		lextwt.NewDateTime(parseTime("2021-01-24T02:19:54Z"), "2021-01-24T02:19:54Z"),
+		lextwt.NewContentWarning("spoilers"),
+		lextwt.NewText(" Hello"),
+	)
+	assert.Equal(t, "spoilers", twt.ContentWarning())
+	assert.Equal(t, "[CW: spoilers] Hello", twt.LiteralText())
+	assert.Equal(t, `<span class="content-warning">CW: spoilers</span> Hello`, fmt.Sprintf("%h", twt))
+}
+
 type testExpandLinksCase struct {
 	twt    types.Twt
 	target *types.Twter
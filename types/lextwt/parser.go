@@ -27,6 +27,7 @@ type parser struct {
 	errs []error
 
 	skipSubject bool
+	skipWarning bool
 }
 
 func NewParser(l *lexer) *parser {
@@ -163,6 +164,7 @@ func (p *parser) ParseTwt() *Twt {
 	p.next()
 
 	p.skipSubject = false
+	p.skipWarning = false
 	for elem := p.ParseElem(); elem != nil; elem = p.ParseElem() {
 		p.push()
 		twt.append(elem)
@@ -348,13 +350,18 @@ func (p *parser) ParseDateTime() *DateTime {
 //   [...](...) -> ParseLink
 //   ![...](...) -> ParseLink
 //   <...> -> ParseLink
+//   [CW: ...] -> parseContentWarning
 // If the parse fails for Tag or Mention it will fallback to Text
 func (p *parser) ParseElem() Elem {
 	var e Elem
 
 	switch p.curTok.Type {
 	case TokLBRACK, TokBANG, TokLT:
+		warning := p.curTokenIs(TokLBRACK) && !p.skipWarning
 		e = p.ParseLink()
+		if (e == nil || e.IsNil()) && warning {
+			e = p.parseContentWarning()
+		}
 		p.skipSubject = true // if parsing a non text or mention -> enable subject skip
 	case TokCODE:
 		e = p.ParseCode()
@@ -393,6 +400,17 @@ func (p *parser) ParseElem() Elem {
 		}
 	}
 
+	// A content warning may only follow mentions and a subject
+	switch elem := e.(type) {
+	case *Mention, *Subject:
+	case *Text:
+		if !elem.IsSpace() {
+			p.skipWarning = true
+		}
+	default:
+		p.skipWarning = true
+	}
+
 	return e
 }
 
@@ -640,6 +658,40 @@ func (p *parser) ParseSubject() *Subject {
 	return nil
 }
 
+// parseContentWarning from the literal left by a failed ParseLink.
+// Forms parsed:
+//   [CW: text]
+func (p *parser) parseContentWarning() *ContentWarning {
+	lit := p.Literal()
+	if len(lit) < len("[CW:") || !strings.EqualFold(lit[:len("[CW:")], "[CW:") {
+		return nil
+	}
+
+	// ParseLink stops short of the closing ] at parentheses in the text
+	if !strings.HasSuffix(lit, "]") {
+		if strings.Contains(lit, "]") {
+			return nil
+		}
+		for !p.curTokenIs(TokRBRACK, TokLBRACK, TokNL, TokLS, TokEOF) {
+			p.append(p.curTok.Literal...) // text
+			p.next()
+		}
+		if !p.curTokenIs(TokRBRACK) {
+			return nil
+		}
+		p.append(p.curTok.Literal...) // ]
+		p.next()
+		lit = p.Literal()
+	}
+
+	text := strings.TrimSpace(lit[len("[CW:") : len(lit)-1])
+	if text == "" || strings.ContainsAny(text, "[]\u2028") {
+		return nil
+	}
+
+	return &ContentWarning{lit: lit, text: text}
+}
+
 // ParseText from tokens.
 // Forms parsed:
 //   combination of string and space tokens.
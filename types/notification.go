@@ -0,0 +1,34 @@
+package types
+
+import (
+	"time"
+)
+
+// NotificationType is the kind of event a Notification is about
+type NotificationType string
+
+const (
+	// NotificationMention is a twt mentioning the user
+	NotificationMention NotificationType = "mention"
+
+	// NotificationReply is a reply in a conversation the user started
+	NotificationReply NotificationType = "reply"
+
+	// NotificationFollower is a new follower of the user
+	NotificationFollower NotificationType = "follower"
+
+	// NotificationFork is a fork of a conversation from one of the user's twts
+	NotificationFork NotificationType = "fork"
+)
+
+// Notification is an event a user is notified of, Nick and URI identify the
+// twter (or follower) that caused it and Hash the twt it is about if any.
+type Notification struct {
+	ID        string           `json:"id"`
+	Type      NotificationType `json:"type"`
+	Nick      string           `json:"nick"`
+	URI       string           `json:"uri"`
+	Hash      string           `json:"hash,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	Read      bool             `json:"read"`
+}
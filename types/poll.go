@@ -0,0 +1,80 @@
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+// PollOption is one of a poll's options and the number of votes it received
+type PollOption struct {
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+// Poll is the tally of the votes cast on a poll twt
+type Poll struct {
+	Hash    string       `json:"hash"`
+	Options []PollOption `json:"options"`
+	Votes   int          `json:"votes"`
+
+	voters map[string]string
+}
+
+// Percent returns the percentage of votes the given option received
+func (p *Poll) Percent(option PollOption) int {
+	if p.Votes == 0 {
+		return 0
+	}
+	return option.Votes * 100 / p.Votes
+}
+
+// VoteOf returns the option the twter identified by uri voted for
+func (p *Poll) VoteOf(uri string) string {
+	return p.voters[uri]
+}
+
+// TallyPoll tallies the votes cast by replies to a poll twt counting only the
+// latest vote of each twter. Returns nil if the twt is not a poll.
+func TallyPoll(twt Twt, replies Twts) *Poll {
+	options := twt.PollOptions()
+	if options == nil {
+		return nil
+	}
+
+	poll := &Poll{
+		Hash:    twt.Hash(),
+		Options: make([]PollOption, len(options)),
+		voters:  make(map[string]string),
+	}
+	for i, option := range options {
+		poll.Options[i].Text = option
+	}
+
+	sorted := make(Twts, len(replies))
+	copy(sorted, replies)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Created().Before(sorted[j].Created())
+	})
+
+	votes := make(map[string]int)
+	for _, reply := range sorted {
+		vote := reply.Vote()
+		if vote == "" {
+			continue
+		}
+		for i, option := range options {
+			if strings.EqualFold(vote, option) {
+				votes[reply.Twter().URI] = i
+				poll.voters[reply.Twter().URI] = option
+				break
+			}
+		}
+	}
+
+	for _, i := range votes {
+		poll.Options[i].Votes++
+		poll.Votes++
+	}
+
+	return poll
+}
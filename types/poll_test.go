@@ -0,0 +1,36 @@
+package types_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"git.mills.io/yarnsocial/yarn/types"
+)
+
+func TestTallyPoll(t *testing.T) {
+	alice := types.Twter{Nick: "alice", URI: "https://example.com/alice.txt"}
+	bob := types.Twter{Nick: "bob", URI: "https://example.com/bob.txt"}
+
+	ts := time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+	poll := types.MakeTwt(alice, ts, "Best colour?\u2028[ ] Red\u2028[ ] Blue")
+	subject := "(#" + poll.Hash() + ") "
+
+	replies := types.Twts{
+		types.MakeTwt(bob, ts.Add(2*time.Minute), subject+"[x] blue"),
+		types.MakeTwt(bob, ts.Add(time.Minute), subject+"[x] Red"),
+		types.MakeTwt(alice, ts.Add(time.Minute), subject+"[x] Red"),
+		types.MakeTwt(alice, ts.Add(time.Minute), subject+"[x] Green"),
+		types.MakeTwt(alice, ts.Add(time.Minute), subject+"Nice poll!"),
+	}
+
+	result := types.TallyPoll(poll, replies)
+	assert.Equal(t, poll.Hash(), result.Hash)
+	assert.Equal(t, []types.PollOption{{Text: "Red", Votes: 1}, {Text: "Blue", Votes: 1}}, result.Options)
+	assert.Equal(t, 2, result.Votes)
+	assert.Equal(t, 50, result.Percent(result.Options[0]))
+	assert.Equal(t, "Blue", result.VoteOf(bob.URI))
+
+	assert.Nil(t, types.TallyPoll(types.MakeTwt(alice, ts, "Not a poll"), replies))
+}
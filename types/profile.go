@@ -2,6 +2,8 @@ package types
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -83,6 +85,10 @@ type OldProfile struct {
 
 	Links Links
 
+	// Metadata holds additional KV pairs (properties) of the user/feed
+	// See: [Metadata Extension](https://dev.twtxt.net/doc/metadataextension.html)
+	Metadata url.Values
+
 	// Used by the Mobile App for "Post as..."
 	Feeds []string
 
@@ -129,6 +135,10 @@ type Profile struct {
 
 	Links Links
 
+	// Metadata holds additional KV pairs (properties) of the user/feed
+	// See: [Metadata Extension](https://dev.twtxt.net/doc/metadataextension.html)
+	Metadata url.Values
+
 	// Used by the Mobile App for "Post as..."
 	Feeds []string
 
@@ -185,8 +195,9 @@ func (p Profile) AsOldProfile() OldProfile {
 		Avatar:   p.Avatar,
 		Tagline:  p.Description,
 
-		Links: p.Links,
-		Feeds: p.Feeds,
+		Links:    p.Links,
+		Metadata: p.Metadata,
+		Feeds:    p.Feeds,
 
 		Muted:      p.Muted,
 		Follows:    p.Follows,
@@ -215,3 +226,34 @@ type Link struct {
 }
 
 type Links []Link
+
+// NewLinksFromMetadata returns the `link` fields of a feed's metadata as Links
+// Each `link` value consists of the link text followed by whitespace and the
+// actual URL, the link text can itself contain whitespace.
+// See: [Metadata Extension](https://dev.twtxt.net/doc/metadataextension.html)
+func NewLinksFromMetadata(metadata url.Values) Links {
+	var links Links
+
+	for _, value := range metadata["link"] {
+		value = strings.TrimSpace(value)
+
+		i := strings.LastIndexAny(value, " \t")
+		if i == -1 {
+			continue
+		}
+
+		title := strings.TrimSpace(value[:i])
+		if title == "" {
+			continue
+		}
+
+		u, err := url.Parse(value[i+1:])
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			continue
+		}
+
+		links = append(links, Link{Title: title, URL: u.String()})
+	}
+
+	return links
+}
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"time"
+)
+
+// SessionType is the kind of a Session
+type SessionType string
+
+const (
+	// SessionWeb is a user logged in via the web interface
+	SessionWeb SessionType = "session"
+
+	// SessionToken is an API token issued to a client
+	SessionToken SessionType = "token"
+)
+
+// Session is an active web session or API token of a user, RemoteAddr is the
+// approximate address (network) of the client it was last used from and
+// ClientID identifies the third-party client an IndieAuth token was issued to.
+type Session struct {
+	ID         string      `json:"id"`
+	Type       SessionType `json:"type"`
+	Current    bool        `json:"current"`
+	UserAgent  string      `json:"user_agent"`
+	RemoteAddr string      `json:"remote_addr"`
+	ClientID   string      `json:"client_id,omitempty"`
+	Scopes     []string    `json:"scopes,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	LastSeenAt time.Time   `json:"last_seen_at"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+}
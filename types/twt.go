@@ -47,6 +47,10 @@ type Twter struct {
 
 	Follow map[string]Twter
 
+	// HashVersion is the hash version the feed declares its twts are hashed
+	// with (See: TwtHashVersion) or zero for DefaultTwtHashVersion
+	HashVersion TwtHashVersion
+
 	// Metadata holds additinoal KV pairs (properties) of the feed
 	// See: [Metadata Extension](https://dev.twtxt.net/doc/metadataextension.html)
 	Metadata url.Values
@@ -56,32 +60,58 @@ func (twter Twter) IsZero() bool {
 	return twter.Nick == "" && twter.URI == ""
 }
 
+type jsonTwter struct {
+	Nick       string `json:"nick"`
+	URI        string `json:"uri"`
+	HashingURI string `json:"hashing_uri"`
+	// URL Deprecated and maintained for backwards compatibility with APIv1
+	// Remove in APIv2
+	URL         string           `json:"url"`
+	Avatar      string           `json:"avatar"`
+	Tagline     string           `json:"tagline"`
+	Following   int              `json:"following"`
+	Followers   int              `json:"followers"`
+	Follow      map[string]Twter `json:"follow"`
+	HashVersion TwtHashVersion   `json:"hash_version,omitempty"`
+}
+
 func (twter Twter) MarshalJSON() ([]byte, error) {
-	return json.Marshal(struct {
-		Nick       string `json:"nick"`
-		URI        string `json:"uri"`
-		HashingURI string `json:"hashing_uri"`
-		// URL Deprecated and maintained for backwards compatibility with APIv1
-		// Remove in APIv2
-		URL       string           `json:"url"`
-		Avatar    string           `json:"avatar"`
-		Tagline   string           `json:"tagline"`
-		Following int              `json:"following"`
-		Followers int              `json:"followers"`
-		Follow    map[string]Twter `json:"follow"`
-	}{
-		Nick:       twter.Nick,
-		URI:        twter.URI,
-		HashingURI: twter.HashingURI,
-		URL:        twter.URI,
-		Avatar:     twter.Avatar,
-		Tagline:    twter.Tagline,
-		Following:  twter.Following,
-		Followers:  twter.Followers,
-		Follow:     twter.Follow,
+	return json.Marshal(jsonTwter{
+		Nick:        twter.Nick,
+		URI:         twter.URI,
+		HashingURI:  twter.HashingURI,
+		URL:         twter.URI,
+		Avatar:      twter.Avatar,
+		Tagline:     twter.Tagline,
+		Following:   twter.Following,
+		Followers:   twter.Followers,
+		Follow:      twter.Follow,
+		HashVersion: twter.HashVersion,
 	})
 }
 
+func (twter *Twter) UnmarshalJSON(data []byte) error {
+	var enc jsonTwter
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+
+	*twter = Twter{
+		Nick:        enc.Nick,
+		URI:         enc.URI,
+		HashingURI:  enc.HashingURI,
+		URL:         enc.URL,
+		Avatar:      enc.Avatar,
+		Tagline:     enc.Tagline,
+		Following:   enc.Following,
+		Followers:   enc.Followers,
+		Follow:      enc.Follow,
+		HashVersion: enc.HashVersion,
+	}
+
+	return nil
+}
+
 func (twter Twter) String() string { return fmt.Sprintf("%v\t%v", twter.Nick, twter.URI) }
 
 func (twter Twter) Domain() string {
@@ -114,11 +144,23 @@ type Twt interface {
 	Created() time.Time
 
 	Hash() string
+	// Hashes returns the twt's hash under every known hash version
+	// starting with its primary hash as returned by Hash()
+	Hashes() []string
 	Subject() Subject
 	Mentions() MentionList
 	Links() LinkList
 	Tags() TagList
 
+	// PollOptions returns the options of a poll twt or nil if the twt is
+	// not a poll
+	PollOptions() []string
+	// Vote returns the poll option a reply to a poll votes for
+	Vote() string
+	// ContentWarning returns the content warning the twt is flagged with or
+	// an empty string if it is not
+	ContentWarning() string
+
 	ExpandMentions(FmtOpts, FeedLookup)
 
 	fmt.Formatter
@@ -357,11 +399,15 @@ func (*nilTwt) Twter() Twter       { return Twter{} }
 func (*nilTwt) Created() time.Time { return time.Now() }
 func (*nilTwt) Text() string       { return "" }
 
-func (*nilTwt) Hash() string          { return "" }
-func (*nilTwt) Subject() Subject      { return nil }
-func (*nilTwt) Mentions() MentionList { return nil }
-func (*nilTwt) Tags() TagList         { return nil }
-func (*nilTwt) Links() LinkList       { return nil }
+func (*nilTwt) Hash() string           { return "" }
+func (*nilTwt) Hashes() []string       { return nil }
+func (*nilTwt) Subject() Subject       { return nil }
+func (*nilTwt) Mentions() MentionList  { return nil }
+func (*nilTwt) Tags() TagList          { return nil }
+func (*nilTwt) Links() LinkList        { return nil }
+func (*nilTwt) PollOptions() []string  { return nil }
+func (*nilTwt) Vote() string           { return "" }
+func (*nilTwt) ContentWarning() string { return "" }
 
 func (*nilTwt) ExpandMentions(FmtOpts, FeedLookup)       {}
 func (*nilTwt) Format(state fmt.State, c rune)           {}